@@ -23,10 +23,26 @@ import (
 type DataSource struct {
 	// Path to the actual secret
 	Path string `json:"path"`
-	// Key where the actual secret is stored
-	Key string `json:"key"`
+	// Key where the actual secret is stored. Ignored when a template is set
+	Key string `json:"key,omitempty"`
 	// Encoding type for the secret. Only base64 supported. Optional
 	Encoding string `json:"encoding,omitempty"`
+	// Template is a Go text/template rendered with all the keys stored in the path, e.g.
+	// postgres://{{.username}}:{{.password}}@{{.host}}. Optional
+	Template string `json:"template,omitempty"`
+	// Transform applied to the value once decoded: base64-encode or base64-decode. Optional
+	Transform string `json:"transform,omitempty"`
+	// Optional keys missing in the backend are left out of the secret instead of failing the sync. Optional
+	Optional bool `json:"optional,omitempty"`
+	// WrapTTL reads the path response-wrapped for the given TTL, e.g. 5m, storing the wrapping token instead of
+	// the secret. Key and template are ignored. Optional
+	WrapTTL string `json:"wrapTTL,omitempty"`
+	// AllKeys stores every key of the path in the secret, named as in the backend unless renamed by Remap,
+	// instead of a single key named as the keysMap entry. Key and template are ignored. Optional
+	AllKeys bool `json:"allKeys,omitempty"`
+	// Remap renames the keys read with allKeys, from their name in the backend to their secret key, e.g.
+	// db_password: POSTGRES_PASSWORD. Optional
+	Remap map[string]string `json:"remap,omitempty"`
 }
 
 // SecretDefinitionSpec defines the desired state of SecretDefinition
@@ -36,6 +52,12 @@ type SecretDefinitionSpec struct {
 	Name    string                `json:"name"`
 	Type    string                `json:"type,omitempty"`
 	KeysMap map[string]DataSource `json:"keysMap"`
+	// VaultRole is a Vault token role the keys are read with, using a child token created for this
+	// SecretDefinition instead of the controller token. Optional
+	VaultRole string `json:"vaultRole,omitempty"`
+	// VaultNamespace is the Vault Enterprise namespace the keys are read from, e.g. org/team-a, instead of
+	// the one the controller is configured with. Optional
+	VaultNamespace string `json:"vaultNamespace,omitempty"`
 }
 
 // SecretDefinitionStatus defines the observed state of SecretDefinition
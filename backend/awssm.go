@@ -0,0 +1,108 @@
+package backend
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/secretsmanager"
+	"github.com/aws/aws-sdk-go/service/secretsmanager/secretsmanageriface"
+	"github.com/go-logr/logr"
+	"github.com/tuenti/secrets-manager/errors"
+)
+
+const (
+	awsSecretsManagerBackendName = "aws-secrets-manager"
+)
+
+type awsSecretsManagerClient struct {
+	client  secretsmanageriface.SecretsManagerAPI
+	metrics *backendMetrics
+	logger  logr.Logger
+}
+
+// awsSession returns an AWS session resolving the credentials with the AWS SDK default chain: environment,
+// web identity (EKS IAM roles for service accounts), shared credentials and config files, ECS container
+// credentials and EC2 instance metadata
+func awsSession(config *aws.Config) (*session.Session, error) {
+	return session.NewSessionWithOptions(session.Options{
+		Config:            *config,
+		SharedConfigState: session.SharedConfigEnable,
+	})
+}
+
+func awsSecretsManagerBackend(l logr.Logger, cfg Config) (*awsSecretsManagerClient, error) {
+	config := aws.NewConfig().WithHTTPClient(&http.Client{Timeout: cfg.BackendTimeout})
+	if cfg.AWSRegion != "" {
+		config = config.WithRegion(cfg.AWSRegion)
+	}
+	if cfg.AWSSecretsManagerEndpoint != "" {
+		config = config.WithEndpoint(cfg.AWSSecretsManagerEndpoint)
+	}
+	sess, err := awsSession(config)
+	if err != nil {
+		return nil, &errors.BackendConfigError{ErrType: errors.BackendConfigErrorType, Backend: awsSecretsManagerBackendName, Reason: err.Error()}
+	}
+	region := aws.StringValue(sess.Config.Region)
+	if region == "" {
+		return nil, &errors.BackendConfigError{ErrType: errors.BackendConfigErrorType, Backend: awsSecretsManagerBackendName, Reason: "AWS region not set"}
+	}
+
+	return &awsSecretsManagerClient{
+		client:  secretsmanager.New(sess),
+		metrics: newBackendMetrics(awsSecretsManagerBackendName, cfg),
+		logger:  l.WithName("aws-secrets-manager").WithValues("aws_region", region),
+	}, nil
+}
+
+// ReadSecret reads the secret with the given name or ARN. If key is not empty the secret value
+// must be a JSON object and the given key is returned, otherwise the whole value is returned
+func (c *awsSecretsManagerClient) ReadSecret(path string, key string) (string, error) {
+	value, err := c.getSecretValue(path)
+	if err != nil {
+		c.metrics.updateSecretReadErrorsTotalMetric(path, key, errors.GetErrorType(err))
+		return "", err
+	}
+	data, err := secretJSONKey(path, key, value)
+	if err != nil {
+		c.metrics.updateSecretReadErrorsTotalMetric(path, key, errors.GetErrorType(err))
+	}
+	return data, err
+}
+
+func (c *awsSecretsManagerClient) getSecretValue(secretID string) ([]byte, error) {
+	secret, err := c.client.GetSecretValue(&secretsmanager.GetSecretValueInput{SecretId: aws.String(secretID)})
+	if err != nil {
+		if awsErr, ok := err.(awserr.Error); ok && awsErr.Code() == secretsmanager.ErrCodeResourceNotFoundException {
+			return nil, &errors.BackendSecretNotFoundError{ErrType: errors.BackendSecretNotFoundErrorType, Path: secretID}
+		}
+		return nil, err
+	}
+	if secret.SecretString == nil && secret.SecretBinary != nil {
+		return secret.SecretBinary, nil
+	}
+	return []byte(aws.StringValue(secret.SecretString)), nil
+}
+
+// secretJSONKey returns the whole value when key is empty, or the given key of the value decoded as a JSON object
+func secretJSONKey(path string, key string, value []byte) (string, error) {
+	if key == "" {
+		return string(value), nil
+	}
+	var data map[string]interface{}
+	if err := json.Unmarshal(value, &data); err != nil {
+		return "", &errors.BackendSecretTypeError{ErrType: errors.BackendSecretTypeErrorType, Path: path, Key: key, Type: "non-JSON object"}
+	}
+	v, ok := data[key]
+	if !ok {
+		return "", &errors.BackendSecretNotFoundError{ErrType: errors.BackendSecretNotFoundErrorType, Path: path, Key: key}
+	}
+	s, ok := v.(string)
+	if !ok {
+		return "", &errors.BackendSecretTypeError{ErrType: errors.BackendSecretTypeErrorType, Path: path, Key: key, Type: fmt.Sprintf("%T", v)}
+	}
+	return s, nil
+}
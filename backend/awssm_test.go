@@ -0,0 +1,153 @@
+package backend
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/secretsmanager"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/tuenti/secrets-manager/errors"
+)
+
+// awsTestEnv sets the credentials the AWS Secrets Manager test server expects
+var awsTestEnv = map[string]string{"AWS_ACCESS_KEY_ID": "foo", "AWS_SECRET_ACCESS_KEY": "bar"}
+
+// setAWSEnv sets the given environment variables, clearing every other AWS credentials variable,
+// and returns a function restoring the previous environment
+func setAWSEnv(env map[string]string) func() {
+	names := []string{"AWS_ACCESS_KEY_ID", "AWS_SECRET_ACCESS_KEY", "AWS_SESSION_TOKEN", "AWS_ROLE_ARN", "AWS_WEB_IDENTITY_TOKEN_FILE",
+		"AWS_SHARED_CREDENTIALS_FILE", "AWS_CONFIG_FILE", "AWS_PROFILE", "AWS_CONTAINER_CREDENTIALS_RELATIVE_URI",
+		"AWS_CONTAINER_CREDENTIALS_FULL_URI", "AWS_CONTAINER_AUTHORIZATION_TOKEN", "AWS_REGION", "AWS_DEFAULT_REGION"}
+	previous := make(map[string]string)
+	for _, name := range names {
+		if v, ok := os.LookupEnv(name); ok {
+			previous[name] = v
+		}
+		os.Unsetenv(name)
+	}
+	for k, v := range env {
+		os.Setenv(k, v)
+	}
+	return func() {
+		for _, name := range names {
+			os.Unsetenv(name)
+		}
+		for k, v := range previous {
+			os.Setenv(k, v)
+		}
+	}
+}
+
+func newAWSSecretsManagerTestServer(t *testing.T) *httptest.Server {
+	secrets := map[string]string{
+		"app/db":     `{"username": "foo", "password": "bar", "port": 5432}`,
+		"app/plain":  "plain value",
+		"app/binary": "",
+	}
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Amz-Target") != "secretsmanager.GetSecretValue" || !strings.HasPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256 Credential=foo/") {
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprint(w, `{"__type": "InvalidRequestException", "message": "bad request"}`)
+			return
+		}
+		var req map[string]string
+		json.NewDecoder(r.Body).Decode(&req)
+		w.Header().Set("Content-Type", "application/x-amz-json-1.1")
+		secret, ok := secrets[req["SecretId"]]
+		if !ok {
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprint(w, `{"__type": "ResourceNotFoundException", "message": "Secrets Manager can't find the specified secret."}`)
+			return
+		}
+		if req["SecretId"] == "app/binary" {
+			fmt.Fprint(w, `{"Name": "app/binary", "SecretBinary": "aGVsbG8gd29ybGQ="}`)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]string{"Name": req["SecretId"], "SecretString": secret})
+	}))
+}
+
+func newAWSSecretsManagerTestClient(t *testing.T, endpoint string) *awsSecretsManagerClient {
+	client, err := awsSecretsManagerBackend(logger, Config{AWSRegion: "eu-west-1", AWSSecretsManagerEndpoint: endpoint})
+	assert.Nil(t, err)
+	return client
+}
+
+func TestAWSSecretsManagerReadSecret(t *testing.T) {
+	defer setAWSEnv(awsTestEnv)()
+	server := newAWSSecretsManagerTestServer(t)
+	defer server.Close()
+	client := newAWSSecretsManagerTestClient(t, server.URL)
+
+	value, err := client.ReadSecret("app/db", "password")
+	assert.Nil(t, err)
+	assert.Equal(t, "bar", value)
+
+	value, err = client.ReadSecret("app/plain", "")
+	assert.Nil(t, err)
+	assert.Equal(t, "plain value", value)
+
+	value, err = client.ReadSecret("app/binary", "")
+	assert.Nil(t, err)
+	assert.Equal(t, "hello world", value)
+}
+
+func TestAWSSecretsManagerSecretNotFound(t *testing.T) {
+	defer setAWSEnv(awsTestEnv)()
+	server := newAWSSecretsManagerTestServer(t)
+	defer server.Close()
+	client := newAWSSecretsManagerTestClient(t, server.URL)
+
+	client.metrics.secretReadErrorsTotal.Reset()
+	_, err := client.ReadSecret("app/missing", "password")
+	assert.True(t, errors.IsBackendSecretNotFound(err))
+	metric, _ := client.metrics.secretReadErrorsTotal.GetMetricWithLabelValues("", "", "", "", "", "", "app/missing", "password", "", errors.BackendSecretNotFoundErrorType, awsSecretsManagerBackendName)
+	assert.Equal(t, 1.0, testutil.ToFloat64(metric))
+
+	_, err = client.ReadSecret("app/db", "missing")
+	assert.True(t, errors.IsBackendSecretNotFound(err))
+}
+
+func TestAWSSecretsManagerSecretType(t *testing.T) {
+	defer setAWSEnv(awsTestEnv)()
+	server := newAWSSecretsManagerTestServer(t)
+	defer server.Close()
+	client := newAWSSecretsManagerTestClient(t, server.URL)
+
+	_, err := client.ReadSecret("app/db", "port")
+	assert.True(t, errors.IsBackendSecretType(err))
+	_, err = client.ReadSecret("app/plain", "foo")
+	assert.True(t, errors.IsBackendSecretType(err))
+}
+
+func TestAWSSecretsManagerMissingRegion(t *testing.T) {
+	defer setAWSEnv(nil)()
+	_, err := awsSecretsManagerBackend(logger, Config{})
+	assert.True(t, errors.IsBackendConfig(err))
+}
+
+func TestAWSSecretsManagerRegionFromEnv(t *testing.T) {
+	defer setAWSEnv(map[string]string{"AWS_REGION": "eu-central-1"})()
+	client, err := awsSecretsManagerBackend(logger, Config{})
+	assert.Nil(t, err)
+	assert.Equal(t, "eu-central-1", aws.StringValue(client.client.(*secretsmanager.SecretsManager).Config.Region))
+}
+
+func TestAWSSecretsManagerRequestError(t *testing.T) {
+	defer setAWSEnv(map[string]string{"AWS_ACCESS_KEY_ID": "other", "AWS_SECRET_ACCESS_KEY": "bar"})()
+	server := newAWSSecretsManagerTestServer(t)
+	defer server.Close()
+	client := newAWSSecretsManagerTestClient(t, server.URL)
+
+	_, err := client.ReadSecret("app/db", "password")
+	assert.NotNil(t, err)
+	assert.False(t, errors.IsBackendSecretNotFound(err))
+	assert.Contains(t, err.Error(), "InvalidRequestException")
+}
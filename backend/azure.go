@@ -0,0 +1,46 @@
+package backend
+
+import (
+	"context"
+	"net/http"
+	"strings"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
+	"github.com/Azure/azure-sdk-for-go/sdk/azidentity"
+)
+
+const (
+	azureIMDSEndpoint = "http://169.254.169.254"
+)
+
+// azureCredentialsProvider gets Azure AD access tokens of a resource with the Azure SDK default credential chain:
+// environment (client secret, certificate or username and password), workload identity, managed identity and
+// Azure CLI. The SDK caches the tokens until they are about to expire
+type azureCredentialsProvider struct {
+	credential   azcore.TokenCredential
+	scope        string
+	imdsEndpoint string
+}
+
+func newAzureCredentialsProvider(httpClient *http.Client, resource string) (*azureCredentialsProvider, error) {
+	credential, err := azidentity.NewDefaultAzureCredential(&azidentity.DefaultAzureCredentialOptions{
+		ClientOptions: azcore.ClientOptions{Transport: httpClient},
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &azureCredentialsProvider{
+		credential:   credential,
+		scope:        strings.TrimSuffix(resource, "/") + "/.default",
+		imdsEndpoint: azureIMDSEndpoint,
+	}, nil
+}
+
+func (p *azureCredentialsProvider) accessToken() (string, error) {
+	token, err := p.credential.GetToken(context.Background(), policy.TokenRequestOptions{Scopes: []string{p.scope}})
+	if err != nil {
+		return "", err
+	}
+	return token.Token, nil
+}
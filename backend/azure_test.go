@@ -0,0 +1,54 @@
+package backend
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeAzureCredential returns token when the scope requested is the expected one
+type fakeAzureCredential struct {
+	scope string
+	token string
+}
+
+func (c fakeAzureCredential) GetToken(ctx context.Context, options policy.TokenRequestOptions) (azcore.AccessToken, error) {
+	if len(options.Scopes) != 1 || options.Scopes[0] != c.scope {
+		return azcore.AccessToken{}, fmt.Errorf("unexpected scopes %v", options.Scopes)
+	}
+	return azcore.AccessToken{Token: c.token, ExpiresOn: time.Now().Add(time.Hour)}, nil
+}
+
+func newFakeAzureCredentialsProvider(resource string, token string, imdsEndpoint string) *azureCredentialsProvider {
+	scope := resource + "/.default"
+	return &azureCredentialsProvider{
+		credential:   fakeAzureCredential{scope: scope, token: token},
+		scope:        scope,
+		imdsEndpoint: imdsEndpoint,
+	}
+}
+
+func TestAzureCredentialsScope(t *testing.T) {
+	provider, err := newAzureCredentialsProvider(http.DefaultClient, azureKeyVaultResource+"/")
+	assert.Nil(t, err)
+	assert.Equal(t, "https://vault.azure.net/.default", provider.scope)
+	assert.Equal(t, azureIMDSEndpoint, provider.imdsEndpoint)
+
+	provider.credential = fakeAzureCredential{scope: "https://vault.azure.net/.default", token: "kv-token"}
+	token, err := provider.accessToken()
+	assert.Nil(t, err)
+	assert.Equal(t, "kv-token", token)
+}
+
+func TestAzureCredentialsError(t *testing.T) {
+	provider := newFakeAzureCredentialsProvider(defaultAzureResource, "token", azureIMDSEndpoint)
+	provider.scope = azureKeyVaultResource + "/.default"
+	_, err := provider.accessToken()
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,126 @@
+package backend
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/go-logr/logr"
+	"github.com/tuenti/secrets-manager/errors"
+)
+
+const (
+	azureKeyVaultBackendName = "azure-key-vault"
+	azureKeyVaultResource    = "https://vault.azure.net"
+	azureKeyVaultAPIVersion  = "7.4"
+)
+
+type azureKeyVaultClient struct {
+	httpClient  *http.Client
+	credentials *azureCredentialsProvider
+	metrics     *backendMetrics
+	logger      logr.Logger
+}
+
+type azureSecretBundle struct {
+	Value string `json:"value"`
+}
+
+type azureErrorResponse struct {
+	Error struct {
+		Code    string `json:"code"`
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+func azureKeyVaultBackend(l logr.Logger, cfg Config) (*azureKeyVaultClient, error) {
+	httpClient := &http.Client{Timeout: cfg.BackendTimeout}
+	credentials, err := newAzureCredentialsProvider(httpClient, azureKeyVaultResource)
+	if err != nil {
+		return nil, &errors.BackendConfigError{ErrType: errors.BackendConfigErrorType, Backend: azureKeyVaultBackendName, Reason: err.Error()}
+	}
+	return &azureKeyVaultClient{
+		httpClient:  httpClient,
+		credentials: credentials,
+		metrics:     newBackendMetrics(azureKeyVaultBackendName, cfg),
+		logger:      l.WithName("azure-key-vault"),
+	}, nil
+}
+
+// ReadSecret reads a secret given its identifier, https://<vault>.vault.azure.net/secrets/<name>, optionally followed
+// by /<version>. The latest version is read by default. If key is not empty the secret value must be a JSON object
+// and the given key is returned, otherwise the whole value is returned
+func (c *azureKeyVaultClient) ReadSecret(path string, key string) (string, error) {
+	value, err := c.getSecret(path)
+	if err != nil {
+		c.metrics.updateSecretReadErrorsTotalMetric(path, key, errors.GetErrorType(err))
+		return "", err
+	}
+	data, err := secretJSONKey(path, key, []byte(value))
+	if err != nil {
+		c.metrics.updateSecretReadErrorsTotalMetric(path, key, errors.GetErrorType(err))
+	}
+	return data, err
+}
+
+// secretURL validates the secret identifier and returns the URL used to get it
+func secretURL(path string) (string, error) {
+	u, err := url.Parse(path)
+	if err != nil || u.Scheme != "https" || u.Host == "" {
+		return "", fmt.Errorf("invalid azure key vault secret identifier %s, expected https://<vault>.vault.azure.net/secrets/<name>", path)
+	}
+	segments := strings.Split(strings.Trim(u.Path, "/"), "/")
+	if len(segments) < 2 || len(segments) > 3 || segments[0] != "secrets" || segments[1] == "" {
+		return "", fmt.Errorf("invalid azure key vault secret identifier %s, expected https://<vault>.vault.azure.net/secrets/<name>", path)
+	}
+	return fmt.Sprintf("%s://%s/%s?api-version=%s", u.Scheme, u.Host, strings.Join(segments, "/"), azureKeyVaultAPIVersion), nil
+}
+
+func (c *azureKeyVaultClient) getSecret(path string) (string, error) {
+	uri, err := secretURL(path)
+	if err != nil {
+		return "", err
+	}
+	token, err := c.credentials.accessToken()
+	if err != nil {
+		return "", err
+	}
+	req, err := http.NewRequest(http.MethodGet, uri, nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return "", err
+	}
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotFound:
+		return "", &errors.BackendSecretNotFoundError{ErrType: errors.BackendSecretNotFoundErrorType, Path: path}
+	case http.StatusForbidden:
+		return "", &errors.BackendForbiddenError{ErrType: errors.BackendForbiddenErrorType, Backend: azureKeyVaultBackendName, Path: path}
+	default:
+		var azureErr azureErrorResponse
+		if err := json.Unmarshal(body, &azureErr); err != nil || azureErr.Error.Code == "" {
+			return "", fmt.Errorf("azure key vault returned %s: %s", resp.Status, strings.TrimSpace(string(body)))
+		}
+		return "", fmt.Errorf("azure key vault returned %d %s: %s", resp.StatusCode, azureErr.Error.Code, azureErr.Error.Message)
+	}
+
+	var secret azureSecretBundle
+	if err := json.Unmarshal(body, &secret); err != nil {
+		return "", err
+	}
+	return secret.Value, nil
+}
@@ -0,0 +1,94 @@
+package backend
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/tuenti/secrets-manager/errors"
+)
+
+func newAzureKeyVaultTestServer(t *testing.T) *httptest.Server {
+	return httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if r.Header.Get("Authorization") != "Bearer kv-token" || r.URL.Query().Get("api-version") != azureKeyVaultAPIVersion {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		switch r.URL.Path {
+		case "/secrets/db":
+			fmt.Fprint(w, `{"value": "{\"username\": \"foo\", \"password\": \"bar\"}", "id": "https://vault/secrets/db/2"}`)
+		case "/secrets/db/1":
+			fmt.Fprint(w, `{"value": "{\"username\": \"foo\", \"password\": \"old\"}", "id": "https://vault/secrets/db/1"}`)
+		case "/secrets/unavailable":
+			http.Error(w, "upstream connect error", http.StatusServiceUnavailable)
+		case "/secrets/forbidden":
+			w.WriteHeader(http.StatusForbidden)
+			fmt.Fprint(w, `{"error": {"code": "Forbidden", "message": "The user does not have secrets get permission"}}`)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprint(w, `{"error": {"code": "SecretNotFound", "message": "A secret was not found in this key vault"}}`)
+		}
+	}))
+}
+
+func newAzureKeyVaultTestClient(server *httptest.Server) *azureKeyVaultClient {
+	client, _ := azureKeyVaultBackend(logger, Config{})
+	client.httpClient = server.Client()
+	client.credentials = newFakeAzureCredentialsProvider(azureKeyVaultResource, "kv-token", azureIMDSEndpoint)
+	return client
+}
+
+func TestAzureKeyVaultReadSecret(t *testing.T) {
+	server := newAzureKeyVaultTestServer(t)
+	defer server.Close()
+	client := newAzureKeyVaultTestClient(server)
+
+	value, err := client.ReadSecret(server.URL+"/secrets/db", "password")
+	assert.Nil(t, err)
+	assert.Equal(t, "bar", value)
+
+	value, err = client.ReadSecret(server.URL+"/secrets/db/1", "password")
+	assert.Nil(t, err)
+	assert.Equal(t, "old", value)
+
+	value, err = client.ReadSecret(server.URL+"/secrets/db", "")
+	assert.Nil(t, err)
+	assert.Equal(t, `{"username": "foo", "password": "bar"}`, value)
+}
+
+func TestAzureKeyVaultErrors(t *testing.T) {
+	server := newAzureKeyVaultTestServer(t)
+	defer server.Close()
+	client := newAzureKeyVaultTestClient(server)
+
+	client.metrics.secretReadErrorsTotal.Reset()
+	path := server.URL + "/secrets/missing"
+	_, err := client.ReadSecret(path, "password")
+	metric, _ := client.metrics.secretReadErrorsTotal.GetMetricWithLabelValues("", "", "", "", "", "", path, "password", "", errors.BackendSecretNotFoundErrorType, azureKeyVaultBackendName)
+	assert.True(t, errors.IsBackendSecretNotFound(err))
+	assert.Equal(t, 1.0, testutil.ToFloat64(metric))
+
+	_, err = client.ReadSecret(server.URL+"/secrets/forbidden", "password")
+	assert.True(t, errors.IsBackendForbidden(err))
+
+	_, err = client.ReadSecret("http://vault.azure.net/db", "password")
+	assert.NotNil(t, err)
+
+	_, err = client.ReadSecret(server.URL+"/secrets/unavailable", "password")
+	assert.EqualError(t, err, "azure key vault returned 503 Service Unavailable: upstream connect error")
+}
+
+func TestAzureSecretURL(t *testing.T) {
+	uri, err := secretURL("https://foo.vault.azure.net/secrets/bar/")
+	assert.Nil(t, err)
+	assert.Equal(t, "https://foo.vault.azure.net/secrets/bar?api-version="+azureKeyVaultAPIVersion, uri)
+	uri, err = secretURL("https://foo.vault.azure.net/secrets/bar/1234")
+	assert.Nil(t, err)
+	assert.Equal(t, "https://foo.vault.azure.net/secrets/bar/1234?api-version="+azureKeyVaultAPIVersion, uri)
+	_, err = secretURL("https://foo.vault.azure.net/keys/bar")
+	assert.NotNil(t, err)
+}
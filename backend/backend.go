@@ -5,6 +5,7 @@ import (
 	"time"
 
 	"github.com/go-logr/logr"
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/tuenti/secrets-manager/errors"
 )
 
@@ -13,34 +14,127 @@ const vaultBackendName = "vault"
 var supportedBackends map[string]bool
 
 func init() {
-	supportedBackends = map[string]bool{vaultBackendName: true}
+	supportedBackends = map[string]bool{vaultBackendName: true, memoryBackendName: true, awsSecretsManagerBackendName: true, gcpSecretManagerBackendName: true, azureKeyVaultBackendName: true, consulBackendName: true}
 }
 
 // Config type represent backend config, and should include all backends config
 type Config struct {
-	BackendTimeout          time.Duration
-	VaultURL                string
-	VaultAuthMethod         string
-	VaultRoleID             string
-	VaultSecretID           string
-	VaultKubernetesRole     string
-	VaultMaxTokenTTL        int64
-	VaultTokenPollingPeriod time.Duration
-	VaultRenewTTLIncrement  int
-	VaultEngine             string
-	VaultApprolePath        string
-	VaultKubernetesPath     string
+	BackendTimeout time.Duration
+	// MetricsRegisterer is the registry the metrics of the client are registered in, the controller-runtime one
+	// when nil. Clients sharing a registry must name their metrics after another namespace or subsystem
+	MetricsRegisterer           prometheus.Registerer
+	MetricsNamespace            string
+	MetricsSubsystem            string
+	LogLevel                    string
+	LogFormat                   string
+	VaultURL                    string
+	VaultFallbackURLs           []string
+	VaultFailoverInterval       time.Duration
+	VaultNamespace              string
+	VaultAuthMethod             string
+	VaultRoleID                 string
+	VaultSecretID               string
+	VaultTokenFile              string
+	VaultWrappedToken           string
+	VaultWrappedTokenPath       string
+	VaultTokenRole              string
+	VaultTokenPolicies          []string
+	VaultSecretIDFile           string
+	VaultKubernetesRole         string
+	VaultMaxTokenTTL            int64
+	VaultRenewThresholdRatio    float64
+	VaultRenewGracePeriod       time.Duration
+	VaultTokenPollingPeriod     time.Duration
+	VaultTokenPollingJitter     int
+	VaultRenewMaxBackoff        time.Duration
+	VaultRenewTTLIncrement      int
+	VaultDefaultKey             string
+	VaultEngine                 string
+	VaultMountEngines           map[string]string
+	VaultKVMount                string
+	VaultAllowedPathPrefixes    []string
+	VaultDeniedPathPrefixes     []string
+	VaultApprolePath            string
+	VaultKubernetesPath         string
+	VaultKubernetesJWTPath      string
+	VaultJWTRole                string
+	VaultJWTPath                string
+	VaultJWTMountPath           string
+	VaultUsername               string
+	VaultPassword               string
+	VaultPasswordFile           string
+	VaultUserpassPath           string
+	VaultCertRole               string
+	VaultCertMountPath          string
+	VaultAWSRole                string
+	VaultAWSMountPath           string
+	VaultGCPRole                string
+	VaultGCPMountPath           string
+	VaultAzureRole              string
+	VaultAzureMountPath         string
+	VaultAzureResource          string
+	VaultCACert                 string
+	VaultCACertPath             string
+	VaultClientCert             string
+	VaultClientKey              string
+	VaultTLSServerName          string
+	VaultTLSSkipVerify          bool
+	VaultProxyURL               string
+	VaultMaxRetries             int
+	VaultRetryBackoff           time.Duration
+	VaultRetryMaxBackoff        time.Duration
+	VaultRequestTimeout         time.Duration
+	VaultStartupRetries         int
+	VaultStartupRetryInterval   time.Duration
+	VaultBreakerThreshold       int
+	VaultBreakerWindow          time.Duration
+	VaultBreakerCoolDown        time.Duration
+	VaultMaxIdleConns           int
+	VaultMaxConnsPerHost        int
+	VaultIdleConnTimeout        time.Duration
+	VaultCacheTTL               time.Duration
+	VaultReadinessThreshold     time.Duration
+	VaultHealthPollingPeriod    time.Duration
+	VaultHealthFailureThreshold int
+	VaultHealthSuccessThreshold int
+	VaultMetricsPathLabels      bool
+	VaultMetricsPathDepth       int
+	VaultMetricsIdentity        bool
+	VaultMetricsDurationBuckets []float64
+	VaultCacheMaxSize           int
+	VaultCacheEncrypt           bool
+	VaultTreeMaxDepth           int
+	VaultTreeSeparator          string
+	VaultMaxSecretValueSize     int
+	VaultUserAgent              string
+	MemorySecrets               map[string]map[string]string
+	AWSRegion                   string
+	AWSSecretsManagerEndpoint   string
+	GCPProject                  string
+	GCPSecretManagerEndpoint    string
+	ConsulAddress               string
+	ConsulToken                 string
+	ConsulDatacenter            string
 }
 
-// Client interface represent a backend client interface that should be implemented
+// Client interface represent a backend client interface that should be implemented.
+// Every supported backend implements it, so the controller doesn't depend on any of them
 type Client interface {
 	ReadSecret(path string, key string) (string, error)
 }
 
 // NewBackendClient returns and implementation of Client interface, given the selected backend
 func NewBackendClient(ctx context.Context, backend string, logger logr.Logger, cfg Config) (*Client, error) {
+	if logger == nil {
+		logger = defaultLogger()
+	}
+	// vclient is the Vault client, its background routines are started once every other step succeeded and it's
+	// closed otherwise, so its metrics are unregistered
+	var vclient *client
 	var err error
 	var client Client
+	// collectors are the metrics of the cache and the non-Vault backends, registered once the client is built
+	var collectors []prometheus.Collector
 
 	if !supportedBackends[backend] {
 		err = &errors.BackendNotImplementedError{ErrType: errors.BackendNotImplementedErrorType, Backend: backend}
@@ -48,13 +142,65 @@ func NewBackendClient(ctx context.Context, backend string, logger logr.Logger, c
 	}
 	switch backend {
 	case vaultBackendName:
-		vclient, verr := vaultClient(logger, cfg)
+		var verr error
+		vclient, verr = vaultClient(logger, cfg)
 		if verr != nil {
 			return nil, verr
 		}
-		vclient.startTokenRenewer(ctx)
 		client = vclient
+		if cfg.VaultCacheTTL > 0 {
+			cached := newCachedClient(vclient, vaultBackendName, cfg.VaultCacheTTL, cfg.VaultCacheMaxSize)
+			cached.metrics = newCacheMetrics(cfg.MetricsNamespace)
+			if cfg.VaultCacheEncrypt {
+				if err := cached.encryptValues(); err != nil {
+					logger.Error(err, "unable to setup vault cache encryption")
+					vclient.Close(ctx)
+					return nil, err
+				}
+			}
+			client = cached
+			collectors = cached.metrics.collectors()
+		}
 		err = verr
+	case memoryBackendName:
+		client = NewMemoryClient(cfg.MemorySecrets)
+	case awsSecretsManagerBackendName:
+		awsClient, awsErr := awsSecretsManagerBackend(logger, cfg)
+		if awsErr != nil {
+			return nil, awsErr
+		}
+		client = awsClient
+		collectors = awsClient.metrics.collectors()
+	case gcpSecretManagerBackendName:
+		gcpClient, gcpErr := gcpSecretManagerBackend(ctx, logger, cfg)
+		if gcpErr != nil {
+			return nil, gcpErr
+		}
+		client = gcpClient
+		collectors = gcpClient.metrics.collectors()
+	case azureKeyVaultBackendName:
+		azureClient, azureErr := azureKeyVaultBackend(logger, cfg)
+		if azureErr != nil {
+			return nil, azureErr
+		}
+		client = azureClient
+		collectors = azureClient.metrics.collectors()
+	case consulBackendName:
+		consulClient := consulBackend(logger, cfg)
+		client = consulClient
+		collectors = consulClient.metrics.collectors()
+	}
+	if len(collectors) > 0 {
+		if err := registerCollectors(metricsRegisterer(cfg), collectors); err != nil {
+			logger.Error(err, "unable to register backend metrics")
+			if vclient != nil {
+				vclient.Close(ctx)
+			}
+			return nil, err
+		}
+	}
+	if vclient != nil {
+		vclient.startBackgroundRoutines(ctx)
 	}
 	return &client, err
 }
@@ -0,0 +1,103 @@
+package backend
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+var (
+	backendCacheLabelNames = []string{"backend"}
+)
+
+// backendMetrics are the metrics of the non-Vault backends. Their read errors are counted with the Vault read errors
+// metric, labeled by backend type, so the same dashboards work with every backend
+type backendMetrics struct {
+	backend               string
+	secretReadErrorsTotal *prometheus.CounterVec
+}
+
+// newBackendMetrics creates the metrics of backend, named after the metrics namespace and subsystem of cfg
+func newBackendMetrics(backend string, cfg Config) *backendMetrics {
+	namespace, subsystem := metricsNames(cfg)
+	return &backendMetrics{
+		backend:               backend,
+		secretReadErrorsTotal: newSecretReadErrorsTotal(namespace, subsystem),
+	}
+}
+
+func (bm *backendMetrics) collectors() []prometheus.Collector {
+	return []prometheus.Collector{bm.secretReadErrorsTotal}
+}
+
+// cacheMetrics are the metrics of the cache of a backend, labeled by backend type
+type cacheMetrics struct {
+	cacheHitsTotal   *prometheus.CounterVec
+	cacheMissesTotal *prometheus.CounterVec
+}
+
+// newCacheMetrics creates the cache metrics, named after namespace, e.g. secretsmanager for
+// secretsmanager_backend_cache_hits_total. An empty namespace keeps the default one
+func newCacheMetrics(namespace string) *cacheMetrics {
+	if namespace == "" {
+		namespace = defaultMetricsNamespace
+	}
+	return &cacheMetrics{
+		cacheHitsTotal: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "backend",
+			Name:      "cache_hits_total",
+			Help:      "Backend reads served from the cache counter",
+		}, backendCacheLabelNames),
+		cacheMissesTotal: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "backend",
+			Name:      "cache_misses_total",
+			Help:      "Backend reads not found or expired in the cache counter",
+		}, backendCacheLabelNames),
+	}
+}
+
+func (cm *cacheMetrics) collectors() []prometheus.Collector {
+	return []prometheus.Collector{cm.cacheHitsTotal, cm.cacheMissesTotal}
+}
+
+// metricsRegisterer returns the registry the metrics of the clients built with cfg are registered in, the
+// controller-runtime one unless cfg.MetricsRegisterer is set
+func metricsRegisterer(cfg Config) prometheus.Registerer {
+	if cfg.MetricsRegisterer != nil {
+		return cfg.MetricsRegisterer
+	}
+	return metrics.Registry
+}
+
+// registerCollectors registers every collector in r. When one of them can't be registered, e.g. because another
+// client already registered metrics with the same name, the ones registered so far are unregistered
+func registerCollectors(r prometheus.Registerer, collectors []prometheus.Collector) error {
+	for i, collector := range collectors {
+		if err := r.Register(collector); err != nil {
+			unregisterCollectors(r, collectors[:i])
+			return err
+		}
+	}
+	return nil
+}
+
+func unregisterCollectors(r prometheus.Registerer, collectors []prometheus.Collector) {
+	for _, collector := range collectors {
+		r.Unregister(collector)
+	}
+}
+
+// updateSecretReadErrorsTotalMetric counts a read error with the Vault read errors metric, leaving the labels only
+// Vault has empty
+func (bm *backendMetrics) updateSecretReadErrorsTotalMetric(path string, key string, errorType string) {
+	bm.secretReadErrorsTotal.WithLabelValues("", "", "", "", "", "", path, key, "", errorType, bm.backend).Inc()
+}
+
+func (cm *cacheMetrics) updateBackendCacheHitsTotalMetric(backend string) {
+	cm.cacheHitsTotal.WithLabelValues(backend).Inc()
+}
+
+func (cm *cacheMetrics) updateBackendCacheMissesTotalMetric(backend string) {
+	cm.cacheMissesTotal.WithLabelValues(backend).Inc()
+}
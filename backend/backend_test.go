@@ -4,7 +4,9 @@ import (
 	"context"
 	"fmt"
 	"testing"
+	"time"
 
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/stretchr/testify/assert"
 	"github.com/tuenti/secrets-manager/errors"
 )
@@ -17,3 +19,52 @@ func TestNotImplementedBackend(t *testing.T) {
 	_, err := NewBackendClient(ctx, backend, nil, cfg)
 	assert.EqualError(t, err, fmt.Sprintf("[%s] backend %s not supported", errors.BackendNotImplementedErrorType, backend))
 }
+
+func TestBackendMetricsRegisterer(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	cfg := Config{ConsulAddress: "http://127.0.0.1:8500", MetricsRegisterer: prometheus.NewRegistry()}
+	_, err := NewBackendClient(ctx, consulBackendName, nil, cfg)
+	assert.Nil(t, err)
+
+	// The metrics of the first client are already registered
+	_, err = NewBackendClient(ctx, consulBackendName, nil, cfg)
+	assert.IsType(t, prometheus.AlreadyRegisteredError{}, err)
+
+	cfg.MetricsRegisterer = prometheus.NewRegistry()
+	_, err = NewBackendClient(ctx, consulBackendName, nil, cfg)
+	assert.Nil(t, err)
+}
+
+func TestBackendMetricsNamespace(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	registry := prometheus.NewRegistry()
+	cfg := Config{ConsulAddress: "http://127.0.0.1:8500", MetricsRegisterer: registry, MetricsNamespace: "secretsmanager"}
+	client, err := NewBackendClient(ctx, consulBackendName, nil, cfg)
+	assert.Nil(t, err)
+
+	(*client).(*consulClient).metrics.updateSecretReadErrorsTotalMetric("config/app", "key", errors.BackendSecretNotFoundErrorType)
+	assert.Equal(t, []string{"secretsmanager_vault_read_secret_errors_total"}, gatheredNames(t, registry))
+}
+
+func TestBackendClientClosedOnError(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	registry := prometheus.NewRegistry()
+	// The cache metrics can't be registered, once the Vault client and its metrics were set up
+	for _, collector := range newCacheMetrics("").collectors() {
+		registry.MustRegister(collector)
+	}
+	cfg := vaultCfg
+	cfg.MetricsRegisterer = registry
+	cfg.VaultCacheTTL = time.Minute
+	_, err := NewBackendClient(ctx, vaultBackendName, nil, cfg)
+	assert.IsType(t, prometheus.AlreadyRegisteredError{}, err)
+
+	// The Vault metrics of the failed client were unregistered
+	cfg.VaultCacheTTL = 0
+	vclient, err := NewBackendClient(ctx, vaultBackendName, nil, cfg)
+	assert.Nil(t, err)
+	assert.Nil(t, (*vclient).(*client).Close(ctx))
+}
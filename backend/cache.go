@@ -0,0 +1,299 @@
+package backend
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/vault/api"
+	"github.com/tuenti/secrets-manager/errors"
+)
+
+// cacheEntry is a secret value read from a backend and the time it stops being fresh. Values are kept in sealed
+// instead of value when the cache encrypts them
+type cacheEntry struct {
+	value      string
+	sealed     []byte
+	expiration time.Time
+}
+
+// Cache is implemented by backends caching the values they read, whose TTL is applied by Reload
+type Cache interface {
+	Invalidate(path string, key string)
+	Purge()
+}
+
+// cachedClient wraps a backend Client keeping the values it reads for a while, so reconciling
+// many SecretDefinitions referencing the same secrets doesn't hammer the backend. Errors are never cached
+type cachedClient struct {
+	client  Client
+	backend string
+	ttl     time.Duration
+	maxSize int
+	mutex   sync.Mutex
+	entries map[string]cacheEntry
+	// cipher, when set, encrypts the cached values
+	cipher  *cacheCipher
+	metrics *cacheMetrics
+}
+
+// newCachedClient returns a Client caching successful reads of the given one for ttl. A maxSize
+// of 0 or less doesn't limit the number of cached values
+func newCachedClient(client Client, backend string, ttl time.Duration, maxSize int) *cachedClient {
+	return &cachedClient{
+		client:  client,
+		backend: backend,
+		ttl:     ttl,
+		maxSize: maxSize,
+		entries: make(map[string]cacheEntry),
+		metrics: newCacheMetrics(""),
+	}
+}
+
+// encryptValues makes the cache keep its values encrypted with a key generated for the process, decrypting
+// them on every hit
+func (c *cachedClient) encryptValues() error {
+	cc, err := newCacheCipher()
+	if err != nil {
+		return err
+	}
+	c.cipher = cc
+	return nil
+}
+
+func cacheKey(path string, key string) string {
+	return path + "\x00" + key
+}
+
+func (c *cachedClient) ReadSecret(path string, key string) (string, error) {
+	return c.ReadSecretWithContext(context.Background(), path, key)
+}
+
+// ReadSecretWithContext reads a secret key from the cache, or from the wrapped client using ctx if it
+// can read with a context. Reads scoped to a Vault token role are never cached, as the value must only be
+// returned to the owners allowed to read it, and neither are reads in another Vault namespace, as entries are
+// only keyed by path
+func (c *cachedClient) ReadSecretWithContext(ctx context.Context, path string, key string) (string, error) {
+	if VaultRoleFromContext(ctx) != "" || VaultNamespaceFromContext(ctx) != "" {
+		reader, ok := c.client.(ContextReader)
+		if !ok {
+			return "", &errors.BackendNotImplementedError{ErrType: errors.BackendNotImplementedErrorType, Backend: c.backend}
+		}
+		return reader.ReadSecretWithContext(ctx, path, key)
+	}
+	k := cacheKey(path, key)
+	c.mutex.Lock()
+	entry, ok := c.entries[k]
+	c.mutex.Unlock()
+	if ok && time.Now().Before(entry.expiration) {
+		if c.cipher == nil {
+			c.metrics.updateBackendCacheHitsTotalMetric(c.backend)
+			return entry.value, nil
+		}
+		if value, err := c.cipher.open(k, entry.sealed); err == nil {
+			c.metrics.updateBackendCacheHitsTotalMetric(c.backend)
+			return value, nil
+		}
+	}
+
+	c.metrics.updateBackendCacheMissesTotalMetric(c.backend)
+	var value string
+	var err error
+	if reader, ok := c.client.(ContextReader); ok {
+		value, err = reader.ReadSecretWithContext(ctx, path, key)
+	} else {
+		value, err = c.client.ReadSecret(path, key)
+	}
+	if err != nil {
+		return value, err
+	}
+	entry = cacheEntry{value: value}
+	if c.cipher != nil {
+		if entry.sealed, err = c.cipher.seal(k, value); err != nil {
+			// Values that can't be encrypted are not cached at all
+			return value, nil
+		}
+		entry.value = ""
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if _, cached := c.entries[k]; !cached && c.maxSize > 0 && len(c.entries) >= c.maxSize {
+		c.evict()
+	}
+	entry.expiration = time.Now().Add(c.ttl)
+	c.entries[k] = entry
+	return value, nil
+}
+
+// evict drops the expired entries or, if none is expired, the one closest to expire. Must be called with the mutex held
+func (c *cachedClient) evict() {
+	now := time.Now()
+	oldest := ""
+	for k, entry := range c.entries {
+		if now.After(entry.expiration) {
+			delete(c.entries, k)
+			continue
+		}
+		if oldest == "" || entry.expiration.Before(c.entries[oldest].expiration) {
+			oldest = k
+		}
+	}
+	if len(c.entries) >= c.maxSize && oldest != "" {
+		delete(c.entries, oldest)
+	}
+}
+
+// Invalidate drops the cached value of a secret key, so the next read gets it from the backend
+func (c *cachedClient) Invalidate(path string, key string) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	delete(c.entries, cacheKey(path, key))
+}
+
+// invalidatePath drops the cached values of every key of a secret path, with or without a leading slash
+func (c *cachedClient) invalidatePath(path string) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	path = strings.Trim(path, "/")
+	for k := range c.entries {
+		if strings.Trim(strings.SplitN(k, "\x00", 2)[0], "/") == path {
+			delete(c.entries, k)
+		}
+	}
+}
+
+// Purge drops every cached value
+func (c *cachedClient) Purge() {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.entries = make(map[string]cacheEntry)
+}
+
+// ReadSecretAllKeys delegates on the wrapped client, if it can read all the keys of a secret. Values
+// are not cached, as a template reading them is rendered once per reconciliation
+func (c *cachedClient) ReadSecretAllKeys(path string) (map[string]string, error) {
+	if reader, ok := c.client.(AllKeysReader); ok {
+		return reader.ReadSecretAllKeys(path)
+	}
+	return nil, &errors.BackendNotImplementedError{ErrType: errors.BackendNotImplementedErrorType, Backend: c.backend}
+}
+
+// ReadSecretAllKeysWithContext delegates on the wrapped client, using ctx if it can read all the keys of a
+// secret with a context
+func (c *cachedClient) ReadSecretAllKeysWithContext(ctx context.Context, path string) (map[string]string, error) {
+	if reader, ok := c.client.(AllKeysContextReader); ok {
+		return reader.ReadSecretAllKeysWithContext(ctx, path)
+	}
+	return c.ReadSecretAllKeys(path)
+}
+
+// ReadSecretJSON delegates on the wrapped client, if it can read structured values. Values are not cached, as
+// the cache only keeps the values read with ReadSecret
+func (c *cachedClient) ReadSecretJSON(path string, key string) (json.RawMessage, error) {
+	if reader, ok := c.client.(JSONReader); ok {
+		return reader.ReadSecretJSON(path, key)
+	}
+	return nil, &errors.BackendNotImplementedError{ErrType: errors.BackendNotImplementedErrorType, Backend: c.backend}
+}
+
+// ReadRaw delegates on the wrapped client, if it can return whole responses. Responses are not cached
+func (c *cachedClient) ReadRaw(path string, params map[string][]string) (*api.Secret, error) {
+	if reader, ok := c.client.(RawReader); ok {
+		return reader.ReadRaw(path, params)
+	}
+	return nil, &errors.BackendNotImplementedError{ErrType: errors.BackendNotImplementedErrorType, Backend: c.backend}
+}
+
+// ReadSecretWrapped delegates on the wrapped client, if it can read secrets response-wrapped. Wrapping tokens
+// can only be used once, so they are never cached
+func (c *cachedClient) ReadSecretWrapped(path string, wrapTTL string) (string, error) {
+	if reader, ok := c.client.(WrappedReader); ok {
+		return reader.ReadSecretWrapped(path, wrapTTL)
+	}
+	return "", &errors.BackendNotImplementedError{ErrType: errors.BackendNotImplementedErrorType, Backend: c.backend}
+}
+
+// ValidateWrappingToken delegates on the wrapped client, if it can check wrapping tokens
+func (c *cachedClient) ValidateWrappingToken(wrappingToken string, creationPath string) error {
+	if validator, ok := c.client.(WrapValidator); ok {
+		return validator.ValidateWrappingToken(wrappingToken, creationPath)
+	}
+	return &errors.BackendNotImplementedError{ErrType: errors.BackendNotImplementedErrorType, Backend: c.backend}
+}
+
+// RenewNow delegates on the wrapped client, if it can renew its credentials on demand
+func (c *cachedClient) RenewNow() error {
+	if renewer, ok := c.client.(TokenRenewer); ok {
+		return renewer.RenewNow()
+	}
+	return &errors.BackendNotImplementedError{ErrType: errors.BackendNotImplementedErrorType, Backend: c.backend}
+}
+
+// WatchSecretEvents delegates on the wrapped client, if it can notify secret changes. The cached values of a
+// changed path are dropped before notifying it, so it's read again from the backend
+func (c *cachedClient) WatchSecretEvents(ctx context.Context, notify func(path string)) error {
+	if watcher, ok := c.client.(EventWatcher); ok {
+		return watcher.WatchSecretEvents(ctx, func(path string) {
+			c.invalidatePath(path)
+			notify(path)
+		})
+	}
+	return &errors.BackendNotImplementedError{ErrType: errors.BackendNotImplementedErrorType, Backend: c.backend}
+}
+
+// ReadSecretTree delegates on the wrapped client, if it can read every secret under a path. Values are not cached
+func (c *cachedClient) ReadSecretTree(prefix string) (map[string]string, error) {
+	if reader, ok := c.client.(TreeReader); ok {
+		return reader.ReadSecretTree(prefix)
+	}
+	return nil, &errors.BackendNotImplementedError{ErrType: errors.BackendNotImplementedErrorType, Backend: c.backend}
+}
+
+// Ready delegates on the wrapped client, if it can tell whether it is ready
+func (c *cachedClient) Ready() error {
+	if checker, ok := c.client.(HealthChecker); ok {
+		return checker.Ready()
+	}
+	return nil
+}
+
+// ActiveAddress delegates on the wrapped client, if it can fail over between several addresses
+func (c *cachedClient) ActiveAddress() string {
+	if reporter, ok := c.client.(ActiveAddressReporter); ok {
+		return reporter.ActiveAddress()
+	}
+	return ""
+}
+
+// Status delegates on the wrapped client, if it can report the status of its session
+func (c *cachedClient) Status() BackendStatus {
+	if reporter, ok := c.client.(StatusReporter); ok {
+		return reporter.Status()
+	}
+	return BackendStatus{}
+}
+
+// PauseTokenRenewal delegates on the wrapped client, if it renews its credentials
+func (c *cachedClient) PauseTokenRenewal(paused bool) {
+	if pauser, ok := c.client.(TokenRenewalPauser); ok {
+		pauser.PauseTokenRenewal(paused)
+	}
+}
+
+// ReleaseVaultRole delegates on the wrapped client, if it caches credentials per owner
+func (c *cachedClient) ReleaseVaultRole(ctx context.Context, owner string) {
+	if releaser, ok := c.client.(VaultRoleReleaser); ok {
+		releaser.ReleaseVaultRole(ctx, owner)
+	}
+}
+
+// Close delegates on the wrapped client, if it holds resources to release
+func (c *cachedClient) Close(ctx context.Context) error {
+	if closer, ok := c.client.(Closer); ok {
+		return closer.Close(ctx)
+	}
+	return nil
+}
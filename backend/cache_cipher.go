@@ -0,0 +1,64 @@
+package backend
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"io"
+)
+
+// cacheCipher encrypts the values kept by the cache with AES-GCM and a key generated for the process, which is
+// never stored anywhere, so secrets don't sit in plaintext in memory while cached. It's defense in depth: the
+// key lives in the same memory, and the values returned to callers are regular strings
+type cacheCipher struct {
+	aead cipher.AEAD
+}
+
+func newCacheCipher() (*cacheCipher, error) {
+	key := make([]byte, 32)
+	defer zeroBytes(key)
+	if _, err := io.ReadFull(rand.Reader, key); err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	return &cacheCipher{aead: aead}, nil
+}
+
+// seal encrypts value bound to its cache key, so an encrypted value can't be returned for another key
+func (c *cacheCipher) seal(key string, value string) ([]byte, error) {
+	nonce := make([]byte, c.aead.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, err
+	}
+	plaintext := []byte(value)
+	defer zeroBytes(plaintext)
+	return c.aead.Seal(nonce, nonce, plaintext, []byte(key)), nil
+}
+
+// open decrypts a value sealed for key, zeroing the decrypted buffer once copied to the returned string
+func (c *cacheCipher) open(key string, sealed []byte) (string, error) {
+	nonceSize := c.aead.NonceSize()
+	if len(sealed) < nonceSize {
+		return "", io.ErrUnexpectedEOF
+	}
+	plaintext, err := c.aead.Open(nil, sealed[:nonceSize], sealed[nonceSize:], []byte(key))
+	if err != nil {
+		return "", err
+	}
+	defer zeroBytes(plaintext)
+	return string(plaintext), nil
+}
+
+// zeroBytes overwrites b, so secrets are not left in memory until the buffer is garbage collected
+func zeroBytes(b []byte) {
+	for i := range b {
+		b[i] = 0
+	}
+}
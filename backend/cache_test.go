@@ -0,0 +1,204 @@
+package backend
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/tuenti/secrets-manager/errors"
+)
+
+// countingClient returns a different value on every read, so tests can tell cached values apart
+type countingClient struct {
+	mutex sync.Mutex
+	reads int
+}
+
+func (c *countingClient) ReadSecret(path string, key string) (string, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if path == "missing" {
+		return "", &errors.BackendSecretNotFoundError{ErrType: errors.BackendSecretNotFoundErrorType, Path: path, Key: key}
+	}
+	c.reads++
+	return fmt.Sprintf("%s/%s/%d", path, key, c.reads), nil
+}
+
+func TestCachedClientHit(t *testing.T) {
+	client := newCachedClient(&countingClient{}, "test", time.Minute, 0)
+
+	value, err := client.ReadSecret("secret/data/foo", "bar")
+	assert.Nil(t, err)
+	assert.Equal(t, "secret/data/foo/bar/1", value)
+	value, _ = client.ReadSecret("secret/data/foo", "bar")
+	assert.Equal(t, "secret/data/foo/bar/1", value)
+	value, _ = client.ReadSecret("secret/data/foo", "baz")
+	assert.Equal(t, "secret/data/foo/baz/2", value)
+
+	hits, _ := client.metrics.cacheHitsTotal.GetMetricWithLabelValues("test")
+	misses, _ := client.metrics.cacheMissesTotal.GetMetricWithLabelValues("test")
+	assert.Equal(t, 1.0, testutil.ToFloat64(hits))
+	assert.Equal(t, 2.0, testutil.ToFloat64(misses))
+}
+
+func TestCachedClientExpiration(t *testing.T) {
+	client := newCachedClient(&countingClient{}, "test", 10*time.Millisecond, 0)
+
+	value, _ := client.ReadSecret("secret/data/foo", "bar")
+	assert.Equal(t, "secret/data/foo/bar/1", value)
+	time.Sleep(20 * time.Millisecond)
+	value, _ = client.ReadSecret("secret/data/foo", "bar")
+	assert.Equal(t, "secret/data/foo/bar/2", value)
+}
+
+func TestCachedClientEncrypted(t *testing.T) {
+	client := newCachedClient(&countingClient{}, "test", time.Minute, 0)
+	assert.Nil(t, client.encryptValues())
+
+	value, err := client.ReadSecret("secret/data/foo", "bar")
+	assert.Nil(t, err)
+	assert.Equal(t, "secret/data/foo/bar/1", value)
+	value, _ = client.ReadSecret("secret/data/foo", "bar")
+	assert.Equal(t, "secret/data/foo/bar/1", value)
+
+	entry := client.entries[cacheKey("secret/data/foo", "bar")]
+	assert.Equal(t, "", entry.value)
+	assert.NotContains(t, string(entry.sealed), "secret/data/foo/bar/1")
+
+	// A value sealed for another key is never returned
+	client.entries[cacheKey("secret/data/foo", "baz")] = entry
+	value, _ = client.ReadSecret("secret/data/foo", "baz")
+	assert.Equal(t, "secret/data/foo/baz/2", value)
+}
+
+func TestCachedClientErrorsNotCached(t *testing.T) {
+	client := newCachedClient(&countingClient{}, "test", time.Minute, 0)
+
+	_, err := client.ReadSecret("missing", "bar")
+	assert.True(t, errors.IsBackendSecretNotFound(err))
+	assert.Empty(t, client.entries)
+}
+
+func TestCachedClientMaxSize(t *testing.T) {
+	client := newCachedClient(&countingClient{}, "test", time.Minute, 2)
+
+	client.ReadSecret("secret/data/foo", "a")
+	client.ReadSecret("secret/data/foo", "b")
+	client.ReadSecret("secret/data/foo", "c")
+	assert.Len(t, client.entries, 2)
+	assert.NotContains(t, client.entries, cacheKey("secret/data/foo", "a"))
+}
+
+func TestCachedClientInvalidate(t *testing.T) {
+	client := newCachedClient(&countingClient{}, "test", time.Minute, 0)
+
+	client.ReadSecret("secret/data/foo", "bar")
+	client.Invalidate("secret/data/foo", "bar")
+	value, _ := client.ReadSecret("secret/data/foo", "bar")
+	assert.Equal(t, "secret/data/foo/bar/2", value)
+
+	client.Purge()
+	assert.Empty(t, client.entries)
+}
+
+func TestCachedClientConcurrentReads(t *testing.T) {
+	client := newCachedClient(&countingClient{}, "test", time.Minute, 10)
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			client.ReadSecret("secret/data/foo", fmt.Sprintf("key%d", i%20))
+		}(i)
+	}
+	wg.Wait()
+	assert.True(t, len(client.entries) <= 10)
+}
+
+// contextCountingClient is a countingClient able to read with a context
+type contextCountingClient struct {
+	countingClient
+}
+
+func (c *contextCountingClient) ReadSecretWithContext(ctx context.Context, path string, key string) (string, error) {
+	return c.ReadSecret(path, key)
+}
+
+func TestCachedClientVaultRoleNotCached(t *testing.T) {
+	client := newCachedClient(&contextCountingClient{}, "test", time.Minute, 0)
+	ctx := WithVaultRole(context.Background(), "team-a", "team-a/db")
+
+	value, err := client.ReadSecretWithContext(ctx, "secret/data/foo", "bar")
+	assert.Nil(t, err)
+	assert.Equal(t, "secret/data/foo/bar/1", value)
+	value, _ = client.ReadSecretWithContext(ctx, "secret/data/foo", "bar")
+	assert.Equal(t, "secret/data/foo/bar/2", value)
+
+	// Values read with a role are never returned to reads without it
+	value, _ = client.ReadSecretWithContext(context.Background(), "secret/data/foo", "bar")
+	assert.Equal(t, "secret/data/foo/bar/3", value)
+
+	_, err = newCachedClient(&countingClient{}, "test", time.Minute, 0).ReadSecretWithContext(ctx, "secret/data/foo", "bar")
+	assert.True(t, errors.IsBackendNotImplemented(err))
+}
+
+func TestCachedClientRenewNowNotImplemented(t *testing.T) {
+	err := newCachedClient(&countingClient{}, "test", time.Minute, 0).RenewNow()
+	assert.True(t, errors.IsBackendNotImplemented(err))
+}
+
+type eventCountingClient struct {
+	countingClient
+}
+
+func (c *eventCountingClient) WatchSecretEvents(ctx context.Context, notify func(path string)) error {
+	notify("secret/data/foo")
+	return nil
+}
+
+func TestCachedClientWatchSecretEvents(t *testing.T) {
+	client := newCachedClient(&eventCountingClient{}, "test", time.Minute, 0)
+	client.ReadSecret("/secret/data/foo", "bar")
+	client.ReadSecret("secret/data/other", "bar")
+
+	notified := []string{}
+	err := client.WatchSecretEvents(context.Background(), func(path string) { notified = append(notified, path) })
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"secret/data/foo"}, notified)
+
+	// Changed paths are read again, the others are still cached
+	value, _ := client.ReadSecret("/secret/data/foo", "bar")
+	assert.Equal(t, "/secret/data/foo/bar/3", value)
+	value, _ = client.ReadSecret("secret/data/other", "bar")
+	assert.Equal(t, "secret/data/other/bar/2", value)
+
+	err = newCachedClient(&countingClient{}, "test", time.Minute, 0).WatchSecretEvents(context.Background(), func(string) {})
+	assert.True(t, errors.IsBackendNotImplemented(err))
+}
+
+// benchmarkCachedClientHit reads a cached value, decrypting it on every hit when encrypt is set
+func benchmarkCachedClientHit(b *testing.B, encrypt bool) {
+	client := newCachedClient(&countingClient{}, "test", time.Hour, 0)
+	if encrypt {
+		if err := client.encryptValues(); err != nil {
+			b.Fatal(err)
+		}
+	}
+	client.ReadSecret("secret/data/foo", "bar")
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		client.ReadSecret("secret/data/foo", "bar")
+	}
+}
+
+func BenchmarkCachedClientHit(b *testing.B) {
+	benchmarkCachedClientHit(b, false)
+}
+
+func BenchmarkCachedClientHitEncrypted(b *testing.B) {
+	benchmarkCachedClientHit(b, true)
+}
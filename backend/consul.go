@@ -0,0 +1,115 @@
+package backend
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/go-logr/logr"
+	"github.com/tuenti/secrets-manager/errors"
+)
+
+const (
+	consulBackendName = "consul"
+	consulAddress     = "http://127.0.0.1:8500"
+	consulTokenHeader = "X-Consul-Token"
+)
+
+type consulClient struct {
+	httpClient *http.Client
+	address    string
+	token      string
+	datacenter string
+	metrics    *backendMetrics
+	logger     logr.Logger
+}
+
+// consulBackend returns a Consul KV client. CONSUL_HTTP_ADDR and CONSUL_HTTP_TOKEN are used when the
+// address or the token are not configured, as the Consul CLI does
+func consulBackend(l logr.Logger, cfg Config) *consulClient {
+	address := cfg.ConsulAddress
+	if address == "" {
+		address = os.Getenv("CONSUL_HTTP_ADDR")
+	}
+	if address == "" {
+		address = consulAddress
+	}
+	if !strings.Contains(address, "://") {
+		address = "http://" + address
+	}
+	token := cfg.ConsulToken
+	if token == "" {
+		token = os.Getenv("CONSUL_HTTP_TOKEN")
+	}
+	return &consulClient{
+		httpClient: &http.Client{Timeout: cfg.BackendTimeout},
+		address:    strings.TrimSuffix(address, "/"),
+		token:      token,
+		datacenter: cfg.ConsulDatacenter,
+		metrics:    newBackendMetrics(consulBackendName, cfg),
+		logger:     l.WithName("consul"),
+	}
+}
+
+// ReadSecret reads the value stored at a Consul KV path. If key is not empty the value must be a JSON object
+// and the given key is returned, otherwise the whole value is returned
+func (c *consulClient) ReadSecret(path string, key string) (string, error) {
+	value, err := c.getKey(path)
+	if err != nil {
+		c.metrics.updateSecretReadErrorsTotalMetric(path, key, errors.GetErrorType(err))
+		return "", err
+	}
+	data, err := secretJSONKey(path, key, value)
+	if err != nil {
+		c.metrics.updateSecretReadErrorsTotalMetric(path, key, errors.GetErrorType(err))
+	}
+	return data, err
+}
+
+// keyURL returns the URL reading the raw value of a Consul KV path in the configured datacenter
+func (c *consulClient) keyURL(path string) string {
+	query := url.Values{}
+	if c.datacenter != "" {
+		query.Set("dc", c.datacenter)
+	}
+	// raw returns the value as it's stored, instead of base64 encoded in a JSON list of entries
+	rawQuery := "raw"
+	if encoded := query.Encode(); encoded != "" {
+		rawQuery += "&" + encoded
+	}
+	return fmt.Sprintf("%s/v1/kv/%s?%s", c.address, strings.Trim(path, "/"), rawQuery)
+}
+
+func (c *consulClient) getKey(path string) ([]byte, error) {
+	req, err := http.NewRequest(http.MethodGet, c.keyURL(path), nil)
+	if err != nil {
+		return nil, err
+	}
+	if c.token != "" {
+		req.Header.Set(consulTokenHeader, c.token)
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return body, nil
+	case http.StatusNotFound:
+		return nil, &errors.BackendSecretNotFoundError{ErrType: errors.BackendSecretNotFoundErrorType, Path: path}
+	case http.StatusForbidden:
+		return nil, &errors.BackendForbiddenError{ErrType: errors.BackendForbiddenErrorType, Backend: consulBackendName, Path: path}
+	default:
+		return nil, fmt.Errorf("consul returned %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+}
@@ -0,0 +1,87 @@
+package backend
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/tuenti/secrets-manager/errors"
+)
+
+func newConsulTestServer(t *testing.T) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, raw := r.URL.Query()["raw"]; !raw {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		if r.Header.Get(consulTokenHeader) != "consul-token" {
+			w.WriteHeader(http.StatusForbidden)
+			fmt.Fprint(w, "Permission denied")
+			return
+		}
+		switch fmt.Sprintf("%s@%s", r.URL.Path, r.URL.Query().Get("dc")) {
+		case "/v1/kv/config/flags@":
+			fmt.Fprint(w, `{"new_ui": "true", "beta": "false"}`)
+		case "/v1/kv/config/flags@dc2":
+			fmt.Fprint(w, `{"new_ui": "false"}`)
+		case "/v1/kv/config/banner@":
+			fmt.Fprint(w, "maintenance on sunday")
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+}
+
+func TestConsulReadSecret(t *testing.T) {
+	server := newConsulTestServer(t)
+	defer server.Close()
+	client := consulBackend(logger, Config{ConsulAddress: server.URL, ConsulToken: "consul-token"})
+
+	value, err := client.ReadSecret("config/flags", "new_ui")
+	assert.Nil(t, err)
+	assert.Equal(t, "true", value)
+
+	value, err = client.ReadSecret("/config/banner", "")
+	assert.Nil(t, err)
+	assert.Equal(t, "maintenance on sunday", value)
+
+	client = consulBackend(logger, Config{ConsulAddress: server.URL, ConsulToken: "consul-token", ConsulDatacenter: "dc2"})
+	value, err = client.ReadSecret("config/flags", "new_ui")
+	assert.Nil(t, err)
+	assert.Equal(t, "false", value)
+}
+
+func TestConsulSecretNotFound(t *testing.T) {
+	server := newConsulTestServer(t)
+	defer server.Close()
+	client := consulBackend(logger, Config{ConsulAddress: server.URL, ConsulToken: "consul-token"})
+
+	client.metrics.secretReadErrorsTotal.Reset()
+	_, err := client.ReadSecret("config/missing", "new_ui")
+	metric, _ := client.metrics.secretReadErrorsTotal.GetMetricWithLabelValues("", "", "", "", "", "", "config/missing", "new_ui", "", errors.BackendSecretNotFoundErrorType, consulBackendName)
+	assert.True(t, errors.IsBackendSecretNotFound(err))
+	assert.Equal(t, 1.0, testutil.ToFloat64(metric))
+
+	_, err = client.ReadSecret("config/flags", "missing")
+	assert.True(t, errors.IsBackendSecretNotFound(err))
+}
+
+func TestConsulForbidden(t *testing.T) {
+	server := newConsulTestServer(t)
+	defer server.Close()
+	client := consulBackend(logger, Config{ConsulAddress: server.URL, ConsulToken: "wrong-token"})
+
+	_, err := client.ReadSecret("config/flags", "new_ui")
+	assert.True(t, errors.IsBackendForbidden(err))
+}
+
+func TestConsulAddress(t *testing.T) {
+	client := consulBackend(logger, Config{ConsulAddress: "consul.service:8500/"})
+	assert.Equal(t, "http://consul.service:8500/v1/kv/config/flags?raw", client.keyURL("config/flags"))
+
+	client = consulBackend(logger, Config{ConsulAddress: "https://consul", ConsulDatacenter: "dc2"})
+	assert.Equal(t, "https://consul/v1/kv/config/flags?raw&dc=dc2", client.keyURL("config/flags"))
+}
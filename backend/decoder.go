@@ -15,6 +15,12 @@ const (
 
 	// DefaultEncodingType is the default encoding to use.
 	DefaultEncodingType = "text"
+
+	// Base64EncodeTransform encodes a secret value as base64 before it is written to Kubernetes
+	Base64EncodeTransform = "base64-encode"
+
+	// Base64DecodeTransform decodes a base64 secret value before it is written to Kubernetes
+	Base64DecodeTransform = "base64-decode"
 )
 
 // Decoder interface represents anything that can implement DecodeString: get some bytes from input string
@@ -36,7 +42,7 @@ type TextDecoder struct {
 func (d Base64Decoder) DecodeString(input string) ([]byte, error) {
 	data, err := base64.StdEncoding.DecodeString(input)
 	if err != nil {
-		return nil, err
+		return nil, &errors.BackendSecretEncodingError{ErrType: errors.BackendSecretEncodingErrorType, Encoding: d.Encoding, Err: err}
 	}
 	return data, err
 }
@@ -60,3 +66,24 @@ func NewDecoder(encoding string) (Decoder, error) {
 		return nil, &errors.EncodingNotImplementedError{ErrType: errors.EncodingNotImplementedErrorType, Encoding: encoding}
 	}
 }
+
+// Transform applies a transform to a decoded secret value. An empty transform returns the value as is
+func Transform(transform string, data []byte) ([]byte, error) {
+	switch transform {
+	case "":
+		return data, nil
+	case Base64EncodeTransform:
+		encoded := make([]byte, base64.StdEncoding.EncodedLen(len(data)))
+		base64.StdEncoding.Encode(encoded, data)
+		return encoded, nil
+	case Base64DecodeTransform:
+		decoded := make([]byte, base64.StdEncoding.DecodedLen(len(data)))
+		n, err := base64.StdEncoding.Decode(decoded, data)
+		if err != nil {
+			return nil, &errors.BackendSecretEncodingError{ErrType: errors.BackendSecretEncodingErrorType, Encoding: Base64EncodingType, Err: err}
+		}
+		return decoded[:n], nil
+	default:
+		return nil, &errors.EncodingNotImplementedError{ErrType: errors.EncodingNotImplementedErrorType, Encoding: transform}
+	}
+}
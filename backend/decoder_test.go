@@ -61,3 +61,37 @@ func TestDecodeText(t *testing.T) {
 	assert.Nil(t, err)
 	assert.Equal(t, text, fmt.Sprintf("%s", data))
 }
+
+func TestBase64DecoderInvalid(t *testing.T) {
+	decoder, _ := NewDecoder("base64")
+	_, err := decoder.DecodeString("not base64!")
+	assert.True(t, errors.IsBackendSecretEncoding(err))
+}
+
+func TestTransform(t *testing.T) {
+	data, err := Transform("", []byte("foo"))
+	assert.Nil(t, err)
+	assert.Equal(t, []byte("foo"), data)
+
+	data, err = Transform(Base64EncodeTransform, []byte("foo"))
+	assert.Nil(t, err)
+	assert.Equal(t, []byte("Zm9v"), data)
+
+	data, err = Transform(Base64DecodeTransform, []byte("Zm9v"))
+	assert.Nil(t, err)
+	assert.Equal(t, []byte("foo"), data)
+
+	data, err = Transform(Base64EncodeTransform, []byte{})
+	assert.Nil(t, err)
+	assert.Empty(t, data)
+}
+
+func TestTransformInvalidBase64(t *testing.T) {
+	_, err := Transform(Base64DecodeTransform, []byte("not base64!"))
+	assert.True(t, errors.IsBackendSecretEncoding(err))
+}
+
+func TestTransformNotImplemented(t *testing.T) {
+	_, err := Transform("rot13", []byte("foo"))
+	assert.True(t, errors.IsEncodingNotImplemented(err))
+}
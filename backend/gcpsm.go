@@ -0,0 +1,130 @@
+package backend
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+
+	"github.com/go-logr/logr"
+	"github.com/tuenti/secrets-manager/errors"
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/google"
+)
+
+const (
+	gcpSecretManagerBackendName = "gcp-secret-manager"
+	gcpSecretManagerEndpoint    = "https://secretmanager.googleapis.com"
+	gcpCloudPlatformScope       = "https://www.googleapis.com/auth/cloud-platform"
+	gcpLatestVersion            = "latest"
+)
+
+type gcpSecretManagerClient struct {
+	httpClient *http.Client
+	endpoint   string
+	project    string
+	metrics    *backendMetrics
+	logger     logr.Logger
+}
+
+type gcpAccessSecretVersionResponse struct {
+	Name    string `json:"name"`
+	Payload struct {
+		Data string `json:"data"`
+	} `json:"payload"`
+}
+
+type gcpErrorResponse struct {
+	Error struct {
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+		Status  string `json:"status"`
+	} `json:"error"`
+}
+
+// gcpSecretManagerBackend returns a GCP Secret Manager client authenticated with Application Default Credentials
+func gcpSecretManagerBackend(ctx context.Context, l logr.Logger, cfg Config) (*gcpSecretManagerClient, error) {
+	ctx = context.WithValue(ctx, oauth2.HTTPClient, &http.Client{Timeout: cfg.BackendTimeout})
+	tokenSource, err := google.DefaultTokenSource(ctx, gcpCloudPlatformScope)
+	if err != nil {
+		return nil, &errors.BackendConfigError{ErrType: errors.BackendConfigErrorType, Backend: gcpSecretManagerBackendName, Reason: err.Error()}
+	}
+	httpClient := oauth2.NewClient(ctx, tokenSource)
+	httpClient.Timeout = cfg.BackendTimeout
+	return newGCPSecretManagerClient(l, cfg, httpClient), nil
+}
+
+func newGCPSecretManagerClient(l logr.Logger, cfg Config, httpClient *http.Client) *gcpSecretManagerClient {
+	endpoint := cfg.GCPSecretManagerEndpoint
+	if endpoint == "" {
+		endpoint = gcpSecretManagerEndpoint
+	}
+	return &gcpSecretManagerClient{
+		httpClient: httpClient,
+		endpoint:   strings.TrimSuffix(endpoint, "/"),
+		project:    cfg.GCPProject,
+		metrics:    newBackendMetrics(gcpSecretManagerBackendName, cfg),
+		logger:     l.WithName("gcp-secret-manager"),
+	}
+}
+
+// ReadSecret reads a secret version. path is projects/P/secrets/S, optionally followed by /versions/N,
+// and the latest version is read by default. A secret name alone is read from the configured project.
+// If key is not empty the payload must be a JSON object and the given key is returned, otherwise the whole payload is returned
+func (c *gcpSecretManagerClient) ReadSecret(path string, key string) (string, error) {
+	value, err := c.accessSecretVersion(path)
+	if err != nil {
+		c.metrics.updateSecretReadErrorsTotalMetric(path, key, errors.GetErrorType(err))
+		return "", err
+	}
+	data, err := secretJSONKey(path, key, value)
+	if err != nil {
+		c.metrics.updateSecretReadErrorsTotalMetric(path, key, errors.GetErrorType(err))
+	}
+	return data, err
+}
+
+// secretVersionName returns the full resource name of the secret version referenced by path
+func (c *gcpSecretManagerClient) secretVersionName(path string) string {
+	name := strings.Trim(path, "/")
+	if !strings.HasPrefix(name, "projects/") && c.project != "" {
+		name = fmt.Sprintf("projects/%s/secrets/%s", c.project, name)
+	}
+	if !strings.Contains(name, "/versions/") {
+		name = name + "/versions/" + gcpLatestVersion
+	}
+	return name
+}
+
+func (c *gcpSecretManagerClient) accessSecretVersion(path string) ([]byte, error) {
+	resp, err := c.httpClient.Get(fmt.Sprintf("%s/v1/%s:access", c.endpoint, c.secretVersionName(path)))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		if resp.StatusCode == http.StatusNotFound {
+			return nil, &errors.BackendSecretNotFoundError{ErrType: errors.BackendSecretNotFoundErrorType, Path: path}
+		}
+		var gcpErr gcpErrorResponse
+		if err := json.Unmarshal(body, &gcpErr); err != nil || gcpErr.Error.Message == "" {
+			// Errors of proxies and load balancers in front of the API are not JSON
+			return nil, fmt.Errorf("gcp secret manager returned %s: %s", resp.Status, strings.TrimSpace(string(body)))
+		}
+		return nil, fmt.Errorf("gcp secret manager returned %d %s: %s", resp.StatusCode, gcpErr.Error.Status, gcpErr.Error.Message)
+	}
+
+	var secret gcpAccessSecretVersionResponse
+	if err := json.Unmarshal(body, &secret); err != nil {
+		return nil, err
+	}
+	return base64.StdEncoding.DecodeString(secret.Payload.Data)
+}
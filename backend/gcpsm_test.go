@@ -0,0 +1,88 @@
+package backend
+
+import (
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/tuenti/secrets-manager/errors"
+)
+
+func newGCPSecretManagerTestServer(t *testing.T) *httptest.Server {
+	versions := map[string]string{
+		"projects/foo/secrets/db/versions/1":       `{"password": "old"}`,
+		"projects/foo/secrets/db/versions/2":       `{"password": "new"}`,
+		"projects/foo/secrets/db/versions/latest":  `{"password": "new"}`,
+		"projects/foo/secrets/tls/versions/latest": "certificate",
+	}
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		name := strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, "/v1/"), ":access")
+		w.Header().Set("Content-Type", "application/json")
+		payload, ok := versions[name]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprintf(w, `{"error": {"code": 404, "message": "Secret [%s] not found or has no versions.", "status": "NOT_FOUND"}}`, name)
+			return
+		}
+		fmt.Fprintf(w, `{"name": "%s", "payload": {"data": "%s"}}`, name, base64.StdEncoding.EncodeToString([]byte(payload)))
+	}))
+}
+
+func TestGCPSecretManagerReadSecret(t *testing.T) {
+	server := newGCPSecretManagerTestServer(t)
+	defer server.Close()
+	client := newGCPSecretManagerClient(logger, Config{GCPSecretManagerEndpoint: server.URL, GCPProject: "foo"}, http.DefaultClient)
+
+	value, err := client.ReadSecret("projects/foo/secrets/db", "password")
+	assert.Nil(t, err)
+	assert.Equal(t, "new", value)
+
+	value, err = client.ReadSecret("projects/foo/secrets/db/versions/1", "password")
+	assert.Nil(t, err)
+	assert.Equal(t, "old", value)
+
+	value, err = client.ReadSecret("tls", "")
+	assert.Nil(t, err)
+	assert.Equal(t, "certificate", value)
+}
+
+func TestGCPSecretManagerSecretNotFound(t *testing.T) {
+	server := newGCPSecretManagerTestServer(t)
+	defer server.Close()
+	client := newGCPSecretManagerClient(logger, Config{GCPSecretManagerEndpoint: server.URL}, http.DefaultClient)
+
+	client.metrics.secretReadErrorsTotal.Reset()
+	path := "projects/foo/secrets/db/versions/3"
+	_, err := client.ReadSecret(path, "password")
+	metric, _ := client.metrics.secretReadErrorsTotal.GetMetricWithLabelValues("", "", "", "", "", "", path, "password", "", errors.BackendSecretNotFoundErrorType, gcpSecretManagerBackendName)
+
+	assert.True(t, errors.IsBackendSecretNotFound(err))
+	assert.Equal(t, 1.0, testutil.ToFloat64(metric))
+}
+
+func TestGCPSecretManagerNonJSONError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "upstream connect error", http.StatusBadGateway)
+	}))
+	defer server.Close()
+	client := newGCPSecretManagerClient(logger, Config{GCPSecretManagerEndpoint: server.URL, GCPProject: "foo"}, http.DefaultClient)
+
+	client.metrics.secretReadErrorsTotal.Reset()
+	_, err := client.ReadSecret("db", "password")
+	metric, _ := client.metrics.secretReadErrorsTotal.GetMetricWithLabelValues("", "", "", "", "", "", "db", "password", "", errors.UnknownErrorType, gcpSecretManagerBackendName)
+
+	assert.EqualError(t, err, "gcp secret manager returned 502 Bad Gateway: upstream connect error")
+	assert.Equal(t, 1.0, testutil.ToFloat64(metric))
+}
+
+func TestGCPSecretVersionName(t *testing.T) {
+	client := newGCPSecretManagerClient(logger, Config{GCPProject: "foo"}, http.DefaultClient)
+	assert.Equal(t, "projects/foo/secrets/db/versions/latest", client.secretVersionName("db"))
+	assert.Equal(t, "projects/bar/secrets/db/versions/latest", client.secretVersionName("/projects/bar/secrets/db"))
+	assert.Equal(t, "projects/bar/secrets/db/versions/5", client.secretVersionName("projects/bar/secrets/db/versions/5"))
+}
@@ -0,0 +1,84 @@
+package backend
+
+import (
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/binary"
+	"encoding/hex"
+	"hash"
+	"sort"
+
+	"github.com/tuenti/secrets-manager/errors"
+)
+
+const (
+	// HashSHA256 is the default secret hash algorithm
+	HashSHA256 = "sha256"
+	HashSHA384 = "sha384"
+	HashSHA512 = "sha512"
+)
+
+func newHash(algorithm string) (hash.Hash, error) {
+	switch algorithm {
+	case "", HashSHA256:
+		return sha256.New(), nil
+	case HashSHA384:
+		return sha512.New384(), nil
+	case HashSHA512:
+		return sha512.New(), nil
+	default:
+		return nil, &errors.HashNotImplementedError{ErrType: errors.HashNotImplementedErrorType, Algorithm: algorithm}
+	}
+}
+
+// ValidateHashAlgorithm returns an error if the secret hash algorithm is not implemented
+func ValidateHashAlgorithm(algorithm string) error {
+	_, err := newHash(algorithm)
+	return err
+}
+
+// HashSecret returns a stable hash of the keys and values of a secret, prefixed with the algorithm
+// used, e.g. sha256:9f86d0.... The keys are hashed in order, so the hash only changes with the content
+func HashSecret(algorithm string, data map[string][]byte) (string, error) {
+	h, err := newHash(algorithm)
+	if err != nil {
+		return "", err
+	}
+	if algorithm == "" {
+		algorithm = HashSHA256
+	}
+	keys := make([]string, 0, len(data))
+	for k := range data {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	// Lengths are hashed along with keys and values, so moving bytes from a value to the next key changes the hash
+	length := make([]byte, 8)
+	for _, k := range keys {
+		binary.BigEndian.PutUint64(length, uint64(len(k)))
+		h.Write(length)
+		h.Write([]byte(k))
+		binary.BigEndian.PutUint64(length, uint64(len(data[k])))
+		h.Write(length)
+		h.Write(data[k])
+	}
+	return algorithm + ":" + hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// ReadSecretHash reads a secret key and returns the hash of its value, e.g. to tell whether it changed
+// without keeping the value around
+func ReadSecretHash(c Client, algorithm string, path string, key string) (string, error) {
+	h, err := newHash(algorithm)
+	if err != nil {
+		return "", err
+	}
+	if algorithm == "" {
+		algorithm = HashSHA256
+	}
+	value, err := c.ReadSecret(path, key)
+	if err != nil {
+		return "", err
+	}
+	h.Write([]byte(value))
+	return algorithm + ":" + hex.EncodeToString(h.Sum(nil)), nil
+}
@@ -0,0 +1,44 @@
+package backend
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/tuenti/secrets-manager/errors"
+)
+
+func TestHashSecret(t *testing.T) {
+	data := map[string][]byte{"username": []byte("app"), "password": []byte("s3cr3t")}
+	hash, err := HashSecret("", data)
+	assert.Nil(t, err)
+	assert.Regexp(t, "^sha256:[0-9a-f]{64}$", hash)
+
+	// The hash doesn't depend on the map order and changes with the content
+	same, _ := HashSecret(HashSHA256, map[string][]byte{"password": []byte("s3cr3t"), "username": []byte("app")})
+	assert.Equal(t, hash, same)
+	changed, _ := HashSecret(HashSHA256, map[string][]byte{"username": []byte("app"), "password": []byte("s3cr3t2")})
+	assert.NotEqual(t, hash, changed)
+	shifted, _ := HashSecret(HashSHA256, map[string][]byte{"a": []byte("bc"), "d": []byte("")})
+	other, _ := HashSecret(HashSHA256, map[string][]byte{"a": []byte("b"), "cd": []byte("")})
+	assert.NotEqual(t, shifted, other)
+
+	sha512Hash, err := HashSecret(HashSHA512, data)
+	assert.Nil(t, err)
+	assert.Regexp(t, "^sha512:[0-9a-f]{128}$", sha512Hash)
+
+	_, err = HashSecret("md5", data)
+	assert.True(t, errors.IsHashNotImplemented(err))
+}
+
+func TestReadSecretHash(t *testing.T) {
+	client := NewMemoryClient(map[string]map[string]string{"secret/data/db": {"password": "test"}})
+	hash, err := ReadSecretHash(client, "", "secret/data/db", "password")
+	assert.Nil(t, err)
+	assert.Equal(t, "sha256:9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08", hash)
+
+	_, err = ReadSecretHash(client, "", "secret/data/db", "username")
+	assert.True(t, errors.IsBackendSecretNotFound(err))
+
+	_, err = ReadSecretHash(client, "crc32", "secret/data/db", "password")
+	assert.True(t, errors.IsHashNotImplemented(err))
+}
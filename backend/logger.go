@@ -0,0 +1,111 @@
+package backend
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/go-logr/logr"
+	"github.com/go-logr/zapr"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	crzap "sigs.k8s.io/controller-runtime/pkg/log/zap"
+)
+
+const (
+	logFormatJSON    = "json"
+	logFormatConsole = "console"
+)
+
+// NewLogger returns a logger writing to stderr with the level and format set in LogLevel and LogFormat, so the
+// backend and the controller log consistently. The controller-runtime defaults are kept when both are unset:
+// debug level and console format in development mode, info level and JSON format otherwise
+func NewLogger(cfg Config, development bool) (logr.Logger, error) {
+	if cfg.LogLevel == "" && cfg.LogFormat == "" {
+		return crzap.Logger(development), nil
+	}
+	logger, _, err := NewReloadableLogger(cfg, development)
+	return logger, err
+}
+
+// LogLevel is the level of a logger built with NewReloadableLogger, which can be changed while it's running
+type LogLevel struct {
+	level       zap.AtomicLevel
+	development bool
+}
+
+// parseLogLevel returns the level named level, or the default one when empty: debug in development mode and
+// info otherwise
+func parseLogLevel(level string, development bool) (zapcore.Level, error) {
+	if level == "" {
+		if development {
+			return zap.DebugLevel, nil
+		}
+		return zap.InfoLevel, nil
+	}
+	var l zapcore.Level
+	if err := l.UnmarshalText([]byte(level)); err != nil {
+		return l, fmt.Errorf("invalid log level %s: %v", level, err)
+	}
+	return l, nil
+}
+
+// Validate returns an error when level is not a valid log level, without changing the current one
+func (l *LogLevel) Validate(level string) error {
+	_, err := parseLogLevel(level, l.development)
+	return err
+}
+
+// Set changes the level of the logger, an empty level setting the default one
+func (l *LogLevel) Set(level string) error {
+	parsed, err := parseLogLevel(level, l.development)
+	if err != nil {
+		return err
+	}
+	l.level.SetLevel(parsed)
+	return nil
+}
+
+func (l *LogLevel) String() string {
+	return l.level.String()
+}
+
+// NewReloadableLogger returns a logger like NewLogger along with its level, so it can be changed while running.
+// The controller-runtime defaults are never used, as their level can't be changed
+func NewReloadableLogger(cfg Config, development bool) (logr.Logger, *LogLevel, error) {
+	parsed, err := parseLogLevel(cfg.LogLevel, development)
+	if err != nil {
+		return nil, nil, err
+	}
+	level := &LogLevel{level: zap.NewAtomicLevelAt(parsed), development: development}
+
+	encoderConfig := zap.NewProductionEncoderConfig()
+	if development {
+		encoderConfig = zap.NewDevelopmentEncoderConfig()
+	}
+	format := cfg.LogFormat
+	if format == "" {
+		format = logFormatJSON
+		if development {
+			format = logFormatConsole
+		}
+	}
+	var encoder zapcore.Encoder
+	switch format {
+	case logFormatJSON:
+		encoder = zapcore.NewJSONEncoder(encoderConfig)
+	case logFormatConsole:
+		encoder = zapcore.NewConsoleEncoder(encoderConfig)
+	default:
+		return nil, nil, fmt.Errorf("invalid log format %s, supported: %s, %s", cfg.LogFormat, logFormatJSON, logFormatConsole)
+	}
+
+	sink := zapcore.AddSync(os.Stderr)
+	core := zapcore.NewCore(&crzap.KubeAwareEncoder{Encoder: encoder, Verbose: development}, sink, level.level)
+	return zapr.NewLogger(zap.New(core, zap.AddCallerSkip(1), zap.AddStacktrace(zap.ErrorLevel), zap.ErrorOutput(sink))), level, nil
+}
+
+// defaultLogger is used by the backend clients built without a logger. Every client keeps its own logger, so
+// clients running in the same process never log with each other's context
+func defaultLogger() logr.Logger {
+	return crzap.Logger(false)
+}
@@ -0,0 +1,106 @@
+package backend
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/go-logr/logr"
+	"github.com/stretchr/testify/assert"
+)
+
+// recordingLogger keeps every message logged through it, or any logger derived from it, along with its values
+type recordingLogger struct {
+	mutex    *sync.Mutex
+	messages *[]string
+	values   []interface{}
+}
+
+func newRecordingLogger() *recordingLogger {
+	return &recordingLogger{mutex: &sync.Mutex{}, messages: &[]string{}}
+}
+
+func (l *recordingLogger) record(msg string, keysAndValues []interface{}) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	*l.messages = append(*l.messages, fmt.Sprintf("%s %v", msg, append(append([]interface{}{}, l.values...), keysAndValues...)))
+}
+
+func (l *recordingLogger) Info(msg string, keysAndValues ...interface{}) {
+	l.record(msg, keysAndValues)
+}
+
+func (l *recordingLogger) Enabled() bool { return true }
+
+func (l *recordingLogger) Error(err error, msg string, keysAndValues ...interface{}) {
+	l.record(msg, append(keysAndValues, "error", err))
+}
+
+func (l *recordingLogger) V(level int) logr.InfoLogger { return l }
+
+func (l *recordingLogger) WithName(name string) logr.Logger { return l }
+
+func (l *recordingLogger) WithValues(keysAndValues ...interface{}) logr.Logger {
+	return &recordingLogger{mutex: l.mutex, messages: l.messages, values: append(append([]interface{}{}, l.values...), keysAndValues...)}
+}
+
+func (l *recordingLogger) recorded() []string {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	return append([]string(nil), *l.messages...)
+}
+
+func TestNewLogger(t *testing.T) {
+	for _, cfg := range []Config{{}, {LogLevel: "debug"}, {LogFormat: "console"}, {LogLevel: "error", LogFormat: "json"}} {
+		l, err := NewLogger(cfg, false)
+		assert.Nil(t, err)
+		assert.NotNil(t, l)
+	}
+}
+
+func TestNewLoggerInvalidConfig(t *testing.T) {
+	_, err := NewLogger(Config{LogLevel: "verbose"}, false)
+	assert.Contains(t, err.Error(), "invalid log level verbose")
+	_, err = NewLogger(Config{LogFormat: "logfmt"}, false)
+	assert.Contains(t, err.Error(), "invalid log format logfmt")
+}
+
+func TestVaultClientsLogWithOwnContext(t *testing.T) {
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	first := newRecordingLogger()
+	second := newRecordingLogger()
+	secondCfg := vaultCfg
+	secondCfg.VaultNamespace = "team-b"
+
+	firstClient, err := vaultClient(first, vaultCfg)
+	assert.Nil(t, err)
+	secondClient, err := vaultClient(second, secondCfg)
+	assert.Nil(t, err)
+
+	firstClient.logger.Info("first client message")
+	secondClient.logger.Info("second client message")
+
+	for _, msg := range first.recorded() {
+		assert.NotContains(t, msg, "team-b")
+		assert.NotContains(t, msg, "second client message")
+	}
+	for _, msg := range second.recorded() {
+		assert.NotContains(t, msg, "first client message")
+	}
+	assert.Contains(t, first.recorded()[len(first.recorded())-1], "first client message")
+	last := second.recorded()[len(second.recorded())-1]
+	assert.Contains(t, last, "second client message")
+	assert.Contains(t, last, "vault_namespace team-b")
+}
+
+func TestVaultClientDefaultLogger(t *testing.T) {
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	client, err := vaultClient(nil, vaultCfg)
+	assert.Nil(t, err)
+	assert.NotNil(t, client.logger)
+	assert.NotNil(t, client.leaseRenewer.logger)
+}
@@ -0,0 +1,56 @@
+package backend
+
+import (
+	"sync"
+
+	"github.com/tuenti/secrets-manager/errors"
+)
+
+const memoryBackendName = "memory"
+
+// memoryClient is a backend keeping secrets in memory, meant for tests and local development
+type memoryClient struct {
+	mutex   sync.RWMutex
+	secrets map[string]map[string]string
+}
+
+// NewMemoryClient returns a Client reading secrets from the given map of paths to keys and values.
+// The map is copied, so later changes to it won't be seen by the client
+func NewMemoryClient(secrets map[string]map[string]string) Client {
+	c := &memoryClient{secrets: make(map[string]map[string]string, len(secrets))}
+	for path, data := range secrets {
+		c.secrets[path] = make(map[string]string, len(data))
+		for k, v := range data {
+			c.secrets[path][k] = v
+		}
+	}
+	return c
+}
+
+func (c *memoryClient) ReadSecret(path string, key string) (string, error) {
+	if key == "" {
+		key = defaultSecretKey
+	}
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	value, ok := c.secrets[path][key]
+	if !ok {
+		return "", &errors.BackendSecretNotFoundError{ErrType: errors.BackendSecretNotFoundErrorType, Path: path, Key: key}
+	}
+	return value, nil
+}
+
+// ReadSecretAllKeys returns a copy of every key stored in the given path
+func (c *memoryClient) ReadSecretAllKeys(path string) (map[string]string, error) {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	secret, ok := c.secrets[path]
+	if !ok {
+		return nil, &errors.BackendSecretNotFoundError{ErrType: errors.BackendSecretNotFoundErrorType, Path: path}
+	}
+	data := make(map[string]string, len(secret))
+	for k, v := range secret {
+		data[k] = v
+	}
+	return data, nil
+}
@@ -0,0 +1,42 @@
+package backend
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/tuenti/secrets-manager/errors"
+)
+
+func TestMemoryClientReadSecret(t *testing.T) {
+	secrets := map[string]map[string]string{
+		"secret/data/foo": {"bar": "baz", "data": "default"},
+	}
+	client := NewMemoryClient(secrets)
+	secrets["secret/data/foo"]["bar"] = "changed"
+
+	value, err := client.ReadSecret("secret/data/foo", "bar")
+	assert.Nil(t, err)
+	assert.Equal(t, "baz", value)
+
+	value, err = client.ReadSecret("secret/data/foo", "")
+	assert.Nil(t, err)
+	assert.Equal(t, "default", value)
+}
+
+func TestMemoryClientSecretNotFound(t *testing.T) {
+	client := NewMemoryClient(nil)
+	_, err := client.ReadSecret("secret/data/foo", "bar")
+	assert.True(t, errors.IsBackendSecretNotFound(err))
+}
+
+func TestMemoryBackend(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	cfg := Config{MemorySecrets: map[string]map[string]string{"secret/data/foo": {"bar": "baz"}}}
+	client, err := NewBackendClient(ctx, memoryBackendName, logger, cfg)
+	assert.Nil(t, err)
+	value, err := (*client).ReadSecret("secret/data/foo", "bar")
+	assert.Nil(t, err)
+	assert.Equal(t, "baz", value)
+}
@@ -0,0 +1,44 @@
+package backend
+
+import (
+	"sync/atomic"
+
+	"github.com/tuenti/secrets-manager/errors"
+)
+
+// Reloader is implemented by backends able to apply new settings while running. Only the settings not related
+// to the connection or the authentication are reloaded: VaultMetricsPathLabels, VaultTokenPollingJitter and
+// VaultCacheTTL. Either every setting is applied or, when any is invalid, none is
+type Reloader interface {
+	Reload(cfg Config) error
+}
+
+// Reload applies the metric label mode and the token polling jitter of cfg
+func (c *client) Reload(cfg Config) error {
+	if cfg.VaultTokenPollingJitter < 0 {
+		err := &errors.BackendConfigError{ErrType: errors.BackendConfigErrorType, Backend: vaultBackendName, Reason: "token polling jitter can't be negative"}
+		c.logger.Error(err, "unable to reload vault settings")
+		return err
+	}
+	atomic.StoreInt32(&c.tokenPollingJitter, int32(cfg.VaultTokenPollingJitter))
+	c.metrics.setPathLabels(cfg.VaultMetricsPathLabels)
+	return nil
+}
+
+// Reload applies the TTL of cfg to the values cached from now on, and the rest of the settings to the wrapped
+// client when it can reload them. The cache can't be enabled or disabled without a restart, a TTL of 0 makes
+// new values expire right away
+func (c *cachedClient) Reload(cfg Config) error {
+	if cfg.VaultCacheTTL < 0 {
+		return &errors.BackendConfigError{ErrType: errors.BackendConfigErrorType, Backend: c.backend, Reason: "cache TTL can't be negative"}
+	}
+	if reloader, ok := c.client.(Reloader); ok {
+		if err := reloader.Reload(cfg); err != nil {
+			return err
+		}
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.ttl = cfg.VaultCacheTTL
+	return nil
+}
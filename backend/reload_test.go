@@ -0,0 +1,30 @@
+package backend
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/tuenti/secrets-manager/errors"
+)
+
+func TestReload(t *testing.T) {
+	vclient := &client{logger: defaultLogger(), metrics: newVaultMetrics(newTestVaultCollectors(), "", "", "", "", "", ""), tokenPollingJitter: 10}
+	cached := newCachedClient(vclient, vaultBackendName, time.Minute, 0)
+
+	assert.Nil(t, cached.Reload(Config{VaultCacheTTL: time.Hour, VaultTokenPollingJitter: 20, VaultMetricsPathLabels: false}))
+	assert.Equal(t, time.Hour, cached.ttl)
+	assert.Equal(t, int32(20), vclient.tokenPollingJitter)
+	assert.Equal(t, "", vclient.metrics.pathLabel("secret/data/foo"))
+
+	// Nothing is applied when any setting is invalid
+	err := cached.Reload(Config{VaultCacheTTL: time.Minute, VaultTokenPollingJitter: -1, VaultMetricsPathLabels: true})
+	assert.True(t, errors.IsBackendConfig(err))
+	assert.Equal(t, time.Hour, cached.ttl)
+	assert.Equal(t, int32(20), vclient.tokenPollingJitter)
+	assert.Equal(t, "", vclient.metrics.pathLabel("secret/data/foo"))
+
+	err = cached.Reload(Config{VaultCacheTTL: -time.Minute, VaultMetricsPathLabels: true})
+	assert.True(t, errors.IsBackendConfig(err))
+	assert.Equal(t, "", vclient.metrics.pathLabel("secret/data/foo"))
+}
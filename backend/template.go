@@ -0,0 +1,54 @@
+package backend
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"text/template"
+
+	"github.com/tuenti/secrets-manager/errors"
+)
+
+// AllKeysReader is implemented by backends able to read every key of a secret path at once
+type AllKeysReader interface {
+	ReadSecretAllKeys(path string) (map[string]string, error)
+}
+
+// AllKeysContextReader is implemented by backends able to read every key of a secret path with a context, e.g.
+// so the read is shared with the other reads of the path made with a context returned by WithSharedReads
+type AllKeysContextReader interface {
+	ReadSecretAllKeysWithContext(ctx context.Context, path string) (map[string]string, error)
+}
+
+// RenderTemplate reads every key stored in path with a single request and executes the given
+// text/template with them, e.g. postgres://{{.username}}:{{.password}}@{{.host}}. Referencing a
+// key that doesn't exist in the secret is an error
+func RenderTemplate(c Client, path string, text string) (string, error) {
+	return RenderTemplateWithContext(context.Background(), c, path, text)
+}
+
+// RenderTemplateWithContext is RenderTemplate reading the keys with ctx if the backend can read with a context
+func RenderTemplateWithContext(ctx context.Context, c Client, path string, text string) (string, error) {
+	reader, ok := c.(AllKeysReader)
+	if !ok {
+		return "", &errors.SecretTemplateError{ErrType: errors.SecretTemplateErrorType, Path: path, Err: fmt.Errorf("backend can't read all the keys of a secret")}
+	}
+	tmpl, err := template.New(path).Option("missingkey=error").Parse(text)
+	if err != nil {
+		return "", &errors.SecretTemplateError{ErrType: errors.SecretTemplateErrorType, Path: path, Err: err}
+	}
+	var data map[string]string
+	if ctxReader, ok := c.(AllKeysContextReader); ok {
+		data, err = ctxReader.ReadSecretAllKeysWithContext(ctx, path)
+	} else {
+		data, err = reader.ReadSecretAllKeys(path)
+	}
+	if err != nil {
+		return "", err
+	}
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return "", &errors.SecretTemplateError{ErrType: errors.SecretTemplateErrorType, Path: path, Err: err}
+	}
+	return buf.String(), nil
+}
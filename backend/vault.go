@@ -3,139 +3,408 @@ package backend
 import (
 	"context"
 	"encoding/json"
+	stderrors "errors"
 	"fmt"
 	"io"
-	"io/ioutil"
+	"math/rand"
 	"net/http"
-	"os"
+	"net/url"
+	"sort"
 	"strconv"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/go-logr/logr"
 	"github.com/hashicorp/vault/api"
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/tuenti/secrets-manager/errors"
 )
 
-var vMetrics *vaultMetrics
-
 const (
-	defaultSecretKey       = "data"
-	kubernetesJwtTokenPath = "/var/run/secrets/kubernetes.io/serviceaccount/token"
-	kubernetesAuthMethod   = "kubernetes"
-	appRoleAuthMethod      = "approle"
+	defaultSecretKey = "data"
+	batchTokenType   = "batch"
+	serviceTokenType = "service"
+	// vaultInconsistentHeader set to vaultForwardActiveNode makes performance standby nodes forward
+	// the request to the active node instead of answering it
+	vaultInconsistentHeader = "X-Vault-Inconsistent"
+	vaultForwardActiveNode  = "forward-active-node"
+	// vaultMaxTTL is the default max TTL of Vault tokens, longer renew increments are capped by Vault
+	vaultMaxTTL = 768 * time.Hour
+	// minTokenTTLPollingPeriods is how many token polling periods the initial token must last at least
+	minTokenTTLPollingPeriods = 3
+	// defaultStartupRetryInterval is the time between connection attempts at startup when none is configured
+	defaultStartupRetryInterval = 5 * time.Second
+	// defaultMaxSecretValueSize leaves room for other keys and metadata below the 1MiB size limit of Kubernetes secrets
+	defaultMaxSecretValueSize = 768 * 1024
 )
 
 type client struct {
-	vclient            *api.Client
-	logical            *api.Logical
-	roleID             string
-	authMethod         string
-	secretID           string
-	kubernetesRole     string
-	maxTokenTTL        int64
-	tokenPollingPeriod time.Duration
-	renewTTLIncrement  int
-	engine             engine
-	approlePath        string
-	kubernetesPath     string
-	logger             logr.Logger
-}
-
-func (c *client) vaultLogin() error {
-	switch c.authMethod {
-	case kubernetesAuthMethod:
-		fd, err := os.Open(kubernetesJwtTokenPath)
-		defer fd.Close()
-		if err != nil {
-			return err
-		}
-		return c.vaultKubernetesLogin(fd)
-	case appRoleAuthMethod:
-		fallthrough
-	default:
-		return c.vaultAppRoleLogin()
-	}
+	vclient             *api.Client
+	logical             *api.Logical
+	roleID              string
+	authMethod          string
+	secretID            string
+	secretIDFile        string
+	kubernetesRole      string
+	maxTokenTTL         int64
+	renewThresholdRatio float64
+	renewGracePeriod    time.Duration
+	tokenPollingPeriod  time.Duration
+	tokenPollingJitter  int32
+	renewMaxBackoff     time.Duration
+	treeMaxDepth        int
+	treeSeparator       string
+	maxSecretValueSize  int
+	paths               pathFilter
+	renewTTLIncrement   int
+	engine              engine
+	mountEngines        map[string]engine
+	engineDetector      engineDetector
+	defaultKey          string
+	approlePath         string
+	kubernetesPath      string
+	kubernetesJWTPath   string
+	jwtRole             string
+	jwtPath             string
+	jwtMountPath        string
+	username            string
+	password            string
+	passwordFile        string
+	userpassPath        string
+	certRole            string
+	certMountPath       string
+	awsRole             string
+	awsMountPath        string
+	awsSTSEndpoint      string
+	gcpRole             string
+	gcpMountPath        string
+	gcpMetadataEndpoint string
+	azureRole           string
+	azureMountPath      string
+	azureCredentials    *azureCredentialsProvider
+	cloudHTTPClient     *http.Client
+	tokenFile           string
+	tokenFileModTime    time.Time
+	tokenRole           string
+	tokenPolicies       []string
+	maxRetries          int
+	retryBackoff        time.Duration
+	retryMaxBackoff     time.Duration
+	requestTimeout      time.Duration
+	breaker             *circuitBreaker
+	metrics             *vaultMetrics
+	// metricsRegisterer is the registry the metrics are registered in, they are unregistered from it on Close
+	metricsRegisterer   prometheus.Registerer
+	healthPollingPeriod time.Duration
+	failedPolls         int
+	renewalPaused       int32
+	renewalMutex        sync.Mutex
+	batchTokenLogged    int32
+	leaseRenewer        *leaseRenewer
+	health              vaultHealth
+	failover            vaultFailover
+	transport           *http.Transport
+	cancel              context.CancelFunc
+	routines            sync.WaitGroup
+	inflight            sync.WaitGroup
+	roleTokensMutex     sync.Mutex
+	roleTokens          map[string]roleToken
+	roleTokenCreations  map[string]*roleTokenCreation
+	ctx                 context.Context
+	logger              logr.Logger
 }
 
-func (c *client) vaultAppRoleLogin() error {
-	appRole := map[string]interface{}{
-		"role_id":   c.roleID,
-		"secret_id": c.secretID,
+func vaultClient(l logr.Logger, cfg Config) (*client, error) {
+	if l == nil {
+		l = defaultLogger()
 	}
-	resp, err := c.logical.Write(fmt.Sprintf("auth/%s/login", c.approlePath), appRole)
-	if err != nil {
-		return err
+	logger := l.WithName("vault").WithValues(
+		"vault_url", cfg.VaultURL,
+		"vault_engine", cfg.VaultEngine)
+
+	if cfg.VaultTLSSkipVerify {
+		logger.Info("WARNING: vault TLS certificate verification is disabled, this is insecure and must not be used in production")
 	}
-	c.vclient.SetToken(resp.Auth.ClientToken)
-	return nil
-}
 
-func (c *client) vaultKubernetesLogin(podSATokenReader io.Reader) error {
-	jwt, err := ioutil.ReadAll(podSATokenReader)
-	if err != nil {
-		return err
+	if warning := renewTTLIncrementWarning(cfg.VaultRenewTTLIncrement, cfg.VaultMaxTokenTTL+int64(cfg.VaultRenewGracePeriod/time.Second)); warning != "" {
+		logger.Info("WARNING: "+warning, "vault_renew_ttl_increment", cfg.VaultRenewTTLIncrement, "vault_max_token_ttl", cfg.VaultMaxTokenTTL, "vault_renew_grace_period", cfg.VaultRenewGracePeriod.String())
+	}
+
+	if cfg.VaultHealthFailureThreshold < 0 || cfg.VaultHealthSuccessThreshold < 0 {
+		err := &errors.BackendConfigError{ErrType: errors.BackendConfigErrorType, Backend: vaultBackendName, Reason: "health check thresholds can't be negative"}
+		logger.Error(err, "invalid vault health config")
+		return nil, err
+	}
+
+	if cfg.VaultStartupRetries < 0 {
+		err := &errors.BackendConfigError{ErrType: errors.BackendConfigErrorType, Backend: vaultBackendName, Reason: "startup retries can't be negative"}
+		logger.Error(err, "invalid vault startup config")
+		return nil, err
+	}
+
+	if cfg.VaultRenewThresholdRatio < 0 || cfg.VaultRenewThresholdRatio >= 1 {
+		err := &errors.BackendConfigError{ErrType: errors.BackendConfigErrorType, Backend: vaultBackendName, Reason: "renew threshold ratio must be between 0 and 1"}
+		logger.Error(err, "invalid vault token renewal config")
+		return nil, err
+	}
+
+	if cfg.VaultRenewGracePeriod < 0 {
+		err := &errors.BackendConfigError{ErrType: errors.BackendConfigErrorType, Backend: vaultBackendName, Reason: "renew grace period can't be negative"}
+		logger.Error(err, "invalid vault token renewal config")
+		return nil, err
+	}
+
+	if cfg.VaultAuthMethod == certAuthMethod && (cfg.VaultClientCert == "" || cfg.VaultClientKey == "") {
+		err := &errors.BackendConfigError{ErrType: errors.BackendConfigErrorType, Backend: vaultBackendName, Reason: "cert auth method requires a client certificate and key"}
+		logger.Error(err, "invalid vault auth config")
+		return nil, err
 	}
-	kubernetes := map[string]interface{}{
-		"jwt":  string(jwt),
-		"role": c.kubernetesRole,
+
+	if cfg.VaultTokenFile != "" && (cfg.VaultTokenRole != "" || len(cfg.VaultTokenPolicies) > 0) {
+		err := &errors.BackendConfigError{ErrType: errors.BackendConfigErrorType, Backend: vaultBackendName, Reason: "child tokens can't be created with a token file, Vault Agent manages its token"}
+		logger.Error(err, "invalid vault auth config")
+		return nil, err
 	}
-	resp, err := c.logical.Write(fmt.Sprintf("auth/%s/login", c.kubernetesPath), kubernetes)
+
+	if cfg.VaultWrappedTokenPath != "" && cfg.VaultWrappedToken == "" {
+		err := &errors.BackendConfigError{ErrType: errors.BackendConfigErrorType, Backend: vaultBackendName, Reason: "wrapped token path requires a wrapped token"}
+		logger.Error(err, "invalid vault auth config")
+		return nil, err
+	}
+
+	if (cfg.VaultAuthMethod == gcpAuthMethod && cfg.VaultGCPRole == "") || (cfg.VaultAuthMethod == azureAuthMethod && cfg.VaultAzureRole == "") {
+		err := &errors.BackendConfigError{ErrType: errors.BackendConfigErrorType, Backend: vaultBackendName, Reason: fmt.Sprintf("%s auth method requires a role", cfg.VaultAuthMethod)}
+		logger.Error(err, "invalid vault auth config")
+		return nil, err
+	}
+
+	namespace, subsystem := metricsNames(cfg)
+	if !metricNameRegexp.MatchString(namespace) || !metricNameRegexp.MatchString(subsystem) {
+		err := &errors.BackendConfigError{ErrType: errors.BackendConfigErrorType, Backend: vaultBackendName, Reason: "metrics namespace and subsystem must be valid prometheus metric names"}
+		logger.Error(err, "invalid metrics configuration")
+		return nil, err
+	}
+	durationBuckets := prometheus.DefBuckets
+	if len(cfg.VaultMetricsDurationBuckets) > 0 {
+		for i := 1; i < len(cfg.VaultMetricsDurationBuckets); i++ {
+			if cfg.VaultMetricsDurationBuckets[i] <= cfg.VaultMetricsDurationBuckets[i-1] {
+				err := &errors.BackendConfigError{ErrType: errors.BackendConfigErrorType, Backend: vaultBackendName, Reason: "metrics duration buckets must be in increasing order"}
+				logger.Error(err, "invalid metrics configuration")
+				return nil, err
+			}
+		}
+		durationBuckets = cfg.VaultMetricsDurationBuckets
+	}
+	// The metrics are registered once the client is ready, but responses are counted from the first request
+	collectors := newVaultCollectors(namespace, subsystem, durationBuckets)
+
+	transport, err := newVaultTransport(cfg)
 	if err != nil {
-		return err
+		logger.Error(err, "unable to setup vault http transport")
+		return nil, err
 	}
-	c.vclient.SetToken(resp.Auth.ClientToken)
-	return nil
-}
 
-func vaultClient(l logr.Logger, cfg Config) (*client, error) {
-	logger := l.WithName("vault").WithValues(
-		"vault_url", cfg.VaultURL,
-		"vault_engine", cfg.VaultEngine)
+	addresses := vaultAddresses(cfg)
+	if len(addresses) == 0 {
+		addresses = []string{cfg.VaultURL}
+	}
+
+	if req, err := http.NewRequest(http.MethodGet, addresses[0], nil); err == nil {
+		if proxyURL, err := transport.Proxy(req); err == nil && proxyURL != nil {
+			logger.Info("reaching vault through a proxy", "vault_proxy", redactProxyURL(proxyURL.String()))
+		}
+	}
+	logger.V(1).Info("vault http transport settings",
+		"max_idle_conns", transport.MaxIdleConns,
+		"max_conns_per_host", transport.MaxConnsPerHost,
+		"idle_conn_timeout", transport.IdleConnTimeout.String())
 
-	httpClient := new(http.Client)
+	httpClient := &http.Client{Transport: &responseMetricsTransport{next: &requestIDTransport{next: transport}, responsesTotal: collectors.responsesTotal}}
 	httpClient.Timeout = cfg.BackendTimeout
 
-	vclient, err := api.NewClient(&api.Config{Address: cfg.VaultURL, HttpClient: httpClient})
+	vclient, err := api.NewClient(&api.Config{Address: addresses[0], HttpClient: httpClient})
 
 	if err != nil {
 		logger.Error(err, "unable to create vault api client")
 		return nil, err
 	}
 
+	userAgent := cfg.VaultUserAgent
+	if userAgent == "" {
+		userAgent = defaultUserAgent
+	}
+	vclient.SetHeaders(http.Header{"User-Agent": []string{userAgent}})
+
+	// An empty namespace targets the root namespace
+	if cfg.VaultNamespace != "" {
+		vclient.SetNamespace(cfg.VaultNamespace)
+		logger = logger.WithValues("vault_namespace", cfg.VaultNamespace)
+	}
+
 	logical := vclient.Logical()
 
-	engine, err := newEngine(cfg.VaultEngine)
+	kubernetesPath := cfg.VaultKubernetesPath
+	if kubernetesPath == "" {
+		kubernetesPath = defaultKubernetesPath
+	}
+
+	kubernetesJWTPath := cfg.VaultKubernetesJWTPath
+	if kubernetesJWTPath == "" {
+		kubernetesJWTPath = kubernetesJwtTokenPath
+	}
+
+	jwtMountPath := cfg.VaultJWTMountPath
+	if jwtMountPath == "" {
+		jwtMountPath = defaultJWTMountPath
+	}
+
+	userpassPath := cfg.VaultUserpassPath
+	if userpassPath == "" {
+		userpassPath = defaultUserpassPath
+	}
+
+	certMountPath := cfg.VaultCertMountPath
+	if certMountPath == "" {
+		certMountPath = defaultCertMountPath
+	}
+
+	treeMaxDepth := cfg.VaultTreeMaxDepth
+	if treeMaxDepth <= 0 {
+		treeMaxDepth = defaultTreeMaxDepth
+	}
+
+	maxSecretValueSize := cfg.VaultMaxSecretValueSize
+	if maxSecretValueSize <= 0 {
+		maxSecretValueSize = defaultMaxSecretValueSize
+	}
+
+	treeSeparator := cfg.VaultTreeSeparator
+	if treeSeparator == "" {
+		treeSeparator = defaultTreeSeparator
+	}
+
+	awsMountPath := cfg.VaultAWSMountPath
+	if awsMountPath == "" {
+		awsMountPath = defaultAWSMountPath
+	}
+
+	gcpMountPath := cfg.VaultGCPMountPath
+	if gcpMountPath == "" {
+		gcpMountPath = defaultGCPMountPath
+	}
+
+	azureMountPath := cfg.VaultAzureMountPath
+	if azureMountPath == "" {
+		azureMountPath = defaultAzureMountPath
+	}
+
+	azureResource := cfg.VaultAzureResource
+	if azureResource == "" {
+		azureResource = defaultAzureResource
+	}
+
+	vaultEngine := cfg.VaultEngine
+	if vaultEngine == autoEngineName {
+		vaultEngine = kvEngineV2Name
+	}
+	engine, err := newEngine(vaultEngine)
 	if err != nil {
 		logger.Error(err, "unable to setup vault engine")
 		return nil, err
 	}
+	if cfg.VaultKVMount != "" {
+		engine = engineWithMount(engine, cfg.VaultKVMount)
+	}
 
-	client := client{
-		vclient:            vclient,
-		logical:            logical,
-		authMethod:         cfg.VaultAuthMethod,
-		roleID:             cfg.VaultRoleID,
-		secretID:           cfg.VaultSecretID,
-		kubernetesRole:     cfg.VaultKubernetesRole,
-		maxTokenTTL:        cfg.VaultMaxTokenTTL,
-		tokenPollingPeriod: cfg.VaultTokenPollingPeriod,
-		renewTTLIncrement:  cfg.VaultRenewTTLIncrement,
-		engine:             engine,
-		approlePath:        cfg.VaultApprolePath,
-		kubernetesPath:     cfg.VaultKubernetesPath,
-	}
-
-	err = client.vaultLogin()
+	mountEngines, err := newMountEngines(cfg.VaultMountEngines)
 	if err != nil {
-		logger.Error(err, "unable to login to vault with provided credentials")
+		logger.Error(err, "unable to setup vault mount engines")
 		return nil, err
 	}
 
-	sys := vclient.Sys()
-	health, err := sys.Health()
+	defaultKey := cfg.VaultDefaultKey
+	if defaultKey == "" {
+		defaultKey = defaultSecretKey
+	}
 
+	cloudHTTPClient := &http.Client{Timeout: cfg.BackendTimeout}
+	var azureCredentials *azureCredentialsProvider
+	if cfg.VaultAuthMethod == azureAuthMethod {
+		azureCredentials, err = newAzureCredentialsProvider(cloudHTTPClient, azureResource)
+		if err != nil {
+			logger.Error(err, "unable to setup azure credentials")
+			return nil, &errors.BackendConfigError{ErrType: errors.BackendConfigErrorType, Backend: vaultBackendName, Reason: err.Error()}
+		}
+	}
+	client := client{
+		vclient:             vclient,
+		logical:             logical,
+		authMethod:          cfg.VaultAuthMethod,
+		roleID:              cfg.VaultRoleID,
+		secretID:            cfg.VaultSecretID,
+		secretIDFile:        cfg.VaultSecretIDFile,
+		kubernetesRole:      cfg.VaultKubernetesRole,
+		maxTokenTTL:         cfg.VaultMaxTokenTTL,
+		renewThresholdRatio: cfg.VaultRenewThresholdRatio,
+		renewGracePeriod:    cfg.VaultRenewGracePeriod,
+		tokenPollingPeriod:  cfg.VaultTokenPollingPeriod,
+		tokenPollingJitter:  int32(cfg.VaultTokenPollingJitter),
+		renewMaxBackoff:     cfg.VaultRenewMaxBackoff,
+		treeMaxDepth:        treeMaxDepth,
+		treeSeparator:       treeSeparator,
+		maxSecretValueSize:  maxSecretValueSize,
+		paths:               pathFilter{allowed: cfg.VaultAllowedPathPrefixes, denied: cfg.VaultDeniedPathPrefixes},
+		renewTTLIncrement:   cfg.VaultRenewTTLIncrement,
+		engine:              engine,
+		mountEngines:        mountEngines,
+		engineDetector:      engineDetector{enabled: cfg.VaultEngine == autoEngineName, fallback: engine},
+		defaultKey:          defaultKey,
+		approlePath:         cfg.VaultApprolePath,
+		kubernetesPath:      kubernetesPath,
+		kubernetesJWTPath:   kubernetesJWTPath,
+		jwtRole:             cfg.VaultJWTRole,
+		jwtPath:             cfg.VaultJWTPath,
+		jwtMountPath:        jwtMountPath,
+		username:            cfg.VaultUsername,
+		password:            cfg.VaultPassword,
+		passwordFile:        cfg.VaultPasswordFile,
+		userpassPath:        userpassPath,
+		certRole:            cfg.VaultCertRole,
+		certMountPath:       certMountPath,
+		awsRole:             cfg.VaultAWSRole,
+		awsMountPath:        awsMountPath,
+		awsSTSEndpoint:      awsSTSEndpoint,
+		gcpRole:             cfg.VaultGCPRole,
+		gcpMountPath:        gcpMountPath,
+		gcpMetadataEndpoint: gcpMetadataEndpoint,
+		azureRole:           cfg.VaultAzureRole,
+		azureMountPath:      azureMountPath,
+		azureCredentials:    azureCredentials,
+		cloudHTTPClient:     cloudHTTPClient,
+		tokenFile:           cfg.VaultTokenFile,
+		tokenRole:           cfg.VaultTokenRole,
+		tokenPolicies:       cfg.VaultTokenPolicies,
+		maxRetries:          cfg.VaultMaxRetries,
+		retryBackoff:        cfg.VaultRetryBackoff,
+		retryMaxBackoff:     cfg.VaultRetryMaxBackoff,
+		requestTimeout:      cfg.VaultRequestTimeout,
+		healthPollingPeriod: cfg.VaultHealthPollingPeriod,
+		health:              newVaultHealth(cfg),
+		failover:            vaultFailover{addresses: addresses, minInterval: cfg.VaultFailoverInterval},
+		transport:           transport,
+		ctx:                 context.Background(),
+		logger:              logger,
+	}
+
+	client.leaseRenewer = newLeaseRenewer(cfg.VaultMaxTokenTTL, cfg.VaultRenewTTLIncrement, client.renewLease, logger)
+
+	if len(addresses) > 1 {
+		client.selectAddress()
+	}
+
+	health, loginDuration, err := client.connect(cfg)
 	if err != nil {
-		logger.Error(err, "could not get health information about vault cluster")
 		return nil, err
 	}
 
@@ -150,87 +419,476 @@ func vaultClient(l logr.Logger, cfg Config) (*client, error) {
 	logger.Info("successfully logged into vault cluster")
 
 	client.logger = logger
+	client.leaseRenewer.logger = logger
+
+	client.metrics = newVaultMetrics(collectors, addresses[0], health.Version, cfg.VaultEngine, health.ClusterID, health.ClusterName, cfg.VaultNamespace)
+	client.metrics.pathLabels = cfg.VaultMetricsPathLabels
+	client.metrics.identity = cfg.VaultMetricsIdentity
+	client.metrics.normalizePath = newPathNormalizer(cfg.VaultMetricsPathDepth)
+	client.metrics.engineName = client.engineName
+	client.leaseRenewer.metrics = client.metrics
+	client.breaker = newCircuitBreaker(cfg.VaultBreakerThreshold, cfg.VaultBreakerWindow, cfg.VaultBreakerCoolDown, client.metrics, logger)
 
-	vMetrics = newVaultMetrics(cfg.VaultURL, health.Version, cfg.VaultEngine, health.ClusterID, health.ClusterName)
+	client.metrics.updateVaultMaxTokenTTLMetric(cfg.VaultMaxTokenTTL)
+	client.metrics.updateVaultHealthMetrics(health)
+	client.metrics.updateVaultLoginDurationMetric(loginDuration)
+	// Metrics don't exist yet while connecting, so the startup login is recorded as a whole
+	loginOperation := vaultLoginOperationName
+	if cfg.VaultWrappedToken != "" {
+		loginOperation = vaultUnwrapOperationName
+	}
+	client.observeTokenRequest(loginOperation, loginDuration, nil)
+	if client.childTokens() {
+		client.metrics.updateVaultChildTokensCreatedTotalMetric(client.tokenRole)
+	}
+	client.metrics.updateVaultLoginSuccessesTotalMetric()
 
-	vMetrics.updateVaultMaxTokenTTLMetric(cfg.VaultMaxTokenTTL)
+	// Refined with the ratio and the period of the token on every poll
+	client.metrics.updateVaultTokenRenewThresholdMetric(client.renewFloor())
+	logger.Info("vault token renewal threshold", "vault_token_renew_threshold", client.renewFloor(), "vault_max_token_ttl", cfg.VaultMaxTokenTTL, "vault_renew_grace_period", cfg.VaultRenewGracePeriod.String())
+
+	if err := client.checkTokenMinTTL(); err != nil {
+		logger.Error(err, "refusing to start with a short lived vault token", "vault_token_polling_period", cfg.VaultTokenPollingPeriod.String())
+		return nil, err
+	}
+
+	registerer := metricsRegisterer(cfg)
+	if err := registerCollectors(registerer, collectors.collectors()); err != nil {
+		logger.Error(err, "unable to register vault metrics")
+		return nil, err
+	}
+	client.metricsRegisterer = registerer
 
 	return &client, err
 }
 
+// connect logs in and checks the health of Vault, retrying up to VaultStartupRetries times, so secrets-manager can
+// start before Vault is reachable or its auth method is configured, e.g. while bootstrapping a cluster. Once
+// logged in only the health check is retried, as wrapped tokens can only be unwrapped once
+func (c *client) connect(cfg Config) (*api.HealthResponse, time.Duration, error) {
+	retryInterval := cfg.VaultStartupRetryInterval
+	if retryInterval <= 0 {
+		retryInterval = defaultStartupRetryInterval
+	}
+	var loginDuration time.Duration
+	loggedIn := false
+	for attempt := 0; ; attempt++ {
+		var err error
+		if !loggedIn {
+			loginStart := time.Now()
+			if cfg.VaultWrappedToken != "" {
+				if cfg.VaultWrappedTokenPath != "" {
+					// Lookups don't use the token, so failed checks are retried as any other login
+					err = c.ValidateWrappingToken(cfg.VaultWrappedToken, cfg.VaultWrappedTokenPath)
+				}
+				if err == nil {
+					err = c.vaultUnwrapToken(cfg.VaultWrappedToken)
+				}
+			} else {
+				err = c.vaultLogin()
+			}
+			if err == nil {
+				err = c.createChildToken()
+			}
+			loginDuration = time.Since(loginStart)
+			loggedIn = err == nil
+		}
+		if loggedIn {
+			var health *api.HealthResponse
+			if health, err = c.checkHealth(); err == nil {
+				return health, loginDuration, nil
+			}
+		}
+		if attempt >= cfg.VaultStartupRetries {
+			if !loggedIn {
+				c.logger.Error(err, "unable to login to vault with provided credentials")
+			} else {
+				c.logger.Error(err, "could not get health information about vault cluster")
+			}
+			return nil, 0, err
+		}
+		c.logger.Info("unable to connect to vault at startup, retrying", "attempt", attempt+1, "vault_startup_retries", cfg.VaultStartupRetries, "retry_interval", retryInterval.String(), "logged_in", loggedIn, "error", err.Error())
+		time.Sleep(retryInterval)
+	}
+}
+
+// checkTokenMinTTL fails when the token expires before the renewal loop could check it a few times and it can't
+// be renewed, instead of letting reads start failing once it silently expired. Renewable tokens only get a warning.
+// Tokens that can't be looked up are left to the renewal loop, and tokens read from a file to Vault Agent
+func (c *client) checkTokenMinTTL() error {
+	if c.tokenFile != "" {
+		return nil
+	}
+	token, err := c.getToken()
+	if err != nil {
+		c.logger.Info("WARNING: unable to check the vault token ttl", "error", err.Error())
+		return nil
+	}
+	ttl, err := c.getTokenTTL(token)
+	if err != nil {
+		c.logger.Info("WARNING: unable to check the vault token ttl", "error", err.Error())
+		return nil
+	}
+	minTTL := int64((minTokenTTLPollingPeriods * c.tokenPollingPeriod).Seconds())
+	// A ttl of 0 never expires, e.g. root tokens
+	if ttl == 0 || ttl >= minTTL {
+		return nil
+	}
+	if renewable, _ := token.TokenIsRenewable(); renewable {
+		c.logger.Info("WARNING: vault token ttl is shorter than the minimum, it may expire before it is renewed", "vault_token_ttl", ttl, "vault_token_min_ttl", minTTL)
+		return nil
+	}
+	return &errors.VaultTokenTTLTooShortError{ErrType: errors.VaultTokenTTLTooShortErrorType, TTL: ttl, MinTTL: minTTL}
+}
+
 func (c *client) getToken() (*api.Secret, error) {
 	auth := c.vclient.Auth()
-	lookup, err := auth.Token().LookupSelf()
+	var lookup *api.Secret
+	err := c.withRetry(c.ctx, vaultLookupSelfOperationName, func() error {
+		var err error
+		start := time.Now()
+		lookup, err = auth.Token().LookupSelf()
+		c.observeTokenRequest(vaultLookupSelfOperationName, time.Since(start), err)
+		return err
+	})
 	if err != nil {
-		vMetrics.updateVaultTokenRenewalErrorsTotalMetric(vaultLookupSelfOperationName, errors.UnknownErrorType)
+		c.metrics.updateVaultTokenRenewalErrorsTotalMetric(vaultLookupSelfOperationName, errors.UnknownErrorType)
 		return nil, err
 	}
+	if lookup != nil && lookup.Data != nil {
+		entityID, _ := lookup.Data["entity_id"].(string)
+		displayName, _ := lookup.Data["display_name"].(string)
+		c.metrics.updateVaultTokenIdentityMetric(entityID, displayName)
+	}
 	return lookup, nil
 }
 
+// getTokenTTL returns the TTL of a token lookup response. A response without a numeric ttl returns a
+// VaultTokenMalformedError instead of making the renewer panic
 func (c *client) getTokenTTL(token *api.Secret) (int64, error) {
-	var ttl int64
-	ttl, err := token.Data["ttl"].(json.Number).Int64()
+	if token == nil || token.Data == nil {
+		return -1, c.tokenMalformed("the response has no data")
+	}
+	tokenType := getTokenType(token)
+	renewable, _ := token.TokenIsRenewable()
+	c.health.recordToken(renewable, tokenType)
+	if tokenType == batchTokenType && atomic.CompareAndSwapInt32(&c.batchTokenLogged, 0, 1) {
+		c.logger.Info("vault token is a batch token, it won't be renewed but replaced logging in again before it expires")
+	}
+	value, ok := token.Data["ttl"].(json.Number)
+	if !ok {
+		if token.Data["ttl"] == nil {
+			return -1, c.tokenMalformed("ttl is missing")
+		}
+		return -1, c.tokenMalformed(fmt.Sprintf("ttl is a %T instead of a number", token.Data["ttl"]))
+	}
+	ttl, err := value.Int64()
 	if err != nil {
-		return -1, err
+		return -1, c.tokenMalformed(fmt.Sprintf("ttl %s is not an integer", value))
 	}
-	vMetrics.updateVaultTokenTTLMetric(ttl)
+	c.metrics.updateVaultTokenTTLMetric(ttl, tokenType)
+	c.health.recordTokenTTL(ttl)
 	return ttl, nil
 }
 
+// getTokenType returns the type of a token lookup response, service or batch. Vault versions without
+// batch tokens don't send it, so service is assumed
+func getTokenType(token *api.Secret) string {
+	if tokenType, ok := token.Data["type"].(string); ok && tokenType != "" {
+		return tokenType
+	}
+	return serviceTokenType
+}
+
+// getTokenPeriod returns the period of a periodic token lookup response in seconds, or 0 if the token isn't periodic
+func getTokenPeriod(token *api.Secret) int64 {
+	return getTokenInt(token, "period")
+}
+
+// getTokenCreationTTL returns the TTL the token was issued with, it doesn't change when the token is renewed
+func getTokenCreationTTL(token *api.Secret) int64 {
+	return getTokenInt(token, "creation_ttl")
+}
+
+func getTokenInt(token *api.Secret, field string) int64 {
+	value, ok := token.Data[field].(json.Number)
+	if !ok {
+		return 0
+	}
+	number, err := value.Int64()
+	if err != nil {
+		return 0
+	}
+	return number
+}
+
+func (c *client) tokenMalformed(reason string) error {
+	c.metrics.updateVaultTokenMalformedTotalMetric()
+	return &errors.VaultTokenMalformedError{ErrType: errors.VaultTokenMalformedErrorType, Reason: reason}
+}
+
+// renewToken renews the token by renewTTLIncrement seconds, or by its period if it's a periodic token
 func (c *client) renewToken(token *api.Secret) error {
 	isRenewable, err := token.TokenIsRenewable()
 	if err != nil {
-		vMetrics.updateVaultTokenRenewalErrorsTotalMetric(vaultIsRenewableOperationName, errors.UnknownErrorType)
+		c.metrics.updateVaultTokenRenewalErrorsTotalMetric(vaultIsRenewableOperationName, errors.UnknownErrorType)
 		return err
 	}
 	if !isRenewable {
-		vMetrics.updateVaultTokenRenewalErrorsTotalMetric(vaultIsRenewableOperationName, errors.VaultTokenNotRenewableErrorType)
+		c.metrics.updateVaultTokenRenewalErrorsTotalMetric(vaultIsRenewableOperationName, errors.VaultTokenNotRenewableErrorType)
 		err = &errors.VaultTokenNotRenewableError{ErrType: errors.VaultTokenNotRenewableErrorType}
 		return err
 	}
+	increment := c.renewTTLIncrement
+	if period := getTokenPeriod(token); period > 0 {
+		increment = int(period)
+	}
 	auth := c.vclient.Auth()
-	if _, err = auth.Token().RenewSelf(c.renewTTLIncrement); err != nil {
-		vMetrics.updateVaultTokenRenewalErrorsTotalMetric(vaultRenewSelfOperationName, errors.UnknownErrorType)
+	var renewed *api.Secret
+	err = c.withRetry(c.ctx, vaultRenewSelfOperationName, func() error {
+		var err error
+		start := time.Now()
+		renewed, err = auth.Token().RenewSelf(increment)
+		c.observeTokenRequest(vaultRenewSelfOperationName, time.Since(start), err)
 		return err
+	})
+	if err != nil {
+		c.metrics.updateVaultTokenRenewalErrorsTotalMetric(vaultRenewSelfOperationName, errors.UnknownErrorType)
+		return err
+	}
+	c.health.recordRenewal(time.Now())
+	if renewed != nil && renewed.Auth != nil {
+		renewedTTL := int64(renewed.Auth.LeaseDuration)
+		c.health.recordTokenTTL(renewedTTL)
+		// Vault answers successfully even when the TTL can't grow past the token max TTL anymore
+		if ttl, err := token.TokenTTL(); err == nil && renewedTTL <= int64(ttl.Seconds()) {
+			c.metrics.updateVaultTokenRenewNoProgressTotalMetric()
+			return &errors.VaultTokenRenewNoProgressError{ErrType: errors.VaultTokenRenewNoProgressErrorType, TTL: renewedTTL}
+		}
 	}
 	return nil
 }
 
-func (c *client) renewalLoop() {
+// renewalLoop checks the token TTL renewing it, or logging in again, when it's close to expire, and then
+// renews the leases of dynamic secrets. When the token is read from a file it is reloaded on changes instead. Token errors are returned after updating the consecutive failed polls metric,
+// the renewer keeps polling anyway
+func (c *client) renewalLoop() error {
+	if atomic.LoadInt32(&c.renewalPaused) == 1 {
+		return nil
+	}
+	c.renewalMutex.Lock()
+	defer c.renewalMutex.Unlock()
+	var err error
+	if c.tokenFile != "" {
+		err = c.reloadTokenFile()
+	} else {
+		err = c.checkToken()
+	}
+	if err != nil {
+		c.failedPolls++
+	} else {
+		c.failedPolls = 0
+	}
+	c.metrics.updateVaultTokenRenewalConsecutiveFailuresMetric(c.failedPolls)
+	c.leaseRenewer.renewAll()
+	return err
+}
+
+// RenewNow renews the token right away instead of waiting for the next renewal check, e.g. after its policies
+// changed. Tokens that can't be renewed are replaced logging in again, and tokens read from a file are reloaded.
+// It's safe to call along with the background renewer, even while it's paused
+func (c *client) RenewNow() error {
+	c.renewalMutex.Lock()
+	defer c.renewalMutex.Unlock()
+	c.logger.Info("renewing vault token on demand")
+	if c.tokenFile != "" {
+		return c.reloadTokenFile()
+	}
+	return c.refreshToken(true)
+}
+
+func (c *client) checkToken() error {
+	return c.refreshToken(false)
+}
+
+// refreshToken renews the token once its ttl drops below the renewal threshold, or right away when forced
+func (c *client) refreshToken(force bool) error {
 	token, err := c.getToken()
 	if err != nil {
 		c.logger.Error(err, "unable to get vault token")
-		c.logger.Info("trying to login to vault again")
-		if err = c.vaultLogin(); err != nil {
-			vMetrics.updateVaultLoginErrorsTotalMetric()
-			c.logger.Error(err, "login error, vault token not obtained")
-		} else {
-			c.logger.Info("login successful, got a new vault token")
-		}
-		return
+		return c.vaultRelogin()
 	}
 
 	ttl, err := c.getTokenTTL(token)
 	if err != nil {
 		c.logger.Error(err, "failed to read vault token TTL")
-	} else if ttl < c.maxTokenTTL {
-		c.logger.Info("vault token is really close to expire", "vault_token_ttl", ttl)
+		return err
+	}
+	if force || ttl < c.renewThreshold(token) {
+		if getTokenType(token) == batchTokenType {
+			c.logger.Info("vault batch token is really close to expire, logging in again", "vault_token_ttl", ttl)
+			return c.vaultRelogin()
+		}
+		if !force {
+			c.logger.Info("vault token is really close to expire", "vault_token_ttl", ttl)
+		}
 		err := c.renewToken(token)
-		if err != nil {
+		var noProgress *errors.VaultTokenRenewNoProgressError
+		if errors.IsVaultTokenNotRenewable(err) {
+			c.logger.Error(err, "vault token can not be renewed anymore")
+			return c.vaultRelogin()
+		} else if stderrors.As(err, &noProgress) {
+			c.logger.Info("WARNING: vault token renewal didn't extend its TTL, it's probably at its max TTL", "vault_token_ttl", noProgress.TTL)
+			return c.vaultRelogin()
+		} else if err != nil {
 			c.logger.Error(err, "failed to renew vault token")
-		} else {
-			c.logger.Info("vault token renewed successfully!")
+			return err
 		}
+		c.logger.Info("vault token renewed successfully!")
+	}
+	return nil
+}
+
+// renewFloor returns the TTL below which every token is renewed: maxTokenTTL plus the grace period, so a slow
+// renewal or a poll delayed by the jitter still happens before the token gets below maxTokenTTL
+func (c *client) renewFloor() int64 {
+	return c.maxTokenTTL + int64(c.renewGracePeriod/time.Second)
+}
+
+// renewThreshold returns the TTL below which the token is renewed. With a renew threshold ratio, the token is
+// renewed once less than that fraction of its creation TTL remains, renewFloor being the floor. Periodic tokens
+// get their TTL reset to the period on every renewal, so they are renewed once half of the period is gone if
+// the threshold is longer
+func (c *client) renewThreshold(token *api.Secret) int64 {
+	threshold := c.renewFloor()
+	if c.renewThresholdRatio > 0 {
+		if relative := int64(float64(getTokenCreationTTL(token)) * c.renewThresholdRatio); relative > threshold {
+			threshold = relative
+		}
+	}
+	if period := getTokenPeriod(token); period > 0 && period/2 < threshold {
+		threshold = period / 2
+	}
+	c.metrics.updateVaultTokenRenewThresholdMetric(threshold)
+	return threshold
+}
+
+// observeTokenRequest records the latency and the result of a login or token request, so a slow Vault is
+// noticed before the token expires
+func (c *client) observeTokenRequest(operation string, duration time.Duration, err error) {
+	result := requestResult(err)
+	c.metrics.updateVaultTokenRequestDurationMetric(operation, result, duration)
+	c.metrics.updateVaultTokenRequestsTotalMetric(operation, result)
+}
+
+// tokenPollingDelay returns the token polling period randomized by +/- tokenPollingJitter percent,
+// so that replicas started at the same time don't poll Vault in lockstep
+func (c *client) tokenPollingDelay() time.Duration {
+	jitter := atomic.LoadInt32(&c.tokenPollingJitter)
+	if jitter <= 0 || c.tokenPollingPeriod <= 0 {
+		return c.tokenPollingPeriod
+	}
+	delta := int64(c.tokenPollingPeriod) * int64(jitter) / 100
+	return c.tokenPollingPeriod + time.Duration(rand.Int63n(2*delta+1)-delta)
+}
+
+// renewalDelay returns the time to wait before the next token renewal poll. The polling delay is doubled
+// for every poll failed in a row, up to renewMaxBackoff, so a degraded Vault isn't flooded with renewals
+func (c *client) renewalDelay() time.Duration {
+	delay := c.tokenPollingDelay()
+	if c.failedPolls == 0 || c.renewMaxBackoff <= 0 {
+		c.metrics.updateVaultTokenRenewalBackoffMetric(0)
+		return delay
+	}
+	for i := 0; i < c.failedPolls && delay < c.renewMaxBackoff; i++ {
+		delay *= 2
+	}
+	if delay > c.renewMaxBackoff {
+		delay = c.renewMaxBackoff
+	}
+	c.logger.Info("vault token renewal failing, backing off", "vault_token_renewal_failures", c.failedPolls, "backoff", delay.String())
+	c.metrics.updateVaultTokenRenewalBackoffMetric(delay)
+	return delay
+}
+
+// ParseRenewTTLIncrement parses a token renew increment given either as a number of seconds, e.g. 600,
+// or as a duration string, e.g. 1h. The increment is returned in seconds, as Vault expects it
+func ParseRenewTTLIncrement(value string) (int, error) {
+	seconds, err := parseSeconds(value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid renew ttl increment %q, expected seconds or a duration like 1h", value)
+	}
+	return int(seconds), nil
+}
+
+// ParseMaxTokenTTL parses the token TTL below which tokens are renewed, given either as a number of
+// seconds, e.g. 300, or as a duration string, e.g. 5m. The TTL is returned in seconds
+func ParseMaxTokenTTL(value string) (int64, error) {
+	seconds, err := parseSeconds(value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid max token ttl %q, expected seconds or a duration like 5m", value)
+	}
+	return seconds, nil
+}
+
+func parseSeconds(value string) (int64, error) {
+	if seconds, err := strconv.ParseInt(value, 10, 64); err == nil {
+		return seconds, nil
+	}
+	duration, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, err
+	}
+	return int64(duration / time.Second), nil
+}
+
+// renewTTLIncrementWarning returns why a renew increment makes no sense, or an empty string if it looks fine.
+// Tokens renewed for less than the renewal floor, maxTokenTTL plus the grace period, are renewed again on every poll
+func renewTTLIncrementWarning(increment int, renewFloor int64) string {
+	switch {
+	case increment <= 0:
+		return "vault renew ttl increment is not positive, vault will renew tokens with their default ttl"
+	case int64(increment) <= renewFloor:
+		return "vault renew ttl increment is not greater than the max token ttl plus the renew grace period, tokens will be renewed on every poll"
+	case time.Duration(increment)*time.Second > vaultMaxTTL:
+		return "vault renew ttl increment is greater than vault default max ttl, vault will likely cap it"
+	}
+	return ""
+}
+
+// TokenRenewer is implemented by backends able to renew their credentials on demand
+type TokenRenewer interface {
+	RenewNow() error
+}
+
+// TokenRenewalPauser is implemented by backends renewing their credentials in the background
+type TokenRenewalPauser interface {
+	PauseTokenRenewal(paused bool)
+}
+
+// PauseTokenRenewal stops or resumes checking and renewing the token, e.g. on standby replicas. The token
+// may expire while paused, then a new one is obtained by the first renewal loop after resuming. Token
+// expiration doesn't make the client unready while paused
+func (c *client) PauseTokenRenewal(paused bool) {
+	value := int32(0)
+	if paused {
+		value = 1
+		c.health.recordTokenTTL(0)
+	}
+	if atomic.SwapInt32(&c.renewalPaused, value) != value {
+		c.logger.Info("vault token renewal state changed", "paused", paused)
 	}
-	return
 }
 
 func (c *client) startTokenRenewer(ctx context.Context) {
+	// Retries backoff is interrupted when shutting down
+	c.ctx = ctx
+	c.routines.Add(1)
 	go func(ctx context.Context) {
+		defer c.routines.Done()
 		for {
 			select {
-			case <-time.After(c.tokenPollingPeriod):
+			case <-time.After(c.renewalDelay()):
 				c.renewalLoop()
 				break
 			case <-ctx.Done():
@@ -242,38 +900,411 @@ func (c *client) startTokenRenewer(ctx context.Context) {
 }
 
 func (c *client) ReadSecret(path string, key string) (string, error) {
+	return c.ReadSecretWithContext(context.Background(), path, key)
+}
+
+// ReadSecretWithContext reads a secret key aborting the request when ctx is done or
+// the configured request timeout elapses, whatever happens first
+func (c *client) ReadSecretWithContext(ctx context.Context, path string, key string) (string, error) {
+	return c.readSecret(ctx, path, key, "", nil)
+}
+
+// ReadSecretVersion reads the given version of a secret. Only engines supporting versioning (KV version 2)
+// can pin a version, version 0 means the latest one.
+func (c *client) ReadSecretVersion(path string, key string, version int) (string, error) {
+	if key == "" {
+		key = c.defaultKey
+	}
+	v := strconv.Itoa(version)
+	if engine := c.engineFor(context.Background(), path); !engine.versioned() {
+		c.metrics.updateVaultSecretReadErrorsTotalMetric(path, key, v, errors.VaultVersioningNotSupportedErrorType)
+		return "", &errors.VaultVersioningNotSupportedError{ErrType: errors.VaultVersioningNotSupportedErrorType, Engine: engine.getName()}
+	}
+	return c.readSecret(context.Background(), path, key, v, map[string][]string{"version": {v}})
+}
+
+// secretMetadataKeys are the KV version 2 metadata fields returned by ReadSecretMetadata
+var secretMetadataKeys = []string{"current_version", "created_time", "updated_time", "custom_metadata"}
+
+// ReadSecretMetadata returns the current version, creation and update times and custom metadata of a secret,
+// with the values as returned by Vault. Only engines supporting versioning (KV version 2) keep metadata
+func (c *client) ReadSecretMetadata(path string) (map[string]interface{}, error) {
+	engine := c.engineFor(context.Background(), path)
+	if !engine.versioned() {
+		c.metrics.updateVaultSecretReadErrorsTotalMetric(path, "", "", errors.VaultVersioningNotSupportedErrorType)
+		return nil, &errors.VaultVersioningNotSupportedError{ErrType: errors.VaultVersioningNotSupportedErrorType, Engine: engine.getName()}
+	}
+	if err := c.checkPath(path); err != nil {
+		c.metrics.updateVaultSecretReadErrorsTotalMetric(path, "", "", errors.PathNotAllowedErrorType)
+		return nil, err
+	}
+
+	var secret *api.Secret
+	err := c.withRetry(context.Background(), vaultReadOperationName, func() error {
+		var err error
+		secret, err = c.logical.Read(engine.metadataPath(path))
+		return err
+	})
+	if err != nil {
+		c.metrics.updateVaultSecretReadErrorsTotalMetric(path, "", "", errors.UnknownErrorType)
+		return nil, err
+	}
+	if secret == nil || secret.Data == nil {
+		c.metrics.updateVaultSecretReadErrorsTotalMetric(path, "", "", errors.BackendSecretNotFoundErrorType)
+		return nil, &errors.BackendSecretNotFoundError{ErrType: errors.BackendSecretNotFoundErrorType, Path: path}
+	}
+
+	metadata := make(map[string]interface{}, len(secretMetadataKeys))
+	for _, k := range secretMetadataKeys {
+		if v, ok := secret.Data[k]; ok {
+			metadata[k] = v
+		}
+	}
+	return metadata, nil
+}
+
+func (c *client) readSecret(ctx context.Context, path string, key string, version string, params map[string][]string) (string, error) {
+	metrics := c.metricsFor(ctx)
 	data := ""
 	if key == "" {
-		key = defaultSecretKey
+		key = c.defaultKey
 	}
 
-	logical := c.logical
-	secret, err := logical.Read(path)
+	secretData, err := c.readSecretData(ctx, path, key, version, params)
 	if err != nil {
-		vMetrics.updateVaultSecretReadErrorsTotalMetric(path, key, errors.UnknownErrorType)
 		return data, err
 	}
 
-	if secret != nil {
-		secretData := c.engine.getData(secret)
-		warnings := secret.Warnings
-		if secretData != nil {
-			if secretData[key] != nil {
-				data = secretData[key].(string)
-			} else {
-				vMetrics.updateVaultSecretReadErrorsTotalMetric(path, key, errors.BackendSecretNotFoundErrorType)
-				err = &errors.BackendSecretNotFoundError{ErrType: errors.BackendSecretNotFoundErrorType, Path: path, Key: key}
-			}
-		} else {
-			for _, w := range warnings {
-				c.logger.Info("secret contains warnings", "vault_secret_warning", w)
-			}
-			vMetrics.updateVaultSecretReadErrorsTotalMetric(path, key, errors.BackendSecretNotFoundErrorType)
-			err = &errors.BackendSecretNotFoundError{ErrType: errors.BackendSecretNotFoundErrorType, Path: path, Key: key}
+	if secretData[key] != nil {
+		value, ok := secretData[key].(string)
+		if !ok {
+			metrics.updateVaultSecretReadErrorsTotalMetric(path, key, version, errors.BackendSecretTypeErrorType)
+			return data, &errors.BackendSecretTypeError{ErrType: errors.BackendSecretTypeErrorType, Path: path, Key: key, Type: fmt.Sprintf("%T", secretData[key])}
+		}
+		if err := c.checkValueSize(ctx, path, key, version, value); err != nil {
+			return data, err
 		}
+		data = value
+		metrics.updateVaultSecretReadSuccessesTotalMetric(path, key, version)
+		metrics.updateVaultSecretLastSyncMetric(path)
 	} else {
-		vMetrics.updateVaultSecretReadErrorsTotalMetric(path, key, errors.BackendSecretNotFoundErrorType)
+		metrics.updateVaultSecretReadErrorsTotalMetric(path, key, version, errors.BackendSecretNotFoundErrorType)
 		err = &errors.BackendSecretNotFoundError{ErrType: errors.BackendSecretNotFoundErrorType, Path: path, Key: key}
 	}
 	return data, err
 }
+
+// ReadSecretBytes reads a secret key returning its bytes decoded with encoding. Binary values stored as base64
+// (TLS keys, keytabs...) are read with Base64EncodingType, while TextEncodingType, or no encoding, returns the
+// value as is. Values are never guessed to be base64, as plain text such as "test" is valid base64 too
+func (c *client) ReadSecretBytes(path string, key string, encoding string) ([]byte, error) {
+	decoder, err := NewDecoder(encoding)
+	if err != nil {
+		return nil, err
+	}
+	data, err := c.ReadSecret(path, key)
+	if err != nil {
+		return nil, err
+	}
+	return decoder.DecodeString(data)
+}
+
+// ReadSecretAllKeys reads every key stored in a secret path with a single request to Vault.
+// Every key is checked, values that are not strings failing with a BackendSecretTypeError, and when several keys
+// fail their errors are returned together in a BackendSecretKeysError
+func (c *client) ReadSecretAllKeys(path string) (map[string]string, error) {
+	return c.ReadSecretAllKeysWithContext(context.Background(), path)
+}
+
+// ReadSecretAllKeysWithContext is ReadSecretAllKeys sharing the read with the other reads of the path made
+// with ctx, when it's returned by WithSharedReads
+func (c *client) ReadSecretAllKeysWithContext(ctx context.Context, path string) (map[string]string, error) {
+	secretData, err := c.readSecretData(ctx, path, "", "", nil)
+	if err != nil {
+		return nil, err
+	}
+
+	// Keys are checked in order, so errors are always reported the same way
+	keys := make([]string, 0, len(secretData))
+	for k := range secretData {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	data := make(map[string]string, len(secretData))
+	var errs []error
+	for _, k := range keys {
+		value, ok := secretData[k].(string)
+		if !ok {
+			c.metricsFor(ctx).updateVaultSecretReadErrorsTotalMetric(path, k, "", errors.BackendSecretTypeErrorType)
+			errs = append(errs, &errors.BackendSecretTypeError{ErrType: errors.BackendSecretTypeErrorType, Path: path, Key: k, Type: fmt.Sprintf("%T", secretData[k])})
+			continue
+		}
+		if err := c.checkValueSize(ctx, path, k, "", value); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		data[k] = value
+	}
+	if len(errs) == 1 {
+		return nil, errs[0]
+	}
+	if len(errs) > 1 {
+		return nil, &errors.BackendSecretKeysError{ErrType: errors.BackendSecretKeysErrorType, Path: path, Errs: errs}
+	}
+	metrics := c.metricsFor(ctx)
+	metrics.updateVaultSecretReadSuccessesTotalMetric(path, "", "")
+	metrics.updateVaultSecretLastSyncMetric(path)
+	return data, nil
+}
+
+// checkValueSize fails values larger than maxSecretValueSize, e.g. a path pointing at a whole bundle by
+// mistake, before they make the Kubernetes secret too large to be stored
+func (c *client) checkValueSize(ctx context.Context, path string, key string, version string, value string) error {
+	if len(value) <= c.maxSecretValueSize {
+		return nil
+	}
+	metrics := c.metricsFor(ctx)
+	metrics.updateVaultSecretReadErrorsTotalMetric(path, key, version, errors.BackendSecretTooLargeErrorType)
+	metrics.updateVaultSecretTooLargeTotalMetric(path, key)
+	return &errors.BackendSecretTooLargeError{ErrType: errors.BackendSecretTooLargeErrorType, Path: path, Key: key, Size: len(value), MaxSize: c.maxSecretValueSize}
+}
+
+// readSecretData reads a secret path and returns its data, logging any warnings Vault sent back
+// when the secret could not be found. Reads of the latest version made with a context from WithSharedReads
+// share the response with any other read of the path made with it
+func (c *client) readSecretData(ctx context.Context, path string, key string, version string, params map[string][]string) (map[string]interface{}, error) {
+	metrics := c.metricsFor(ctx)
+	var secret *api.Secret
+	var secretData map[string]interface{}
+	var err error
+	if reads := sharedReadsFromContext(ctx); reads != nil && version == "" && len(params) == 0 {
+		secret, secretData, err = reads.do(VaultRoleFromContext(ctx)+"\x00"+VaultNamespaceFromContext(ctx)+"\x00"+path, func() (*api.Secret, map[string]interface{}, error) {
+			return c.decodeSecret(ctx, path, key, version, params)
+		})
+	} else {
+		secret, secretData, err = c.decodeSecret(ctx, path, key, version, params)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if secretData != nil {
+		return secretData, nil
+	}
+
+	if secret != nil {
+		for _, w := range secret.Warnings {
+			c.logger.Info("secret contains warnings", "vault_secret_warning", w)
+		}
+	}
+	metrics.updateVaultSecretReadErrorsTotalMetric(path, key, version, errors.BackendSecretNotFoundErrorType)
+	return nil, &errors.BackendSecretNotFoundError{ErrType: errors.BackendSecretNotFoundErrorType, Path: path, Key: key}
+}
+
+// decodeSecret reads a secret path and returns the response along with the data decoded by its engine, nil
+// when nothing was found
+func (c *client) decodeSecret(ctx context.Context, path string, key string, version string, params map[string][]string) (*api.Secret, map[string]interface{}, error) {
+	metrics := c.metricsFor(ctx)
+	secret, err := c.readRaw(ctx, path, key, version, params)
+	if err != nil || secret == nil {
+		return nil, nil, err
+	}
+	secretData, err := c.engineFor(ctx, path).getData(path, secret)
+	if err != nil {
+		metrics.updateVaultSecretReadErrorsTotalMetric(path, key, version, errors.GetErrorType(err))
+		return nil, nil, err
+	}
+	return secret, secretData, nil
+}
+
+// RawReader is implemented by backends able to return the whole response of a read, for callers needing
+// more than the secret data, e.g. its lease or its metadata
+type RawReader interface {
+	ReadRaw(path string, params map[string][]string) (*api.Secret, error)
+}
+
+// ReadRaw reads a path sending the given query parameters, e.g. version for KV version 2, and returns the
+// Vault response as it is. Reads go through the same seal, circuit breaker, retries and timeout as secret reads
+func (c *client) ReadRaw(path string, params map[string][]string) (*api.Secret, error) {
+	version := ""
+	if len(params["version"]) > 0 {
+		version = params["version"][0]
+	}
+	secret, err := c.readRaw(context.Background(), path, "", version, params)
+	if err != nil {
+		return nil, err
+	}
+	if secret == nil {
+		c.metrics.updateVaultSecretReadErrorsTotalMetric(path, "", version, errors.BackendSecretNotFoundErrorType)
+		return nil, &errors.BackendSecretNotFoundError{ErrType: errors.BackendSecretNotFoundErrorType, Path: path}
+	}
+	return secret, nil
+}
+
+// readRaw reads a path mapping the errors to the secrets-manager ones and counting them in the read error
+// metrics, labelled with the given key and version. A nil secret is returned when nothing was found
+func (c *client) readRaw(ctx context.Context, path string, key string, version string, params map[string][]string) (*api.Secret, error) {
+	metrics := c.metricsFor(ctx)
+	c.inflight.Add(1)
+	defer c.inflight.Done()
+
+	if err := c.checkPath(path); err != nil {
+		metrics.updateVaultSecretReadErrorsTotalMetric(path, key, version, errors.PathNotAllowedErrorType)
+		return nil, err
+	}
+
+	if c.health.isSealed() {
+		metrics.updateVaultSecretReadErrorsTotalMetric(path, key, version, errors.VaultSealedErrorType)
+		return nil, &errors.VaultSealedError{ErrType: errors.VaultSealedErrorType, Path: path}
+	}
+
+	if allowed, retryIn := c.breaker.allow(); !allowed {
+		metrics.updateVaultSecretReadErrorsTotalMetric(path, key, version, errors.VaultCircuitOpenErrorType)
+		return nil, &errors.VaultCircuitOpenError{ErrType: errors.VaultCircuitOpenErrorType, Path: path, RetryIn: retryIn.Round(time.Second).String()}
+	}
+
+	token, err := c.scopedToken(ctx)
+	if err != nil {
+		metrics.updateVaultSecretReadErrorsTotalMetric(path, key, version, errors.VaultRoleTokenErrorType)
+		return nil, err
+	}
+	if token != "" {
+		ctx = withClientToken(ctx, token)
+	}
+
+	if c.requestTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.requestTimeout)
+		defer cancel()
+	}
+
+	var secret *api.Secret
+	err = c.withRetry(ctx, vaultReadOperationName, func() error {
+		var err error
+		start := time.Now()
+		secret, err = c.readWithContext(ctx, path, params)
+		metrics.updateVaultSecretReadDurationMetric(path, requestResult(err), time.Since(start))
+		return err
+	})
+	c.recordReadResult(err)
+	c.breaker.record(err)
+	if err != nil {
+		if ctx.Err() == context.DeadlineExceeded {
+			metrics.updateVaultSecretReadErrorsTotalMetric(path, key, version, errors.VaultTimeoutErrorType)
+			return nil, &errors.VaultTimeoutError{ErrType: errors.VaultTimeoutErrorType, Path: path, Err: err}
+		}
+		if vaultStatusCode(err) == http.StatusForbidden {
+			c.dropScopedToken(ctx)
+			metrics.updateVaultSecretReadErrorsTotalMetric(path, key, version, errors.VaultForbiddenErrorType)
+			return nil, &errors.VaultForbiddenError{ErrType: errors.VaultForbiddenErrorType, Path: path}
+		}
+		metrics.updateVaultSecretReadErrorsTotalMetric(path, key, version, errors.UnknownErrorType)
+		return nil, err
+	}
+	return secret, nil
+}
+
+// readWithContext behaves like api.Logical ReadWithData, which always uses a background context,
+// but the in-flight request is cancelled along with ctx. Performance standby nodes answer 412 when they
+// haven't caught up with the active node yet, the read is then sent again asking the standby to forward
+// it to the active node
+func (c *client) readWithContext(ctx context.Context, path string, params map[string][]string) (*api.Secret, error) {
+	r := c.vclient.NewRequest("GET", "/v1/"+path)
+	if len(params) > 0 {
+		r.Params = url.Values(params)
+	}
+	if wrapTTL := wrapTTLFromContext(ctx); wrapTTL != "" {
+		r.WrapTTL = wrapTTL
+	}
+	if token := clientTokenFromContext(ctx); token != "" {
+		r.ClientToken = token
+	}
+	setNamespaceHeader(ctx, r)
+
+	resp, err := c.vclient.RawRequestWithContext(ctx, r)
+	if resp != nil && resp.StatusCode == http.StatusPreconditionFailed {
+		resp.Body.Close()
+		c.logger.Info("vault performance standby is not up to date, forwarding the read to the active node", "path", path)
+		r = c.vclient.NewRequest("GET", "/v1/"+path)
+		if len(params) > 0 {
+			r.Params = url.Values(params)
+		}
+		if wrapTTL := wrapTTLFromContext(ctx); wrapTTL != "" {
+			r.WrapTTL = wrapTTL
+		}
+		if token := clientTokenFromContext(ctx); token != "" {
+			r.ClientToken = token
+		}
+		setNamespaceHeader(ctx, r)
+		// Request headers are shared with the api client, they are copied to not leak the header to other requests
+		r.Headers = cloneHeader(r.Headers)
+		r.Headers.Set(vaultInconsistentHeader, vaultForwardActiveNode)
+		resp, err = c.vclient.RawRequestWithContext(ctx, r)
+		outcome := retryOutcomeSuccess
+		if err != nil {
+			outcome = retryOutcomeFailure
+		}
+		c.metrics.updateVaultStandbyForwardsTotalMetric(outcome)
+	}
+	if resp != nil {
+		defer resp.Body.Close()
+	}
+	if resp != nil && resp.StatusCode == http.StatusNotFound {
+		secret, parseErr := api.ParseSecret(resp.Body)
+		switch parseErr {
+		case nil:
+		case io.EOF:
+			return nil, nil
+		default:
+			return nil, err
+		}
+		if secret != nil && (len(secret.Warnings) > 0 || len(secret.Data) > 0) {
+			return secret, nil
+		}
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return api.ParseSecret(resp.Body)
+}
+
+// ListSecrets returns the keys under the given path. Keys ending with a slash are folders.
+// A path without children returns an empty slice, while a path that doesn't exist returns a BackendSecretNotFoundError
+func (c *client) ListSecrets(path string) ([]string, error) {
+	if err := c.checkPath(path); err != nil {
+		c.metrics.updateVaultSecretListErrorsTotalMetric(path, errors.PathNotAllowedErrorType)
+		return nil, err
+	}
+	keys := []string{}
+	listPath := c.engineFor(context.Background(), path).metadataPath(path)
+
+	logical := c.logical
+	secret, err := logical.List(listPath)
+	if err != nil {
+		c.metrics.updateVaultSecretListErrorsTotalMetric(path, errors.UnknownErrorType)
+		return nil, err
+	}
+
+	if secret == nil {
+		leaf, err := logical.Read(listPath)
+		if err != nil {
+			c.metrics.updateVaultSecretListErrorsTotalMetric(path, errors.UnknownErrorType)
+			return nil, err
+		}
+		if leaf == nil {
+			c.metrics.updateVaultSecretListErrorsTotalMetric(path, errors.BackendSecretNotFoundErrorType)
+			return nil, &errors.BackendSecretNotFoundError{ErrType: errors.BackendSecretNotFoundErrorType, Path: path}
+		}
+		return keys, nil
+	}
+
+	if list, ok := secret.Data["keys"].([]interface{}); ok {
+		for _, k := range list {
+			if key, ok := k.(string); ok {
+				keys = append(keys, key)
+			}
+		}
+	}
+	return keys, nil
+}
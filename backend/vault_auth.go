@@ -0,0 +1,268 @@
+package backend
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/vault/api"
+	"github.com/tuenti/secrets-manager/errors"
+)
+
+const (
+	kubernetesJwtTokenPath = "/var/run/secrets/kubernetes.io/serviceaccount/token"
+	kubernetesAuthMethod   = "kubernetes"
+	appRoleAuthMethod      = "approle"
+	jwtAuthMethod          = "jwt"
+	userpassAuthMethod     = "userpass"
+	certAuthMethod         = "cert"
+
+	defaultKubernetesPath = "kubernetes"
+	defaultJWTMountPath   = "jwt"
+	defaultUserpassPath   = "userpass"
+	defaultCertMountPath  = "cert"
+)
+
+func (c *client) vaultLogin() error {
+	// A token written by Vault Agent takes precedence over any auth method
+	if c.tokenFile != "" {
+		return c.loadTokenFile()
+	}
+	switch c.authMethod {
+	case kubernetesAuthMethod:
+		fd, err := os.Open(c.kubernetesJWTPath)
+		if err != nil {
+			return &errors.VaultKubernetesAuthError{ErrType: errors.VaultKubernetesAuthErrorType, Role: c.kubernetesRole, Err: err}
+		}
+		defer fd.Close()
+		return c.vaultKubernetesLogin(fd)
+	case jwtAuthMethod:
+		return c.vaultJWTLogin()
+	case userpassAuthMethod:
+		return c.vaultUserpassLogin()
+	case certAuthMethod:
+		return c.vaultCertLogin()
+	case awsAuthMethod:
+		return c.vaultAWSLogin()
+	case gcpAuthMethod:
+		return c.vaultGCPLogin()
+	case azureAuthMethod:
+		return c.vaultAzureLogin()
+	case appRoleAuthMethod:
+		fallthrough
+	default:
+		return c.vaultAppRoleLogin()
+	}
+}
+
+// vaultRelogin will get a brand new token from Vault using the configured auth method,
+// updating the login metrics accordingly
+func (c *client) vaultRelogin() error {
+	c.logger.Info("trying to login to vault again")
+	start := time.Now()
+	err := c.vaultLogin()
+	c.observeTokenRequest(vaultLoginOperationName, time.Since(start), err)
+	if err == nil && c.childTokens() {
+		createStart := time.Now()
+		err = c.createChildToken()
+		c.observeTokenRequest(vaultTokenCreateOperationName, time.Since(createStart), err)
+		if err != nil {
+			c.metrics.updateVaultChildTokenErrorsTotalMetric(c.tokenRole, errors.GetErrorType(err))
+		} else {
+			c.metrics.updateVaultChildTokensCreatedTotalMetric(c.tokenRole)
+		}
+	}
+	c.metrics.updateVaultLoginDurationMetric(time.Since(start))
+	if err != nil {
+		c.metrics.updateVaultLoginErrorsTotalMetric(errors.GetErrorType(err))
+		c.logger.Error(err, "login error, vault token not obtained")
+		return err
+	}
+	c.metrics.updateVaultLoginSuccessesTotalMetric()
+	c.logger.Info("login successful, got a new vault token")
+	return nil
+}
+
+func (c *client) vaultAppRoleLogin() error {
+	secretID, err := c.appRoleSecretID()
+	if err != nil {
+		return &errors.VaultAppRoleAuthError{ErrType: errors.VaultAppRoleAuthErrorType, RoleID: c.roleID, Err: err}
+	}
+	appRole := map[string]interface{}{
+		"role_id":   c.roleID,
+		"secret_id": secretID,
+	}
+	resp, err := c.logical.Write(fmt.Sprintf("auth/%s/login", c.approlePath), appRole)
+	if err != nil {
+		return &errors.VaultAppRoleAuthError{ErrType: errors.VaultAppRoleAuthErrorType, RoleID: c.roleID, Err: err}
+	}
+	if err := c.setToken(resp); err != nil {
+		return &errors.VaultAppRoleAuthError{ErrType: errors.VaultAppRoleAuthErrorType, RoleID: c.roleID, Err: err}
+	}
+	return nil
+}
+
+// appRoleSecretID returns the configured secret_id. If a secret_id file is configured
+// it is read on every call, so that it can be rotated without restarting secrets-manager
+func (c *client) appRoleSecretID() (string, error) {
+	if c.secretIDFile == "" {
+		return c.secretID, nil
+	}
+	secretID, err := ioutil.ReadFile(c.secretIDFile)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(secretID)), nil
+}
+
+func (c *client) vaultKubernetesLogin(podSATokenReader io.Reader) error {
+	jwt, err := ioutil.ReadAll(podSATokenReader)
+	if err != nil {
+		return &errors.VaultKubernetesAuthError{ErrType: errors.VaultKubernetesAuthErrorType, Role: c.kubernetesRole, Err: err}
+	}
+	kubernetes := map[string]interface{}{
+		"jwt":  string(jwt),
+		"role": c.kubernetesRole,
+	}
+	resp, err := c.logical.Write(fmt.Sprintf("auth/%s/login", c.kubernetesPath), kubernetes)
+	if err != nil {
+		return &errors.VaultKubernetesAuthError{ErrType: errors.VaultKubernetesAuthErrorType, Role: c.kubernetesRole, Err: err}
+	}
+	if err := c.setToken(resp); err != nil {
+		return &errors.VaultKubernetesAuthError{ErrType: errors.VaultKubernetesAuthErrorType, Role: c.kubernetesRole, Err: err}
+	}
+	return nil
+}
+
+// authClientToken extracts the client token from a Vault login response
+func authClientToken(resp *api.Secret) (string, error) {
+	if resp == nil || resp.Auth == nil || resp.Auth.ClientToken == "" {
+		return "", fmt.Errorf("login response does not contain a client token")
+	}
+	return resp.Auth.ClientToken, nil
+}
+
+// vaultJWTLogin logins with a JWT issued by an external identity provider. The JWT file is read
+// on every login, as the provider rotates it
+func (c *client) vaultJWTLogin() error {
+	jwt, err := ioutil.ReadFile(c.jwtPath)
+	if err != nil {
+		return &errors.VaultJWTAuthError{ErrType: errors.VaultJWTAuthErrorType, Role: c.jwtRole, Err: err}
+	}
+	params := map[string]interface{}{
+		"jwt":  strings.TrimSpace(string(jwt)),
+		"role": c.jwtRole,
+	}
+	resp, err := c.logical.Write(fmt.Sprintf("auth/%s/login", c.jwtMountPath), params)
+	if err != nil {
+		return &errors.VaultJWTAuthError{ErrType: errors.VaultJWTAuthErrorType, Role: c.jwtRole, Err: err}
+	}
+	if err := c.setToken(resp); err != nil {
+		return &errors.VaultJWTAuthError{ErrType: errors.VaultJWTAuthErrorType, Role: c.jwtRole, Err: err}
+	}
+	return nil
+}
+
+// vaultUserpassLogin logins with a username and password. If a password file is configured it is read
+// on every login, so that the password can be rotated without restarting secrets-manager
+func (c *client) vaultUserpassLogin() error {
+	password := c.password
+	if c.passwordFile != "" {
+		data, err := ioutil.ReadFile(c.passwordFile)
+		if err != nil {
+			return &errors.VaultUserpassAuthError{ErrType: errors.VaultUserpassAuthErrorType, Username: c.username, Err: err}
+		}
+		password = strings.TrimSpace(string(data))
+	}
+	params := map[string]interface{}{
+		"password": password,
+	}
+	resp, err := c.logical.Write(fmt.Sprintf("auth/%s/login/%s", c.userpassPath, c.username), params)
+	if err != nil {
+		return &errors.VaultUserpassAuthError{ErrType: errors.VaultUserpassAuthErrorType, Username: c.username, Err: err}
+	}
+	if err := c.setToken(resp); err != nil {
+		return &errors.VaultUserpassAuthError{ErrType: errors.VaultUserpassAuthErrorType, Username: c.username, Err: err}
+	}
+	return nil
+}
+
+// vaultCertLogin logins with the TLS client certificate configured for mutual TLS. Idle connections are closed
+// first, so the login happens on a new TLS handshake presenting the certificate currently on disk
+func (c *client) vaultCertLogin() error {
+	if c.transport != nil {
+		c.transport.CloseIdleConnections()
+	}
+	params := map[string]interface{}{}
+	if c.certRole != "" {
+		params["name"] = c.certRole
+	}
+	resp, err := c.logical.Write(fmt.Sprintf("auth/%s/login", c.certMountPath), params)
+	if err != nil {
+		return &errors.VaultCertAuthError{ErrType: errors.VaultCertAuthErrorType, Role: c.certRole, Err: err}
+	}
+	if err := c.setToken(resp); err != nil {
+		return &errors.VaultCertAuthError{ErrType: errors.VaultCertAuthErrorType, Role: c.certRole, Err: err}
+	}
+	return nil
+}
+
+// vaultUnwrapToken gets the token wrapped with response-wrapping. The wrapped response may be
+// a login response or contain the token in its token field. Wrapping tokens are single-use
+func (c *client) vaultUnwrapToken(wrappingToken string) error {
+	resp, err := c.logical.Unwrap(wrappingToken)
+	if err != nil {
+		c.vclient.ClearToken()
+		return &errors.VaultUnwrapError{ErrType: errors.VaultUnwrapErrorType, Err: err}
+	}
+	if resp != nil && resp.Auth != nil {
+		if err := c.setToken(resp); err != nil {
+			return &errors.VaultUnwrapError{ErrType: errors.VaultUnwrapErrorType, Err: err}
+		}
+		return nil
+	}
+	var token string
+	if resp != nil {
+		token, _ = resp.Data["token"].(string)
+	}
+	if token == "" {
+		c.vclient.ClearToken()
+		return &errors.VaultUnwrapError{ErrType: errors.VaultUnwrapErrorType, Err: fmt.Errorf("unwrapped response does not contain a token")}
+	}
+	c.vclient.SetToken(token)
+	return nil
+}
+
+// childTokens returns true when reads must use a child token of the token logged in
+func (c *client) childTokens() bool {
+	return c.tokenRole != "" || len(c.tokenPolicies) > 0
+}
+
+// createChildToken replaces the token logged in with a child token created with it (auth/token/create), scoped to
+// the configured token role or policies, so a bootstrap token able to create tokens is never used for reads. The
+// child token is renewed as any other token, and created again along with a new login once it can't be renewed
+func (c *client) createChildToken() error {
+	if !c.childTokens() {
+		return nil
+	}
+	path := "auth/token/create"
+	if c.tokenRole != "" {
+		path += "/" + c.tokenRole
+	}
+	var data map[string]interface{}
+	if len(c.tokenPolicies) > 0 {
+		data = map[string]interface{}{"policies": c.tokenPolicies}
+	}
+	resp, err := c.logical.Write(path, data)
+	if err == nil {
+		err = c.setToken(resp)
+	}
+	if err != nil {
+		return &errors.VaultTokenCreateError{ErrType: errors.VaultTokenCreateErrorType, Role: c.tokenRole, Err: err}
+	}
+	c.logger.Info("created vault child token", "vault_token_role", c.tokenRole, "vault_token_policies", c.tokenPolicies, "vault_token_ttl", resp.Auth.LeaseDuration)
+	return nil
+}
@@ -0,0 +1,389 @@
+package backend
+
+import (
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/vault/api"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/tuenti/secrets-manager/errors"
+)
+
+func newKubernetesAuthTestClient(t *testing.T, jwtPath string) *client {
+	httpClient := new(http.Client)
+	vclient, err := api.NewClient(&api.Config{Address: vaultCfg.VaultURL, HttpClient: httpClient})
+	assert.Nil(t, err)
+	return &client{
+		vclient:           vclient,
+		logical:           vclient.Logical(),
+		authMethod:        kubernetesAuthMethod,
+		kubernetesRole:    "secrets-manager",
+		kubernetesPath:    defaultKubernetesPath,
+		kubernetesJWTPath: jwtPath,
+		metrics:           newTestVaultMetrics(),
+		logger:            logger,
+	}
+}
+
+func TestVaultLoginKubernetesJWTPath(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "secrets-manager")
+	defer os.RemoveAll(dir)
+	jwtPath := filepath.Join(dir, "token")
+	ioutil.WriteFile(jwtPath, []byte(fakeKubernetesSAToken), 0600)
+
+	mutex.Lock()
+	defer mutex.Unlock()
+	testCfg.invalidKubernetesRole = defaultKubernetesRole
+
+	c := newKubernetesAuthTestClient(t, jwtPath)
+	err := c.vaultLogin()
+	assert.Nil(t, err)
+	assert.Equal(t, fakeToken, c.vclient.Token())
+}
+
+func TestVaultLoginKubernetesMissingJWT(t *testing.T) {
+	c := newKubernetesAuthTestClient(t, "/non/existent/token")
+	err := c.vaultLogin()
+	assert.True(t, errors.IsVaultKubernetesAuth(err))
+}
+
+func TestVaultLoginKubernetesInvalidRole(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "secrets-manager")
+	defer os.RemoveAll(dir)
+	jwtPath := filepath.Join(dir, "token")
+	ioutil.WriteFile(jwtPath, []byte(fakeKubernetesSAToken), 0600)
+
+	mutex.Lock()
+	defer mutex.Unlock()
+	testCfg.invalidKubernetesRole = true
+
+	c := newKubernetesAuthTestClient(t, jwtPath)
+	err := c.vaultLogin()
+	assert.True(t, errors.IsVaultKubernetesAuth(err))
+	testCfg.invalidKubernetesRole = defaultKubernetesRole
+}
+
+func TestVaultClientDefaultKubernetesPaths(t *testing.T) {
+	client, err := vaultClient(logger, vaultCfg)
+	assert.Nil(t, err)
+	assert.Equal(t, defaultKubernetesPath, client.kubernetesPath)
+	assert.Equal(t, kubernetesJwtTokenPath, client.kubernetesJWTPath)
+}
+
+func TestRenewalLoopNotRenewableTokenRelogin(t *testing.T) {
+	client, _ := vaultClient(logger, vaultCfg)
+	mutex.Lock()
+	defer mutex.Unlock()
+	testCfg.tokenRenewable = false
+	testCfg.tokenRevoked = false
+	testCfg.tokenTTL = 600
+	client.maxTokenTTL = 6000
+
+	client.metrics.loginSuccessesTotal.Reset()
+	client.renewalLoop()
+	metricLoginSuccessesTotal, _ := client.metrics.loginSuccessesTotal.GetMetricWithLabelValues(vaultCfg.VaultURL, vaultCfg.VaultEngine, vaultFakeVersion, vaultFakeClusterID, vaultFakeClusterName, vaultCfg.VaultNamespace)
+
+	assert.Equal(t, 1.0, testutil.ToFloat64(metricLoginSuccessesTotal))
+	testCfg.tokenRenewable = defaultTokenRenewable
+}
+
+func TestVaultAppRoleLoginSecretIDFile(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "secrets-manager")
+	defer os.RemoveAll(dir)
+	secretIDPath := filepath.Join(dir, "secret-id")
+	ioutil.WriteFile(secretIDPath, []byte(vaultFakeSecretID+"\n"), 0600)
+
+	client, err := vaultClient(logger, vaultCfg)
+	assert.Nil(t, err)
+	client.secretIDFile = secretIDPath
+
+	secretID, err := client.appRoleSecretID()
+	assert.Nil(t, err)
+	assert.Equal(t, vaultFakeSecretID, secretID)
+	assert.Nil(t, client.vaultLogin())
+
+	client.secretIDFile = filepath.Join(dir, "missing")
+	err = client.vaultLogin()
+	assert.True(t, errors.IsVaultAppRoleAuth(err))
+}
+
+func TestVaultAppRoleLoginRejected(t *testing.T) {
+	client, _ := vaultClient(logger, vaultCfg)
+	mutex.Lock()
+	defer mutex.Unlock()
+	testCfg.invalidSecretID = true
+
+	client.metrics.loginErrorsTotal.Reset()
+	err := client.vaultRelogin()
+	metricLoginErrorsTotal, _ := client.metrics.loginErrorsTotal.GetMetricWithLabelValues(vaultCfg.VaultURL, vaultCfg.VaultEngine, vaultFakeVersion, vaultFakeClusterID, vaultFakeClusterName, vaultCfg.VaultNamespace, errors.VaultAppRoleAuthErrorType)
+	assert.True(t, errors.IsVaultAppRoleAuth(err))
+	assert.Equal(t, 1.0, testutil.ToFloat64(metricLoginErrorsTotal))
+	testCfg.invalidSecretID = defaultInvalidAppRole
+}
+
+func newJWTAuthTestClient(t *testing.T, jwtPath string) *client {
+	httpClient := new(http.Client)
+	vclient, err := api.NewClient(&api.Config{Address: vaultCfg.VaultURL, HttpClient: httpClient})
+	assert.Nil(t, err)
+	return &client{
+		vclient:      vclient,
+		logical:      vclient.Logical(),
+		authMethod:   jwtAuthMethod,
+		jwtRole:      fakeJWTRole,
+		jwtPath:      jwtPath,
+		jwtMountPath: defaultJWTMountPath,
+		metrics:      newTestVaultMetrics(),
+		logger:       logger,
+	}
+}
+
+func TestVaultLoginJWT(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "secrets-manager")
+	defer os.RemoveAll(dir)
+	jwtPath := filepath.Join(dir, "token")
+	ioutil.WriteFile(jwtPath, []byte(fakeJWT+"\n"), 0600)
+
+	c := newJWTAuthTestClient(t, jwtPath)
+	err := c.vaultLogin()
+	assert.Nil(t, err)
+	assert.Equal(t, fakeToken, c.vclient.Token())
+}
+
+func TestVaultLoginJWTRotated(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "secrets-manager")
+	defer os.RemoveAll(dir)
+	jwtPath := filepath.Join(dir, "token")
+	ioutil.WriteFile(jwtPath, []byte("expired"), 0600)
+
+	c := newJWTAuthTestClient(t, jwtPath)
+	err := c.vaultLogin()
+	assert.True(t, errors.IsVaultJWTAuth(err))
+
+	ioutil.WriteFile(jwtPath, []byte(fakeJWT), 0600)
+	err = c.vaultLogin()
+	assert.Nil(t, err)
+}
+
+func TestVaultLoginJWTMissingFile(t *testing.T) {
+	c := newJWTAuthTestClient(t, "/non/existent/token")
+	err := c.vaultLogin()
+	assert.True(t, errors.IsVaultJWTAuth(err))
+}
+
+func TestVaultReloginJWTErrorMetric(t *testing.T) {
+	c := newJWTAuthTestClient(t, "/non/existent/token")
+	c.metrics.loginErrorsTotal.Reset()
+	err := c.vaultRelogin()
+	metricLoginErrorsTotal, _ := c.metrics.loginErrorsTotal.GetMetricWithLabelValues(vaultCfg.VaultURL, vaultCfg.VaultEngine, vaultFakeVersion, vaultFakeClusterID, vaultFakeClusterName, vaultCfg.VaultNamespace, errors.VaultJWTAuthErrorType)
+	assert.True(t, errors.IsVaultJWTAuth(err))
+	assert.Equal(t, 1.0, testutil.ToFloat64(metricLoginErrorsTotal))
+}
+
+func TestVaultClientWrappedToken(t *testing.T) {
+	for _, wrappingToken := range []string{fakeWrappingToken, fakeWrappingDataToken} {
+		cfg := vaultCfg
+		cfg.VaultWrappedToken = wrappingToken
+		cfg.VaultRoleID = ""
+		client, err := vaultClient(logger, cfg)
+		assert.Nil(t, err)
+		assert.Equal(t, fakeToken, client.vclient.Token())
+
+		// Wrapping tokens are single-use
+		_, err = vaultClient(logger, cfg)
+		assert.True(t, errors.IsVaultUnwrap(err))
+	}
+}
+
+// v1AuthTokenCreate creates a child token named after the policies it was asked for
+func v1AuthTokenCreate(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		Policies []string `json:"policies"`
+	}
+	json.NewDecoder(r.Body).Decode(&body)
+	w.Header().Set("Content-Type", "application/json")
+	fmt.Fprintf(w, `{"auth": {"client_token": "child-%s", "lease_duration": 3600, "renewable": true}}`, strings.Join(body.Policies, "-"))
+}
+
+func TestVaultClientChildToken(t *testing.T) {
+	mutex.Lock()
+	defer mutex.Unlock()
+	testCfg.roleTokensCreated = 0
+
+	cfg := vaultCfg
+	cfg.VaultTokenRole = "secrets-manager"
+	client, err := vaultClient(logger, cfg)
+	assert.Nil(t, err)
+	assert.Equal(t, "child-secrets-manager-1", client.vclient.Token())
+	metric, _ := client.metrics.childTokensCreatedTotal.GetMetricWithLabelValues(vaultCfg.VaultURL, vaultCfg.VaultEngine, vaultFakeVersion, vaultFakeClusterID, vaultFakeClusterName, vaultCfg.VaultNamespace, "secrets-manager")
+	assert.Equal(t, 1.0, testutil.ToFloat64(metric))
+
+	// Logging in again creates a new child token
+	assert.Nil(t, client.vaultRelogin())
+	assert.Equal(t, "child-secrets-manager-2", client.vclient.Token())
+	assert.Equal(t, 2.0, testutil.ToFloat64(metric))
+
+	cfg.VaultTokenRole = ""
+	cfg.VaultTokenPolicies = []string{"read-a", "read-b"}
+	client, err = vaultClient(logger, cfg)
+	assert.Nil(t, err)
+	assert.Equal(t, "child-read-a-read-b", client.vclient.Token())
+}
+
+func TestVaultClientChildTokenErrors(t *testing.T) {
+	cfg := vaultCfg
+	cfg.VaultTokenRole = "denied"
+	_, err := vaultClient(logger, cfg)
+	assert.True(t, errors.IsVaultTokenCreate(err))
+
+	cfg.VaultTokenFile = "/var/run/vault/token"
+	_, err = vaultClient(logger, cfg)
+	assert.True(t, errors.IsBackendConfig(err))
+}
+
+func TestVaultClientInvalidWrappedToken(t *testing.T) {
+	cfg := vaultCfg
+	cfg.VaultWrappedToken = "s.invalid"
+	_, err := vaultClient(logger, cfg)
+	assert.True(t, errors.IsVaultUnwrap(err))
+	assert.Contains(t, err.Error(), "wrapping token is not valid")
+}
+
+func newUserpassAuthTestClient(t *testing.T, password string, passwordFile string) *client {
+	httpClient := new(http.Client)
+	vclient, err := api.NewClient(&api.Config{Address: vaultCfg.VaultURL, HttpClient: httpClient})
+	assert.Nil(t, err)
+	return &client{
+		vclient:      vclient,
+		logical:      vclient.Logical(),
+		authMethod:   userpassAuthMethod,
+		username:     fakeUsername,
+		password:     password,
+		passwordFile: passwordFile,
+		userpassPath: defaultUserpassPath,
+		metrics:      newTestVaultMetrics(),
+		logger:       logger,
+	}
+}
+
+func TestVaultLoginUserpass(t *testing.T) {
+	c := newUserpassAuthTestClient(t, fakePassword, "")
+	err := c.vaultLogin()
+	assert.Nil(t, err)
+	assert.Equal(t, fakeToken, c.vclient.Token())
+}
+
+func TestVaultLoginUserpassPasswordFileRotated(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "secrets-manager")
+	defer os.RemoveAll(dir)
+	passwordFile := filepath.Join(dir, "password")
+	ioutil.WriteFile(passwordFile, []byte("old-password"), 0600)
+
+	c := newUserpassAuthTestClient(t, "", passwordFile)
+	err := c.vaultLogin()
+	assert.True(t, errors.IsVaultUserpassAuth(err))
+
+	ioutil.WriteFile(passwordFile, []byte(fakePassword+"\n"), 0600)
+	err = c.vaultLogin()
+	assert.Nil(t, err)
+}
+
+func TestVaultReloginUserpassErrorMetric(t *testing.T) {
+	c := newUserpassAuthTestClient(t, "invalid", "")
+	c.metrics.loginErrorsTotal.Reset()
+	err := c.vaultRelogin()
+	metricLoginErrorsTotal, _ := c.metrics.loginErrorsTotal.GetMetricWithLabelValues(vaultCfg.VaultURL, vaultCfg.VaultEngine, vaultFakeVersion, vaultFakeClusterID, vaultFakeClusterName, vaultCfg.VaultNamespace, errors.VaultUserpassAuthErrorType)
+	assert.True(t, errors.IsVaultUserpassAuth(err))
+	assert.Equal(t, 1.0, testutil.ToFloat64(metricLoginErrorsTotal))
+}
+
+// newCertAuthTestServer returns a TLS server answering cert logins with the common name of the
+// client certificate as token, so tests can check which certificate was presented
+func newCertAuthTestServer(t *testing.T) *httptest.Server {
+	certPEM, keyPEM := generateTestCertificate(t, "vault")
+	cert, err := tls.X509KeyPair(certPEM, keyPEM)
+	assert.Nil(t, err)
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if r.URL.Path != "/v1/auth/cert/login" || len(r.TLS.PeerCertificates) == 0 {
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprint(w, `{"errors":["invalid certificate or no client certificate supplied"]}`)
+			return
+		}
+		fmt.Fprintf(w, `{"auth":{"client_token":"%s","renewable":true,"lease_duration":3600}}`, r.TLS.PeerCertificates[0].Subject.CommonName)
+	}))
+	server.TLS = &tls.Config{Certificates: []tls.Certificate{cert}, ClientAuth: tls.RequestClientCert}
+	server.StartTLS()
+	return server
+}
+
+func newCertAuthTestClient(t *testing.T, address string, cfg Config) *client {
+	transport, err := newVaultTransport(cfg)
+	assert.Nil(t, err)
+	vclient, err := api.NewClient(&api.Config{Address: address, HttpClient: &http.Client{Transport: transport}})
+	assert.Nil(t, err)
+	return &client{
+		vclient:       vclient,
+		logical:       vclient.Logical(),
+		authMethod:    certAuthMethod,
+		certRole:      "secrets-manager",
+		certMountPath: defaultCertMountPath,
+		transport:     transport,
+		metrics:       newTestVaultMetrics(),
+		logger:        logger,
+	}
+}
+
+func TestVaultLoginCertRotated(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "secrets-manager")
+	defer os.RemoveAll(dir)
+	server := newCertAuthTestServer(t)
+	defer server.Close()
+
+	certPath := filepath.Join(dir, "cert.pem")
+	keyPath := filepath.Join(dir, "key.pem")
+	certPEM, keyPEM := generateTestCertificate(t, "secrets-manager-1")
+	ioutil.WriteFile(certPath, certPEM, 0600)
+	ioutil.WriteFile(keyPath, keyPEM, 0600)
+
+	c := newCertAuthTestClient(t, server.URL, Config{VaultTLSSkipVerify: true, VaultClientCert: certPath, VaultClientKey: keyPath})
+	err := c.vaultLogin()
+	assert.Nil(t, err)
+	assert.Equal(t, "secrets-manager-1", c.vclient.Token())
+
+	certPEM, keyPEM = generateTestCertificate(t, "secrets-manager-2")
+	ioutil.WriteFile(certPath, certPEM, 0600)
+	ioutil.WriteFile(keyPath, keyPEM, 0600)
+	os.Chtimes(certPath, time.Now(), time.Now().Add(time.Minute))
+	err = c.vaultLogin()
+	assert.Nil(t, err)
+	assert.Equal(t, "secrets-manager-2", c.vclient.Token())
+}
+
+func TestVaultReloginCertErrorMetric(t *testing.T) {
+	server := newCertAuthTestServer(t)
+	defer server.Close()
+
+	c := newCertAuthTestClient(t, server.URL, Config{VaultTLSSkipVerify: true})
+	c.metrics.loginErrorsTotal.Reset()
+	err := c.vaultRelogin()
+	metricLoginErrorsTotal, _ := c.metrics.loginErrorsTotal.GetMetricWithLabelValues(vaultCfg.VaultURL, vaultCfg.VaultEngine, vaultFakeVersion, vaultFakeClusterID, vaultFakeClusterName, vaultCfg.VaultNamespace, errors.VaultCertAuthErrorType)
+	assert.True(t, errors.IsVaultCertAuth(err))
+	assert.Equal(t, 1.0, testutil.ToFloat64(metricLoginErrorsTotal))
+}
+
+func TestVaultClientCertAuthWithoutClientCert(t *testing.T) {
+	cfg := vaultCfg
+	cfg.VaultAuthMethod = certAuthMethod
+	_, err := vaultClient(logger, cfg)
+	assert.True(t, errors.IsBackendConfig(err))
+}
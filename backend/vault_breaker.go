@@ -0,0 +1,122 @@
+package backend
+
+import (
+	"sync"
+	"time"
+
+	"github.com/go-logr/logr"
+)
+
+const (
+	breakerStateClosed   = "closed"
+	breakerStateOpen     = "open"
+	breakerStateHalfOpen = "half-open"
+)
+
+// breakerStateValues are the values of the circuit breaker state gauge
+var breakerStateValues = map[string]float64{
+	breakerStateClosed:   0,
+	breakerStateHalfOpen: 1,
+	breakerStateOpen:     2,
+}
+
+// circuitBreaker stops sending reads to Vault after threshold reads failed in a row within window, so a
+// recovering Vault isn't flooded by every reconciliation. Once coolDown elapses a single probe read is let
+// through, closing the breaker if it succeeds or opening it again otherwise. A nil breaker is always closed
+type circuitBreaker struct {
+	mutex        sync.Mutex
+	threshold    int
+	window       time.Duration
+	coolDown     time.Duration
+	state        string
+	failures     int
+	firstFailure time.Time
+	openedAt     time.Time
+	probing      bool
+	metrics      *vaultMetrics
+	logger       logr.Logger
+}
+
+func newCircuitBreaker(threshold int, window time.Duration, coolDown time.Duration, metrics *vaultMetrics, logger logr.Logger) *circuitBreaker {
+	if threshold <= 0 {
+		return nil
+	}
+	return &circuitBreaker{
+		threshold: threshold,
+		window:    window,
+		coolDown:  coolDown,
+		state:     breakerStateClosed,
+		metrics:   metrics,
+		logger:    logger,
+	}
+}
+
+// allow tells whether a read can be sent to Vault and, when it can't, the time left until the next probe
+func (b *circuitBreaker) allow() (bool, time.Duration) {
+	if b == nil {
+		return true, 0
+	}
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	switch b.state {
+	case breakerStateOpen:
+		if elapsed := time.Since(b.openedAt); elapsed < b.coolDown {
+			return false, b.coolDown - elapsed
+		}
+		b.transition(breakerStateHalfOpen)
+		b.probing = true
+		return true, 0
+	case breakerStateHalfOpen:
+		// Only the probe is let through until its result is known
+		if b.probing {
+			return false, 0
+		}
+		b.probing = true
+		return true, 0
+	}
+	return true, 0
+}
+
+// record updates the breaker with the result of a read. Only errors telling Vault is unavailable count as
+// failures, a missing secret or a denied path don't
+func (b *circuitBreaker) record(err error) {
+	if b == nil {
+		return
+	}
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	b.probing = false
+	if !isRetryable(err) {
+		b.failures = 0
+		if b.state != breakerStateClosed {
+			b.transition(breakerStateClosed)
+		}
+		return
+	}
+	if b.state == breakerStateHalfOpen {
+		b.open()
+		return
+	}
+	now := time.Now()
+	if b.failures == 0 || (b.window > 0 && now.Sub(b.firstFailure) > b.window) {
+		b.failures = 0
+		b.firstFailure = now
+	}
+	b.failures++
+	if b.state == breakerStateClosed && b.failures >= b.threshold {
+		b.open()
+	}
+}
+
+func (b *circuitBreaker) open() {
+	b.openedAt = time.Now()
+	b.failures = 0
+	b.transition(breakerStateOpen)
+}
+
+// transition changes the breaker state, logging it and updating its metrics. Must be called with the mutex held
+func (b *circuitBreaker) transition(state string) {
+	b.logger.Info("vault circuit breaker state changed", "from", b.state, "to", state, "cool_down", b.coolDown.String())
+	b.state = state
+	b.metrics.updateVaultCircuitBreakerMetrics(state)
+}
@@ -0,0 +1,117 @@
+package backend
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/tuenti/secrets-manager/errors"
+)
+
+var errVaultUnavailable = &url.Error{Op: "Get", URL: "http://vault:8200", Err: fmt.Errorf("connection refused")}
+
+func newTestCircuitBreaker(threshold int, window time.Duration, coolDown time.Duration) *circuitBreaker {
+	return newCircuitBreaker(threshold, window, coolDown, newTestVaultMetrics(), logger)
+}
+
+func TestCircuitBreakerOpens(t *testing.T) {
+	b := newTestCircuitBreaker(3, time.Minute, time.Hour)
+	b.record(errVaultUnavailable)
+	b.record(errVaultUnavailable)
+	allowed, _ := b.allow()
+	assert.True(t, allowed)
+
+	b.record(errVaultUnavailable)
+	allowed, retryIn := b.allow()
+	assert.False(t, allowed)
+	assert.True(t, retryIn > 59*time.Minute)
+	assert.Equal(t, breakerStateOpen, b.state)
+}
+
+func TestCircuitBreakerIgnoresClientErrors(t *testing.T) {
+	b := newTestCircuitBreaker(2, time.Minute, time.Hour)
+	b.record(errVaultUnavailable)
+	b.record(&errors.BackendSecretNotFoundError{ErrType: errors.BackendSecretNotFoundErrorType})
+	b.record(errVaultUnavailable)
+	allowed, _ := b.allow()
+	assert.True(t, allowed)
+}
+
+func TestCircuitBreakerWindow(t *testing.T) {
+	b := newTestCircuitBreaker(2, time.Minute, time.Hour)
+	b.record(errVaultUnavailable)
+	// The first failure is out of the window, so counting starts again
+	b.firstFailure = time.Now().Add(-2 * time.Minute)
+	b.record(errVaultUnavailable)
+	assert.Equal(t, breakerStateClosed, b.state)
+	b.record(errVaultUnavailable)
+	assert.Equal(t, breakerStateOpen, b.state)
+}
+
+func TestCircuitBreakerHalfOpen(t *testing.T) {
+	b := newTestCircuitBreaker(1, time.Minute, time.Hour)
+	b.record(errVaultUnavailable)
+	b.openedAt = time.Now().Add(-2 * time.Hour)
+
+	// A single probe is let through once the cool-down elapsed
+	allowed, _ := b.allow()
+	assert.True(t, allowed)
+	assert.Equal(t, breakerStateHalfOpen, b.state)
+	allowed, _ = b.allow()
+	assert.False(t, allowed)
+
+	// A failed probe opens the breaker again
+	b.record(errVaultUnavailable)
+	assert.Equal(t, breakerStateOpen, b.state)
+
+	// A successful probe closes it
+	b.openedAt = time.Now().Add(-2 * time.Hour)
+	b.allow()
+	b.record(nil)
+	assert.Equal(t, breakerStateClosed, b.state)
+	allowed, _ = b.allow()
+	assert.True(t, allowed)
+}
+
+func TestCircuitBreakerDisabled(t *testing.T) {
+	b := newCircuitBreaker(0, time.Minute, time.Hour, nil, logger)
+	assert.Nil(t, b)
+	b.record(errVaultUnavailable)
+	allowed, _ := b.allow()
+	assert.True(t, allowed)
+}
+
+func TestReadSecretCircuitOpen(t *testing.T) {
+	cfg := vaultCfg
+	cfg.VaultBreakerThreshold = 2
+	cfg.VaultBreakerCoolDown = time.Hour
+	client, _ := vaultClient(logger, cfg)
+	client.engine, _ = newEngine("kv2")
+
+	mutex.Lock()
+	defer mutex.Unlock()
+	testCfg.secretReadFailures = 3
+	testCfg.secretReadStatusCode = http.StatusServiceUnavailable
+
+	client.metrics.circuitBreakerState.Reset()
+	client.metrics.circuitBreakerTransitionsTotal.Reset()
+	client.metrics.secretReadErrorsTotal.Reset()
+	client.ReadSecret("secret/data/flaky", "foo")
+	client.ReadSecret("secret/data/flaky", "foo")
+	_, err := client.ReadSecret("secret/data/flaky", "foo")
+	metricState, _ := client.metrics.circuitBreakerState.GetMetricWithLabelValues(vaultCfg.VaultURL, vaultCfg.VaultEngine, vaultFakeVersion, vaultFakeClusterID, vaultFakeClusterName, vaultCfg.VaultNamespace)
+	metricOpened, _ := client.metrics.circuitBreakerTransitionsTotal.GetMetricWithLabelValues(vaultCfg.VaultURL, vaultCfg.VaultEngine, vaultFakeVersion, vaultFakeClusterID, vaultFakeClusterName, vaultCfg.VaultNamespace, breakerStateOpen)
+	metricCircuitOpen, _ := client.metrics.secretReadErrorsTotal.GetMetricWithLabelValues(vaultCfg.VaultURL, vaultCfg.VaultEngine, vaultFakeVersion, vaultFakeClusterID, vaultFakeClusterName, vaultCfg.VaultNamespace, "secret/data/flaky", "foo", "", errors.VaultCircuitOpenErrorType, vaultBackendName)
+
+	// The third read isn't sent to Vault
+	assert.True(t, errors.IsVaultCircuitOpen(err))
+	assert.Equal(t, 1, testCfg.secretReadFailures)
+	assert.Equal(t, 2.0, testutil.ToFloat64(metricState))
+	assert.Equal(t, 1.0, testutil.ToFloat64(metricOpened))
+	assert.Equal(t, 1.0, testutil.ToFloat64(metricCircuitOpen))
+	testCfg.secretReadFailures = 0
+}
@@ -0,0 +1,54 @@
+package backend
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"sort"
+
+	"github.com/tuenti/secrets-manager/errors"
+)
+
+// SecretChanged tells whether a secret changed since lastKnownVersion was synced, returning its current version.
+// With KV version 2 only the secret metadata is read. KV version 1 has no versions, so the secret is read and
+// its version is a hash of the data instead
+func (c *client) SecretChanged(path string, lastKnownVersion int) (bool, int, error) {
+	if !c.engineFor(context.Background(), path).versioned() {
+		data, err := c.readSecretData(context.Background(), path, "", "", nil)
+		if err != nil {
+			return false, 0, err
+		}
+		version := hashSecretData(data)
+		return version != lastKnownVersion, version, nil
+	}
+
+	metadata, err := c.ReadSecretMetadata(path)
+	if err != nil {
+		return false, 0, err
+	}
+	currentVersion, ok := metadata["current_version"].(json.Number)
+	if !ok {
+		return false, 0, &errors.BackendSecretTypeError{ErrType: errors.BackendSecretTypeErrorType, Path: path, Key: "current_version", Type: fmt.Sprintf("%T", metadata["current_version"])}
+	}
+	version, err := currentVersion.Int64()
+	if err != nil {
+		return false, 0, err
+	}
+	return int(version) != lastKnownVersion, int(version), nil
+}
+
+// hashSecretData returns a non-negative FNV-1a hash of the secret keys and values, independent of the keys order
+func hashSecretData(data map[string]interface{}) int {
+	keys := make([]string, 0, len(data))
+	for k := range data {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	h := fnv.New32a()
+	for _, k := range keys {
+		fmt.Fprintf(h, "%s\x00%v\x00", k, data[k])
+	}
+	return int(h.Sum32() & 0x7fffffff)
+}
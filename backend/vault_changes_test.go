@@ -0,0 +1,54 @@
+package backend
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/tuenti/secrets-manager/errors"
+)
+
+func TestSecretChangedKv2(t *testing.T) {
+	client, _ := vaultClient(logger, vaultCfg)
+	client.engine, _ = newEngine("kv2")
+
+	changed, version, err := client.SecretChanged("/secret/data/test", 1)
+	assert.Nil(t, err)
+	assert.True(t, changed)
+	assert.Equal(t, 2, version)
+
+	changed, version, err = client.SecretChanged("/secret/data/test", version)
+	assert.Nil(t, err)
+	assert.False(t, changed)
+	assert.Equal(t, 2, version)
+}
+
+func TestSecretChangedKv1(t *testing.T) {
+	client, _ := vaultClient(logger, vaultCfg)
+	client.engine, _ = newEngine("kv1")
+
+	changed, version, err := client.SecretChanged("/secret/test", 0)
+	assert.Nil(t, err)
+	assert.True(t, changed)
+	assert.Equal(t, hashSecretData(map[string]interface{}{"foo": "bar"}), version)
+
+	changed, _, err = client.SecretChanged("/secret/test", version)
+	assert.Nil(t, err)
+	assert.False(t, changed)
+}
+
+func TestSecretChangedNotFound(t *testing.T) {
+	client, _ := vaultClient(logger, vaultCfg)
+	client.engine, _ = newEngine("kv2")
+
+	_, _, err := client.SecretChanged("/secret/data/unknown", 1)
+	assert.True(t, errors.IsBackendSecretNotFound(err))
+}
+
+func TestHashSecretData(t *testing.T) {
+	a := hashSecretData(map[string]interface{}{"foo": "bar", "baz": "qux"})
+	b := hashSecretData(map[string]interface{}{"baz": "qux", "foo": "bar"})
+	c := hashSecretData(map[string]interface{}{"foo": "bar", "baz": "quux"})
+	assert.Equal(t, a, b)
+	assert.NotEqual(t, a, c)
+	assert.True(t, a >= 0)
+}
@@ -0,0 +1,166 @@
+package backend
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/sts"
+	"github.com/tuenti/secrets-manager/errors"
+)
+
+const (
+	awsAuthMethod   = "aws"
+	gcpAuthMethod   = "gcp"
+	azureAuthMethod = "azure"
+
+	defaultAWSMountPath   = "aws"
+	defaultGCPMountPath   = "gcp"
+	defaultAzureMountPath = "azure"
+	defaultAzureResource  = "https://management.azure.com"
+
+	// The global STS endpoint is signed for us-east-1, it's the one Vault expects by default
+	awsSTSEndpoint      = "https://sts.amazonaws.com"
+	awsSTSRegion        = "us-east-1"
+	gcpMetadataEndpoint = "http://metadata.google.internal"
+)
+
+// vaultAWSLogin logins with the aws auth method iam type. A sts:GetCallerIdentity request is signed with the
+// credentials of the AWS SDK default chain and Vault runs it to find out the IAM principal of secrets-manager
+func (c *client) vaultAWSLogin() error {
+	sess, err := awsSession(aws.NewConfig().WithHTTPClient(c.cloudHTTPClient).WithEndpoint(c.awsSTSEndpoint).WithRegion(awsSTSRegion))
+	if err != nil {
+		return &errors.VaultAWSAuthError{ErrType: errors.VaultAWSAuthErrorType, Role: c.awsRole, Err: err}
+	}
+	req, _ := sts.New(sess).GetCallerIdentityRequest(nil)
+	if err := req.Sign(); err != nil {
+		return &errors.VaultAWSAuthError{ErrType: errors.VaultAWSAuthErrorType, Role: c.awsRole, Err: err}
+	}
+	headers, err := json.Marshal(req.HTTPRequest.Header)
+	if err != nil {
+		return &errors.VaultAWSAuthError{ErrType: errors.VaultAWSAuthErrorType, Role: c.awsRole, Err: err}
+	}
+	body, err := ioutil.ReadAll(req.HTTPRequest.Body)
+	if err != nil {
+		return &errors.VaultAWSAuthError{ErrType: errors.VaultAWSAuthErrorType, Role: c.awsRole, Err: err}
+	}
+	params := map[string]interface{}{
+		"iam_http_request_method": req.HTTPRequest.Method,
+		"iam_request_url":         base64.StdEncoding.EncodeToString([]byte(req.HTTPRequest.URL.String())),
+		"iam_request_body":        base64.StdEncoding.EncodeToString(body),
+		"iam_request_headers":     base64.StdEncoding.EncodeToString(headers),
+	}
+	// Without a role Vault uses the friendly name of the IAM principal
+	if c.awsRole != "" {
+		params["role"] = c.awsRole
+	}
+	resp, err := c.logical.Write(fmt.Sprintf("auth/%s/login", c.awsMountPath), params)
+	if err != nil {
+		return &errors.VaultAWSAuthError{ErrType: errors.VaultAWSAuthErrorType, Role: c.awsRole, Err: err}
+	}
+	if err := c.setToken(resp); err != nil {
+		return &errors.VaultAWSAuthError{ErrType: errors.VaultAWSAuthErrorType, Role: c.awsRole, Err: err}
+	}
+	return nil
+}
+
+// vaultGCPLogin logins with the gcp auth method gce type, using an identity token of the instance
+// service account issued by the metadata server
+func (c *client) vaultGCPLogin() error {
+	params := url.Values{"audience": {fmt.Sprintf("http://vault/%s", c.gcpRole)}, "format": {"full"}}
+	req, err := http.NewRequest(http.MethodGet, c.gcpMetadataEndpoint+"/computeMetadata/v1/instance/service-accounts/default/identity?"+params.Encode(), nil)
+	if err != nil {
+		return &errors.VaultGCPAuthError{ErrType: errors.VaultGCPAuthErrorType, Role: c.gcpRole, Err: err}
+	}
+	req.Header.Set("Metadata-Flavor", "Google")
+	jwt, err := c.cloudMetadata(req)
+	if err != nil {
+		return &errors.VaultGCPAuthError{ErrType: errors.VaultGCPAuthErrorType, Role: c.gcpRole, Err: err}
+	}
+	login := map[string]interface{}{
+		"role": c.gcpRole,
+		"jwt":  strings.TrimSpace(string(jwt)),
+	}
+	resp, err := c.logical.Write(fmt.Sprintf("auth/%s/login", c.gcpMountPath), login)
+	if err != nil {
+		return &errors.VaultGCPAuthError{ErrType: errors.VaultGCPAuthErrorType, Role: c.gcpRole, Err: err}
+	}
+	if err := c.setToken(resp); err != nil {
+		return &errors.VaultGCPAuthError{ErrType: errors.VaultGCPAuthErrorType, Role: c.gcpRole, Err: err}
+	}
+	return nil
+}
+
+type azureInstanceMetadata struct {
+	Compute struct {
+		Name              string `json:"name"`
+		ResourceGroupName string `json:"resourceGroupName"`
+		SubscriptionID    string `json:"subscriptionId"`
+		VMScaleSetName    string `json:"vmScaleSetName"`
+	} `json:"compute"`
+}
+
+// vaultAzureLogin logins with the azure auth method, using an access token of the Azure SDK default credential
+// chain. The VM details Vault may bind the role
+// to are read from the instance metadata service when available
+func (c *client) vaultAzureLogin() error {
+	jwt, err := c.azureCredentials.accessToken()
+	if err != nil {
+		return &errors.VaultAzureAuthError{ErrType: errors.VaultAzureAuthErrorType, Role: c.azureRole, Err: err}
+	}
+	login := map[string]interface{}{
+		"role": c.azureRole,
+		"jwt":  jwt,
+	}
+	req, err := http.NewRequest(http.MethodGet, c.azureCredentials.imdsEndpoint+"/metadata/instance?api-version=2017-08-01", nil)
+	if err != nil {
+		return &errors.VaultAzureAuthError{ErrType: errors.VaultAzureAuthErrorType, Role: c.azureRole, Err: err}
+	}
+	req.Header.Set("Metadata", "true")
+	var instance azureInstanceMetadata
+	body, err := c.cloudMetadata(req)
+	if err == nil {
+		err = json.Unmarshal(body, &instance)
+	}
+	if err != nil {
+		c.logger.Info("azure instance metadata not available, login without vm details", "error", err.Error())
+	} else {
+		login["subscription_id"] = instance.Compute.SubscriptionID
+		login["resource_group_name"] = instance.Compute.ResourceGroupName
+		if instance.Compute.VMScaleSetName != "" {
+			login["vmss_name"] = instance.Compute.VMScaleSetName
+		} else {
+			login["vm_name"] = instance.Compute.Name
+		}
+	}
+	resp, err := c.logical.Write(fmt.Sprintf("auth/%s/login", c.azureMountPath), login)
+	if err != nil {
+		return &errors.VaultAzureAuthError{ErrType: errors.VaultAzureAuthErrorType, Role: c.azureRole, Err: err}
+	}
+	if err := c.setToken(resp); err != nil {
+		return &errors.VaultAzureAuthError{ErrType: errors.VaultAzureAuthErrorType, Role: c.azureRole, Err: err}
+	}
+	return nil
+}
+
+// cloudMetadata runs a request against a cloud metadata service and returns the response body
+func (c *client) cloudMetadata(req *http.Request) ([]byte, error) {
+	resp, err := c.cloudHTTPClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s returned %d: %s", req.URL.Path, resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+	return body, nil
+}
@@ -0,0 +1,191 @@
+package backend
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/vault/api"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/tuenti/secrets-manager/errors"
+)
+
+const (
+	fakeAWSRole        = "secrets-manager-aws"
+	fakeAWSAccessKeyID = "AKIAFAKE"
+	fakeGCPRole        = "secrets-manager-gcp"
+	fakeAzureRole      = "secrets-manager-azure"
+	fakeAzureToken     = "azure-access-token"
+
+	awsGetCallerIdentityBody = "Action=GetCallerIdentity&Version=2011-06-15"
+)
+
+func writeLoginResponse(w http.ResponseWriter, ok bool, reason string) {
+	w.Header().Set("Content-Type", "application/json")
+	if !ok {
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprintf(w, `{"errors":["%s"]}`, reason)
+		return
+	}
+	fmt.Fprintf(w, `{"auth":{"client_token":"%s","policies":["secrets-manager"],"lease_duration":2764800,"renewable":true}}`, fakeToken)
+}
+
+func decodeBase64Param(value string) string {
+	decoded, _ := base64.StdEncoding.DecodeString(value)
+	return string(decoded)
+}
+
+func v1AuthAWSLogin(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		Role    string `json:"role"`
+		Method  string `json:"iam_http_request_method"`
+		URL     string `json:"iam_request_url"`
+		Body    string `json:"iam_request_body"`
+		Headers string `json:"iam_request_headers"`
+	}
+	json.NewDecoder(r.Body).Decode(&body)
+	var headers http.Header
+	json.Unmarshal([]byte(decodeBase64Param(body.Headers)), &headers)
+	ok := body.Role == fakeAWSRole &&
+		body.Method == http.MethodPost &&
+		decodeBase64Param(body.URL) == awsSTSEndpoint+"/" &&
+		decodeBase64Param(body.Body) == awsGetCallerIdentityBody &&
+		strings.HasPrefix(headers.Get("Authorization"), "AWS4-HMAC-SHA256 Credential="+fakeAWSAccessKeyID+"/") &&
+		strings.Contains(headers.Get("Authorization"), "/us-east-1/sts/aws4_request")
+	writeLoginResponse(w, ok, "invalid signed sts request")
+}
+
+func v1AuthGCPLogin(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		Role string `json:"role"`
+		JWT  string `json:"jwt"`
+	}
+	json.NewDecoder(r.Body).Decode(&body)
+	writeLoginResponse(w, body.Role == fakeGCPRole && body.JWT == "jwt-for-http://vault/"+fakeGCPRole, "invalid gcp identity token")
+}
+
+func v1AuthAzureLogin(w http.ResponseWriter, r *http.Request) {
+	var body map[string]string
+	json.NewDecoder(r.Body).Decode(&body)
+	ok := body["role"] == fakeAzureRole && body["jwt"] == fakeAzureToken &&
+		body["subscription_id"] == "sub" && body["resource_group_name"] == "rg" && body["vmss_name"] == "pool"
+	writeLoginResponse(w, ok, "invalid azure login")
+}
+
+// newCloudMetadataServer mimics the GCP and Azure metadata services
+func newCloudMetadataServer() *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/computeMetadata/v1/instance/service-accounts/default/identity":
+			if r.Header.Get("Metadata-Flavor") != "Google" || r.URL.Query().Get("format") != "full" {
+				w.WriteHeader(http.StatusForbidden)
+				return
+			}
+			fmt.Fprintf(w, "jwt-for-%s\n", r.URL.Query().Get("audience"))
+		case "/metadata/instance":
+			fmt.Fprint(w, `{"compute":{"name":"pool_0","resourceGroupName":"rg","subscriptionId":"sub","vmScaleSetName":"pool"}}`)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+}
+
+func newCloudAuthTestClient(t *testing.T, authMethod string, metadataURL string) *client {
+	httpClient := new(http.Client)
+	vclient, err := api.NewClient(&api.Config{Address: vaultCfg.VaultURL, HttpClient: httpClient})
+	assert.Nil(t, err)
+	return &client{
+		vclient:             vclient,
+		logical:             vclient.Logical(),
+		authMethod:          authMethod,
+		awsRole:             fakeAWSRole,
+		awsMountPath:        defaultAWSMountPath,
+		awsSTSEndpoint:      awsSTSEndpoint,
+		gcpRole:             fakeGCPRole,
+		gcpMountPath:        defaultGCPMountPath,
+		gcpMetadataEndpoint: metadataURL,
+		azureRole:           fakeAzureRole,
+		azureMountPath:      defaultAzureMountPath,
+		azureCredentials:    newFakeAzureCredentialsProvider(defaultAzureResource, fakeAzureToken, metadataURL),
+		cloudHTTPClient:     httpClient,
+		metrics:             newTestVaultMetrics(),
+		logger:              logger,
+	}
+}
+
+func setAWSTestCredentials() func() {
+	os.Setenv("AWS_ACCESS_KEY_ID", fakeAWSAccessKeyID)
+	os.Setenv("AWS_SECRET_ACCESS_KEY", "secret")
+	return func() {
+		os.Unsetenv("AWS_ACCESS_KEY_ID")
+		os.Unsetenv("AWS_SECRET_ACCESS_KEY")
+	}
+}
+
+func TestVaultLoginAWS(t *testing.T) {
+	defer setAWSTestCredentials()()
+	c := newCloudAuthTestClient(t, awsAuthMethod, "")
+	err := c.vaultLogin()
+	assert.Nil(t, err)
+	assert.Equal(t, fakeToken, c.vclient.Token())
+}
+
+func TestVaultReloginAWSInvalidRole(t *testing.T) {
+	defer setAWSTestCredentials()()
+	c := newCloudAuthTestClient(t, awsAuthMethod, "")
+	c.awsRole = "unknown"
+
+	c.metrics.loginErrorsTotal.Reset()
+	err := c.vaultRelogin()
+	metricLoginErrorsTotal, _ := c.metrics.loginErrorsTotal.GetMetricWithLabelValues(vaultCfg.VaultURL, vaultCfg.VaultEngine, vaultFakeVersion, vaultFakeClusterID, vaultFakeClusterName, vaultCfg.VaultNamespace, errors.VaultAWSAuthErrorType)
+	assert.True(t, errors.IsVaultAWSAuth(err))
+	assert.Equal(t, 1.0, testutil.ToFloat64(metricLoginErrorsTotal))
+}
+
+func TestVaultLoginGCP(t *testing.T) {
+	metadata := newCloudMetadataServer()
+	defer metadata.Close()
+	c := newCloudAuthTestClient(t, gcpAuthMethod, metadata.URL)
+	err := c.vaultLogin()
+	assert.Nil(t, err)
+	assert.Equal(t, fakeToken, c.vclient.Token())
+}
+
+func TestVaultLoginGCPMetadataUnavailable(t *testing.T) {
+	c := newCloudAuthTestClient(t, gcpAuthMethod, "http://127.0.0.1:1")
+	err := c.vaultLogin()
+	assert.True(t, errors.IsVaultGCPAuth(err))
+}
+
+func TestVaultLoginAzure(t *testing.T) {
+	metadata := newCloudMetadataServer()
+	defer metadata.Close()
+	c := newCloudAuthTestClient(t, azureAuthMethod, metadata.URL)
+	err := c.vaultLogin()
+	assert.Nil(t, err)
+	assert.Equal(t, fakeToken, c.vclient.Token())
+}
+
+func TestVaultLoginAzureInvalidRole(t *testing.T) {
+	metadata := newCloudMetadataServer()
+	defer metadata.Close()
+	c := newCloudAuthTestClient(t, azureAuthMethod, metadata.URL)
+	c.azureRole = "unknown"
+	err := c.vaultLogin()
+	assert.True(t, errors.IsVaultAzureAuth(err))
+}
+
+func TestVaultClientCloudAuthRequiresRole(t *testing.T) {
+	for _, method := range []string{gcpAuthMethod, azureAuthMethod} {
+		cfg := vaultCfg
+		cfg.VaultAuthMethod = method
+		_, err := vaultClient(logger, cfg)
+		assert.True(t, errors.IsBackendConfig(err), method)
+	}
+}
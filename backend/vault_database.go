@@ -0,0 +1,37 @@
+package backend
+
+import (
+	"fmt"
+
+	"github.com/tuenti/secrets-manager/errors"
+)
+
+const defaultDatabasePath = "database"
+
+// ReadDynamicCredentials gets a new pair of credentials from the database secrets engine for the given role.
+// The lease of the credentials is renewed along with the Vault token until it can't be renewed anymore
+func (c *client) ReadDynamicCredentials(role string) (map[string]string, error) {
+	path := fmt.Sprintf("%s/creds/%s", defaultDatabasePath, role)
+
+	secret, err := c.ReadRaw(path, nil)
+	if err != nil {
+		return nil, err
+	}
+	if secret.Data == nil {
+		c.metrics.updateVaultSecretReadErrorsTotalMetric(path, "", "", errors.BackendSecretNotFoundErrorType)
+		return nil, &errors.BackendSecretNotFoundError{ErrType: errors.BackendSecretNotFoundErrorType, Path: path}
+	}
+
+	creds := make(map[string]string, 2)
+	for _, key := range []string{"username", "password"} {
+		value, ok := secret.Data[key].(string)
+		if !ok {
+			c.metrics.updateVaultSecretReadErrorsTotalMetric(path, key, "", errors.BackendSecretTypeErrorType)
+			return nil, &errors.BackendSecretTypeError{ErrType: errors.BackendSecretTypeErrorType, Path: path, Key: key, Type: fmt.Sprintf("%T", secret.Data[key])}
+		}
+		creds[key] = value
+	}
+
+	c.leaseRenewer.register(path, secret)
+	return creds, nil
+}
@@ -0,0 +1,66 @@
+package backend
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/tuenti/secrets-manager/errors"
+)
+
+const (
+	databaseFakeRole     = "readonly"
+	databaseFakeLeaseID  = "database/creds/readonly/2f6a614c"
+	databaseFakeUsername = "v-approle-readonly-x8AB1"
+	databaseFakePassword = "A1a-3gkMQpTq7Ye0VCvA"
+	databaseLeaseTTL     = 3600
+)
+
+func v1DatabaseCreds(w http.ResponseWriter, r *http.Request) {
+	if mux.Vars(r)["role"] != databaseFakeRole {
+		v1NotFound(w, r)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	fmt.Fprintf(w, `{"lease_id":"%s","lease_duration":%d,"renewable":true,"data":{"username":"%s","password":"%s"}}`,
+		databaseFakeLeaseID, databaseLeaseTTL, databaseFakeUsername, databaseFakePassword)
+}
+
+func v1SysLeasesRenew(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		LeaseID string `json:"lease_id"`
+	}
+	json.NewDecoder(r.Body).Decode(&body)
+	w.Header().Set("Content-Type", "application/json")
+	if body.LeaseID != databaseFakeLeaseID {
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprint(w, `{"errors":["lease not found"]}`)
+		return
+	}
+	mutex.Lock()
+	testCfg.leaseRenewals++
+	mutex.Unlock()
+	fmt.Fprintf(w, `{"lease_id":"%s","lease_duration":%d,"renewable":true}`, databaseFakeLeaseID, databaseLeaseTTL)
+}
+
+func TestReadDynamicCredentials(t *testing.T) {
+	client, _ := vaultClient(logger, vaultCfg)
+
+	creds, err := client.ReadDynamicCredentials(databaseFakeRole)
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]string{"username": databaseFakeUsername, "password": databaseFakePassword}, creds)
+	assert.Contains(t, client.leaseRenewer.leases, databaseFakeLeaseID)
+	assert.True(t, client.leaseRenewer.leases[databaseFakeLeaseID].renewable)
+}
+
+func TestReadDynamicCredentialsRoleNotFound(t *testing.T) {
+	client, _ := vaultClient(logger, vaultCfg)
+
+	creds, err := client.ReadDynamicCredentials("unknown")
+	assert.Nil(t, creds)
+	assert.True(t, errors.IsBackendSecretNotFound(err))
+	assert.Empty(t, client.leaseRenewer.leases)
+}
@@ -1,36 +1,169 @@
 package backend
 
 import (
+	"fmt"
+	"strings"
+
 	"github.com/hashicorp/vault/api"
 	"github.com/tuenti/secrets-manager/errors"
 )
 
 const (
-	kvEngineV1Name = "kv1"
-	kvEngineV2Name = "kv2"
+	kvEngineV1Name    = "kv1"
+	kvEngineV2Name    = "kv2"
+	transitEngineName = "transit"
+
+	defaultTransitPath = "transit"
 )
 
 type engine interface {
-	getData(s *api.Secret) map[string]interface{}
+	getData(path string, s *api.Secret) (map[string]interface{}, error)
+	getName() string
+	versioned() bool
+	metadataPath(path string) string
+	wrapData(data map[string]interface{}) map[string]interface{}
 }
 
 type kvEngineV1 struct {
 	name string
 }
 
+// kvEngineV2 rewrites paths under mount when it's known. Otherwise the first segment of a path is taken as its mount
 type kvEngineV2 struct {
+	name  string
+	mount string
+}
+
+// transitEngine doesn't store secrets, it decrypts ciphertexts encrypted with Vault transit keys
+type transitEngine struct {
 	name string
 }
 
-func (e kvEngineV1) getData(s *api.Secret) map[string]interface{} {
-	return s.Data
+func (e kvEngineV1) getData(path string, s *api.Secret) (map[string]interface{}, error) {
+	return s.Data, nil
+}
+
+// getData returns the secret data of a KV version 2 read. Vault still answers with the version
+// metadata when the version was soft-deleted or destroyed, so those are told apart from absent paths
+func (e kvEngineV2) getData(path string, s *api.Secret) (map[string]interface{}, error) {
+	if s.Data["data"] != nil {
+		data, ok := s.Data["data"].(map[string]interface{})
+		if !ok {
+			// A KV version 1 secret with a data key read as version 2
+			return nil, &errors.BackendSecretTypeError{ErrType: errors.BackendSecretTypeErrorType, Path: path, Key: "data", Type: fmt.Sprintf("%T", s.Data["data"])}
+		}
+		return data, nil
+	}
+	metadata, ok := s.Data["metadata"].(map[string]interface{})
+	if !ok {
+		return nil, nil
+	}
+	version := fmt.Sprintf("%v", metadata["version"])
+	if destroyed, _ := metadata["destroyed"].(bool); destroyed {
+		return nil, &errors.VaultSecretDestroyedError{ErrType: errors.VaultSecretDestroyedErrorType, Path: path, Version: version}
+	}
+	if deletionTime, _ := metadata["deletion_time"].(string); deletionTime != "" {
+		return nil, &errors.VaultSecretDeletedError{ErrType: errors.VaultSecretDeletedErrorType, Path: path, Version: version, DeletionTime: deletionTime}
+	}
+	return nil, nil
+}
+
+func (e transitEngine) getData(path string, s *api.Secret) (map[string]interface{}, error) {
+	return s.Data, nil
+}
+
+func (e kvEngineV1) getName() string {
+	return e.name
+}
+
+func (e kvEngineV2) getName() string {
+	return e.name
+}
+
+func (e transitEngine) getName() string {
+	return e.name
+}
+
+func (e kvEngineV1) versioned() bool {
+	return false
+}
+
+func (e kvEngineV2) versioned() bool {
+	return true
+}
+
+func (e transitEngine) versioned() bool {
+	return false
+}
+
+func (e kvEngineV1) metadataPath(path string) string {
+	return path
+}
+
+// metadataPath rewrites a KV version 2 path to its metadata form, used to list and inspect secrets.
+// Both secret/data/foo and secret/foo would become secret/metadata/foo, and with the mount set to
+// teams/kv, teams/kv/data/foo would become teams/kv/metadata/foo
+func (e kvEngineV2) metadataPath(path string) string {
+	segments := strings.Split(strings.Trim(path, "/"), "/")
+	i := e.mountDepth(segments)
+	if len(segments) <= i {
+		return strings.Join(append(segments, "metadata"), "/")
+	}
+	switch segments[i] {
+	case "metadata":
+	case "data":
+		segments[i] = "metadata"
+	default:
+		segments = append(append(append([]string{}, segments[:i]...), "metadata"), segments[i:]...)
+	}
+	return strings.Join(segments, "/")
+}
+
+// mountDepth returns the number of segments of the mount of a path
+func (e kvEngineV2) mountDepth(segments []string) int {
+	if e.mount == "" {
+		return 1
+	}
+	mount := strings.Split(e.mount, "/")
+	if len(segments) < len(mount) {
+		return 1
+	}
+	for i := range mount {
+		if segments[i] != mount[i] {
+			return 1
+		}
+	}
+	return len(mount)
+}
+
+func (e transitEngine) metadataPath(path string) string {
+	return path
+}
+
+func (e kvEngineV1) wrapData(data map[string]interface{}) map[string]interface{} {
+	return data
+}
+
+// wrapData puts the secret data inside the envelope expected by KV version 2 writes
+func (e kvEngineV2) wrapData(data map[string]interface{}) map[string]interface{} {
+	return map[string]interface{}{"data": data}
+}
+
+func (e transitEngine) wrapData(data map[string]interface{}) map[string]interface{} {
+	return data
+}
+
+func (e transitEngine) decryptPath(keyName string) string {
+	return fmt.Sprintf("%s/decrypt/%s", defaultTransitPath, keyName)
 }
 
-func (e kvEngineV2) getData(s *api.Secret) map[string]interface{} {
-	if s.Data["data"] == nil {
-		return nil
+// engineWithMount returns e rewriting paths under mount, only KV version 2 paths are rewritten
+func engineWithMount(e engine, mount string) engine {
+	if kv2, ok := e.(kvEngineV2); ok {
+		kv2.mount = strings.Trim(mount, "/")
+		return kv2
 	}
-	return s.Data["data"].(map[string]interface{})
+	return e
 }
 
 func newEngine(eng string) (engine, error) {
@@ -42,6 +175,8 @@ func newEngine(eng string) (engine, error) {
 		return kvEngineV1{name: kvEngineV1Name}, nil
 	case kvEngineV2Name:
 		return kvEngineV2{name: kvEngineV2Name}, nil
+	case transitEngineName:
+		return transitEngine{name: transitEngineName}, nil
 	default:
 		return nil, &errors.VaultEngineNotImplementedError{ErrType: errors.VaultEngineNotImplementedErrorType, Engine: eng}
 	}
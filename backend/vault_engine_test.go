@@ -1,6 +1,7 @@
 package backend
 
 import (
+	"encoding/json"
 	"fmt"
 	"testing"
 
@@ -23,6 +24,15 @@ func TestNewEngineKV2(t *testing.T) {
 	assert.Equal(t, eng, engine.(kvEngineV2).name)
 }
 
+func TestNewEngineTransit(t *testing.T) {
+	eng := "transit"
+	engine, err := newEngine(eng)
+	assert.Nil(t, err)
+	assert.Equal(t, eng, engine.(transitEngine).name)
+	assert.False(t, engine.versioned())
+	assert.Equal(t, "transit/decrypt/foo", engine.(transitEngine).decryptPath("foo"))
+}
+
 func TestNotImplementedEngine(t *testing.T) {
 	eng := "kv3"
 	_, err := newEngine(eng)
@@ -35,7 +45,8 @@ func TestGetDataKv1(t *testing.T) {
 	data["foo"] = "bar"
 	s := &api.Secret{Data: data}
 	engine, _ := newEngine("kv1")
-	d := engine.getData(s)
+	d, err := engine.getData("secret/data/foo", s)
+	assert.Nil(t, err)
 	assert.NotNil(t, d)
 	assert.Equal(t, data, d)
 }
@@ -47,7 +58,8 @@ func TestGetDataKv2(t *testing.T) {
 	data["data"] = nested
 	s := &api.Secret{Data: data}
 	engine, _ := newEngine("kv2")
-	d := engine.getData(s)
+	d, err := engine.getData("secret/data/foo", s)
+	assert.Nil(t, err)
 	assert.NotNil(t, d)
 	assert.Equal(t, nested, d)
 }
@@ -59,7 +71,82 @@ func TestGetDataKv2WithKv1Engine(t *testing.T) {
 	data["data"] = nested
 	s := &api.Secret{Data: data}
 	engine, _ := newEngine("kv1")
-	d := engine.getData(s)
+	d, err := engine.getData("secret/data/foo", s)
+	assert.Nil(t, err)
 	assert.NotNil(t, d)
 	assert.Equal(t, data, d)
 }
+
+func TestGetDataKv2NonMapData(t *testing.T) {
+	engine, _ := newEngine("kv2")
+	s := &api.Secret{Data: map[string]interface{}{"data": "a kv1 value"}}
+	d, err := engine.getData("secret/foo", s)
+	assert.Nil(t, d)
+	assert.True(t, errors.IsBackendSecretType(err))
+	assert.EqualError(t, err, fmt.Sprintf("[%s] secret key data at secret/foo has unsupported type string", errors.BackendSecretTypeErrorType))
+}
+
+func TestGetDataKv2Deleted(t *testing.T) {
+	engine, _ := newEngine("kv2")
+	metadata := map[string]interface{}{"deletion_time": "2019-06-01T10:00:00Z", "destroyed": false, "version": json.Number("2")}
+	s := &api.Secret{Data: map[string]interface{}{"data": nil, "metadata": metadata}}
+	d, err := engine.getData("secret/data/foo", s)
+	assert.Nil(t, d)
+	assert.EqualError(t, err, fmt.Sprintf("[%s] secret secret/data/foo version 2 was deleted at 2019-06-01T10:00:00Z", errors.VaultSecretDeletedErrorType))
+}
+
+func TestGetDataKv2Destroyed(t *testing.T) {
+	engine, _ := newEngine("kv2")
+	metadata := map[string]interface{}{"deletion_time": "", "destroyed": true, "version": json.Number("3")}
+	s := &api.Secret{Data: map[string]interface{}{"data": nil, "metadata": metadata}}
+	d, err := engine.getData("secret/data/foo", s)
+	assert.Nil(t, d)
+	assert.True(t, errors.IsVaultSecretDestroyed(err))
+}
+
+func TestGetDataKv2Absent(t *testing.T) {
+	engine, _ := newEngine("kv2")
+	s := &api.Secret{Data: map[string]interface{}{"data": nil}}
+	d, err := engine.getData("secret/data/foo", s)
+	assert.Nil(t, d)
+	assert.Nil(t, err)
+}
+
+func TestEngineVersioned(t *testing.T) {
+	kv1, _ := newEngine("kv1")
+	kv2, _ := newEngine("kv2")
+	assert.False(t, kv1.versioned())
+	assert.True(t, kv2.versioned())
+}
+
+func TestMetadataPathKv1(t *testing.T) {
+	engine, _ := newEngine("kv1")
+	assert.Equal(t, "secret/foo", engine.metadataPath("secret/foo"))
+}
+
+func TestMetadataPathKv2(t *testing.T) {
+	engine, _ := newEngine("kv2")
+	assert.Equal(t, "secret/metadata/foo/bar", engine.metadataPath("/secret/data/foo/bar"))
+	assert.Equal(t, "secret/metadata/foo/bar", engine.metadataPath("secret/foo/bar/"))
+	assert.Equal(t, "secret/metadata/foo", engine.metadataPath("secret/metadata/foo"))
+	assert.Equal(t, "secret/metadata", engine.metadataPath("secret"))
+}
+
+func TestMetadataPathKv2Mount(t *testing.T) {
+	engine := engineWithMount(kvEngineV2{name: kvEngineV2Name}, "kv-apps")
+	assert.Equal(t, "kv-apps/metadata/foo", engine.metadataPath("kv-apps/data/foo"))
+	assert.Equal(t, "kv-apps/metadata/foo", engine.metadataPath("kv-apps/foo"))
+
+	engine = engineWithMount(kvEngineV2{name: kvEngineV2Name}, "/teams/payments/kv/")
+	assert.Equal(t, "teams/payments/kv/metadata/foo/bar", engine.metadataPath("teams/payments/kv/data/foo/bar"))
+	assert.Equal(t, "teams/payments/kv/metadata/foo", engine.metadataPath("/teams/payments/kv/foo"))
+	assert.Equal(t, "teams/payments/kv/metadata", engine.metadataPath("teams/payments/kv"))
+	// Paths out of the mount, or shorter than it, are rewritten after their first segment
+	assert.Equal(t, "teams/metadata/payments", engine.metadataPath("teams/payments"))
+	assert.Equal(t, "secret/metadata/foo", engine.metadataPath("secret/data/foo"))
+}
+
+func TestEngineWithMountKv1(t *testing.T) {
+	engine, _ := newEngine("kv1")
+	assert.Equal(t, "legacy/foo", engineWithMount(engine, "legacy").metadataPath("legacy/foo"))
+}
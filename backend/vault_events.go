@@ -0,0 +1,158 @@
+package backend
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+const (
+	eventsSubscribePath = "/v1/sys/events/subscribe/"
+	// secretEventTypes matches the events of every KV engine version, e.g. kv-v2/data-write or kv-v1/delete
+	secretEventTypes  = "kv*"
+	eventsMinBackoff  = time.Second
+	eventsMaxBackoff  = time.Minute
+	eventsDialTimeout = 30 * time.Second
+)
+
+// EventWatcher is implemented by backends able to notify secret changes as they happen, so they are synced
+// without waiting for the next reconciliation
+type EventWatcher interface {
+	// WatchSecretEvents calls notify with the path of every secret changed until ctx is done. It returns nil
+	// right away when the backend can't send events, leaving changes to be picked up by polling
+	WatchSecretEvents(ctx context.Context, notify func(path string)) error
+}
+
+// vaultEvent is the part of the Vault event notifications holding the changed path
+type vaultEvent struct {
+	Data struct {
+		EventType string `json:"event_type"`
+		Event     struct {
+			Metadata struct {
+				Path string `json:"path"`
+			} `json:"metadata"`
+		} `json:"event"`
+	} `json:"data"`
+}
+
+// WatchSecretEvents subscribes to the KV events of Vault (sys/events/subscribe), available since Vault 1.13 as
+// an experimental feature, subscribing again whenever the connection is lost. Vault versions without events
+// refuse the first subscription, and nil is returned
+func (c *client) WatchSecretEvents(ctx context.Context, notify func(path string)) error {
+	subscribed := false
+	backoff := eventsMinBackoff
+	for {
+		connected, err := c.watchEvents(ctx, notify)
+		if ctx.Err() != nil {
+			return nil
+		}
+		if !subscribed && !connected && isEventsNotAvailable(err) {
+			c.logger.Info("vault events are not available, secret changes are picked up by polling", "error", err.Error())
+			return nil
+		}
+		if connected {
+			subscribed = true
+			backoff = eventsMinBackoff
+		}
+		c.logger.Info("WARNING: vault events subscription lost, subscribing again", "error", err.Error(), "backoff", backoff.String())
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+		if backoff > eventsMaxBackoff {
+			backoff = eventsMaxBackoff
+		}
+	}
+}
+
+// watchEvents notifies the changed paths until the subscription fails or ctx is done, and returns whether it
+// was subscribed at all
+func (c *client) watchEvents(ctx context.Context, notify func(path string)) (bool, error) {
+	conn, err := c.dialEvents(ctx)
+	if err != nil {
+		return false, err
+	}
+	defer conn.Close()
+	c.logger.V(1).Info("subscribed to vault events", "vault_event_types", secretEventTypes)
+
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+			conn.Close()
+		case <-done:
+		}
+	}()
+
+	for {
+		var event vaultEvent
+		if err := conn.ReadJSON(&event); err != nil {
+			return true, err
+		}
+		c.metrics.updateVaultEventsReceivedTotalMetric(event.Data.EventType)
+		if path := event.Data.Event.Metadata.Path; path != "" {
+			c.logger.V(1).Info("vault secret changed", "path", path, "vault_event_type", event.Data.EventType)
+			notify(path)
+		}
+	}
+}
+
+// eventsStatusError is returned when Vault answers the subscription with an error status instead of upgrading
+// the connection
+type eventsStatusError struct {
+	status string
+}
+
+func (e *eventsStatusError) Error() string {
+	return fmt.Sprintf("vault refused the events subscription: %s", e.status)
+}
+
+// dialEvents opens the websocket the KV events are sent through, with the same token, namespace, proxy and TLS
+// settings as any other request
+func (c *client) dialEvents(ctx context.Context) (*websocket.Conn, error) {
+	u, err := url.Parse(c.ActiveAddress())
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme == "https" {
+		u.Scheme = "wss"
+	} else {
+		u.Scheme = "ws"
+	}
+	u.Path = strings.TrimRight(u.Path, "/") + eventsSubscribePath + secretEventTypes
+	u.RawQuery = "json=true"
+
+	header := http.Header{}
+	for k, v := range c.vclient.Headers() {
+		header[k] = v
+	}
+	header.Set("X-Vault-Token", c.vclient.Token())
+	dialer := &websocket.Dialer{
+		Proxy:            http.ProxyFromEnvironment,
+		HandshakeTimeout: eventsDialTimeout,
+	}
+	if c.transport != nil {
+		dialer.Proxy = c.transport.Proxy
+		dialer.TLSClientConfig = c.transport.TLSClientConfig
+	}
+	conn, resp, err := dialer.DialContext(ctx, u.String(), header)
+	if err == websocket.ErrBadHandshake && resp != nil {
+		return nil, &eventsStatusError{status: resp.Status}
+	}
+	return conn, err
+}
+
+// isEventsNotAvailable returns true when Vault answered the subscription with an error status instead of
+// upgrading the connection, e.g. with Vault versions without events
+func isEventsNotAvailable(err error) bool {
+	_, ok := err.(*eventsStatusError)
+	return ok
+}
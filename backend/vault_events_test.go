@@ -0,0 +1,157 @@
+package backend
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+// v1SysEventsSubscribe sends a kv-v2/data-write event for every path in testCfg.events and keeps the
+// subscription open. It answers 404, as Vault versions without events, when testCfg.eventsDisabled is set
+func v1SysEventsSubscribe(w http.ResponseWriter, r *http.Request) {
+	mutex.Lock()
+	disabled := testCfg.eventsDisabled
+	events := testCfg.events
+	mutex.Unlock()
+	if disabled || r.URL.Query().Get("json") != "true" || r.Header.Get("X-Vault-Token") == "" {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	conn, err := new(websocket.Upgrader).Upgrade(w, r, nil)
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+	for _, path := range events {
+		event := fmt.Sprintf(`{"id":"1","source":"vault://vault-mock","data":{"event":{"id":"1","metadata":{"path":"%s","current_version":"2"}},"event_type":"kv-v2/data-write"}}`, path)
+		conn.WriteMessage(websocket.TextMessage, []byte(event))
+	}
+	for {
+		if _, _, err := conn.ReadMessage(); err != nil {
+			return
+		}
+	}
+}
+
+// newConnectProxy returns a proxy tunneling CONNECT requests, counting them in tunnels
+func newConnectProxy(tunnels *int32) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodConnect {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		upstream, err := net.Dial("tcp", r.Host)
+		if err != nil {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		atomic.AddInt32(tunnels, 1)
+		w.WriteHeader(http.StatusOK)
+		conn, _, err := w.(http.Hijacker).Hijack()
+		if err != nil {
+			upstream.Close()
+			return
+		}
+		go func() {
+			io.Copy(upstream, conn)
+			upstream.Close()
+		}()
+		io.Copy(conn, upstream)
+		conn.Close()
+	}))
+}
+
+func TestWatchSecretEvents(t *testing.T) {
+	client, _ := vaultClient(logger, vaultCfg)
+	mutex.Lock()
+	testCfg.events = []string{"secret/data/foo", "secret/data/bar"}
+	mutex.Unlock()
+	client.metrics.eventsReceivedTotal.Reset()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	paths := make(chan string, 2)
+	done := make(chan error)
+	go func() {
+		done <- client.WatchSecretEvents(ctx, func(path string) { paths <- path })
+	}()
+
+	assert.Equal(t, "secret/data/foo", <-paths)
+	assert.Equal(t, "secret/data/bar", <-paths)
+	metric, _ := client.metrics.eventsReceivedTotal.GetMetricWithLabelValues(vaultCfg.VaultURL, vaultCfg.VaultEngine, vaultFakeVersion, vaultFakeClusterID, vaultFakeClusterName, vaultCfg.VaultNamespace, "kv-v2/data-write")
+	assert.Equal(t, 2.0, testutil.ToFloat64(metric))
+
+	cancel()
+	select {
+	case err := <-done:
+		assert.Nil(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("the subscription was not closed")
+	}
+
+	mutex.Lock()
+	testCfg.events = nil
+	mutex.Unlock()
+}
+
+func TestWatchSecretEventsNotAvailable(t *testing.T) {
+	client, _ := vaultClient(logger, vaultCfg)
+	mutex.Lock()
+	testCfg.eventsDisabled = true
+	mutex.Unlock()
+
+	// Vault without events falls back to polling right away
+	err := client.WatchSecretEvents(context.Background(), func(path string) {
+		t.Errorf("unexpected event for %s", path)
+	})
+	assert.Nil(t, err)
+
+	mutex.Lock()
+	testCfg.eventsDisabled = false
+	mutex.Unlock()
+}
+
+func TestWatchSecretEventsProxy(t *testing.T) {
+	var tunnels int32
+	proxy := newConnectProxy(&tunnels)
+	defer proxy.Close()
+	client, _ := vaultClient(logger, vaultCfg)
+	// The proxy of the vault.proxy-url and environment settings skips loopback addresses like the mock one
+	proxyURL, _ := url.Parse(proxy.URL)
+	client.transport.Proxy = http.ProxyURL(proxyURL)
+	mutex.Lock()
+	testCfg.events = []string{"secret/data/foo"}
+	mutex.Unlock()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	paths := make(chan string, 1)
+	done := make(chan error)
+	go func() {
+		done <- client.WatchSecretEvents(ctx, func(path string) { paths <- path })
+	}()
+
+	assert.Equal(t, "secret/data/foo", <-paths)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&tunnels))
+
+	cancel()
+	select {
+	case err := <-done:
+		assert.Nil(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("the subscription was not closed")
+	}
+
+	mutex.Lock()
+	testCfg.events = nil
+	mutex.Unlock()
+}
@@ -0,0 +1,162 @@
+package backend
+
+import (
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// vaultFailoverThreshold is the number of consecutive failed reads against the active address before failing over
+const vaultFailoverThreshold = 3
+
+// ActiveAddressReporter is implemented by backends able to fail over between several addresses
+type ActiveAddressReporter interface {
+	ActiveAddress() string
+}
+
+// vaultFailover keeps the Vault addresses the client can use, the primary one first, and which of them is active
+type vaultFailover struct {
+	mutex        sync.Mutex
+	addresses    []string
+	active       int
+	failures     int
+	minInterval  time.Duration
+	lastFailover time.Time
+}
+
+// vaultAddresses returns the primary Vault address followed by the fallback ones. VaultURL may contain
+// several comma-separated addresses, which go before VaultFallbackURLs
+func vaultAddresses(cfg Config) []string {
+	addresses := []string{}
+	for _, addr := range append(strings.Split(cfg.VaultURL, ","), cfg.VaultFallbackURLs...) {
+		if addr = strings.TrimSpace(addr); addr != "" {
+			addresses = append(addresses, addr)
+		}
+	}
+	return addresses
+}
+
+func (f *vaultFailover) primary() string {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	if len(f.addresses) == 0 {
+		return ""
+	}
+	return f.addresses[0]
+}
+
+func (f *vaultFailover) usingPrimary() bool {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	return f.active == 0
+}
+
+func (f *vaultFailover) setActive(i int) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	f.active = i
+	f.failures = 0
+}
+
+func (f *vaultFailover) recordSuccess() {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	f.failures = 0
+}
+
+// recordFailure counts a failed request against the active address, returning the next address to use
+// when the failures threshold is reached and the last failover happened more than minInterval ago
+func (f *vaultFailover) recordFailure(now time.Time) (string, bool) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	f.failures++
+	if len(f.addresses) < 2 || f.failures < vaultFailoverThreshold || now.Sub(f.lastFailover) < f.minInterval {
+		return "", false
+	}
+	f.active = (f.active + 1) % len(f.addresses)
+	f.failures = 0
+	f.lastFailover = now
+	return f.addresses[f.active], true
+}
+
+// recoverPrimary makes the primary address the active one again
+func (f *vaultFailover) recoverPrimary(now time.Time) string {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	f.active = 0
+	f.failures = 0
+	f.lastFailover = now
+	return f.addresses[0]
+}
+
+// ActiveAddress returns the Vault address requests are currently sent to
+func (c *client) ActiveAddress() string {
+	return c.vclient.Address()
+}
+
+// selectAddress picks the first address whose health endpoint answers, the primary one is kept when none does
+func (c *client) selectAddress() {
+	for i, addr := range c.failover.addresses {
+		if err := c.vclient.SetAddress(addr); err != nil {
+			c.logger.Error(err, "invalid vault address", "vault_address", addr)
+			continue
+		}
+		if _, err := c.vclient.Sys().Health(); err != nil {
+			c.logger.Info("WARNING: vault address is unreachable", "vault_address", addr, "error", err.Error())
+			continue
+		}
+		c.failover.setActive(i)
+		return
+	}
+	c.failover.setActive(0)
+	c.vclient.SetAddress(c.failover.primary())
+}
+
+// recordReadResult records the last read error and fails over to the next address when reads keep failing with
+// connection or server errors
+func (c *client) recordReadResult(err error) {
+	if err != nil {
+		c.health.recordReadError(err, time.Now())
+	}
+	if err == nil || !isRetryable(err) {
+		c.failover.recordSuccess()
+		return
+	}
+	if addr, ok := c.failover.recordFailure(time.Now()); ok {
+		c.switchAddress(addr, "vault reads keep failing, failing over")
+	}
+}
+
+// checkPrimary goes back to the primary address as soon as it is healthy again
+func (c *client) checkPrimary() {
+	if c.failover.usingPrimary() {
+		return
+	}
+	primary, err := url.Parse(c.failover.primary())
+	if err != nil {
+		return
+	}
+	r := c.vclient.NewRequest("GET", "/v1/sys/health")
+	r.URL.Scheme = primary.Scheme
+	r.URL.Host = primary.Host
+	r.Params.Set("standbyok", "true")
+	r.Params.Set("perfstandbyok", "true")
+	resp, err := c.vclient.RawRequest(r)
+	if resp != nil {
+		resp.Body.Close()
+	}
+	if err != nil {
+		return
+	}
+	c.switchAddress(c.failover.recoverPrimary(time.Now()), "primary vault address recovered")
+}
+
+func (c *client) switchAddress(addr string, msg string) {
+	if err := c.vclient.SetAddress(addr); err != nil {
+		c.logger.Error(err, "unable to switch vault address", "vault_address", addr)
+		return
+	}
+	c.logger.Info(msg, "vault_address", addr)
+	c.metrics.updateVaultFailoversTotalMetric(addr)
+}
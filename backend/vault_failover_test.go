@@ -0,0 +1,97 @@
+package backend
+
+import (
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestVaultAddresses(t *testing.T) {
+	assert.Equal(t, []string{"https://vault-a:8200"}, vaultAddresses(Config{VaultURL: "https://vault-a:8200"}))
+	assert.Equal(t, []string{"https://vault-a:8200", "https://vault-b:8200", "https://vault-c:8200"}, vaultAddresses(Config{
+		VaultURL:          "https://vault-a:8200, https://vault-b:8200",
+		VaultFallbackURLs: []string{"https://vault-c:8200"},
+	}))
+}
+
+func TestVaultFailoverRateLimited(t *testing.T) {
+	f := vaultFailover{addresses: []string{"a", "b"}, minInterval: time.Minute}
+	now := time.Now()
+	for i := 1; i < vaultFailoverThreshold; i++ {
+		_, ok := f.recordFailure(now)
+		assert.False(t, ok)
+	}
+	addr, ok := f.recordFailure(now)
+	assert.True(t, ok)
+	assert.Equal(t, "b", addr)
+
+	for i := 0; i < vaultFailoverThreshold; i++ {
+		_, ok = f.recordFailure(now.Add(time.Second))
+		assert.False(t, ok)
+	}
+	addr, ok = f.recordFailure(now.Add(2 * time.Minute))
+	assert.True(t, ok)
+	assert.Equal(t, "a", addr)
+}
+
+func TestVaultFailoverSingleAddress(t *testing.T) {
+	f := vaultFailover{addresses: []string{"a"}}
+	for i := 0; i < 2*vaultFailoverThreshold; i++ {
+		_, ok := f.recordFailure(time.Now())
+		assert.False(t, ok)
+	}
+}
+
+func TestVaultClientSelectsReachableAddress(t *testing.T) {
+	unreachable := httptest.NewServer(nil)
+	unreachable.Close()
+
+	cfg := vaultCfg
+	cfg.VaultURL = unreachable.URL
+	cfg.VaultFallbackURLs = []string{server.URL}
+	client, err := vaultClient(logger, cfg)
+	assert.Nil(t, err)
+	assert.Equal(t, server.URL, client.ActiveAddress())
+}
+
+func TestVaultFailoverOnReadErrors(t *testing.T) {
+	primary := httptest.NewServer(server.Config.Handler)
+	cfg := vaultCfg
+	cfg.VaultURL = primary.URL
+	cfg.VaultFallbackURLs = []string{server.URL}
+	client, _ := vaultClient(logger, cfg)
+	assert.Equal(t, primary.URL, client.ActiveAddress())
+	primary.Close()
+
+	client.metrics.failoversTotal.Reset()
+	for i := 0; i < vaultFailoverThreshold; i++ {
+		_, err := client.ReadSecret("/secret/data/test", "foo")
+		assert.NotNil(t, err)
+	}
+	assert.Equal(t, server.URL, client.ActiveAddress())
+	metric, _ := client.metrics.failoversTotal.GetMetricWithLabelValues(primary.URL, cfg.VaultEngine, vaultFakeVersion, vaultFakeClusterID, vaultFakeClusterName, cfg.VaultNamespace, server.URL)
+	assert.Equal(t, 1.0, testutil.ToFloat64(metric))
+
+	value, err := client.ReadSecret("/secret/data/test", "foo")
+	assert.Nil(t, err)
+	assert.Equal(t, "bar", value)
+}
+
+func TestVaultFailoverPrimaryRecovered(t *testing.T) {
+	fallback := httptest.NewServer(server.Config.Handler)
+	defer fallback.Close()
+	cfg := vaultCfg
+	cfg.VaultFallbackURLs = []string{fallback.URL}
+	client, _ := vaultClient(logger, cfg)
+
+	client.switchAddress(fallback.URL, "failing over")
+	client.failover.setActive(1)
+	assert.Equal(t, fallback.URL, client.ActiveAddress())
+
+	client.pollHealth()
+	assert.Equal(t, server.URL, client.ActiveAddress())
+	assert.True(t, client.failover.usingPrimary())
+}
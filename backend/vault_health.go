@@ -0,0 +1,218 @@
+package backend
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/vault/api"
+)
+
+// HealthChecker is implemented by backends able to tell whether they can serve secrets
+type HealthChecker interface {
+	Ready() error
+}
+
+// vaultHealth keeps the state the token renewer updates and the readiness probe reads
+type vaultHealth struct {
+	mutex           sync.RWMutex
+	threshold       time.Duration
+	lastHealthCheck time.Time
+	tokenExpiration time.Time
+	sealed          bool
+	tokenRenewable  bool
+	tokenType       string
+	lastRenewal     time.Time
+	lastReadError   string
+	lastReadErrorAt time.Time
+	clusterName     string
+	version         string
+	// failureThreshold failed health polls in a row make readiness go down, and successThreshold successful
+	// ones bring it back up. A failureThreshold of 0 reports every poll as is
+	failureThreshold     int
+	successThreshold     int
+	consecutiveFailures  int
+	consecutiveSuccesses int
+	pollDown             bool
+}
+
+func newVaultHealth(cfg Config) vaultHealth {
+	successThreshold := cfg.VaultHealthSuccessThreshold
+	if successThreshold < 1 {
+		successThreshold = 1
+	}
+	return vaultHealth{
+		threshold:        cfg.VaultReadinessThreshold,
+		failureThreshold: cfg.VaultHealthFailureThreshold,
+		successThreshold: successThreshold,
+	}
+}
+
+func (h *vaultHealth) recordHealthCheck(t time.Time) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	h.lastHealthCheck = t
+}
+
+func (h *vaultHealth) recordSealed(sealed bool) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	h.sealed = sealed
+}
+
+// isSealed returns the seal status reported by the last successful health check
+func (h *vaultHealth) isSealed() bool {
+	h.mutex.RLock()
+	defer h.mutex.RUnlock()
+	return h.sealed
+}
+
+// recordTokenTTL updates when the token expires, a ttl of 0 means the token never expires
+func (h *vaultHealth) recordTokenTTL(ttl int64) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	if ttl <= 0 {
+		h.tokenExpiration = time.Time{}
+		return
+	}
+	h.tokenExpiration = time.Now().Add(time.Duration(ttl) * time.Second)
+}
+
+func (h *vaultHealth) recordToken(renewable bool, tokenType string) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	h.tokenRenewable = renewable
+	h.tokenType = tokenType
+}
+
+func (h *vaultHealth) recordRenewal(t time.Time) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	h.lastRenewal = t
+}
+
+func (h *vaultHealth) recordReadError(err error, t time.Time) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	h.lastReadError = err.Error()
+	h.lastReadErrorAt = t
+}
+
+func (h *vaultHealth) recordCluster(name string, version string) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	h.clusterName = name
+	h.version = version
+}
+
+// recordPoll records the result of a health poll, a sealed Vault counts as a failure, and returns whether the
+// debounced state changed
+func (h *vaultHealth) recordPoll(ok bool) bool {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	if h.failureThreshold <= 0 {
+		return false
+	}
+	if !ok {
+		h.consecutiveSuccesses = 0
+		h.consecutiveFailures++
+		if !h.pollDown && h.consecutiveFailures >= h.failureThreshold {
+			h.pollDown = true
+			return true
+		}
+		return false
+	}
+	h.consecutiveFailures = 0
+	h.consecutiveSuccesses++
+	if h.pollDown && h.consecutiveSuccesses >= h.successThreshold {
+		h.pollDown = false
+		return true
+	}
+	return false
+}
+
+func (h *vaultHealth) ready(now time.Time) error {
+	h.mutex.RLock()
+	defer h.mutex.RUnlock()
+	if h.threshold > 0 && now.Sub(h.lastHealthCheck) > h.threshold {
+		return fmt.Errorf("last successful vault health check was %s ago", now.Sub(h.lastHealthCheck).Round(time.Second))
+	}
+	if h.failureThreshold > 0 {
+		// Debounced, so a single failed or sealed poll doesn't take the pod out of the load balancers
+		if h.pollDown {
+			return fmt.Errorf("vault failed %d health checks in a row, or is sealed", h.failureThreshold)
+		}
+	} else if h.sealed {
+		return fmt.Errorf("vault is sealed")
+	}
+	if !h.tokenExpiration.IsZero() && now.After(h.tokenExpiration) {
+		return fmt.Errorf("vault token expired at %s", h.tokenExpiration.UTC().Format(time.RFC3339))
+	}
+	return nil
+}
+
+// Ready returns an error if Vault health couldn't be checked lately, Vault is sealed or the token expired
+func (c *client) Ready() error {
+	return c.health.ready(time.Now())
+}
+
+// checkHealth queries the Vault health endpoint, recording the time of the last successful check and the seal status
+func (c *client) checkHealth() (*api.HealthResponse, error) {
+	health, err := c.vclient.Sys().Health()
+	if err != nil {
+		return nil, err
+	}
+	c.health.recordHealthCheck(time.Now())
+	c.health.recordSealed(health.Sealed)
+	c.health.recordCluster(health.ClusterName, health.Version)
+	return health, nil
+}
+
+// pollHealth checks Vault health updating the seal, standby and initialization status metrics. When failed over
+// to a fallback address, the primary one is used again as soon as it is healthy
+func (c *client) pollHealth() {
+	c.checkPrimary()
+	health, err := c.checkHealth()
+	healthy := err == nil && !health.Sealed
+	if c.health.recordPoll(healthy) {
+		c.logger.Info("vault readiness changed", "ready", healthy)
+		c.metrics.updateVaultHealthFlapsTotalMetric()
+	}
+	if err != nil {
+		c.logger.Error(err, "could not get health information about vault cluster")
+		return
+	}
+	if health.Sealed && !c.health.isSealed() {
+		c.logger.Info("WARNING: vault cluster is sealed, secrets can't be read until it is unsealed")
+	}
+	c.metrics.updateVaultHealthMetrics(health)
+}
+
+// startHealthPoller checks Vault health every healthPollingPeriod until ctx is done
+func (c *client) startHealthPoller(ctx context.Context) {
+	c.routines.Add(1)
+	go func(ctx context.Context) {
+		defer c.routines.Done()
+		for {
+			select {
+			case <-time.After(c.healthPollingPeriod):
+				c.pollHealth()
+			case <-ctx.Done():
+				c.logger.Info("gracefully shutting down health polling go routine")
+				return
+			}
+		}
+	}(ctx)
+}
+
+// setToken sets the token obtained with a login, it must contain a client token
+func (c *client) setToken(resp *api.Secret) error {
+	token, err := authClientToken(resp)
+	if err != nil {
+		return err
+	}
+	c.vclient.SetToken(token)
+	c.health.recordTokenTTL(int64(resp.Auth.LeaseDuration))
+	return nil
+}
@@ -0,0 +1,46 @@
+package backend
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/tuenti/secrets-manager/errors"
+)
+
+// JSONReader is implemented by backends able to read structured secret values, e.g. a JSON object stored with
+// vault kv put secret/app config=@config.json, instead of failing with a BackendSecretTypeError
+type JSONReader interface {
+	ReadSecretJSON(path string, key string) (json.RawMessage, error)
+}
+
+// ReadSecretJSON reads a secret key returning objects, arrays, numbers and booleans marshalled back to JSON,
+// with numbers kept as Vault returned them. Strings are returned as they are, without quotes, as ReadSecret does
+func (c *client) ReadSecretJSON(path string, key string) (json.RawMessage, error) {
+	if key == "" {
+		key = c.defaultKey
+	}
+	secretData, err := c.readSecretData(context.Background(), path, key, "", nil)
+	if err != nil {
+		return nil, err
+	}
+	value := secretData[key]
+	if value == nil {
+		c.metrics.updateVaultSecretReadErrorsTotalMetric(path, key, "", errors.BackendSecretNotFoundErrorType)
+		return nil, &errors.BackendSecretNotFoundError{ErrType: errors.BackendSecretNotFoundErrorType, Path: path, Key: key}
+	}
+
+	var data []byte
+	if s, ok := value.(string); ok {
+		data = []byte(s)
+	} else if data, err = json.Marshal(value); err != nil {
+		c.metrics.updateVaultSecretReadErrorsTotalMetric(path, key, "", errors.BackendSecretTypeErrorType)
+		return nil, &errors.BackendSecretTypeError{ErrType: errors.BackendSecretTypeErrorType, Path: path, Key: key, Type: fmt.Sprintf("%T", value)}
+	}
+	if err := c.checkValueSize(context.Background(), path, key, "", string(data)); err != nil {
+		return nil, err
+	}
+	c.metrics.updateVaultSecretReadSuccessesTotalMetric(path, key, "")
+	c.metrics.updateVaultSecretLastSyncMetric(path)
+	return json.RawMessage(data), nil
+}
@@ -0,0 +1,68 @@
+package backend
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/tuenti/secrets-manager/errors"
+)
+
+// v1SecretJSONKv2 answers a secret with a key of every JSON type
+func v1SecretJSONKv2(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Write([]byte(`{"data": {"data": {
+		"object": {"port": 5432, "hosts": ["db-0", "db-1"], "tls": true},
+		"array": [1, "two", {"three": 3.0}],
+		"number": 12345678901234567890,
+		"float": 0.25,
+		"bool": false,
+		"string": "{\"already\": \"json\"}",
+		"null": null
+	}, "metadata": {"version": 1}}}`))
+}
+
+func TestReadSecretJSON(t *testing.T) {
+	client, _ := vaultClient(logger, vaultCfg)
+	client.engine, _ = newEngine("kv2")
+
+	for key, expected := range map[string]string{
+		"object": `{"hosts":["db-0","db-1"],"port":5432,"tls":true}`,
+		"array":  `[1,"two",{"three":3.0}]`,
+		"number": `12345678901234567890`,
+		"float":  `0.25`,
+		"bool":   `false`,
+		// Strings are returned as they are, not quoted
+		"string": `{"already": "json"}`,
+	} {
+		value, err := client.ReadSecretJSON("secret/data/json", key)
+		assert.Nil(t, err, key)
+		assert.Equal(t, expected, string(value), key)
+	}
+
+	_, err := client.ReadSecretJSON("secret/data/json", "null")
+	assert.True(t, errors.IsBackendSecretNotFound(err))
+	_, err = client.ReadSecretJSON("secret/data/json", "missing")
+	assert.True(t, errors.IsBackendSecretNotFound(err))
+
+	// ReadSecret still refuses structured values
+	_, err = client.ReadSecret("secret/data/json", "object")
+	assert.True(t, errors.IsBackendSecretType(err))
+
+	client.maxSecretValueSize = 8
+	_, err = client.ReadSecretJSON("secret/data/json", "object")
+	assert.True(t, errors.IsBackendSecretTooLarge(err))
+}
+
+func TestCachedClientReadSecretJSON(t *testing.T) {
+	client, _ := vaultClient(logger, vaultCfg)
+	client.engine, _ = newEngine("kv2")
+	cached := newCachedClient(client, vaultBackendName, 0, 0)
+
+	value, err := cached.ReadSecretJSON("secret/data/json", "bool")
+	assert.Nil(t, err)
+	assert.Equal(t, "false", string(value))
+
+	_, err = newCachedClient(&countingClient{}, "test", 0, 0).ReadSecretJSON("secret/data/json", "bool")
+	assert.True(t, errors.IsBackendNotImplemented(err))
+}
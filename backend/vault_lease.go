@@ -0,0 +1,159 @@
+package backend
+
+import (
+	"sync"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/hashicorp/vault/api"
+	"github.com/tuenti/secrets-manager/errors"
+)
+
+const vaultLeaseRenewOperationName = "lease-renew"
+
+// vaultLease is a lease Vault attached to a dynamic secret, it must be renewed before it expires
+// or the credentials it backs get revoked
+type vaultLease struct {
+	id         string
+	path       string
+	renewable  bool
+	expiration time.Time
+}
+
+// leaseRenewer keeps the leases of dynamic secrets (database credentials, PKI certificates...) alive,
+// renewing them when their TTL goes below the threshold. The auth token has its own renewal logic,
+// as it can be recovered logging in again
+type leaseRenewer struct {
+	mutex     sync.Mutex
+	leases    map[string]*vaultLease
+	ttlPaths  map[string]bool
+	threshold int64
+	increment int
+	renew     func(id string, increment int) (*api.Secret, error)
+	metrics   *vaultMetrics
+	logger    logr.Logger
+}
+
+func newLeaseRenewer(threshold int64, increment int, renew func(string, int) (*api.Secret, error), logger logr.Logger) *leaseRenewer {
+	return &leaseRenewer{
+		leases:    make(map[string]*vaultLease),
+		ttlPaths:  make(map[string]bool),
+		threshold: threshold,
+		increment: increment,
+		renew:     renew,
+		logger:    logger,
+	}
+}
+
+// register starts tracking the lease of a secret. Secrets without a lease are ignored
+func (r *leaseRenewer) register(path string, secret *api.Secret) {
+	if secret == nil || secret.LeaseID == "" {
+		return
+	}
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.leases[secret.LeaseID] = &vaultLease{
+		id:         secret.LeaseID,
+		path:       path,
+		renewable:  secret.Renewable,
+		expiration: time.Now().Add(time.Duration(secret.LeaseDuration) * time.Second),
+	}
+	r.updateTTLMetrics(time.Now())
+}
+
+// updateTTLMetrics sets the TTL of every path to the lowest TTL of its leases, dropping the paths left
+// without leases. It must be called holding the mutex
+func (r *leaseRenewer) updateTTLMetrics(now time.Time) {
+	ttls := make(map[string]int64)
+	for _, lease := range r.leases {
+		path := r.metrics.pathLabel(lease.path)
+		ttl := int64(lease.expiration.Sub(now).Seconds())
+		if current, ok := ttls[path]; !ok || ttl < current {
+			ttls[path] = ttl
+		}
+	}
+	for path, ttl := range ttls {
+		r.metrics.updateVaultLeaseTTLMetric(path, ttl)
+	}
+	for path := range r.ttlPaths {
+		if _, ok := ttls[path]; !ok {
+			r.metrics.deleteVaultLeaseTTLMetric(path)
+			delete(r.ttlPaths, path)
+		}
+	}
+	for path := range ttls {
+		r.ttlPaths[path] = true
+	}
+}
+
+// dueLeases returns a copy of the tracked leases whose TTL is below the threshold. Leases that already
+// expired or can not be renewed are not tracked anymore
+func (r *leaseRenewer) dueLeases(now time.Time) []vaultLease {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	var due []vaultLease
+	for id, lease := range r.leases {
+		ttl := int64(lease.expiration.Sub(now).Seconds())
+		if ttl <= 0 {
+			r.logger.Info("WARNING: vault lease expired, it won't be renewed anymore", "vault_lease_path", lease.path)
+			delete(r.leases, id)
+			continue
+		}
+		if ttl >= r.threshold {
+			continue
+		}
+		if !lease.renewable {
+			r.logger.Info("WARNING: vault lease is not renewable, it will expire", "vault_lease_path", lease.path, "vault_lease_ttl", ttl)
+			delete(r.leases, id)
+			continue
+		}
+		due = append(due, *lease)
+	}
+	r.updateTTLMetrics(now)
+	return due
+}
+
+// renewAll renews the tracked leases whose TTL is below the threshold. Leases are renewed without holding
+// the mutex, so registering new leases never waits for Vault. The last renewal error is returned
+func (r *leaseRenewer) renewAll() error {
+	var lastErr error
+	for _, lease := range r.dueLeases(time.Now()) {
+		secret, err := r.renew(lease.id, r.increment)
+		if err != nil {
+			r.logger.Error(err, "failed to renew vault lease", "vault_lease_path", lease.path, "vault_lease_ttl", int64(time.Until(lease.expiration).Seconds()))
+			r.metrics.updateVaultLeaseRenewalErrorsTotalMetric(lease.path, errors.UnknownErrorType)
+			lastErr = err
+			continue
+		}
+		if secret != nil {
+			r.renewed(lease.id, secret)
+		}
+		r.logger.Info("vault lease renewed successfully!", "vault_lease_path", lease.path)
+	}
+	return lastErr
+}
+
+// renewed updates a lease with the response to its renewal, unless it was forgotten meanwhile
+func (r *leaseRenewer) renewed(id string, secret *api.Secret) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	lease, ok := r.leases[id]
+	if !ok {
+		return
+	}
+	lease.renewable = secret.Renewable
+	lease.expiration = time.Now().Add(time.Duration(secret.LeaseDuration) * time.Second)
+	r.updateTTLMetrics(time.Now())
+}
+
+// renewLease renews a lease through the sys/leases API, retrying transient errors
+func (c *client) renewLease(id string, increment int) (*api.Secret, error) {
+	var secret *api.Secret
+	err := c.withRetry(c.ctx, vaultLeaseRenewOperationName, func() error {
+		var err error
+		secret, err = c.vclient.Sys().Renew(id, increment)
+		return err
+	})
+	return secret, err
+}
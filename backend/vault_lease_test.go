@@ -0,0 +1,134 @@
+package backend
+
+import (
+	"testing"
+	"time"
+
+	"github.com/hashicorp/vault/api"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/tuenti/secrets-manager/errors"
+)
+
+// seriesCount returns the number of series of a collector
+func seriesCount(c prometheus.Collector) int {
+	ch := make(chan prometheus.Metric)
+	go func() {
+		c.Collect(ch)
+		close(ch)
+	}()
+	n := 0
+	for range ch {
+		n++
+	}
+	return n
+}
+
+func TestLeaseRenewerRegister(t *testing.T) {
+	client, _ := vaultClient(logger, vaultCfg)
+	renewer := client.leaseRenewer
+
+	renewer.register("secret/data/test", &api.Secret{})
+	assert.Empty(t, renewer.leases)
+
+	client.metrics.leaseTTL.Reset()
+	renewer.register("database/creds/readonly", &api.Secret{LeaseID: "foo", LeaseDuration: 60, Renewable: true})
+	metric, _ := client.metrics.leaseTTL.GetMetricWithLabelValues(vaultCfg.VaultURL, vaultCfg.VaultEngine, vaultFakeVersion, vaultFakeClusterID, vaultFakeClusterName, vaultCfg.VaultNamespace, "database/creds/readonly")
+	assert.Contains(t, renewer.leases, "foo")
+	assert.InDelta(t, 60.0, testutil.ToFloat64(metric), 1)
+}
+
+func TestLeaseTTLMetricByPath(t *testing.T) {
+	client, _ := vaultClient(logger, vaultCfg)
+	renewer := client.leaseRenewer
+	renewer.threshold = 0
+	client.metrics.leaseTTL.Reset()
+	path := "database/creds/readonly"
+	metric, _ := client.metrics.leaseTTL.GetMetricWithLabelValues(vaultCfg.VaultURL, vaultCfg.VaultEngine, vaultFakeVersion, vaultFakeClusterID, vaultFakeClusterName, vaultCfg.VaultNamespace, path)
+
+	// Leases of the same path share a series with the lowest TTL
+	renewer.register(path, &api.Secret{LeaseID: "foo", LeaseDuration: 600, Renewable: true})
+	renewer.register(path, &api.Secret{LeaseID: "bar", LeaseDuration: 60, Renewable: false})
+	assert.InDelta(t, 60.0, testutil.ToFloat64(metric), 1)
+	assert.Equal(t, 1, seriesCount(client.metrics.leaseTTL))
+
+	// Series are dropped with the last lease of their path
+	renewer.leases["bar"].expiration = time.Now().Add(-1 * time.Second)
+	renewer.renewAll()
+	assert.InDelta(t, 600.0, testutil.ToFloat64(metric), 1)
+	renewer.leases["foo"].expiration = time.Now().Add(-1 * time.Second)
+	renewer.renewAll()
+	assert.Equal(t, 0, seriesCount(client.metrics.leaseTTL))
+}
+
+func TestRenewLeasesWithoutHoldingTheMutex(t *testing.T) {
+	client, _ := vaultClient(logger, vaultCfg)
+	renewer := client.leaseRenewer
+	renewer.threshold = databaseLeaseTTL * 2
+	renewer.leases["foo"] = &vaultLease{id: "foo", path: "database/creds/readonly", renewable: true, expiration: time.Now().Add(time.Minute)}
+	renewer.renew = func(id string, increment int) (*api.Secret, error) {
+		// Dynamic reads keep registering their leases while others are renewed
+		renewer.register("database/creds/readonly", &api.Secret{LeaseID: "bar", LeaseDuration: 60, Renewable: true})
+		return &api.Secret{LeaseID: id, LeaseDuration: 3600, Renewable: true}, nil
+	}
+
+	assert.Nil(t, renewer.renewAll())
+	assert.Contains(t, renewer.leases, "bar")
+	assert.True(t, time.Until(renewer.leases["foo"].expiration) > 59*time.Minute)
+}
+
+func TestRenewLeases(t *testing.T) {
+	client, _ := vaultClient(logger, vaultCfg)
+	client.ReadDynamicCredentials(databaseFakeRole)
+	mutex.Lock()
+	testCfg.leaseRenewals = 0
+	mutex.Unlock()
+
+	// TTL above the threshold, nothing to renew
+	client.leaseRenewer.threshold = databaseLeaseTTL / 2
+	assert.Nil(t, client.leaseRenewer.renewAll())
+	assert.Equal(t, 0, testCfg.leaseRenewals)
+
+	client.leaseRenewer.threshold = databaseLeaseTTL * 2
+	assert.Nil(t, client.leaseRenewer.renewAll())
+	assert.Equal(t, 1, testCfg.leaseRenewals)
+	assert.Contains(t, client.leaseRenewer.leases, databaseFakeLeaseID)
+}
+
+func TestRenewLeasesFailure(t *testing.T) {
+	client, _ := vaultClient(logger, vaultCfg)
+	client.leaseRenewer.threshold = databaseLeaseTTL * 2
+	path := "database/creds/unknown"
+	client.leaseRenewer.leases["unknown"] = &vaultLease{id: "unknown", path: path, renewable: true, expiration: time.Now().Add(time.Hour)}
+	client.metrics.leaseRenewalErrorsTotal.Reset()
+
+	err := client.leaseRenewer.renewAll()
+	metric, _ := client.metrics.leaseRenewalErrorsTotal.GetMetricWithLabelValues(vaultCfg.VaultURL, vaultCfg.VaultEngine, vaultFakeVersion, vaultFakeClusterID, vaultFakeClusterName, vaultCfg.VaultNamespace, path, errors.UnknownErrorType)
+
+	assert.NotNil(t, err)
+	assert.Equal(t, 1.0, testutil.ToFloat64(metric))
+	assert.Contains(t, client.leaseRenewer.leases, "unknown")
+}
+
+func TestRenewLeasesDropsExpiredAndNotRenewable(t *testing.T) {
+	client, _ := vaultClient(logger, vaultCfg)
+	client.leaseRenewer.threshold = databaseLeaseTTL * 2
+	client.leaseRenewer.leases["expired"] = &vaultLease{id: "expired", renewable: true, expiration: time.Now().Add(-1 * time.Second)}
+	client.leaseRenewer.leases["static"] = &vaultLease{id: "static", renewable: false, expiration: time.Now().Add(time.Hour)}
+
+	assert.Nil(t, client.leaseRenewer.renewAll())
+	assert.Empty(t, client.leaseRenewer.leases)
+}
+
+func TestRenewalLoopRenewsLeases(t *testing.T) {
+	client, _ := vaultClient(logger, vaultCfg)
+	client.ReadDynamicCredentials(databaseFakeRole)
+	client.leaseRenewer.threshold = databaseLeaseTTL * 2
+	mutex.Lock()
+	testCfg.leaseRenewals = 0
+	mutex.Unlock()
+
+	client.renewalLoop()
+	assert.Equal(t, 1, testCfg.leaseRenewals)
+}
@@ -1,125 +1,1204 @@
 package backend
 
 import (
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/vault/api"
 	"github.com/prometheus/client_golang/prometheus"
-	"github.com/prometheus/client_golang/prometheus/promauto"
-	"sigs.k8s.io/controller-runtime/pkg/metrics"
 )
 
+const (
+	requestResultSuccess = "success"
+	requestResultError   = "error"
+)
+
+const (
+	defaultMetricsNamespace = "secrets_manager"
+	defaultMetricsSubsystem = "vault"
+)
+
+// metricNameRegexp matches the valid metric namespaces and subsystems
+var metricNameRegexp = regexp.MustCompile(`^[a-zA-Z_:][a-zA-Z0-9_:]*$`)
+
 const (
 	vaultLookupSelfOperationName  = "lookup-self"
 	vaultRenewSelfOperationName   = "renew-self"
 	vaultIsRenewableOperationName = "is-renewable"
+	vaultLoginOperationName       = "login"
+	vaultUnwrapOperationName      = "unwrap"
+	vaultTokenCreateOperationName = "token-create"
 )
 
 var (
-	vaultLabelNames      = []string{"vault_address", "vault_engine", "vault_version", "vault_cluster_id", "vault_cluster_name"}
-	secretLabelNames     = []string{"path", "key", "error"}
+	vaultLabelNames      = []string{"vault_address", "vault_engine", "vault_version", "vault_cluster_id", "vault_cluster_name", "vault_namespace"}
+	secretLabelNames     = []string{"path", "key", "version", "error", "backend"}
+	tokenLabelNames      = []string{"vault_token_type"}
+	mountLabelNames      = []string{"mount", "mount_engine"}
+	identityLabelNames   = []string{"entity_id", "display_name"}
+	responseLabelNames   = []string{"vault_address", "vault_operation", "status_code"}
+	readLabelNames       = []string{"path", "key", "version"}
+	readDurationNames    = []string{"path", "result"}
+	tokenDurationNames   = []string{"vault_operation", "result"}
+	engineReadNames      = []string{"mount_engine", "result"}
 	vaultErrorLabelNames = []string{"vault_operation", "error"}
+	loginErrorLabelNames = []string{"error"}
+	listLabelNames       = []string{"path", "error"}
+	retryLabelNames      = []string{"vault_operation"}
+	retryOutcomeNames    = []string{"vault_operation", "outcome"}
+	transitLabelNames    = []string{"key"}
+	transitErrorNames    = []string{"key", "error"}
+	leaseErrorNames      = []string{"path", "error"}
+	leaseLabelNames      = []string{"path"}
+	writeLabelNames      = []string{"path"}
+	syncLabelNames       = []string{"path"}
+	wrappedLabelNames    = []string{"path", "result"}
+	sizeLabelNames       = []string{"path", "key"}
+	eventLabelNames      = []string{"vault_event_type"}
+	roleLabelNames       = []string{"vault_role"}
+	roleErrorNames       = []string{"vault_role", "error"}
+	writeErrorNames      = []string{"path", "error"}
+	failoverLabelNames   = []string{"address"}
+	pkiLabelNames        = []string{"role"}
+	pkiErrorNames        = []string{"role", "error"}
+)
 
-	// Prometeheus metrics: https://prometheus.io
-	tokenTTL = promauto.NewGaugeVec(prometheus.GaugeOpts{
-		Namespace: "secrets_manager",
-		Subsystem: "vault",
+// vaultCollectors are the Prometheus metrics of a Vault client: https://prometheus.io. Every client has its own, so
+// clients with other names or buckets don't replace the metrics of the ones already running
+type vaultCollectors struct {
+	sealed                          *prometheus.GaugeVec
+	standby                         *prometheus.GaugeVec
+	initialized                     *prometheus.GaugeVec
+	tokenTTL                        *prometheus.GaugeVec
+	maxTokenTTL                     *prometheus.GaugeVec
+	responsesTotal                  *prometheus.CounterVec
+	mountEngineInfo                 *prometheus.GaugeVec
+	tokenIdentityInfo               *prometheus.GaugeVec
+	tokenRenewThreshold             *prometheus.GaugeVec
+	tokenRenewalErrorsTotal         *prometheus.CounterVec
+	tokenRenewalConsecutiveFailures *prometheus.GaugeVec
+	tokenRenewalBackoff             *prometheus.GaugeVec
+	tokenMalformedTotal             *prometheus.CounterVec
+	tokenRenewNoProgressTotal       *prometheus.CounterVec
+	secretReadErrorsTotal           *prometheus.CounterVec
+	secretReadSuccessesTotal        *prometheus.CounterVec
+	engineReadsTotal                *prometheus.CounterVec
+	secretLastSync                  *prometheus.GaugeVec
+	secretLastError                 *prometheus.GaugeVec
+	secretWrappedReadsTotal         *prometheus.CounterVec
+	secretTooLargeTotal             *prometheus.CounterVec
+	pathDeniedTotal                 *prometheus.CounterVec
+	eventsReceivedTotal             *prometheus.CounterVec
+	roleTokensCreatedTotal          *prometheus.CounterVec
+	roleTokenErrorsTotal            *prometheus.CounterVec
+	childTokensCreatedTotal         *prometheus.CounterVec
+	childTokenErrorsTotal           *prometheus.CounterVec
+	healthFlapsTotal                *prometheus.CounterVec
+	secretReadDuration              *prometheus.HistogramVec
+	tokenRequestDuration            *prometheus.HistogramVec
+	tokenRequestsTotal              *prometheus.CounterVec
+	secretListErrorsTotal           *prometheus.CounterVec
+	requestRetriesTotal             *prometheus.CounterVec
+	retriedRequestsTotal            *prometheus.CounterVec
+	standbyForwardsTotal            *prometheus.CounterVec
+	circuitBreakerState             *prometheus.GaugeVec
+	circuitBreakerTransitionsTotal  *prometheus.CounterVec
+	tokenFileReloadsTotal           *prometheus.CounterVec
+	leaseTTL                        *prometheus.GaugeVec
+	leaseRenewalErrorsTotal         *prometheus.CounterVec
+	pkiIssuedCertificatesTotal      *prometheus.CounterVec
+	pkiIssueErrorsTotal             *prometheus.CounterVec
+	pkiIssueDuration                *prometheus.HistogramVec
+	transitDecryptErrorsTotal       *prometheus.CounterVec
+	transitDecryptDuration          *prometheus.HistogramVec
+	failoversTotal                  *prometheus.CounterVec
+	secretWriteErrorsTotal          *prometheus.CounterVec
+	secretWriteDuration             *prometheus.HistogramVec
+	loginErrorsTotal                *prometheus.CounterVec
+	loginSuccessesTotal             *prometheus.CounterVec
+	loginDuration                   *prometheus.HistogramVec
+}
+
+// newVaultCollectors creates the Vault metrics, named after namespace and subsystem, e.g. secretsmanager and vault
+// for secretsmanager_vault_login_successes_total. buckets are the ones of the read and token requests latency histograms
+func newVaultCollectors(namespace string, subsystem string, buckets []float64) *vaultCollectors {
+	vc := &vaultCollectors{}
+	vc.sealed = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: subsystem,
+		Name:      "sealed",
+		Help:      "Vault seal status. 1 = sealed, 0 = unsealed",
+	}, vaultLabelNames)
+	vc.standby = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: subsystem,
+		Name:      "standby",
+		Help:      "Vault standby status of the node answering requests. 1 = standby, 0 = active",
+	}, vaultLabelNames)
+	vc.initialized = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: subsystem,
+		Name:      "initialized",
+		Help:      "Vault initialization status. 1 = initialized, 0 = not initialized",
+	}, vaultLabelNames)
+	vc.tokenTTL = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: subsystem,
 		Name:      "token_ttl",
 		Help:      "Vault token TTL",
-	}, vaultLabelNames)
-	maxTokenTTL = promauto.NewGaugeVec(prometheus.GaugeOpts{
-		Namespace: "secrets_manager",
-		Subsystem: "vault",
+	}, append(vaultLabelNames, tokenLabelNames...))
+	vc.maxTokenTTL = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: subsystem,
 		Name:      "max_token_ttl",
 		Help:      "secrets-manager max Vault token TTL",
 	}, vaultLabelNames)
-	tokenRenewalErrorsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
-		Namespace: "secrets_manager",
-		Subsystem: "vault",
+	// responsesTotal is updated for every request, including the ones sent before the Vault cluster labels are known
+	vc.responsesTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: subsystem,
+		Name:      "responses_total",
+		Help:      "Vault responses by operation and HTTP status code",
+	}, responseLabelNames)
+	vc.mountEngineInfo = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: subsystem,
+		Name:      "mount_engine_info",
+		Help:      "Engine detected for a Vault mount, always 1",
+	}, append(vaultLabelNames, mountLabelNames...))
+	vc.tokenIdentityInfo = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: subsystem,
+		Name:      "token_identity_info",
+		Help:      "Identity entity the Vault token belongs to, always 1. Only reported with vault.metrics-identity",
+	}, append(vaultLabelNames, identityLabelNames...))
+	vc.tokenRenewThreshold = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: subsystem,
+		Name:      "token_renew_threshold_seconds",
+		Help:      "Vault token TTL below which secrets-manager renews the token",
+	}, vaultLabelNames)
+	vc.tokenRenewalErrorsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: subsystem,
 		Name:      "token_renewal_errors_total",
 		Help:      "Vault token renewal errors counter",
 	}, append(vaultLabelNames, vaultErrorLabelNames...))
-	secretReadErrorsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
-		Namespace: "secrets_manager",
-		Subsystem: "vault",
-		Name:      "read_secret_errors_total",
-		Help:      "Vault read operations counter",
-	}, append(vaultLabelNames, secretLabelNames...))
-	loginErrorsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
-		Namespace: "secrets_manager",
-		Subsystem: "vault",
+	vc.tokenRenewalConsecutiveFailures = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: subsystem,
+		Name:      "token_renewal_consecutive_failures",
+		Help:      "Vault token renewal polls failed in a row",
+	}, vaultLabelNames)
+	vc.tokenRenewalBackoff = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: subsystem,
+		Name:      "token_renewal_backoff_seconds",
+		Help:      "Time waited before the next Vault token renewal poll because of failures in a row, 0 when not backing off",
+	}, vaultLabelNames)
+	vc.tokenMalformedTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: subsystem,
+		Name:      "token_malformed_total",
+		Help:      "Vault token lookups whose response didn't have the expected shape",
+	}, vaultLabelNames)
+	vc.tokenRenewNoProgressTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: subsystem,
+		Name:      "token_renew_no_progress_total",
+		Help:      "Vault token renewals that didn't extend the token TTL, usually because it reached its max TTL",
+	}, vaultLabelNames)
+	vc.secretReadErrorsTotal = newSecretReadErrorsTotal(namespace, subsystem)
+	vc.secretReadSuccessesTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: subsystem,
+		Name:      "read_secret_successes_total",
+		Help:      "Vault successful read operations counter. Labeling by path and key may produce a huge cardinality, see vault.metrics-path-labels and vault.metrics-path-depth",
+	}, append(vaultLabelNames, readLabelNames...))
+	vc.engineReadsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: subsystem,
+		Name:      "engine_reads_total",
+		Help:      "Vault read operations by the engine of the path (kv1, kv2, transit, or unknown when it wasn't detected yet) and result",
+	}, append(vaultLabelNames, engineReadNames...))
+	vc.secretLastSync = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: subsystem,
+		Name:      "secret_last_sync_timestamp_seconds",
+		Help:      "Unix time of the last successful read of a secret path. Labeling by path may produce a huge cardinality, see vault.metrics-path-labels and vault.metrics-path-depth",
+	}, append(vaultLabelNames, syncLabelNames...))
+	vc.secretLastError = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: subsystem,
+		Name:      "secret_last_error_timestamp_seconds",
+		Help:      "Unix time of the last failed read of a secret path. Labeling by path may produce a huge cardinality, see vault.metrics-path-labels and vault.metrics-path-depth",
+	}, append(vaultLabelNames, syncLabelNames...))
+	vc.secretWrappedReadsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: subsystem,
+		Name:      "read_secret_wrapped_total",
+		Help:      "Vault response-wrapped read operations counter. Labeling by path may produce a huge cardinality, see vault.metrics-path-labels and vault.metrics-path-depth",
+	}, append(vaultLabelNames, wrappedLabelNames...))
+	vc.secretTooLargeTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: subsystem,
+		Name:      "secret_too_large_total",
+		Help:      "Secret values rejected for being larger than vault.max-secret-value-size. Labeling by path may produce a huge cardinality, see vault.metrics-path-labels and vault.metrics-path-depth",
+	}, append(vaultLabelNames, sizeLabelNames...))
+	vc.pathDeniedTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: subsystem,
+		Name:      "path_denied_total",
+		Help:      "Reads refused because the path is not allowed by vault.allowed-path-prefixes and vault.denied-path-prefixes. Labeling by path may produce a huge cardinality, see vault.metrics-path-labels and vault.metrics-path-depth",
+	}, append(vaultLabelNames, writeLabelNames...))
+	vc.eventsReceivedTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: subsystem,
+		Name:      "events_received_total",
+		Help:      "Vault KV events received, by event type",
+	}, append(vaultLabelNames, eventLabelNames...))
+	vc.roleTokensCreatedTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: subsystem,
+		Name:      "role_tokens_created_total",
+		Help:      "Child tokens created with a Vault token role for the reads of a SecretDefinition",
+	}, append(vaultLabelNames, roleLabelNames...))
+	vc.roleTokenErrorsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: subsystem,
+		Name:      "role_token_errors_total",
+		Help:      "Errors creating child tokens with a Vault token role",
+	}, append(vaultLabelNames, roleErrorNames...))
+	vc.childTokensCreatedTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: subsystem,
+		Name:      "child_tokens_created_total",
+		Help:      "Child tokens created with vault.token-role or vault.token-policies to read with",
+	}, append(vaultLabelNames, roleLabelNames...))
+	vc.childTokenErrorsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: subsystem,
+		Name:      "child_token_errors_total",
+		Help:      "Errors creating child tokens with vault.token-role or vault.token-policies after logging in again",
+	}, append(vaultLabelNames, roleErrorNames...))
+	vc.healthFlapsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: subsystem,
+		Name:      "health_flaps_total",
+		Help:      "Changes of the readiness debounced with vault.health-failure-threshold and vault.health-success-threshold",
+	}, vaultLabelNames)
+	vc.secretReadDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: subsystem,
+		Name:      "read_secret_duration_seconds",
+		Help:      "Vault read operations latency in seconds. Labeling by path may produce a huge cardinality, see vault.metrics-path-labels and vault.metrics-path-depth",
+		Buckets:   buckets,
+	}, append(vaultLabelNames, readDurationNames...))
+	vc.tokenRequestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: subsystem,
+		Name:      "token_request_duration_seconds",
+		Help:      "Vault login, unwrap, token creation, lookup and renewal requests latency in seconds",
+		Buckets:   buckets,
+	}, append(vaultLabelNames, tokenDurationNames...))
+	vc.tokenRequestsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: subsystem,
+		Name:      "token_requests_total",
+		Help:      "Vault login, unwrap, token creation, lookup and renewal requests, by operation and result",
+	}, append(vaultLabelNames, tokenDurationNames...))
+	vc.secretListErrorsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: subsystem,
+		Name:      "list_secrets_errors_total",
+		Help:      "Vault list operations errors counter. Labeling by path may produce a huge cardinality, see vault.metrics-path-labels and vault.metrics-path-depth",
+	}, append(vaultLabelNames, listLabelNames...))
+	vc.requestRetriesTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: subsystem,
+		Name:      "request_retries_total",
+		Help:      "Vault request retries counter",
+	}, append(vaultLabelNames, retryLabelNames...))
+	vc.retriedRequestsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: subsystem,
+		Name:      "retried_requests_total",
+		Help:      "Vault retried requests counter by final outcome",
+	}, append(vaultLabelNames, retryOutcomeNames...))
+	vc.standbyForwardsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: subsystem,
+		Name:      "standby_forwards_total",
+		Help:      "Vault reads forwarded to the active node after a performance standby answered 412, by outcome",
+	}, append(vaultLabelNames, "outcome"))
+	vc.circuitBreakerState = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: subsystem,
+		Name:      "circuit_breaker_state",
+		Help:      "State of the circuit breaker of Vault reads: 0 closed, 1 half-open, 2 open",
+	}, vaultLabelNames)
+	vc.circuitBreakerTransitionsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: subsystem,
+		Name:      "circuit_breaker_transitions_total",
+		Help:      "State changes of the circuit breaker of Vault reads, by new state",
+	}, append(vaultLabelNames, "state"))
+	vc.tokenFileReloadsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: subsystem,
+		Name:      "token_file_reloads_total",
+		Help:      "Vault token reloads from the token file counter",
+	}, vaultLabelNames)
+	vc.leaseTTL = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: subsystem,
+		Name:      "lease_ttl",
+		Help:      "Lowest TTL of the Vault dynamic secrets leases of a path. Labeling by path may produce a huge cardinality, see vault.metrics-path-labels and vault.metrics-path-depth",
+	}, append(vaultLabelNames, leaseLabelNames...))
+	vc.leaseRenewalErrorsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: subsystem,
+		Name:      "lease_renewal_errors_total",
+		Help:      "Vault dynamic secrets lease renewal errors counter. Labeling by path may produce a huge cardinality, see vault.metrics-path-labels and vault.metrics-path-depth",
+	}, append(vaultLabelNames, leaseErrorNames...))
+	vc.pkiIssuedCertificatesTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: subsystem,
+		Name:      "pki_issued_certificates_total",
+		Help:      "Vault PKI issued certificates counter",
+	}, append(vaultLabelNames, pkiLabelNames...))
+	vc.pkiIssueErrorsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: subsystem,
+		Name:      "pki_issue_errors_total",
+		Help:      "Vault PKI certificate issuance errors counter",
+	}, append(vaultLabelNames, pkiErrorNames...))
+	vc.pkiIssueDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: subsystem,
+		Name:      "pki_issue_duration_seconds",
+		Help:      "Vault PKI certificate issuance latency in seconds",
+		Buckets:   prometheus.DefBuckets,
+	}, append(vaultLabelNames, pkiLabelNames...))
+	vc.transitDecryptErrorsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: subsystem,
+		Name:      "transit_decrypt_errors_total",
+		Help:      "Vault transit decrypt errors counter",
+	}, append(vaultLabelNames, transitErrorNames...))
+	vc.transitDecryptDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: subsystem,
+		Name:      "transit_decrypt_duration_seconds",
+		Help:      "Vault transit decrypt calls latency in seconds",
+		Buckets:   prometheus.DefBuckets,
+	}, append(vaultLabelNames, transitLabelNames...))
+	vc.failoversTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: subsystem,
+		Name:      "failovers_total",
+		Help:      "Vault address switches counter, labeled by the address switched to",
+	}, append(vaultLabelNames, failoverLabelNames...))
+	vc.secretWriteErrorsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: subsystem,
+		Name:      "secret_write_errors_total",
+		Help:      "Vault write operations errors counter. Labeling by path may produce a huge cardinality, see vault.metrics-path-labels and vault.metrics-path-depth",
+	}, append(vaultLabelNames, writeErrorNames...))
+	vc.secretWriteDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: subsystem,
+		Name:      "secret_write_duration_seconds",
+		Help:      "Vault write operations latency in seconds. Labeling by path may produce a huge cardinality, see vault.metrics-path-labels and vault.metrics-path-depth",
+		Buckets:   prometheus.DefBuckets,
+	}, append(vaultLabelNames, writeLabelNames...))
+	vc.loginErrorsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: subsystem,
 		Name:      "login_errors_total",
 		Help:      "Vault login errors counter",
+	}, append(vaultLabelNames, loginErrorLabelNames...))
+	vc.loginSuccessesTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: subsystem,
+		Name:      "login_successes_total",
+		Help:      "Vault successful logins counter",
 	}, vaultLabelNames)
-)
+	vc.loginDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: subsystem,
+		Name:      "login_duration_seconds",
+		Help:      "Vault login calls latency in seconds",
+		Buckets:   prometheus.DefBuckets,
+	}, vaultLabelNames)
+	return vc
+}
 
 type vaultMetrics struct {
+	*vaultCollectors
 	vaultLabels map[string]string
+	// pathLabels disables the path and key labels when false, aggregating every secret in the same series
+	pathLabels      bool
+	pathLabelsMutex sync.RWMutex
+	// namespace is the namespace of the reads counted, empty for the namespace of the client
+	namespace string
+	// engineName maps a secret path in a namespace to the name of its engine, e.g. kv1 or kv2
+	engineName func(namespace string, path string) string
+	// normalizePath maps a secret path to the path label value, e.g. to bucket secrets by mount
+	normalizePath func(path string) string
+	// identity enables the token identity info metric
+	identity      bool
+	identityMutex sync.Mutex
+	// identityLabels are the entity_id and display_name the identity info metric was last set with
+	identityLabels []string
+}
+
+// emailRegexp matches the email addresses some auth methods, e.g. jwt or oidc, put in token display names
+var emailRegexp = regexp.MustCompile(`[^\s@/-]+@([^\s@/]+)`)
+
+// redactDisplayName hides the user of the email addresses in a token display name, keeping their domain
+func redactDisplayName(name string) string {
+	return emailRegexp.ReplaceAllString(name, "redacted@$1")
+}
+
+// newSecretReadErrorsTotal creates the read errors counter. The non-Vault backends count their errors with it too,
+// labeled by backend type, so the same dashboards work with every backend
+func newSecretReadErrorsTotal(namespace string, subsystem string) *prometheus.CounterVec {
+	return prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: subsystem,
+		Name:      "read_secret_errors_total",
+		Help:      "Vault read operations counter. Labeling by path and key may produce a huge cardinality, see vault.metrics-path-labels and vault.metrics-path-depth",
+	}, append(vaultLabelNames, secretLabelNames...))
 }
 
-func init() {
-	r := metrics.Registry
-	r.MustRegister(tokenTTL)
-	r.MustRegister(maxTokenTTL)
-	r.MustRegister(tokenRenewalErrorsTotal)
-	r.MustRegister(secretReadErrorsTotal)
-	r.MustRegister(loginErrorsTotal)
+// metricsNames returns the namespace and subsystem of the metrics of the clients built with cfg
+func metricsNames(cfg Config) (string, string) {
+	namespace := cfg.MetricsNamespace
+	if namespace == "" {
+		namespace = defaultMetricsNamespace
+	}
+	subsystem := cfg.MetricsSubsystem
+	if subsystem == "" {
+		subsystem = defaultMetricsSubsystem
+	}
+	return namespace, subsystem
 }
 
-func newVaultMetrics(vaultAddr string, vaultVersion string, vaultEngine string, vaultClusterID string, vaultClusterName string) *vaultMetrics {
+// collectors returns every Vault metric, to register or unregister them at once
+func (vc *vaultCollectors) collectors() []prometheus.Collector {
+	return []prometheus.Collector{
+		vc.sealed,
+		vc.standby,
+		vc.initialized,
+		vc.tokenTTL,
+		vc.maxTokenTTL,
+		vc.tokenRenewThreshold,
+		vc.mountEngineInfo,
+		vc.tokenIdentityInfo,
+		vc.responsesTotal,
+		vc.tokenRenewalErrorsTotal,
+		vc.tokenRenewalConsecutiveFailures,
+		vc.tokenRenewalBackoff,
+		vc.tokenMalformedTotal,
+		vc.tokenRenewNoProgressTotal,
+		vc.secretReadErrorsTotal,
+		vc.secretReadSuccessesTotal,
+		vc.engineReadsTotal,
+		vc.secretLastSync,
+		vc.secretLastError,
+		vc.secretWrappedReadsTotal,
+		vc.secretTooLargeTotal,
+		vc.eventsReceivedTotal,
+		vc.pathDeniedTotal,
+		vc.roleTokensCreatedTotal,
+		vc.roleTokenErrorsTotal,
+		vc.childTokensCreatedTotal,
+		vc.childTokenErrorsTotal,
+		vc.healthFlapsTotal,
+		vc.secretReadDuration,
+		vc.tokenRequestDuration,
+		vc.tokenRequestsTotal,
+		vc.secretListErrorsTotal,
+		vc.requestRetriesTotal,
+		vc.retriedRequestsTotal,
+		vc.standbyForwardsTotal,
+		vc.circuitBreakerState,
+		vc.circuitBreakerTransitionsTotal,
+		vc.tokenFileReloadsTotal,
+		vc.leaseTTL,
+		vc.leaseRenewalErrorsTotal,
+		vc.pkiIssuedCertificatesTotal,
+		vc.pkiIssueErrorsTotal,
+		vc.pkiIssueDuration,
+		vc.transitDecryptErrorsTotal,
+		vc.transitDecryptDuration,
+		vc.failoversTotal,
+		vc.secretWriteErrorsTotal,
+		vc.secretWriteDuration,
+		vc.loginErrorsTotal,
+		vc.loginSuccessesTotal,
+		vc.loginDuration,
+	}
+}
+
+// requestResult returns the result label value of a request
+func requestResult(err error) string {
+	if err != nil {
+		return requestResultError
+	}
+	return requestResultSuccess
+}
+
+func newVaultMetrics(collectors *vaultCollectors, vaultAddr string, vaultVersion string, vaultEngine string, vaultClusterID string, vaultClusterName string, vaultNamespace string) *vaultMetrics {
 	labels := make(map[string]string, len(vaultLabelNames))
 	labels["vault_addr"] = vaultAddr
 	labels["vault_engine"] = vaultEngine
 	labels["vault_version"] = vaultVersion
 	labels["vault_cluster_id"] = vaultClusterID
 	labels["vault_cluster_name"] = vaultClusterName
+	labels["vault_namespace"] = vaultNamespace
+
+	return &vaultMetrics{vaultCollectors: collectors, vaultLabels: labels, pathLabels: true}
+}
+
+// newPathNormalizer returns a function keeping the first depth segments of a path, so secrets are
+// bucketed by mount or prefix. A depth of 0 or less keeps the whole path
+func newPathNormalizer(depth int) func(string) string {
+	return func(path string) string {
+		if depth <= 0 {
+			return path
+		}
+		segments := strings.Split(strings.Trim(path, "/"), "/")
+		if len(segments) <= depth {
+			return path
+		}
+		return strings.Join(segments[:depth], "/")
+	}
+}
+
+// setPathLabels enables or disables the path and key labels of the series updated from now on
+func (vm *vaultMetrics) setPathLabels(enabled bool) {
+	vm.pathLabelsMutex.Lock()
+	defer vm.pathLabelsMutex.Unlock()
+	vm.pathLabels = enabled
+}
+
+func (vm *vaultMetrics) hasPathLabels() bool {
+	vm.pathLabelsMutex.RLock()
+	defer vm.pathLabelsMutex.RUnlock()
+	return vm.pathLabels
+}
+
+// withNamespace returns metrics labelled with the given Vault namespace instead of the configured one, for the
+// reads sent to another namespace. An empty namespace returns vm
+func (vm *vaultMetrics) withNamespace(namespace string) *vaultMetrics {
+	if namespace == "" || vm == nil {
+		return vm
+	}
+	labels := make(map[string]string, len(vm.vaultLabels))
+	for k, v := range vm.vaultLabels {
+		labels[k] = v
+	}
+	labels["vault_namespace"] = namespace
+	return &vaultMetrics{vaultCollectors: vm.vaultCollectors, vaultLabels: labels, namespace: namespace, pathLabels: vm.hasPathLabels(), engineName: vm.engineName, normalizePath: vm.normalizePath}
+}
 
-	return &vaultMetrics{vaultLabels: labels}
+func (vm *vaultMetrics) pathLabel(path string) string {
+	if !vm.hasPathLabels() {
+		return ""
+	}
+	if vm.normalizePath != nil {
+		return vm.normalizePath(path)
+	}
+	return path
+}
+
+func (vm *vaultMetrics) keyLabel(key string) string {
+	if !vm.hasPathLabels() {
+		return ""
+	}
+	return key
 }
 
 func (vm *vaultMetrics) updateVaultMaxTokenTTLMetric(value int64) {
-	maxTokenTTL.WithLabelValues(
+	vm.maxTokenTTL.WithLabelValues(
 		vm.vaultLabels["vault_addr"],
 		vm.vaultLabels["vault_engine"],
 		vm.vaultLabels["vault_version"],
 		vm.vaultLabels["vault_cluster_id"],
-		vm.vaultLabels["vault_cluster_name"]).Set(float64(value))
+		vm.vaultLabels["vault_cluster_name"],
+		vm.vaultLabels["vault_namespace"]).Set(float64(value))
 }
 
-func (vm *vaultMetrics) updateVaultTokenTTLMetric(value int64) {
-	tokenTTL.WithLabelValues(
+func (vm *vaultMetrics) updateVaultMountEngineMetric(mount string, engine string) {
+	vm.mountEngineInfo.WithLabelValues(
 		vm.vaultLabels["vault_addr"],
 		vm.vaultLabels["vault_engine"],
 		vm.vaultLabels["vault_version"],
 		vm.vaultLabels["vault_cluster_id"],
-		vm.vaultLabels["vault_cluster_name"]).Set(float64(value))
+		vm.vaultLabels["vault_cluster_name"],
+		vm.vaultLabels["vault_namespace"],
+		mount,
+		engine).Set(1)
 }
 
-func (vm *vaultMetrics) updateVaultSecretReadErrorsTotalMetric(path string, key string, errorType string) {
-	secretReadErrorsTotal.WithLabelValues(
+// updateVaultTokenIdentityMetric reports the identity of the token, replacing the one previously reported when
+// it changes, e.g. logging in with other credentials
+func (vm *vaultMetrics) updateVaultTokenIdentityMetric(entityID string, displayName string) {
+	if !vm.identity {
+		return
+	}
+	labels := []string{
 		vm.vaultLabels["vault_addr"],
 		vm.vaultLabels["vault_engine"],
 		vm.vaultLabels["vault_version"],
 		vm.vaultLabels["vault_cluster_id"],
 		vm.vaultLabels["vault_cluster_name"],
-		path,
-		key,
+		vm.vaultLabels["vault_namespace"],
+		entityID,
+		redactDisplayName(displayName)}
+
+	vm.identityMutex.Lock()
+	defer vm.identityMutex.Unlock()
+	if vm.identityLabels != nil && strings.Join(vm.identityLabels, "\x00") != strings.Join(labels, "\x00") {
+		vm.tokenIdentityInfo.DeleteLabelValues(vm.identityLabels...)
+	}
+	vm.identityLabels = labels
+	vm.tokenIdentityInfo.WithLabelValues(labels...).Set(1)
+}
+
+func (vm *vaultMetrics) updateVaultTokenRenewThresholdMetric(value int64) {
+	vm.tokenRenewThreshold.WithLabelValues(
+		vm.vaultLabels["vault_addr"],
+		vm.vaultLabels["vault_engine"],
+		vm.vaultLabels["vault_version"],
+		vm.vaultLabels["vault_cluster_id"],
+		vm.vaultLabels["vault_cluster_name"],
+		vm.vaultLabels["vault_namespace"]).Set(float64(value))
+}
+
+func (vm *vaultMetrics) updateVaultTokenTTLMetric(value int64, tokenType string) {
+	// A new login may get a token of another type, don't keep reporting the previous one
+	vm.tokenTTL.Reset()
+	vm.tokenTTL.WithLabelValues(
+		vm.vaultLabels["vault_addr"],
+		vm.vaultLabels["vault_engine"],
+		vm.vaultLabels["vault_version"],
+		vm.vaultLabels["vault_cluster_id"],
+		vm.vaultLabels["vault_cluster_name"],
+		vm.vaultLabels["vault_namespace"],
+		tokenType).Set(float64(value))
+}
+
+func (vm *vaultMetrics) updateVaultTokenRenewalConsecutiveFailuresMetric(value int) {
+	vm.tokenRenewalConsecutiveFailures.WithLabelValues(
+		vm.vaultLabels["vault_addr"],
+		vm.vaultLabels["vault_engine"],
+		vm.vaultLabels["vault_version"],
+		vm.vaultLabels["vault_cluster_id"],
+		vm.vaultLabels["vault_cluster_name"],
+		vm.vaultLabels["vault_namespace"]).Set(float64(value))
+}
+
+func (vm *vaultMetrics) updateVaultTokenRenewalBackoffMetric(backoff time.Duration) {
+	vm.tokenRenewalBackoff.WithLabelValues(
+		vm.vaultLabels["vault_addr"],
+		vm.vaultLabels["vault_engine"],
+		vm.vaultLabels["vault_version"],
+		vm.vaultLabels["vault_cluster_id"],
+		vm.vaultLabels["vault_cluster_name"],
+		vm.vaultLabels["vault_namespace"]).Set(backoff.Seconds())
+}
+
+func (vm *vaultMetrics) updateVaultTokenMalformedTotalMetric() {
+	vm.tokenMalformedTotal.WithLabelValues(
+		vm.vaultLabels["vault_addr"],
+		vm.vaultLabels["vault_engine"],
+		vm.vaultLabels["vault_version"],
+		vm.vaultLabels["vault_cluster_id"],
+		vm.vaultLabels["vault_cluster_name"],
+		vm.vaultLabels["vault_namespace"]).Inc()
+}
+
+func (vm *vaultMetrics) updateVaultTokenRenewNoProgressTotalMetric() {
+	vm.tokenRenewNoProgressTotal.WithLabelValues(
+		vm.vaultLabels["vault_addr"],
+		vm.vaultLabels["vault_engine"],
+		vm.vaultLabels["vault_version"],
+		vm.vaultLabels["vault_cluster_id"],
+		vm.vaultLabels["vault_cluster_name"],
+		vm.vaultLabels["vault_namespace"]).Inc()
+}
+
+func (vm *vaultMetrics) updateVaultSecretReadErrorsTotalMetric(path string, key string, version string, errorType string) {
+	vm.secretReadErrorsTotal.WithLabelValues(
+		vm.vaultLabels["vault_addr"],
+		vm.vaultLabels["vault_engine"],
+		vm.vaultLabels["vault_version"],
+		vm.vaultLabels["vault_cluster_id"],
+		vm.vaultLabels["vault_cluster_name"],
+		vm.vaultLabels["vault_namespace"],
+		vm.pathLabel(path),
+		vm.keyLabel(key),
+		version,
+		errorType,
+		vaultBackendName).Inc()
+	vm.updateVaultEngineReadsTotalMetric(path, requestResultError)
+	vm.updateVaultSecretLastErrorMetric(path)
+}
+
+// updateVaultEngineReadsTotalMetric counts a read of path by the engine of the path, e.g. to compare the errors
+// of KV version 1 and 2 mounts
+func (vm *vaultMetrics) updateVaultEngineReadsTotalMetric(path string, result string) {
+	engine := vm.vaultLabels["vault_engine"]
+	if vm.engineName != nil {
+		engine = vm.engineName(vm.namespace, path)
+	}
+	vm.engineReadsTotal.WithLabelValues(
+		vm.vaultLabels["vault_addr"],
+		vm.vaultLabels["vault_engine"],
+		vm.vaultLabels["vault_version"],
+		vm.vaultLabels["vault_cluster_id"],
+		vm.vaultLabels["vault_cluster_name"],
+		vm.vaultLabels["vault_namespace"],
+		engine,
+		result).Inc()
+}
+
+// updateVaultSecretLastSyncMetric records the time of a successful read of path, so reads silently stopping,
+// e.g. because the token expired, can be alerted on while the process is still alive
+func (vm *vaultMetrics) updateVaultSecretLastSyncMetric(path string) {
+	vm.secretLastSync.WithLabelValues(
+		vm.vaultLabels["vault_addr"],
+		vm.vaultLabels["vault_engine"],
+		vm.vaultLabels["vault_version"],
+		vm.vaultLabels["vault_cluster_id"],
+		vm.vaultLabels["vault_cluster_name"],
+		vm.vaultLabels["vault_namespace"],
+		vm.pathLabel(path)).SetToCurrentTime()
+}
+
+func (vm *vaultMetrics) updateVaultSecretLastErrorMetric(path string) {
+	vm.secretLastError.WithLabelValues(
+		vm.vaultLabels["vault_addr"],
+		vm.vaultLabels["vault_engine"],
+		vm.vaultLabels["vault_version"],
+		vm.vaultLabels["vault_cluster_id"],
+		vm.vaultLabels["vault_cluster_name"],
+		vm.vaultLabels["vault_namespace"],
+		vm.pathLabel(path)).SetToCurrentTime()
+}
+
+func (vm *vaultMetrics) updateVaultSecretWrappedReadsTotalMetric(path string, result string) {
+	vm.secretWrappedReadsTotal.WithLabelValues(
+		vm.vaultLabels["vault_addr"],
+		vm.vaultLabels["vault_engine"],
+		vm.vaultLabels["vault_version"],
+		vm.vaultLabels["vault_cluster_id"],
+		vm.vaultLabels["vault_cluster_name"],
+		vm.vaultLabels["vault_namespace"],
+		vm.pathLabel(path),
+		result).Inc()
+}
+
+func (vm *vaultMetrics) updateVaultSecretTooLargeTotalMetric(path string, key string) {
+	vm.secretTooLargeTotal.WithLabelValues(
+		vm.vaultLabels["vault_addr"],
+		vm.vaultLabels["vault_engine"],
+		vm.vaultLabels["vault_version"],
+		vm.vaultLabels["vault_cluster_id"],
+		vm.vaultLabels["vault_cluster_name"],
+		vm.vaultLabels["vault_namespace"],
+		vm.pathLabel(path),
+		vm.keyLabel(key)).Inc()
+}
+
+func (vm *vaultMetrics) updateVaultPathDeniedTotalMetric(path string) {
+	vm.pathDeniedTotal.WithLabelValues(
+		vm.vaultLabels["vault_addr"],
+		vm.vaultLabels["vault_engine"],
+		vm.vaultLabels["vault_version"],
+		vm.vaultLabels["vault_cluster_id"],
+		vm.vaultLabels["vault_cluster_name"],
+		vm.vaultLabels["vault_namespace"],
+		vm.pathLabel(path)).Inc()
+}
+
+func (vm *vaultMetrics) updateVaultEventsReceivedTotalMetric(eventType string) {
+	vm.eventsReceivedTotal.WithLabelValues(
+		vm.vaultLabels["vault_addr"],
+		vm.vaultLabels["vault_engine"],
+		vm.vaultLabels["vault_version"],
+		vm.vaultLabels["vault_cluster_id"],
+		vm.vaultLabels["vault_cluster_name"],
+		vm.vaultLabels["vault_namespace"],
+		eventType).Inc()
+}
+
+func (vm *vaultMetrics) updateVaultRoleTokensCreatedTotalMetric(role string) {
+	vm.roleTokensCreatedTotal.WithLabelValues(
+		vm.vaultLabels["vault_addr"],
+		vm.vaultLabels["vault_engine"],
+		vm.vaultLabels["vault_version"],
+		vm.vaultLabels["vault_cluster_id"],
+		vm.vaultLabels["vault_cluster_name"],
+		vm.vaultLabels["vault_namespace"],
+		role).Inc()
+}
+
+func (vm *vaultMetrics) updateVaultRoleTokenErrorsTotalMetric(role string, errorType string) {
+	vm.roleTokenErrorsTotal.WithLabelValues(
+		vm.vaultLabels["vault_addr"],
+		vm.vaultLabels["vault_engine"],
+		vm.vaultLabels["vault_version"],
+		vm.vaultLabels["vault_cluster_id"],
+		vm.vaultLabels["vault_cluster_name"],
+		vm.vaultLabels["vault_namespace"],
+		role,
+		errorType).Inc()
+}
+
+func (vm *vaultMetrics) updateVaultChildTokensCreatedTotalMetric(role string) {
+	vm.childTokensCreatedTotal.WithLabelValues(
+		vm.vaultLabels["vault_addr"],
+		vm.vaultLabels["vault_engine"],
+		vm.vaultLabels["vault_version"],
+		vm.vaultLabels["vault_cluster_id"],
+		vm.vaultLabels["vault_cluster_name"],
+		vm.vaultLabels["vault_namespace"],
+		role).Inc()
+}
+
+func (vm *vaultMetrics) updateVaultChildTokenErrorsTotalMetric(role string, errorType string) {
+	vm.childTokenErrorsTotal.WithLabelValues(
+		vm.vaultLabels["vault_addr"],
+		vm.vaultLabels["vault_engine"],
+		vm.vaultLabels["vault_version"],
+		vm.vaultLabels["vault_cluster_id"],
+		vm.vaultLabels["vault_cluster_name"],
+		vm.vaultLabels["vault_namespace"],
+		role,
+		errorType).Inc()
+}
+
+func (vm *vaultMetrics) updateVaultHealthFlapsTotalMetric() {
+	vm.healthFlapsTotal.WithLabelValues(
+		vm.vaultLabels["vault_addr"],
+		vm.vaultLabels["vault_engine"],
+		vm.vaultLabels["vault_version"],
+		vm.vaultLabels["vault_cluster_id"],
+		vm.vaultLabels["vault_cluster_name"],
+		vm.vaultLabels["vault_namespace"]).Inc()
+}
+
+func (vm *vaultMetrics) updateVaultSecretReadSuccessesTotalMetric(path string, key string, version string) {
+	vm.secretReadSuccessesTotal.WithLabelValues(
+		vm.vaultLabels["vault_addr"],
+		vm.vaultLabels["vault_engine"],
+		vm.vaultLabels["vault_version"],
+		vm.vaultLabels["vault_cluster_id"],
+		vm.vaultLabels["vault_cluster_name"],
+		vm.vaultLabels["vault_namespace"],
+		vm.pathLabel(path),
+		vm.keyLabel(key),
+		version).Inc()
+	vm.updateVaultEngineReadsTotalMetric(path, requestResultSuccess)
+}
+
+func (vm *vaultMetrics) updateVaultSecretReadDurationMetric(path string, result string, duration time.Duration) {
+	vm.secretReadDuration.WithLabelValues(
+		vm.vaultLabels["vault_addr"],
+		vm.vaultLabels["vault_engine"],
+		vm.vaultLabels["vault_version"],
+		vm.vaultLabels["vault_cluster_id"],
+		vm.vaultLabels["vault_cluster_name"],
+		vm.vaultLabels["vault_namespace"],
+		vm.pathLabel(path),
+		result).Observe(duration.Seconds())
+}
+
+func (vm *vaultMetrics) updateVaultTokenRequestDurationMetric(operation string, result string, duration time.Duration) {
+	vm.tokenRequestDuration.WithLabelValues(
+		vm.vaultLabels["vault_addr"],
+		vm.vaultLabels["vault_engine"],
+		vm.vaultLabels["vault_version"],
+		vm.vaultLabels["vault_cluster_id"],
+		vm.vaultLabels["vault_cluster_name"],
+		vm.vaultLabels["vault_namespace"],
+		operation,
+		result).Observe(duration.Seconds())
+}
+
+func (vm *vaultMetrics) updateVaultTokenRequestsTotalMetric(operation string, result string) {
+	vm.tokenRequestsTotal.WithLabelValues(
+		vm.vaultLabels["vault_addr"],
+		vm.vaultLabels["vault_engine"],
+		vm.vaultLabels["vault_version"],
+		vm.vaultLabels["vault_cluster_id"],
+		vm.vaultLabels["vault_cluster_name"],
+		vm.vaultLabels["vault_namespace"],
+		operation,
+		result).Inc()
+}
+
+func (vm *vaultMetrics) updateVaultSecretListErrorsTotalMetric(path string, errorType string) {
+	vm.secretListErrorsTotal.WithLabelValues(
+		vm.vaultLabels["vault_addr"],
+		vm.vaultLabels["vault_engine"],
+		vm.vaultLabels["vault_version"],
+		vm.vaultLabels["vault_cluster_id"],
+		vm.vaultLabels["vault_cluster_name"],
+		vm.vaultLabels["vault_namespace"],
+		vm.pathLabel(path),
 		errorType).Inc()
 }
 
 func (vm *vaultMetrics) updateVaultTokenRenewalErrorsTotalMetric(vaultOperation string, errorType string) {
-	tokenRenewalErrorsTotal.WithLabelValues(
+	vm.tokenRenewalErrorsTotal.WithLabelValues(
 		vm.vaultLabels["vault_addr"],
 		vm.vaultLabels["vault_engine"],
 		vm.vaultLabels["vault_version"],
 		vm.vaultLabels["vault_cluster_id"],
 		vm.vaultLabels["vault_cluster_name"],
+		vm.vaultLabels["vault_namespace"],
 		vaultOperation,
 		errorType).Inc()
 }
 
-func (vm *vaultMetrics) updateVaultLoginErrorsTotalMetric() {
-	loginErrorsTotal.WithLabelValues(
+func (vm *vaultMetrics) updateVaultLoginErrorsTotalMetric(errorType string) {
+	vm.loginErrorsTotal.WithLabelValues(
+		vm.vaultLabels["vault_addr"],
+		vm.vaultLabels["vault_engine"],
+		vm.vaultLabels["vault_version"],
+		vm.vaultLabels["vault_cluster_id"],
+		vm.vaultLabels["vault_cluster_name"],
+		vm.vaultLabels["vault_namespace"],
+		errorType).Inc()
+}
+
+func (vm *vaultMetrics) updateVaultLoginSuccessesTotalMetric() {
+	vm.loginSuccessesTotal.WithLabelValues(
+		vm.vaultLabels["vault_addr"],
+		vm.vaultLabels["vault_engine"],
+		vm.vaultLabels["vault_version"],
+		vm.vaultLabels["vault_cluster_id"],
+		vm.vaultLabels["vault_cluster_name"],
+		vm.vaultLabels["vault_namespace"]).Inc()
+}
+
+func (vm *vaultMetrics) updateVaultLoginDurationMetric(duration time.Duration) {
+	vm.loginDuration.WithLabelValues(
+		vm.vaultLabels["vault_addr"],
+		vm.vaultLabels["vault_engine"],
+		vm.vaultLabels["vault_version"],
+		vm.vaultLabels["vault_cluster_id"],
+		vm.vaultLabels["vault_cluster_name"],
+		vm.vaultLabels["vault_namespace"]).Observe(duration.Seconds())
+}
+
+func (vm *vaultMetrics) updateVaultRequestRetriesTotalMetric(operation string) {
+	vm.requestRetriesTotal.WithLabelValues(
+		vm.vaultLabels["vault_addr"],
+		vm.vaultLabels["vault_engine"],
+		vm.vaultLabels["vault_version"],
+		vm.vaultLabels["vault_cluster_id"],
+		vm.vaultLabels["vault_cluster_name"],
+		vm.vaultLabels["vault_namespace"],
+		operation).Inc()
+}
+
+func (vm *vaultMetrics) updateVaultRetriedRequestsTotalMetric(operation string, outcome string) {
+	vm.retriedRequestsTotal.WithLabelValues(
+		vm.vaultLabels["vault_addr"],
+		vm.vaultLabels["vault_engine"],
+		vm.vaultLabels["vault_version"],
+		vm.vaultLabels["vault_cluster_id"],
+		vm.vaultLabels["vault_cluster_name"],
+		vm.vaultLabels["vault_namespace"],
+		operation,
+		outcome).Inc()
+}
+
+func (vm *vaultMetrics) updateVaultStandbyForwardsTotalMetric(outcome string) {
+	vm.standbyForwardsTotal.WithLabelValues(
 		vm.vaultLabels["vault_addr"],
 		vm.vaultLabels["vault_engine"],
 		vm.vaultLabels["vault_version"],
 		vm.vaultLabels["vault_cluster_id"],
-		vm.vaultLabels["vault_cluster_name"]).Inc()
+		vm.vaultLabels["vault_cluster_name"],
+		vm.vaultLabels["vault_namespace"],
+		outcome).Inc()
+}
+
+func (vm *vaultMetrics) updateVaultCircuitBreakerMetrics(state string) {
+	labels := []string{
+		vm.vaultLabels["vault_addr"],
+		vm.vaultLabels["vault_engine"],
+		vm.vaultLabels["vault_version"],
+		vm.vaultLabels["vault_cluster_id"],
+		vm.vaultLabels["vault_cluster_name"],
+		vm.vaultLabels["vault_namespace"]}
+	vm.circuitBreakerState.WithLabelValues(labels...).Set(breakerStateValues[state])
+	vm.circuitBreakerTransitionsTotal.WithLabelValues(append(labels, state)...).Inc()
+}
+
+func (vm *vaultMetrics) updateVaultTransitDecryptErrorsTotalMetric(key string, errorType string) {
+	vm.transitDecryptErrorsTotal.WithLabelValues(
+		vm.vaultLabels["vault_addr"],
+		vm.vaultLabels["vault_engine"],
+		vm.vaultLabels["vault_version"],
+		vm.vaultLabels["vault_cluster_id"],
+		vm.vaultLabels["vault_cluster_name"],
+		vm.vaultLabels["vault_namespace"],
+		key,
+		errorType).Inc()
+}
+
+func (vm *vaultMetrics) updateVaultTransitDecryptDurationMetric(key string, duration time.Duration) {
+	vm.transitDecryptDuration.WithLabelValues(
+		vm.vaultLabels["vault_addr"],
+		vm.vaultLabels["vault_engine"],
+		vm.vaultLabels["vault_version"],
+		vm.vaultLabels["vault_cluster_id"],
+		vm.vaultLabels["vault_cluster_name"],
+		vm.vaultLabels["vault_namespace"],
+		key).Observe(duration.Seconds())
+}
+
+func (vm *vaultMetrics) updateVaultLeaseRenewalErrorsTotalMetric(path string, errorType string) {
+	vm.leaseRenewalErrorsTotal.WithLabelValues(
+		vm.vaultLabels["vault_addr"],
+		vm.vaultLabels["vault_engine"],
+		vm.vaultLabels["vault_version"],
+		vm.vaultLabels["vault_cluster_id"],
+		vm.vaultLabels["vault_cluster_name"],
+		vm.vaultLabels["vault_namespace"],
+		vm.pathLabel(path),
+		errorType).Inc()
+}
+
+func (vm *vaultMetrics) updateVaultPKIIssuedCertificatesTotalMetric(role string) {
+	vm.pkiIssuedCertificatesTotal.WithLabelValues(
+		vm.vaultLabels["vault_addr"],
+		vm.vaultLabels["vault_engine"],
+		vm.vaultLabels["vault_version"],
+		vm.vaultLabels["vault_cluster_id"],
+		vm.vaultLabels["vault_cluster_name"],
+		vm.vaultLabels["vault_namespace"],
+		role).Inc()
+}
+
+func (vm *vaultMetrics) updateVaultPKIIssueErrorsTotalMetric(role string, errorType string) {
+	vm.pkiIssueErrorsTotal.WithLabelValues(
+		vm.vaultLabels["vault_addr"],
+		vm.vaultLabels["vault_engine"],
+		vm.vaultLabels["vault_version"],
+		vm.vaultLabels["vault_cluster_id"],
+		vm.vaultLabels["vault_cluster_name"],
+		vm.vaultLabels["vault_namespace"],
+		role,
+		errorType).Inc()
+}
+
+func (vm *vaultMetrics) updateVaultPKIIssueDurationMetric(role string, duration time.Duration) {
+	vm.pkiIssueDuration.WithLabelValues(
+		vm.vaultLabels["vault_addr"],
+		vm.vaultLabels["vault_engine"],
+		vm.vaultLabels["vault_version"],
+		vm.vaultLabels["vault_cluster_id"],
+		vm.vaultLabels["vault_cluster_name"],
+		vm.vaultLabels["vault_namespace"],
+		role).Observe(duration.Seconds())
+}
+
+// updateVaultLeaseTTLMetric and deleteVaultLeaseTTLMetric take the path label, as the TTL of a path is
+// computed across the leases of every path with the same label
+func (vm *vaultMetrics) updateVaultLeaseTTLMetric(path string, value int64) {
+	vm.leaseTTL.WithLabelValues(
+		vm.vaultLabels["vault_addr"],
+		vm.vaultLabels["vault_engine"],
+		vm.vaultLabels["vault_version"],
+		vm.vaultLabels["vault_cluster_id"],
+		vm.vaultLabels["vault_cluster_name"],
+		vm.vaultLabels["vault_namespace"],
+		path).Set(float64(value))
+}
+
+func (vm *vaultMetrics) deleteVaultLeaseTTLMetric(path string) {
+	vm.leaseTTL.DeleteLabelValues(
+		vm.vaultLabels["vault_addr"],
+		vm.vaultLabels["vault_engine"],
+		vm.vaultLabels["vault_version"],
+		vm.vaultLabels["vault_cluster_id"],
+		vm.vaultLabels["vault_cluster_name"],
+		vm.vaultLabels["vault_namespace"],
+		path)
+}
+
+func boolToFloat64(b bool) float64 {
+	if b {
+		return 1.0
+	}
+	return 0.0
+}
+
+func (vm *vaultMetrics) updateVaultHealthMetrics(health *api.HealthResponse) {
+	labels := []string{
+		vm.vaultLabels["vault_addr"],
+		vm.vaultLabels["vault_engine"],
+		vm.vaultLabels["vault_version"],
+		vm.vaultLabels["vault_cluster_id"],
+		vm.vaultLabels["vault_cluster_name"],
+		vm.vaultLabels["vault_namespace"],
+	}
+	vm.sealed.WithLabelValues(labels...).Set(boolToFloat64(health.Sealed))
+	vm.standby.WithLabelValues(labels...).Set(boolToFloat64(health.Standby))
+	vm.initialized.WithLabelValues(labels...).Set(boolToFloat64(health.Initialized))
+}
+
+func (vm *vaultMetrics) updateVaultTokenFileReloadsTotalMetric() {
+	vm.tokenFileReloadsTotal.WithLabelValues(
+		vm.vaultLabels["vault_addr"],
+		vm.vaultLabels["vault_engine"],
+		vm.vaultLabels["vault_version"],
+		vm.vaultLabels["vault_cluster_id"],
+		vm.vaultLabels["vault_cluster_name"],
+		vm.vaultLabels["vault_namespace"]).Inc()
+}
+
+func (vm *vaultMetrics) updateVaultSecretWriteErrorsTotalMetric(path string, errorType string) {
+	vm.secretWriteErrorsTotal.WithLabelValues(
+		vm.vaultLabels["vault_addr"],
+		vm.vaultLabels["vault_engine"],
+		vm.vaultLabels["vault_version"],
+		vm.vaultLabels["vault_cluster_id"],
+		vm.vaultLabels["vault_cluster_name"],
+		vm.vaultLabels["vault_namespace"],
+		vm.pathLabel(path),
+		errorType).Inc()
+}
+
+func (vm *vaultMetrics) updateVaultSecretWriteDurationMetric(path string, duration time.Duration) {
+	vm.secretWriteDuration.WithLabelValues(
+		vm.vaultLabels["vault_addr"],
+		vm.vaultLabels["vault_engine"],
+		vm.vaultLabels["vault_version"],
+		vm.vaultLabels["vault_cluster_id"],
+		vm.vaultLabels["vault_cluster_name"],
+		vm.vaultLabels["vault_namespace"],
+		vm.pathLabel(path)).Observe(duration.Seconds())
+}
+
+func (vm *vaultMetrics) updateVaultFailoversTotalMetric(address string) {
+	vm.failoversTotal.WithLabelValues(
+		vm.vaultLabels["vault_addr"],
+		vm.vaultLabels["vault_engine"],
+		vm.vaultLabels["vault_version"],
+		vm.vaultLabels["vault_cluster_id"],
+		vm.vaultLabels["vault_cluster_name"],
+		vm.vaultLabels["vault_namespace"],
+		address).Inc()
 }
@@ -1,11 +1,16 @@
 package backend
 
 import (
+	"context"
 	"testing"
+	"time"
 
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/testutil"
+	dto "github.com/prometheus/client_model/go"
 	"github.com/stretchr/testify/assert"
 	"github.com/tuenti/secrets-manager/errors"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
 )
 
 const (
@@ -14,46 +19,47 @@ const (
 	fakeVaultEngine      = "kv2"
 	fakeVaultClusterID   = "vault-fake-1"
 	fakeVaultClusterName = "vault-fake"
+	fakeVaultNamespace   = "team-a"
 )
 
 func TestUpdateMaxTokenTTL(t *testing.T) {
-	metrics := newVaultMetrics(fakeVaultAddress, fakeVaultVersion, fakeVaultEngine, fakeVaultClusterID, fakeVaultClusterName)
-	maxTokenTTL.Reset()
+	metrics := newVaultMetrics(newTestVaultCollectors(), fakeVaultAddress, fakeVaultVersion, fakeVaultEngine, fakeVaultClusterID, fakeVaultClusterName, fakeVaultNamespace)
+	metrics.maxTokenTTL.Reset()
 	metrics.updateVaultMaxTokenTTLMetric(600)
-	metricMaxTokenTTL, _ := maxTokenTTL.GetMetricWithLabelValues(fakeVaultAddress, fakeVaultEngine, fakeVaultVersion, fakeVaultClusterID, fakeVaultClusterName)
+	metricMaxTokenTTL, _ := metrics.maxTokenTTL.GetMetricWithLabelValues(fakeVaultAddress, fakeVaultEngine, fakeVaultVersion, fakeVaultClusterID, fakeVaultClusterName, fakeVaultNamespace)
 
 	assert.Equal(t, 600.0, testutil.ToFloat64(metricMaxTokenTTL))
 }
 
 func TestUpdateTokenTTL(t *testing.T) {
-	metrics := newVaultMetrics(fakeVaultAddress, fakeVaultVersion, fakeVaultEngine, fakeVaultClusterID, fakeVaultClusterName)
-	tokenTTL.Reset()
-	metrics.updateVaultTokenTTLMetric(300)
-	metricTokenTTL, _ := tokenTTL.GetMetricWithLabelValues(fakeVaultAddress, fakeVaultEngine, fakeVaultVersion, fakeVaultClusterID, fakeVaultClusterName)
+	metrics := newVaultMetrics(newTestVaultCollectors(), fakeVaultAddress, fakeVaultVersion, fakeVaultEngine, fakeVaultClusterID, fakeVaultClusterName, fakeVaultNamespace)
+	metrics.tokenTTL.Reset()
+	metrics.updateVaultTokenTTLMetric(300, serviceTokenType)
+	metricTokenTTL, _ := metrics.tokenTTL.GetMetricWithLabelValues(fakeVaultAddress, fakeVaultEngine, fakeVaultVersion, fakeVaultClusterID, fakeVaultClusterName, fakeVaultNamespace, serviceTokenType)
 
 	assert.Equal(t, 300.0, testutil.ToFloat64(metricTokenTTL))
 }
 
 func TestUpdateTokenLookupErrorsTotal(t *testing.T) {
-	metrics := newVaultMetrics(fakeVaultAddress, fakeVaultVersion, fakeVaultEngine, fakeVaultClusterID, fakeVaultClusterName)
-	tokenRenewalErrorsTotal.Reset()
+	metrics := newVaultMetrics(newTestVaultCollectors(), fakeVaultAddress, fakeVaultVersion, fakeVaultEngine, fakeVaultClusterID, fakeVaultClusterName, fakeVaultNamespace)
+	metrics.tokenRenewalErrorsTotal.Reset()
 	metrics.updateVaultTokenRenewalErrorsTotalMetric(vaultLookupSelfOperationName, errors.UnknownErrorType)
-	metricTokenRenewalErrorsTotal, _ := tokenRenewalErrorsTotal.GetMetricWithLabelValues(fakeVaultAddress, fakeVaultEngine, fakeVaultVersion, fakeVaultClusterID, fakeVaultClusterName, vaultLookupSelfOperationName, errors.UnknownErrorType)
+	metricTokenRenewalErrorsTotal, _ := metrics.tokenRenewalErrorsTotal.GetMetricWithLabelValues(fakeVaultAddress, fakeVaultEngine, fakeVaultVersion, fakeVaultClusterID, fakeVaultClusterName, fakeVaultNamespace, vaultLookupSelfOperationName, errors.UnknownErrorType)
 
 	assert.Equal(t, 1.0, testutil.ToFloat64(metricTokenRenewalErrorsTotal))
 }
 
 func TestUpdateTokenRenewErrorsTotal(t *testing.T) {
-	metrics := newVaultMetrics(fakeVaultAddress, fakeVaultVersion, fakeVaultEngine, fakeVaultClusterID, fakeVaultClusterName)
-	tokenRenewalErrorsTotal.Reset()
+	metrics := newVaultMetrics(newTestVaultCollectors(), fakeVaultAddress, fakeVaultVersion, fakeVaultEngine, fakeVaultClusterID, fakeVaultClusterName, fakeVaultNamespace)
+	metrics.tokenRenewalErrorsTotal.Reset()
 	metrics.updateVaultTokenRenewalErrorsTotalMetric(vaultRenewSelfOperationName, errors.UnknownErrorType)
-	metricTokenRenewalErrorsTotal, _ := tokenRenewalErrorsTotal.GetMetricWithLabelValues(fakeVaultAddress, fakeVaultEngine, fakeVaultVersion, fakeVaultClusterID, fakeVaultClusterName, vaultRenewSelfOperationName, errors.UnknownErrorType)
+	metricTokenRenewalErrorsTotal, _ := metrics.tokenRenewalErrorsTotal.GetMetricWithLabelValues(fakeVaultAddress, fakeVaultEngine, fakeVaultVersion, fakeVaultClusterID, fakeVaultClusterName, fakeVaultNamespace, vaultRenewSelfOperationName, errors.UnknownErrorType)
 
 	assert.Equal(t, 1.0, testutil.ToFloat64(metricTokenRenewalErrorsTotal))
 
-	tokenRenewalErrorsTotal.Reset()
+	metrics.tokenRenewalErrorsTotal.Reset()
 	metrics.updateVaultTokenRenewalErrorsTotalMetric(vaultIsRenewableOperationName, errors.VaultTokenNotRenewableErrorType)
-	metricTokenRenewalErrorsTotal, _ = tokenRenewalErrorsTotal.GetMetricWithLabelValues(fakeVaultAddress, fakeVaultEngine, fakeVaultVersion, fakeVaultClusterID, fakeVaultClusterName, vaultIsRenewableOperationName, errors.VaultTokenNotRenewableErrorType)
+	metricTokenRenewalErrorsTotal, _ = metrics.tokenRenewalErrorsTotal.GetMetricWithLabelValues(fakeVaultAddress, fakeVaultEngine, fakeVaultVersion, fakeVaultClusterID, fakeVaultClusterName, fakeVaultNamespace, vaultIsRenewableOperationName, errors.VaultTokenNotRenewableErrorType)
 
 	assert.Equal(t, 1.0, testutil.ToFloat64(metricTokenRenewalErrorsTotal))
 }
@@ -62,16 +68,251 @@ func TestUpdateReadSecretErrorsTotal(t *testing.T) {
 	path := "/path/to/secret"
 	key := "key"
 
-	metrics := newVaultMetrics(fakeVaultAddress, fakeVaultVersion, fakeVaultEngine, fakeVaultClusterID, fakeVaultClusterName)
-	secretReadErrorsTotal.Reset()
-	metrics.updateVaultSecretReadErrorsTotalMetric(path, key, errors.UnknownErrorType)
-	metricSecretReadErrorsTotal, _ := secretReadErrorsTotal.GetMetricWithLabelValues(fakeVaultAddress, fakeVaultEngine, fakeVaultVersion, fakeVaultClusterID, fakeVaultClusterName, path, key, errors.UnknownErrorType)
+	metrics := newVaultMetrics(newTestVaultCollectors(), fakeVaultAddress, fakeVaultVersion, fakeVaultEngine, fakeVaultClusterID, fakeVaultClusterName, fakeVaultNamespace)
+	metrics.secretReadErrorsTotal.Reset()
+	metrics.updateVaultSecretReadErrorsTotalMetric(path, key, "", errors.UnknownErrorType)
+	metricSecretReadErrorsTotal, _ := metrics.secretReadErrorsTotal.GetMetricWithLabelValues(fakeVaultAddress, fakeVaultEngine, fakeVaultVersion, fakeVaultClusterID, fakeVaultClusterName, fakeVaultNamespace, path, key, "", errors.UnknownErrorType, vaultBackendName)
 
 	assert.Equal(t, 1.0, testutil.ToFloat64(metricSecretReadErrorsTotal))
 
-	secretReadErrorsTotal.Reset()
-	metrics.updateVaultSecretReadErrorsTotalMetric(path, key, errors.BackendSecretNotFoundErrorType)
-	metricSecretReadErrorsTotal, _ = secretReadErrorsTotal.GetMetricWithLabelValues(fakeVaultAddress, fakeVaultEngine, fakeVaultVersion, fakeVaultClusterID, fakeVaultClusterName, path, key, errors.BackendSecretNotFoundErrorType)
+	metrics.secretReadErrorsTotal.Reset()
+	metrics.updateVaultSecretReadErrorsTotalMetric(path, key, "", errors.BackendSecretNotFoundErrorType)
+	metricSecretReadErrorsTotal, _ = metrics.secretReadErrorsTotal.GetMetricWithLabelValues(fakeVaultAddress, fakeVaultEngine, fakeVaultVersion, fakeVaultClusterID, fakeVaultClusterName, fakeVaultNamespace, path, key, "", errors.BackendSecretNotFoundErrorType, vaultBackendName)
 
 	assert.Equal(t, 1.0, testutil.ToFloat64(metricSecretReadErrorsTotal))
 }
+
+func TestUpdateListSecretsErrorsTotal(t *testing.T) {
+	path := "/path/to/secret"
+
+	metrics := newVaultMetrics(newTestVaultCollectors(), fakeVaultAddress, fakeVaultVersion, fakeVaultEngine, fakeVaultClusterID, fakeVaultClusterName, fakeVaultNamespace)
+	metrics.secretListErrorsTotal.Reset()
+	metrics.updateVaultSecretListErrorsTotalMetric(path, errors.BackendSecretNotFoundErrorType)
+	metricSecretListErrorsTotal, _ := metrics.secretListErrorsTotal.GetMetricWithLabelValues(fakeVaultAddress, fakeVaultEngine, fakeVaultVersion, fakeVaultClusterID, fakeVaultClusterName, fakeVaultNamespace, path, errors.BackendSecretNotFoundErrorType)
+
+	assert.Equal(t, 1.0, testutil.ToFloat64(metricSecretListErrorsTotal))
+}
+
+func TestUpdateSecretReadErrorsTotalWithoutPathLabels(t *testing.T) {
+	metrics := newVaultMetrics(newTestVaultCollectors(), fakeVaultAddress, fakeVaultVersion, fakeVaultEngine, fakeVaultClusterID, fakeVaultClusterName, fakeVaultNamespace)
+	metrics.pathLabels = false
+	metrics.secretReadErrorsTotal.Reset()
+	metrics.updateVaultSecretReadErrorsTotalMetric("secret/data/foo", "bar", "", errors.BackendSecretNotFoundErrorType)
+	metrics.updateVaultSecretReadErrorsTotalMetric("secret/data/baz", "qux", "", errors.BackendSecretNotFoundErrorType)
+	metricSecretReadErrorsTotal, _ := metrics.secretReadErrorsTotal.GetMetricWithLabelValues(fakeVaultAddress, fakeVaultEngine, fakeVaultVersion, fakeVaultClusterID, fakeVaultClusterName, fakeVaultNamespace, "", "", "", errors.BackendSecretNotFoundErrorType, vaultBackendName)
+
+	assert.Equal(t, 2.0, testutil.ToFloat64(metricSecretReadErrorsTotal))
+}
+
+func TestUpdateSecretListErrorsTotalNormalizedPath(t *testing.T) {
+	metrics := newVaultMetrics(newTestVaultCollectors(), fakeVaultAddress, fakeVaultVersion, fakeVaultEngine, fakeVaultClusterID, fakeVaultClusterName, fakeVaultNamespace)
+	metrics.normalizePath = newPathNormalizer(2)
+	metrics.secretListErrorsTotal.Reset()
+	metrics.updateVaultSecretListErrorsTotalMetric("secret/metadata/foo", errors.UnknownErrorType)
+	metrics.updateVaultSecretListErrorsTotalMetric("secret/metadata/bar", errors.UnknownErrorType)
+	metricSecretListErrorsTotal, _ := metrics.secretListErrorsTotal.GetMetricWithLabelValues(fakeVaultAddress, fakeVaultEngine, fakeVaultVersion, fakeVaultClusterID, fakeVaultClusterName, fakeVaultNamespace, "secret/metadata", errors.UnknownErrorType)
+
+	assert.Equal(t, 2.0, testutil.ToFloat64(metricSecretListErrorsTotal))
+}
+
+func TestPathNormalizer(t *testing.T) {
+	assert.Equal(t, "/secret/data/foo/bar", newPathNormalizer(0)("/secret/data/foo/bar"))
+	assert.Equal(t, "secret/data", newPathNormalizer(2)("/secret/data/foo/bar"))
+	assert.Equal(t, "secret/data", newPathNormalizer(3)("secret/data"))
+}
+
+func TestRedactDisplayName(t *testing.T) {
+	assert.Equal(t, "approle", redactDisplayName("approle"))
+	assert.Equal(t, "kubernetes-default-secrets-manager", redactDisplayName("kubernetes-default-secrets-manager"))
+	assert.Equal(t, "oidc-redacted@example.com", redactDisplayName("oidc-alice.smith@example.com"))
+}
+
+// histogramSampleCount returns the number of observations of a histogram series
+func histogramSampleCount(t *testing.T, observer prometheus.Observer) uint64 {
+	m := &dto.Metric{}
+	assert.Nil(t, observer.(prometheus.Metric).Write(m))
+	return m.GetHistogram().GetSampleCount()
+}
+
+func histogramSampleSum(t *testing.T, observer prometheus.Observer) float64 {
+	m := &dto.Metric{}
+	assert.Nil(t, observer.(prometheus.Metric).Write(m))
+	return m.GetHistogram().GetSampleSum()
+}
+
+func TestUpdateSecretReadSuccessesTotal(t *testing.T) {
+	vm := newVaultMetrics(newTestVaultCollectors(), fakeVaultAddress, fakeVaultVersion, fakeVaultEngine, fakeVaultClusterID, fakeVaultClusterName, fakeVaultNamespace)
+	vm.secretReadSuccessesTotal.Reset()
+	vm.updateVaultSecretReadSuccessesTotalMetric("secret/data/foo", "bar", "")
+	metric, _ := vm.secretReadSuccessesTotal.GetMetricWithLabelValues(fakeVaultAddress, fakeVaultEngine, fakeVaultVersion, fakeVaultClusterID, fakeVaultClusterName, fakeVaultNamespace, "secret/data/foo", "bar", "")
+	assert.Equal(t, 1.0, testutil.ToFloat64(metric))
+}
+
+func TestUpdateSecretLastSyncAndError(t *testing.T) {
+	vm := newVaultMetrics(newTestVaultCollectors(), fakeVaultAddress, fakeVaultVersion, fakeVaultEngine, fakeVaultClusterID, fakeVaultClusterName, fakeVaultNamespace)
+	vm.normalizePath = newPathNormalizer(2)
+	vm.secretLastSync.Reset()
+	vm.secretLastError.Reset()
+	before := float64(time.Now().Unix())
+	vm.updateVaultSecretLastSyncMetric("secret/data/foo")
+	vm.updateVaultSecretReadErrorsTotalMetric("secret/data/bar", "baz", "", errors.BackendSecretNotFoundErrorType)
+
+	lastSync, _ := vm.secretLastSync.GetMetricWithLabelValues(fakeVaultAddress, fakeVaultEngine, fakeVaultVersion, fakeVaultClusterID, fakeVaultClusterName, fakeVaultNamespace, "secret/data")
+	lastError, _ := vm.secretLastError.GetMetricWithLabelValues(fakeVaultAddress, fakeVaultEngine, fakeVaultVersion, fakeVaultClusterID, fakeVaultClusterName, fakeVaultNamespace, "secret/data")
+	assert.True(t, testutil.ToFloat64(lastSync) >= before)
+	assert.True(t, testutil.ToFloat64(lastError) >= before)
+}
+
+func TestUpdateSecretReadDuration(t *testing.T) {
+	vm := newVaultMetrics(newTestVaultCollectors(), fakeVaultAddress, fakeVaultVersion, fakeVaultEngine, fakeVaultClusterID, fakeVaultClusterName, fakeVaultNamespace)
+	vm.secretReadDuration.Reset()
+	vm.pathLabels = false
+	vm.updateVaultSecretReadDurationMetric("secret/data/foo", requestResultSuccess, 10*time.Millisecond)
+	vm.updateVaultSecretReadDurationMetric("secret/data/bar", requestResultSuccess, 20*time.Millisecond)
+	vm.updateVaultSecretReadDurationMetric("secret/data/bar", requestResultError, 20*time.Millisecond)
+	metric, _ := vm.secretReadDuration.GetMetricWithLabelValues(fakeVaultAddress, fakeVaultEngine, fakeVaultVersion, fakeVaultClusterID, fakeVaultClusterName, fakeVaultNamespace, "", requestResultSuccess)
+	assert.Equal(t, uint64(2), histogramSampleCount(t, metric))
+}
+
+func TestDurationBuckets(t *testing.T) {
+	vm := newVaultMetrics(newVaultCollectors(defaultMetricsNamespace, defaultMetricsSubsystem, []float64{0.01, 0.1, 1}), fakeVaultAddress, fakeVaultVersion, fakeVaultEngine, fakeVaultClusterID, fakeVaultClusterName, fakeVaultNamespace)
+	vm.updateVaultTokenRequestDurationMetric(vaultLookupSelfOperationName, requestResultSuccess, 50*time.Millisecond)
+
+	metric, _ := vm.tokenRequestDuration.GetMetricWithLabelValues(fakeVaultAddress, fakeVaultEngine, fakeVaultVersion, fakeVaultClusterID, fakeVaultClusterName, fakeVaultNamespace, vaultLookupSelfOperationName, requestResultSuccess)
+	m := &dto.Metric{}
+	metric.(prometheus.Metric).Write(m)
+	buckets := m.GetHistogram().GetBucket()
+	assert.Len(t, buckets, 3)
+	assert.Equal(t, uint64(0), buckets[0].GetCumulativeCount())
+	assert.Equal(t, uint64(1), buckets[1].GetCumulativeCount())
+}
+
+// bucketsCount returns the number of buckets of a histogram series
+func bucketsCount(t *testing.T, observer prometheus.Observer) int {
+	m := &dto.Metric{}
+	assert.Nil(t, observer.(prometheus.Metric).Write(m))
+	return len(m.GetHistogram().GetBucket())
+}
+
+func TestVaultClientsDurationBuckets(t *testing.T) {
+	cfg := vaultCfg
+	cfg.VaultMetricsDurationBuckets = []float64{0.01, 0.1, 1}
+	client1, err := vaultClient(logger, cfg)
+	assert.Nil(t, err)
+	cfg.VaultMetricsDurationBuckets = nil
+	client2, err := vaultClient(logger, cfg)
+	assert.Nil(t, err)
+
+	// The second client doesn't replace the histograms of the first one
+	_, err = client1.ReadSecret("secret/data/test", "foo")
+	assert.Nil(t, err)
+	_, err = client2.ReadSecret("secret/data/test", "foo")
+	assert.Nil(t, err)
+	labels := []string{vaultCfg.VaultURL, vaultCfg.VaultEngine, vaultFakeVersion, vaultFakeClusterID, vaultFakeClusterName, vaultCfg.VaultNamespace, "secret/data/test", requestResultSuccess}
+	metric1, _ := client1.metrics.secretReadDuration.GetMetricWithLabelValues(labels...)
+	metric2, _ := client2.metrics.secretReadDuration.GetMetricWithLabelValues(labels...)
+	assert.Equal(t, 3, bucketsCount(t, metric1))
+	assert.Equal(t, len(prometheus.DefBuckets), bucketsCount(t, metric2))
+	assert.Equal(t, uint64(1), histogramSampleCount(t, metric1))
+	assert.Equal(t, uint64(1), histogramSampleCount(t, metric2))
+
+	cfg.VaultMetricsDurationBuckets = []float64{1, 0.1}
+	_, err = vaultClient(logger, cfg)
+	assert.True(t, errors.IsBackendConfig(err))
+}
+
+func BenchmarkUpdateSecretReadDuration(b *testing.B) {
+	vm := newVaultMetrics(newTestVaultCollectors(), fakeVaultAddress, fakeVaultVersion, fakeVaultEngine, fakeVaultClusterID, fakeVaultClusterName, fakeVaultNamespace)
+	for i := 0; i < b.N; i++ {
+		start := time.Now()
+		vm.updateVaultSecretReadDurationMetric("secret/data/foo", requestResultSuccess, time.Since(start))
+	}
+}
+
+// gatheredNames returns the names of the metrics registered in registry
+func gatheredNames(t *testing.T, registry prometheus.Gatherer) []string {
+	families, err := registry.Gather()
+	assert.Nil(t, err)
+	names := []string{}
+	for _, family := range families {
+		names = append(names, family.GetName())
+	}
+	return names
+}
+
+func TestVaultClientsMetricsRegisterer(t *testing.T) {
+	registry1 := prometheus.NewRegistry()
+	registry2 := prometheus.NewRegistry()
+	cfg := vaultCfg
+	cfg.MetricsRegisterer = registry1
+	client1, err := vaultClient(logger, cfg)
+	assert.Nil(t, err)
+	cfg.MetricsRegisterer = registry2
+	cfg.VaultNamespace = fakeVaultNamespace
+	client2, err := vaultClient(logger, cfg)
+	assert.Nil(t, err)
+
+	// Every client keeps its own metrics, in its own registry
+	assert.NotEqual(t, client1.metrics.vaultCollectors, client2.metrics.vaultCollectors)
+	client1.metrics.updateVaultTokenTTLMetric(300, serviceTokenType)
+	client2.metrics.updateVaultTokenTTLMetric(600, batchTokenType)
+	assert.Equal(t, 1, seriesCount(client1.metrics.tokenTTL))
+	assert.Equal(t, 1, seriesCount(client2.metrics.tokenTTL))
+	assert.Contains(t, gatheredNames(t, registry1), "secrets_manager_vault_login_successes_total")
+	assert.Contains(t, gatheredNames(t, registry2), "secrets_manager_vault_login_successes_total")
+
+	// A client registering metrics with the same names in the same registry fails
+	cfg.MetricsRegisterer = registry1
+	_, err = vaultClient(logger, cfg)
+	assert.IsType(t, prometheus.AlreadyRegisteredError{}, err)
+	assert.Contains(t, gatheredNames(t, registry1), "secrets_manager_vault_login_successes_total")
+
+	// Metrics are unregistered on Close, so another client can take over
+	assert.Nil(t, client1.Close(context.Background()))
+	assert.NotContains(t, gatheredNames(t, registry1), "secrets_manager_vault_login_successes_total")
+	client3, err := vaultClient(logger, cfg)
+	assert.Nil(t, err)
+	assert.Nil(t, client3.Close(context.Background()))
+	assert.Nil(t, client2.Close(context.Background()))
+}
+
+func TestVaultClientDefaultMetricsRegisterer(t *testing.T) {
+	cfg := vaultCfg
+	cfg.MetricsRegisterer = nil
+	client, err := vaultClient(logger, cfg)
+	assert.Nil(t, err)
+	assert.Contains(t, gatheredNames(t, metrics.Registry), "secrets_manager_vault_login_successes_total")
+
+	assert.Nil(t, client.Close(context.Background()))
+	assert.NotContains(t, gatheredNames(t, metrics.Registry), "secrets_manager_vault_login_successes_total")
+}
+
+func TestMetricsNamespaceAndSubsystem(t *testing.T) {
+	registry := prometheus.NewRegistry()
+	cfg := vaultCfg
+	cfg.MetricsRegisterer = registry
+	cfg.MetricsNamespace = "secretsmanager"
+	cfg.MetricsSubsystem = "hashicorp_vault"
+	_, err := vaultClient(logger, cfg)
+	assert.Nil(t, err)
+
+	names := gatheredNames(t, registry)
+	assert.Contains(t, names, "secretsmanager_hashicorp_vault_login_successes_total")
+	assert.Contains(t, names, "secretsmanager_hashicorp_vault_max_token_ttl")
+	for _, name := range names {
+		assert.NotContains(t, name, "secrets_manager_vault")
+	}
+
+	// A client with other names, in the same registry, doesn't rename the metrics of the running ones
+	cfg.MetricsSubsystem = ""
+	cfg.MetricsNamespace = ""
+	_, err = vaultClient(logger, cfg)
+	assert.Nil(t, err)
+	names = gatheredNames(t, registry)
+	assert.Contains(t, names, "secretsmanager_hashicorp_vault_login_successes_total")
+	assert.Contains(t, names, "secrets_manager_vault_login_successes_total")
+
+	cfg.MetricsNamespace = "secrets-manager"
+	_, err = vaultClient(logger, cfg)
+	assert.True(t, errors.IsBackendConfig(err))
+}
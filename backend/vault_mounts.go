@@ -0,0 +1,186 @@
+package backend
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/hashicorp/vault/api"
+)
+
+const (
+	autoEngineName = "auto"
+	// unknownEngineName labels the metrics of the paths whose engine wasn't detected yet
+	unknownEngineName = "unknown"
+	// vaultMountsPath is the introspection endpoint telling the mount of a path, its type and options
+	vaultMountsPath = "sys/internal/ui/mounts/"
+)
+
+// engineDetector finds out the engine of a mount asking Vault, caching it per namespace and mount, as mounts
+// with the same path in different namespaces can use different engines
+type engineDetector struct {
+	sync.RWMutex
+	enabled bool
+	// fallback is used when Vault can't tell the engine of a mount, e.g. before Vault 0.10
+	fallback engine
+	// mounts are the engines detected by namespace, the empty one being the namespace of the client
+	mounts map[string]map[string]engine
+}
+
+// cached returns the engine of the deepest mount detected in namespace the path is under
+func (d *engineDetector) cached(namespace string, segments []string) (engine, bool) {
+	d.RLock()
+	defer d.RUnlock()
+	for i := len(segments); i > 0; i-- {
+		if e, ok := d.mounts[namespace][strings.Join(segments[:i], "/")]; ok {
+			return e, true
+		}
+	}
+	return nil, false
+}
+
+func (d *engineDetector) store(namespace string, mount string, e engine) bool {
+	d.Lock()
+	defer d.Unlock()
+	if _, ok := d.mounts[namespace][mount]; ok {
+		return false
+	}
+	if d.mounts == nil {
+		d.mounts = map[string]map[string]engine{}
+	}
+	if d.mounts[namespace] == nil {
+		d.mounts[namespace] = map[string]engine{}
+	}
+	d.mounts[namespace][mount] = e
+	return true
+}
+
+// mountEngine returns the engine matching the type and options of a mount
+func mountEngine(mountType string, options map[string]interface{}) (engine, bool) {
+	switch mountType {
+	case "kv", "generic":
+		if version, _ := options["version"].(string); version == "2" {
+			return kvEngineV2{name: kvEngineV2Name}, true
+		}
+		return kvEngineV1{name: kvEngineV1Name}, true
+	case transitEngineName:
+		return transitEngine{name: transitEngineName}, true
+	}
+	return nil, false
+}
+
+// newMountEngines returns the engine of every mount path in mounts, given as engine names
+func newMountEngines(mounts map[string]string) (map[string]engine, error) {
+	engines := make(map[string]engine, len(mounts))
+	for mount, name := range mounts {
+		e, err := newEngine(name)
+		if err != nil {
+			return nil, err
+		}
+		engines[strings.Trim(mount, "/")] = engineWithMount(e, mount)
+	}
+	return engines, nil
+}
+
+// engineFor returns the engine of the deepest mount the path is under, so nested mounts can use a different
+// engine than their parent. Paths not under any configured mount use the engine detected asking Vault when
+// the client engine is auto, or the client engine otherwise. Engines are detected in the namespace of ctx
+func (c *client) engineFor(ctx context.Context, path string) engine {
+	namespace := VaultNamespaceFromContext(ctx)
+	segments := strings.Split(strings.Trim(path, "/"), "/")
+	if e, ok := c.knownEngine(namespace, segments); ok {
+		return e
+	}
+	return c.detectEngine(ctx, segments)
+}
+
+// knownEngine returns the engine of a path without asking Vault: the engine of the deepest configured mount it
+// is under, the one already detected for it in namespace when the client engine is auto, or the client engine
+// otherwise
+func (c *client) knownEngine(namespace string, segments []string) (engine, bool) {
+	for i := len(segments); i > 0; i-- {
+		if e, ok := c.mountEngines[strings.Join(segments[:i], "/")]; ok {
+			return e, true
+		}
+	}
+	if !c.engineDetector.enabled {
+		return c.engine, true
+	}
+	return c.engineDetector.cached(namespace, segments)
+}
+
+// engineName returns the name of the engine of a path in namespace, e.g. kv1 or kv2, without asking Vault so
+// it can label metrics of reads failing before their engine is detected
+func (c *client) engineName(namespace string, path string) string {
+	if e, ok := c.knownEngine(namespace, strings.Split(strings.Trim(path, "/"), "/")); ok {
+		return e.getName()
+	}
+	return unknownEngineName
+}
+
+// detectEngine asks Vault the mount of a path and its engine, in the namespace of ctx. Mounts Vault can't tell
+// about use the fallback engine, they are cached as well unless Vault couldn't be reached, so they are detected
+// on the next read
+func (c *client) detectEngine(ctx context.Context, segments []string) engine {
+	d := &c.engineDetector
+	namespace := VaultNamespaceFromContext(ctx)
+	if e, ok := d.cached(namespace, segments); ok {
+		return e
+	}
+	path := strings.Join(segments, "/")
+	secret, err := c.readMounts(ctx, path)
+	if err != nil && vaultStatusCode(err) != http.StatusNotFound && vaultStatusCode(err) != http.StatusForbidden {
+		c.logger.Error(err, "unable to detect vault engine, using the fallback engine", "path", path, "vault_engine", d.fallback.getName())
+		return d.fallback
+	}
+
+	mount := segments[0]
+	e := d.fallback
+	if err == nil && secret != nil && secret.Data != nil {
+		if p, ok := secret.Data["path"].(string); ok && p != "" {
+			mount = strings.Trim(p, "/")
+		}
+		mountType, _ := secret.Data["type"].(string)
+		options, _ := secret.Data["options"].(map[string]interface{})
+		if detected, ok := mountEngine(mountType, options); ok {
+			e = engineWithMount(detected, mount)
+		}
+	}
+	if d.store(namespace, mount, e) {
+		c.logger.Info("vault engine detected", "vault_mount", mount, "vault_engine", e.getName(), "vault_namespace", namespace)
+		c.metricsFor(ctx).updateVaultMountEngineMetric(mount, e.getName())
+	}
+	return e
+}
+
+// readMounts reads the mount of path from the introspection endpoint, sending the request to the namespace of ctx
+func (c *client) readMounts(ctx context.Context, path string) (*api.Secret, error) {
+	r := c.vclient.NewRequest("GET", "/v1/"+vaultMountsPath+path)
+	setNamespaceHeader(ctx, r)
+	resp, err := c.vclient.RawRequestWithContext(ctx, r)
+	if resp != nil {
+		defer resp.Body.Close()
+	}
+	if err != nil {
+		return nil, err
+	}
+	return api.ParseSecret(resp.Body)
+}
+
+// ParseMountEngines parses a comma-separated list of mount=engine pairs, e.g. secret=kv2,legacy=kv1
+func ParseMountEngines(value string) (map[string]string, error) {
+	mounts := map[string]string{}
+	if value == "" {
+		return mounts, nil
+	}
+	for _, pair := range strings.Split(value, ",") {
+		parts := strings.SplitN(strings.TrimSpace(pair), "=", 2)
+		if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+			return nil, fmt.Errorf("invalid mount engine %q, expected mount=engine", pair)
+		}
+		mounts[parts[0]] = parts[1]
+	}
+	return mounts, nil
+}
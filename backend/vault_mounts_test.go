@@ -0,0 +1,211 @@
+package backend
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/tuenti/secrets-manager/errors"
+)
+
+func v1SysInternalUIMounts(w http.ResponseWriter, r *http.Request) {
+	testCfg.mountLookups++
+	path := mux.Vars(r)["path"]
+	w.Header().Set("Content-Type", "application/json")
+	switch {
+	// secret is a KV version 1 mount in the org/team-a namespace
+	case strings.HasPrefix(path, "secret/") && r.Header.Get(vaultNamespaceHeader) == "org/team-a":
+		fmt.Fprint(w, `{"data": {"path": "secret/", "type": "kv", "options": {"version": "1"}}}`)
+	case strings.HasPrefix(path, "secret/"):
+		fmt.Fprint(w, `{"data": {"path": "secret/", "type": "kv", "options": {"version": "2"}}}`)
+	case strings.HasPrefix(path, "teams/payments/kv/"):
+		fmt.Fprint(w, `{"data": {"path": "teams/payments/kv/", "type": "kv", "options": {"version": "2"}}}`)
+	case strings.HasPrefix(path, "legacy/"):
+		fmt.Fprint(w, `{"data": {"path": "legacy/", "type": "kv", "options": null}}`)
+	case strings.HasPrefix(path, "broken/"):
+		w.WriteHeader(http.StatusInternalServerError)
+		fmt.Fprint(w, `{"errors": ["internal error"]}`)
+	default:
+		w.WriteHeader(http.StatusNotFound)
+		fmt.Fprint(w, `{"errors": []}`)
+	}
+}
+
+func TestMountEngines(t *testing.T) {
+	cfg := vaultCfg
+	cfg.VaultMountEngines = map[string]string{"legacy": kvEngineV1Name}
+	client, err := vaultClient(logger, cfg)
+	assert.Nil(t, err)
+
+	secretValue, err := client.ReadSecret("secret/data/test", "foo")
+	assert.Nil(t, err)
+	assert.Equal(t, "bar", secretValue)
+
+	secretValue, err = client.ReadSecret("legacy/test", "foo")
+	assert.Nil(t, err)
+	assert.Equal(t, "bar", secretValue)
+
+	_, err = client.ReadSecretVersion("legacy/test", "foo", 1)
+	assert.True(t, errors.IsVaultVersioningNotSupported(err))
+}
+
+func TestEngineReadsMetric(t *testing.T) {
+	cfg := vaultCfg
+	cfg.VaultMountEngines = map[string]string{"legacy": kvEngineV1Name}
+	client, err := vaultClient(logger, cfg)
+	assert.Nil(t, err)
+	client.metrics.engineReadsTotal.Reset()
+	engineRead := func(engine string, result string) float64 {
+		metric, _ := client.metrics.engineReadsTotal.GetMetricWithLabelValues(vaultCfg.VaultURL, vaultCfg.VaultEngine, vaultFakeVersion, vaultFakeClusterID, vaultFakeClusterName, vaultCfg.VaultNamespace, engine, result)
+		return testutil.ToFloat64(metric)
+	}
+
+	client.ReadSecret("secret/data/test", "foo")
+	client.ReadSecret("secret/data/test", "missing")
+	client.ReadSecret("legacy/test", "foo")
+	client.ReadSecret("legacy/test", "foo")
+	client.ReadSecret("legacy/test", "missing")
+
+	// The engine of the path labels reads, not the one the client is configured with
+	assert.Equal(t, 1.0, engineRead(kvEngineV2Name, requestResultSuccess))
+	assert.Equal(t, 1.0, engineRead(kvEngineV2Name, requestResultError))
+	assert.Equal(t, 2.0, engineRead(kvEngineV1Name, requestResultSuccess))
+	assert.Equal(t, 1.0, engineRead(kvEngineV1Name, requestResultError))
+}
+
+func TestMountEnginesNested(t *testing.T) {
+	engines, err := newMountEngines(map[string]string{"teams": kvEngineV1Name, "/teams/payments/kv/": kvEngineV2Name})
+	assert.Nil(t, err)
+	client := &client{engine: transitEngine{name: transitEngineName}, mountEngines: engines}
+
+	assert.Equal(t, kvEngineV1Name, client.engineFor(context.Background(), "teams/search/foo").getName())
+	assert.Equal(t, kvEngineV2Name, client.engineFor(context.Background(), "/teams/payments/kv/data/foo").getName())
+	assert.Equal(t, kvEngineV1Name, client.engineFor(context.Background(), "teams/payments/foo").getName())
+	assert.Equal(t, transitEngineName, client.engineFor(context.Background(), "transit/decrypt/foo").getName())
+	assert.Equal(t, "teams/payments/kv/metadata/foo", client.engineFor(context.Background(), "teams/payments/kv/data/foo").metadataPath("teams/payments/kv/data/foo"))
+}
+
+func TestKVMount(t *testing.T) {
+	cfg := vaultCfg
+	cfg.VaultKVMount = "/apps/kv/"
+	client, err := vaultClient(logger, cfg)
+	assert.Nil(t, err)
+
+	assert.Equal(t, "apps/kv/metadata/foo", client.engineFor(context.Background(), "apps/kv/data/foo").metadataPath("apps/kv/data/foo"))
+	assert.Equal(t, "apps/kv/metadata/foo", client.engineFor(context.Background(), "apps/kv/foo").metadataPath("apps/kv/foo"))
+	// Paths out of the mount are still rewritten after their first segment
+	assert.Equal(t, "secret/metadata/foo", client.engineFor(context.Background(), "secret/data/foo").metadataPath("secret/data/foo"))
+}
+
+func TestAutoEngineNestedMount(t *testing.T) {
+	cfg := vaultCfg
+	cfg.VaultEngine = autoEngineName
+	client, err := vaultClient(logger, cfg)
+	assert.Nil(t, err)
+
+	engine := client.engineFor(context.Background(), "teams/payments/kv/data/app/db")
+	assert.Equal(t, kvEngineV2Name, engine.getName())
+	assert.Equal(t, "teams/payments/kv/metadata/app/db", engine.metadataPath("teams/payments/kv/data/app/db"))
+}
+
+func TestMountEnginesInvalidEngine(t *testing.T) {
+	cfg := vaultCfg
+	cfg.VaultMountEngines = map[string]string{"legacy": "kv3"}
+	_, err := vaultClient(logger, cfg)
+	assert.True(t, errors.IsVaultEngineNotImplemented(err))
+}
+
+func TestParseMountEngines(t *testing.T) {
+	mounts, err := ParseMountEngines("secret=kv2, legacy=kv1")
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]string{"secret": "kv2", "legacy": "kv1"}, mounts)
+
+	mounts, err = ParseMountEngines("")
+	assert.Nil(t, err)
+	assert.Empty(t, mounts)
+
+	_, err = ParseMountEngines("legacy")
+	assert.NotNil(t, err)
+}
+
+func TestAutoEngine(t *testing.T) {
+	cfg := vaultCfg
+	cfg.VaultEngine = autoEngineName
+	client, err := vaultClient(logger, cfg)
+	assert.Nil(t, err)
+	mutex.Lock()
+	defer mutex.Unlock()
+	testCfg.mountLookups = 0
+	client.metrics.mountEngineInfo.Reset()
+
+	secretValue, err := client.ReadSecret("secret/data/test", "foo")
+	assert.Nil(t, err)
+	assert.Equal(t, "bar", secretValue)
+	secretValue, err = client.ReadSecret("legacy/test", "foo")
+	assert.Nil(t, err)
+	assert.Equal(t, "bar", secretValue)
+
+	// Detected engines are cached per mount
+	client.ReadSecret("secret/data/multi", "foo")
+	assert.Equal(t, 2, testCfg.mountLookups)
+
+	metricKV2, _ := client.metrics.mountEngineInfo.GetMetricWithLabelValues(vaultCfg.VaultURL, autoEngineName, vaultFakeVersion, vaultFakeClusterID, vaultFakeClusterName, vaultCfg.VaultNamespace, "secret", kvEngineV2Name)
+	metricKV1, _ := client.metrics.mountEngineInfo.GetMetricWithLabelValues(vaultCfg.VaultURL, autoEngineName, vaultFakeVersion, vaultFakeClusterID, vaultFakeClusterName, vaultCfg.VaultNamespace, "legacy", kvEngineV1Name)
+	assert.Equal(t, 1.0, testutil.ToFloat64(metricKV2))
+	assert.Equal(t, 1.0, testutil.ToFloat64(metricKV1))
+
+	// Reads failing before the engine of their mount is detected don't ask Vault for it
+	assert.Equal(t, unknownEngineName, client.engineName("", "other/test"))
+	assert.Equal(t, kvEngineV1Name, client.engineName("", "legacy/test"))
+	assert.Equal(t, 2, testCfg.mountLookups)
+}
+
+func TestAutoEngineFallback(t *testing.T) {
+	cfg := vaultCfg
+	cfg.VaultEngine = autoEngineName
+	client, _ := vaultClient(logger, cfg)
+	mutex.Lock()
+	defer mutex.Unlock()
+	testCfg.mountLookups = 0
+
+	// Mounts Vault doesn't know about use the fallback engine and are cached
+	assert.Equal(t, kvEngineV2Name, client.engineFor(context.Background(), "unknown/foo").getName())
+	assert.Equal(t, kvEngineV2Name, client.engineFor(context.Background(), "unknown/bar").getName())
+	assert.Equal(t, 1, testCfg.mountLookups)
+
+	// Failed lookups are retried on the next read
+	assert.Equal(t, kvEngineV2Name, client.engineFor(context.Background(), "broken/foo").getName())
+	assert.Equal(t, kvEngineV2Name, client.engineFor(context.Background(), "broken/foo").getName())
+	assert.Equal(t, 3, testCfg.mountLookups)
+
+	// Configured mount engines take precedence over detection
+	client.mountEngines = map[string]engine{"secret": kvEngineV1{name: kvEngineV1Name}}
+	assert.Equal(t, kvEngineV1Name, client.engineFor(context.Background(), "secret/test").getName())
+	assert.Equal(t, 3, testCfg.mountLookups)
+}
+
+func TestAutoEngineNamespaces(t *testing.T) {
+	cfg := vaultCfg
+	cfg.VaultEngine = autoEngineName
+	client, _ := vaultClient(logger, cfg)
+	mutex.Lock()
+	defer mutex.Unlock()
+	testCfg.mountLookups = 0
+	teamA := WithVaultNamespace(context.Background(), "org/team-a")
+
+	// Mounts with the same path are detected and cached in every namespace
+	assert.Equal(t, kvEngineV2Name, client.engineFor(context.Background(), "secret/data/foo").getName())
+	assert.Equal(t, kvEngineV1Name, client.engineFor(teamA, "secret/foo").getName())
+	assert.Equal(t, kvEngineV2Name, client.engineFor(context.Background(), "secret/data/bar").getName())
+	assert.Equal(t, kvEngineV1Name, client.engineFor(teamA, "secret/bar").getName())
+	assert.Equal(t, 2, testCfg.mountLookups)
+
+	assert.Equal(t, kvEngineV2Name, client.engineName("", "secret/data/foo"))
+	assert.Equal(t, kvEngineV1Name, client.engineName("org/team-a", "secret/foo"))
+	assert.Equal(t, unknownEngineName, client.engineName("org/team-b", "secret/foo"))
+}
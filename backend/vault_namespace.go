@@ -0,0 +1,41 @@
+package backend
+
+import (
+	"context"
+
+	"github.com/hashicorp/vault/api"
+)
+
+// vaultNamespaceHeader selects the Vault Enterprise namespace a request is sent to
+const vaultNamespaceHeader = "X-Vault-Namespace"
+
+type vaultNamespaceKey struct{}
+
+// WithVaultNamespace returns a copy of ctx making Vault reads made with it go to the given namespace, e.g.
+// org/team-a, instead of the one set with vault.namespace. Only the requests made with ctx are sent there, reads
+// in other namespaces can run at the same time
+func WithVaultNamespace(ctx context.Context, namespace string) context.Context {
+	return context.WithValue(ctx, vaultNamespaceKey{}, namespace)
+}
+
+// VaultNamespaceFromContext returns the namespace set in ctx with WithVaultNamespace, if any
+func VaultNamespaceFromContext(ctx context.Context) string {
+	namespace, _ := ctx.Value(vaultNamespaceKey{}).(string)
+	return namespace
+}
+
+// setNamespaceHeader sends r to the namespace of ctx, if it has one
+func setNamespaceHeader(ctx context.Context, r *api.Request) {
+	namespace := VaultNamespaceFromContext(ctx)
+	if namespace == "" {
+		return
+	}
+	// Request headers are shared with the api client, they are copied so the namespace never leaks to other requests
+	r.Headers = cloneHeader(r.Headers)
+	r.Headers.Set(vaultNamespaceHeader, namespace)
+}
+
+// metricsFor returns the metrics of the reads made with ctx, labelled with its namespace when it has one
+func (c *client) metricsFor(ctx context.Context) *vaultMetrics {
+	return c.metrics.withNamespace(VaultNamespaceFromContext(ctx))
+}
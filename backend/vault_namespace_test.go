@@ -0,0 +1,91 @@
+package backend
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+// v1SecretNamespacedKv2 answers the namespace the request was sent to, waiting a bit so concurrent reads overlap
+func v1SecretNamespacedKv2(w http.ResponseWriter, r *http.Request) {
+	time.Sleep(time.Millisecond)
+	w.Header().Set("Content-Type", "application/json")
+	fmt.Fprintf(w, `{"data": {"data": {"namespace": %q}, "metadata": {"version": 1}}}`, r.Header.Get(vaultNamespaceHeader))
+}
+
+func TestReadSecretWithVaultNamespace(t *testing.T) {
+	client, _ := vaultClient(logger, vaultCfg)
+	client.engine, _ = newEngine("kv2")
+
+	var wg sync.WaitGroup
+	for _, namespace := range []string{"org/team-a", "org/team-b", ""} {
+		for i := 0; i < 20; i++ {
+			wg.Add(1)
+			go func(namespace string) {
+				defer wg.Done()
+				ctx := context.Background()
+				if namespace != "" {
+					ctx = WithVaultNamespace(ctx, namespace)
+				}
+				value, err := client.ReadSecretWithContext(ctx, "secret/data/namespaced", "namespace")
+				assert.Nil(t, err)
+				assert.Equal(t, namespace, value)
+			}(namespace)
+		}
+	}
+	wg.Wait()
+
+	// The namespace header is never left on the requests made without one
+	assert.Empty(t, client.vclient.Headers().Get(vaultNamespaceHeader))
+}
+
+func TestReadSecretWithVaultNamespaceMetrics(t *testing.T) {
+	client, _ := vaultClient(logger, vaultCfg)
+	client.engine, _ = newEngine("kv2")
+	client.metrics.secretReadSuccessesTotal.Reset()
+
+	ctx := WithVaultNamespace(context.Background(), "org/team-a")
+	_, err := client.ReadSecretWithContext(ctx, "secret/data/namespaced", "namespace")
+	assert.Nil(t, err)
+	_, err = client.ReadSecret("secret/data/namespaced", "namespace")
+	assert.Nil(t, err)
+
+	namespaced, _ := client.metrics.secretReadSuccessesTotal.GetMetricWithLabelValues(vaultCfg.VaultURL, vaultCfg.VaultEngine, vaultFakeVersion, vaultFakeClusterID, vaultFakeClusterName, "org/team-a", "secret/data/namespaced", "namespace", "")
+	assert.Equal(t, 1.0, testutil.ToFloat64(namespaced))
+	configured, _ := client.metrics.secretReadSuccessesTotal.GetMetricWithLabelValues(vaultCfg.VaultURL, vaultCfg.VaultEngine, vaultFakeVersion, vaultFakeClusterID, vaultFakeClusterName, vaultCfg.VaultNamespace, "secret/data/namespaced", "namespace", "")
+	assert.Equal(t, 1.0, testutil.ToFloat64(configured))
+}
+
+func TestCachedClientSkipsVaultNamespaceReads(t *testing.T) {
+	client, _ := vaultClient(logger, vaultCfg)
+	client.engine, _ = newEngine("kv2")
+	cached := newCachedClient(client, vaultBackendName, time.Minute, 0)
+
+	value, err := cached.ReadSecret("secret/data/namespaced", "namespace")
+	assert.Nil(t, err)
+	assert.Equal(t, "", value)
+
+	// A cached value of the path in the configured namespace is not returned for another namespace
+	value, err = cached.ReadSecretWithContext(WithVaultNamespace(context.Background(), "org/team-a"), "secret/data/namespaced", "namespace")
+	assert.Nil(t, err)
+	assert.Equal(t, "org/team-a", value)
+}
+
+func TestSharedReadsKeyedByVaultNamespace(t *testing.T) {
+	client, _ := vaultClient(logger, vaultCfg)
+	client.engine, _ = newEngine("kv2")
+
+	ctx := WithSharedReads(context.Background())
+	value, err := client.ReadSecretWithContext(WithVaultNamespace(ctx, "org/team-a"), "secret/data/namespaced", "namespace")
+	assert.Nil(t, err)
+	assert.Equal(t, "org/team-a", value)
+	value, err = client.ReadSecretWithContext(WithVaultNamespace(ctx, "org/team-b"), "secret/data/namespaced", "namespace")
+	assert.Nil(t, err)
+	assert.Equal(t, "org/team-b", value)
+}
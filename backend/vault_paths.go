@@ -0,0 +1,65 @@
+package backend
+
+import (
+	"path"
+	"strings"
+
+	"github.com/tuenti/secrets-manager/errors"
+)
+
+// pathFilter restricts the paths the client reads, whatever the Vault policies of its token allow, so a
+// misconfigured SecretDefinition can't read arbitrary secrets. Denied prefixes take precedence over allowed
+// ones, and every path is allowed when there are no allowed prefixes
+type pathFilter struct {
+	allowed []string
+	denied  []string
+}
+
+// check returns a PathNotAllowedError when p can't be read. Paths are cleaned first, so dot segments can't
+// escape an allowed prefix
+func (f pathFilter) check(p string) error {
+	if len(f.allowed) == 0 && len(f.denied) == 0 {
+		return nil
+	}
+	cleaned := strings.Trim(path.Clean("/"+p), "/")
+	for _, prefix := range f.denied {
+		if matchesPrefix(cleaned, prefix) {
+			return &errors.PathNotAllowedError{ErrType: errors.PathNotAllowedErrorType, Path: p, Reason: "it matches the denied prefix " + prefix}
+		}
+	}
+	if len(f.allowed) == 0 {
+		return nil
+	}
+	for _, prefix := range f.allowed {
+		if matchesPrefix(cleaned, prefix) {
+			return nil
+		}
+	}
+	return &errors.PathNotAllowedError{ErrType: errors.PathNotAllowedErrorType, Path: p, Reason: "it doesn't match any allowed prefix"}
+}
+
+// matchesPrefix returns true if p starts with prefix. A prefix with glob characters, e.g. secret/data/*/app, is
+// matched with path.Match against as many leading segments of p as it has
+func matchesPrefix(p string, prefix string) bool {
+	prefix = strings.TrimLeft(prefix, "/")
+	if !strings.ContainsAny(prefix, "*?[") {
+		return strings.HasPrefix(p, prefix)
+	}
+	patternSegments := strings.Split(strings.TrimRight(prefix, "/"), "/")
+	segments := strings.Split(p, "/")
+	if len(segments) < len(patternSegments) {
+		return false
+	}
+	ok, _ := path.Match(strings.Join(patternSegments, "/"), strings.Join(segments[:len(patternSegments)], "/"))
+	return ok
+}
+
+// checkPath refuses to read paths not allowed by the path filter, logging and counting every attempt
+func (c *client) checkPath(p string) error {
+	err := c.paths.check(p)
+	if err != nil {
+		c.logger.Info("WARNING: refusing to read a path that is not allowed", "path", p, "error", err.Error())
+		c.metrics.updateVaultPathDeniedTotalMetric(p)
+	}
+	return err
+}
@@ -0,0 +1,57 @@
+package backend
+
+import (
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/tuenti/secrets-manager/errors"
+)
+
+func TestPathFilter(t *testing.T) {
+	filter := pathFilter{
+		allowed: []string{"secret/data/apps/", "secret/data/*/shared"},
+		denied:  []string{"/secret/data/apps/admin"},
+	}
+	for path, allowed := range map[string]bool{
+		"secret/data/apps/web":               true,
+		"/secret/data/apps/web":              true,
+		"secret/data/team-a/shared/db":       true,
+		"secret/data/team-a/other":           false,
+		"secret/data/apps/admin":             false,
+		"secret/data/apps/admin/root":        false,
+		"secret/data/apps/../admin":          false,
+		"secret/data/apps/web/../admin/root": false,
+		"secret/data/shared":                 false,
+	} {
+		err := filter.check(path)
+		assert.Equal(t, allowed, err == nil, path)
+		if !allowed {
+			assert.True(t, errors.IsPathNotAllowed(err), path)
+		}
+	}
+
+	assert.Nil(t, pathFilter{}.check("any/path"))
+	assert.Nil(t, pathFilter{denied: []string{"secret/data/admin"}}.check("secret/data/app"))
+	assert.EqualError(t, pathFilter{denied: []string{"secret/data/admin"}}.check("secret/data/admin"), "[PathNotAllowedError] reading secret/data/admin is not allowed: it matches the denied prefix secret/data/admin")
+}
+
+func TestReadSecretPathNotAllowed(t *testing.T) {
+	cfg := vaultCfg
+	cfg.VaultDeniedPathPrefixes = []string{"secret/data/multi"}
+	client, _ := vaultClient(logger, cfg)
+	client.engine, _ = newEngine("kv2")
+	client.metrics.pathDeniedTotal.Reset()
+	metric, _ := client.metrics.pathDeniedTotal.GetMetricWithLabelValues(cfg.VaultURL, cfg.VaultEngine, vaultFakeVersion, vaultFakeClusterID, vaultFakeClusterName, cfg.VaultNamespace, "/secret/data/multi")
+
+	_, err := client.ReadSecret("/secret/data/multi", "foo")
+	assert.True(t, errors.IsPathNotAllowed(err))
+	_, err = client.ReadSecretAllKeys("/secret/data/multi")
+	assert.True(t, errors.IsPathNotAllowed(err))
+	_, err = client.ListSecrets("/secret/data/multi")
+	assert.True(t, errors.IsPathNotAllowed(err))
+	assert.Equal(t, 3.0, testutil.ToFloat64(metric))
+
+	_, err = client.ReadSecret("/secret/data/test", "foo")
+	assert.False(t, errors.IsPathNotAllowed(err))
+}
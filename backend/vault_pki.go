@@ -0,0 +1,84 @@
+package backend
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/vault/api"
+	"github.com/tuenti/secrets-manager/errors"
+)
+
+const defaultPKIPath = "pki"
+
+// IssueCertificate issues a new certificate from the PKI secrets engine for the given role, returning
+// the PEM-encoded certificate, private key and issuing CA. An empty ttl uses the role default
+func (c *client) IssueCertificate(role string, commonName string, altNames []string, ttl string) (string, string, string, error) {
+	if err := validatePKITTL(ttl); err != nil {
+		c.metrics.updateVaultPKIIssueErrorsTotalMetric(role, errors.VaultPKIErrorType)
+		return "", "", "", &errors.VaultPKIError{ErrType: errors.VaultPKIErrorType, Role: role, Err: err}
+	}
+
+	params := map[string]interface{}{"common_name": commonName}
+	if len(altNames) > 0 {
+		params["alt_names"] = strings.Join(altNames, ",")
+	}
+	if ttl != "" {
+		params["ttl"] = ttl
+	}
+
+	start := time.Now()
+	secret, err := c.logical.Write(fmt.Sprintf("%s/issue/%s", defaultPKIPath, role), params)
+	c.metrics.updateVaultPKIIssueDurationMetric(role, time.Since(start))
+	if err != nil {
+		c.metrics.updateVaultPKIIssueErrorsTotalMetric(role, errors.VaultPKIErrorType)
+		return "", "", "", &errors.VaultPKIError{ErrType: errors.VaultPKIErrorType, Role: role, Err: err}
+	}
+
+	values, err := pkiCertificateData(secret)
+	if err != nil {
+		c.metrics.updateVaultPKIIssueErrorsTotalMetric(role, errors.VaultPKIErrorType)
+		return "", "", "", &errors.VaultPKIError{ErrType: errors.VaultPKIErrorType, Role: role, Err: err}
+	}
+	c.leaseRenewer.register(fmt.Sprintf("%s/issue/%s", defaultPKIPath, role), secret)
+	c.metrics.updateVaultPKIIssuedCertificatesTotalMetric(role)
+	return values[0], values[1], values[2], nil
+}
+
+// validatePKITTL checks the ttl is either a duration string or a number of seconds, as Vault accepts both
+func validatePKITTL(ttl string) error {
+	if ttl == "" {
+		return nil
+	}
+	if seconds, err := strconv.Atoi(ttl); err == nil {
+		if seconds <= 0 {
+			return fmt.Errorf("invalid ttl %s, it must be positive", ttl)
+		}
+		return nil
+	}
+	d, err := time.ParseDuration(ttl)
+	if err != nil {
+		return fmt.Errorf("invalid ttl %s: %v", ttl, err)
+	}
+	if d <= 0 {
+		return fmt.Errorf("invalid ttl %s, it must be positive", ttl)
+	}
+	return nil
+}
+
+func pkiCertificateData(secret *api.Secret) ([]string, error) {
+	if secret == nil || secret.Data == nil {
+		return nil, fmt.Errorf("empty response issuing certificate")
+	}
+	keys := []string{"certificate", "private_key", "issuing_ca"}
+	values := make([]string, len(keys))
+	for i, key := range keys {
+		value, ok := secret.Data[key].(string)
+		if !ok {
+			return nil, fmt.Errorf("response does not contain %s", key)
+		}
+		values[i] = value
+	}
+	return values, nil
+}
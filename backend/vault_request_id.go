@@ -0,0 +1,58 @@
+package backend
+
+import (
+	"context"
+	"net/http"
+)
+
+const (
+	defaultUserAgent = "secrets-manager"
+
+	// vaultRequestIDHeader carries the request ID of a reconciliation. Vault only writes it to the audit
+	// log once it's allowed with sys/config/auditing/request-headers
+	vaultRequestIDHeader = "X-Request-Id"
+)
+
+// ContextReader is implemented by backends able to read secrets with a context, which can carry a request ID
+type ContextReader interface {
+	ReadSecretWithContext(ctx context.Context, path string, key string) (string, error)
+}
+
+type requestIDKey struct{}
+
+// WithRequestID returns a copy of ctx carrying the given request ID, sent along with every Vault request
+// made with that context so Vault audit logs can be correlated with the controller logs
+func WithRequestID(ctx context.Context, requestID string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, requestID)
+}
+
+func requestIDFromContext(ctx context.Context) string {
+	requestID, _ := ctx.Value(requestIDKey{}).(string)
+	return requestID
+}
+
+// requestIDTransport sets the request ID header on the requests whose context carries one
+type requestIDTransport struct {
+	next http.RoundTripper
+}
+
+func (t *requestIDTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	requestID := requestIDFromContext(req.Context())
+	if requestID == "" {
+		return t.next.RoundTrip(req)
+	}
+	// A RoundTripper must not modify the request it's given
+	r := new(http.Request)
+	*r = *req
+	r.Header = cloneHeader(req.Header)
+	r.Header.Set(vaultRequestIDHeader, requestID)
+	return t.next.RoundTrip(r)
+}
+
+func cloneHeader(h http.Header) http.Header {
+	clone := make(http.Header, len(h))
+	for k, v := range h {
+		clone[k] = append([]string(nil), v...)
+	}
+	return clone
+}
@@ -0,0 +1,54 @@
+package backend
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// v1SecretHeadersKv2 echoes the User-Agent and request ID headers as the secret keys
+func v1SecretHeadersKv2(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	fmt.Fprintf(w, `{"data": {"data": {"user_agent": %q, "request_id": %q}, "metadata": {"version": 1}}}`, r.UserAgent(), r.Header.Get(vaultRequestIDHeader))
+}
+
+func TestVaultUserAgent(t *testing.T) {
+	client, _ := vaultClient(logger, vaultCfg)
+	client.engine, _ = newEngine("kv2")
+	userAgent, err := client.ReadSecret("secret/data/headers", "user_agent")
+	assert.Nil(t, err)
+	assert.Equal(t, defaultUserAgent, userAgent)
+
+	cfg := vaultCfg
+	cfg.VaultUserAgent = "secrets-manager/v1.2.3"
+	client, _ = vaultClient(logger, cfg)
+	client.engine, _ = newEngine("kv2")
+	userAgent, err = client.ReadSecret("secret/data/headers", "user_agent")
+	assert.Nil(t, err)
+	assert.Equal(t, "secrets-manager/v1.2.3", userAgent)
+}
+
+func TestVaultRequestID(t *testing.T) {
+	client, _ := vaultClient(logger, vaultCfg)
+	client.engine, _ = newEngine("kv2")
+	requestID, err := client.ReadSecretWithContext(WithRequestID(context.Background(), "4f2c7b1e"), "secret/data/headers", "request_id")
+	assert.Nil(t, err)
+	assert.Equal(t, "4f2c7b1e", requestID)
+
+	// The request ID is only sent along with the reads of its context
+	requestID, err = client.ReadSecret("secret/data/headers", "request_id")
+	assert.Nil(t, err)
+	assert.Equal(t, "", requestID)
+}
+
+func TestCachedClientRequestID(t *testing.T) {
+	client, _ := vaultClient(logger, vaultCfg)
+	client.engine, _ = newEngine("kv2")
+	cached := newCachedClient(client, vaultBackendName, 0, 0)
+	requestID, err := cached.ReadSecretWithContext(WithRequestID(context.Background(), "4f2c7b1e"), "secret/data/headers", "request_id")
+	assert.Nil(t, err)
+	assert.Equal(t, "4f2c7b1e", requestID)
+}
@@ -0,0 +1,89 @@
+package backend
+
+import (
+	"context"
+	"math/rand"
+	"net/url"
+	"regexp"
+	"strconv"
+	"time"
+)
+
+const (
+	vaultReadOperationName = "read"
+
+	retryOutcomeSuccess = "success"
+	retryOutcomeFailure = "failure"
+)
+
+// vaultStatusCodeRegexp matches the status code in the errors returned by the Vault api client
+var vaultStatusCodeRegexp = regexp.MustCompile(`Code: (\d+)\.`)
+
+// vaultStatusCode returns the HTTP status code Vault answered with, or 0 if the error doesn't come from a Vault response
+func vaultStatusCode(err error) int {
+	if err == nil {
+		return 0
+	}
+	match := vaultStatusCodeRegexp.FindStringSubmatch(err.Error())
+	if match == nil {
+		return 0
+	}
+	code, _ := strconv.Atoi(match[1])
+	return code
+}
+
+// isRetryable returns true for connection errors and 5xx responses. Client errors (4xx) are never retried
+func isRetryable(err error) bool {
+	if err == nil {
+		return false
+	}
+	if _, ok := err.(*url.Error); ok {
+		return true
+	}
+	return vaultStatusCode(err) >= 500
+}
+
+// retryBackoff returns the time to wait before the given retry attempt (starting at 0). It grows
+// exponentially up to maxBackoff, and a random jitter of up to half of it is removed to avoid
+// every replica retrying at the same time
+func retryBackoff(attempt int, backoff time.Duration, maxBackoff time.Duration) time.Duration {
+	if backoff <= 0 {
+		return 0
+	}
+	delay := backoff
+	for i := 0; i < attempt && (maxBackoff <= 0 || delay < maxBackoff); i++ {
+		delay *= 2
+	}
+	if maxBackoff > 0 && delay > maxBackoff {
+		delay = maxBackoff
+	}
+	half := int64(delay / 2)
+	return time.Duration(half + rand.Int63n(half+1))
+}
+
+// withRetry runs fn retrying it with exponential backoff while it fails with a retryable error,
+// up to maxRetries times. Waiting between attempts is interrupted as soon as ctx is done
+func (c *client) withRetry(ctx context.Context, operation string, fn func() error) error {
+	err := fn()
+	retries := 0
+	for ; retries < c.maxRetries && isRetryable(err); retries++ {
+		wait := retryBackoff(retries, c.retryBackoff, c.retryMaxBackoff)
+		c.logger.Info("vault request failed, retrying", "vault_operation", operation, "retry", retries+1, "backoff", wait.String(), "error", err.Error())
+		select {
+		case <-time.After(wait):
+		case <-ctx.Done():
+			c.metrics.updateVaultRetriedRequestsTotalMetric(operation, retryOutcomeFailure)
+			return err
+		}
+		c.metrics.updateVaultRequestRetriesTotalMetric(operation)
+		err = fn()
+	}
+	if retries > 0 {
+		outcome := retryOutcomeSuccess
+		if err != nil {
+			outcome = retryOutcomeFailure
+		}
+		c.metrics.updateVaultRetriedRequestsTotalMetric(operation, outcome)
+	}
+	return err
+}
@@ -0,0 +1,116 @@
+package backend
+
+import (
+	"context"
+	e "errors"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIsRetryable(t *testing.T) {
+	assert.False(t, isRetryable(nil))
+	assert.False(t, isRetryable(e.New("foo")))
+	assert.True(t, isRetryable(&url.Error{Op: "Get", URL: "http://127.0.0.1:8200", Err: e.New("connection refused")}))
+	assert.True(t, isRetryable(e.New("Error making API request.\n\nURL: GET http://127.0.0.1:8200/v1/secret/data/foo\nCode: 503. Errors:\n\n* Vault is sealed")))
+	assert.False(t, isRetryable(e.New("Error making API request.\n\nURL: GET http://127.0.0.1:8200/v1/secret/data/foo\nCode: 403. Errors:\n\n* permission denied")))
+}
+
+func TestRetryBackoff(t *testing.T) {
+	backoff := 100 * time.Millisecond
+	maxBackoff := 1 * time.Second
+	for i := 0; i < 100; i++ {
+		wait := retryBackoff(0, backoff, maxBackoff)
+		assert.True(t, wait >= backoff/2 && wait <= backoff)
+		wait = retryBackoff(2, backoff, maxBackoff)
+		assert.True(t, wait >= 2*backoff && wait <= 4*backoff)
+		wait = retryBackoff(10, backoff, maxBackoff)
+		assert.True(t, wait >= maxBackoff/2 && wait <= maxBackoff)
+	}
+	assert.Equal(t, time.Duration(0), retryBackoff(3, 0, maxBackoff))
+}
+
+func TestReadSecretRetry(t *testing.T) {
+	client, _ := vaultClient(logger, vaultCfg)
+	client.engine, _ = newEngine("kv2")
+	client.maxRetries = 2
+	client.retryBackoff = time.Millisecond
+
+	mutex.Lock()
+	defer mutex.Unlock()
+	testCfg.secretReadFailures = 2
+	testCfg.secretReadStatusCode = http.StatusServiceUnavailable
+
+	client.metrics.requestRetriesTotal.Reset()
+	client.metrics.retriedRequestsTotal.Reset()
+	secretValue, err := client.ReadSecret("/secret/data/flaky", "foo")
+	metricRequestRetriesTotal, _ := client.metrics.requestRetriesTotal.GetMetricWithLabelValues(vaultCfg.VaultURL, vaultCfg.VaultEngine, vaultFakeVersion, vaultFakeClusterID, vaultFakeClusterName, vaultCfg.VaultNamespace, vaultReadOperationName)
+	metricRetriedRequestsTotal, _ := client.metrics.retriedRequestsTotal.GetMetricWithLabelValues(vaultCfg.VaultURL, vaultCfg.VaultEngine, vaultFakeVersion, vaultFakeClusterID, vaultFakeClusterName, vaultCfg.VaultNamespace, vaultReadOperationName, retryOutcomeSuccess)
+
+	assert.Nil(t, err)
+	assert.Equal(t, "bar", secretValue)
+	assert.Equal(t, 2.0, testutil.ToFloat64(metricRequestRetriesTotal))
+	assert.Equal(t, 1.0, testutil.ToFloat64(metricRetriedRequestsTotal))
+}
+
+func TestReadSecretRetryExhausted(t *testing.T) {
+	client, _ := vaultClient(logger, vaultCfg)
+	client.engine, _ = newEngine("kv2")
+	client.maxRetries = 1
+	client.retryBackoff = time.Millisecond
+
+	mutex.Lock()
+	defer mutex.Unlock()
+	testCfg.secretReadFailures = 2
+	testCfg.secretReadStatusCode = http.StatusInternalServerError
+
+	client.metrics.retriedRequestsTotal.Reset()
+	_, err := client.ReadSecret("/secret/data/flaky", "foo")
+	metricRetriedRequestsTotal, _ := client.metrics.retriedRequestsTotal.GetMetricWithLabelValues(vaultCfg.VaultURL, vaultCfg.VaultEngine, vaultFakeVersion, vaultFakeClusterID, vaultFakeClusterName, vaultCfg.VaultNamespace, vaultReadOperationName, retryOutcomeFailure)
+
+	assert.NotNil(t, err)
+	assert.Equal(t, 1.0, testutil.ToFloat64(metricRetriedRequestsTotal))
+	testCfg.secretReadFailures = 0
+}
+
+func TestReadSecretNoRetryOnClientError(t *testing.T) {
+	client, _ := vaultClient(logger, vaultCfg)
+	client.engine, _ = newEngine("kv2")
+	client.maxRetries = 2
+	client.retryBackoff = time.Millisecond
+
+	mutex.Lock()
+	defer mutex.Unlock()
+	testCfg.secretReadFailures = 1
+	testCfg.secretReadStatusCode = http.StatusForbidden
+
+	client.metrics.requestRetriesTotal.Reset()
+	_, err := client.ReadSecret("/secret/data/flaky", "foo")
+	metricRequestRetriesTotal, _ := client.metrics.requestRetriesTotal.GetMetricWithLabelValues(vaultCfg.VaultURL, vaultCfg.VaultEngine, vaultFakeVersion, vaultFakeClusterID, vaultFakeClusterName, vaultCfg.VaultNamespace, vaultReadOperationName)
+
+	assert.NotNil(t, err)
+	assert.Equal(t, 0.0, testutil.ToFloat64(metricRequestRetriesTotal))
+}
+
+func TestWithRetryContextDone(t *testing.T) {
+	client, _ := vaultClient(logger, vaultCfg)
+	client.maxRetries = 5
+	client.retryBackoff = time.Hour
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	calls := 0
+	start := time.Now()
+	err := client.withRetry(ctx, vaultReadOperationName, func() error {
+		calls++
+		return &url.Error{Op: "Get", URL: vaultCfg.VaultURL, Err: e.New("connection refused")}
+	})
+
+	assert.NotNil(t, err)
+	assert.Equal(t, 1, calls)
+	assert.True(t, time.Since(start) < time.Second)
+}
@@ -0,0 +1,226 @@
+package backend
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/hashicorp/vault/api"
+	"github.com/tuenti/secrets-manager/errors"
+)
+
+// roleToken is a child token created with a Vault token role, replaced once refreshAt is reached so it's never
+// used close to its expiration. A zero refreshAt never expires
+type roleToken struct {
+	token     string
+	scope     vaultRole
+	refreshAt time.Time
+}
+
+type vaultRoleKey struct{}
+
+// vaultRole is the token role reads are scoped to, and the owner its child tokens are cached for
+type vaultRole struct {
+	role  string
+	owner string
+}
+
+// WithVaultRole returns a copy of ctx making Vault reads made with it use a child token created with the given
+// token role (auth/token/create/:role_name) instead of the controller token. Child tokens are cached per owner,
+// e.g. a SecretDefinition, so owners configured with the same role never share a token
+func WithVaultRole(ctx context.Context, role string, owner string) context.Context {
+	return context.WithValue(ctx, vaultRoleKey{}, vaultRole{role: role, owner: owner})
+}
+
+// VaultRoleFromContext returns the token role set in ctx with WithVaultRole, if any
+func VaultRoleFromContext(ctx context.Context) string {
+	scope, _ := ctx.Value(vaultRoleKey{}).(vaultRole)
+	return scope.role
+}
+
+type clientTokenKey struct{}
+
+func withClientToken(ctx context.Context, token string) context.Context {
+	return context.WithValue(ctx, clientTokenKey{}, token)
+}
+
+func clientTokenFromContext(ctx context.Context) string {
+	token, _ := ctx.Value(clientTokenKey{}).(string)
+	return token
+}
+
+// roleTokenKey identifies the cached child token of an owner and role
+func roleTokenKey(scope vaultRole) string {
+	return scope.owner + "|" + scope.role
+}
+
+// roleTokenCreation is a child token being created, ready when done is closed. Reads needing it while it's in
+// flight wait for it instead of creating another one
+type roleTokenCreation struct {
+	done  chan struct{}
+	token roleToken
+	err   error
+}
+
+// VaultRoleReleaser is implemented by backends caching credentials per owner, e.g. child tokens of a
+// SecretDefinition read with a Vault token role
+type VaultRoleReleaser interface {
+	// ReleaseVaultRole revokes and forgets the credentials cached for owner, e.g. once its SecretDefinition is deleted
+	ReleaseVaultRole(ctx context.Context, owner string)
+}
+
+// scopedToken returns the child token the reads made with ctx must use, creating it when it isn't cached yet or
+// is close to expire. An empty token means the client token is used. Tokens are created without holding the
+// mutex, so reads with other roles, or cached tokens, never wait for Vault
+func (c *client) scopedToken(ctx context.Context) (string, error) {
+	scope, ok := ctx.Value(vaultRoleKey{}).(vaultRole)
+	if !ok || scope.role == "" {
+		return "", nil
+	}
+	key := roleTokenKey(scope)
+
+	c.roleTokensMutex.Lock()
+	if t, ok := c.roleTokens[key]; ok && (t.refreshAt.IsZero() || time.Now().Before(t.refreshAt)) {
+		c.roleTokensMutex.Unlock()
+		return t.token, nil
+	}
+	creation, inFlight := c.roleTokenCreations[key]
+	if !inFlight {
+		creation = &roleTokenCreation{done: make(chan struct{})}
+		if c.roleTokenCreations == nil {
+			c.roleTokenCreations = make(map[string]*roleTokenCreation)
+		}
+		c.roleTokenCreations[key] = creation
+	}
+	c.roleTokensMutex.Unlock()
+
+	if inFlight {
+		select {
+		case <-creation.done:
+			return creation.token.token, creation.err
+		case <-ctx.Done():
+			return "", &errors.VaultRoleTokenError{ErrType: errors.VaultRoleTokenErrorType, Role: scope.role, Err: ctx.Err()}
+		}
+	}
+
+	creation.token, creation.err = c.createRoleToken(ctx, scope)
+	c.roleTokensMutex.Lock()
+	delete(c.roleTokenCreations, key)
+	replaced, hadToken := c.roleTokens[key]
+	if creation.err == nil {
+		if c.roleTokens == nil {
+			c.roleTokens = make(map[string]roleToken)
+		}
+		c.roleTokens[key] = creation.token
+	}
+	c.roleTokensMutex.Unlock()
+	close(creation.done)
+
+	if creation.err == nil && hadToken {
+		c.revokeRoleToken(ctx, replaced.token)
+	}
+	return creation.token.token, creation.err
+}
+
+// createRoleToken creates a child token with the role of scope, giving up when ctx is done or the configured
+// request timeout elapses
+func (c *client) createRoleToken(ctx context.Context, scope vaultRole) (roleToken, error) {
+	if c.requestTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.requestTimeout)
+		defer cancel()
+	}
+	path := "auth/token/create/" + scope.role
+	secret, err := c.writeWithContext(ctx, path)
+	if err == nil && (secret == nil || secret.Auth == nil || secret.Auth.ClientToken == "") {
+		err = &errors.BackendSecretNotFoundError{ErrType: errors.BackendSecretNotFoundErrorType, Path: path}
+	}
+	if err != nil {
+		errorType := errors.UnknownErrorType
+		if ctx.Err() == context.DeadlineExceeded {
+			errorType = errors.VaultTimeoutErrorType
+		} else if vaultStatusCode(err) == http.StatusForbidden {
+			errorType = errors.VaultForbiddenErrorType
+		}
+		c.metrics.updateVaultRoleTokenErrorsTotalMetric(scope.role, errorType)
+		return roleToken{}, &errors.VaultRoleTokenError{ErrType: errors.VaultRoleTokenErrorType, Role: scope.role, Err: err}
+	}
+
+	t := roleToken{token: secret.Auth.ClientToken, scope: scope}
+	if secret.Auth.LeaseDuration > 0 {
+		// Replaced once two thirds of its TTL elapsed, so in-flight reads never use an expired token
+		t.refreshAt = time.Now().Add(time.Duration(secret.Auth.LeaseDuration) * time.Second * 2 / 3)
+	}
+	c.metrics.updateVaultRoleTokensCreatedTotalMetric(scope.role)
+	c.logger.V(1).Info("created vault child token", "vault_role", scope.role, "owner", scope.owner, "vault_token_ttl", secret.Auth.LeaseDuration)
+	return t, nil
+}
+
+// writeWithContext sends an empty write to path, cancelling the in-flight request along with ctx
+func (c *client) writeWithContext(ctx context.Context, path string) (*api.Secret, error) {
+	resp, err := c.vclient.RawRequestWithContext(ctx, c.vclient.NewRequest("POST", "/v1/"+path))
+	if resp != nil {
+		defer resp.Body.Close()
+	}
+	if err != nil {
+		return nil, err
+	}
+	return api.ParseSecret(resp.Body)
+}
+
+// revokeRoleToken revokes a child token no longer used. It is revoked with the token itself, so no policy
+// on auth/token/revoke is needed. Failures are only logged, the token expires anyway
+func (c *client) revokeRoleToken(ctx context.Context, token string) {
+	if c.requestTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.requestTimeout)
+		defer cancel()
+	}
+	r := c.vclient.NewRequest("POST", "/v1/auth/token/revoke-self")
+	r.ClientToken = token
+	resp, err := c.vclient.RawRequestWithContext(ctx, r)
+	if resp != nil {
+		resp.Body.Close()
+	}
+	if err != nil {
+		c.logger.V(1).Info("unable to revoke vault child token", "error", err.Error())
+	}
+}
+
+// dropScopedToken forgets the child token of ctx, e.g. once Vault denied it because it was revoked along with
+// the client token, so the next read creates a new one
+func (c *client) dropScopedToken(ctx context.Context) {
+	scope, ok := ctx.Value(vaultRoleKey{}).(vaultRole)
+	if !ok || scope.role == "" {
+		return
+	}
+	key := roleTokenKey(scope)
+	c.roleTokensMutex.Lock()
+	t, ok := c.roleTokens[key]
+	delete(c.roleTokens, key)
+	c.roleTokensMutex.Unlock()
+	if ok {
+		c.revokeRoleToken(ctx, t.token)
+	}
+}
+
+// ReleaseVaultRole revokes and forgets the child tokens created for owner with any role
+func (c *client) ReleaseVaultRole(ctx context.Context, owner string) {
+	c.revokeRoleTokens(ctx, func(scope vaultRole) bool { return scope.owner == owner })
+}
+
+// revokeRoleTokens revokes and forgets the cached child tokens whose scope matches
+func (c *client) revokeRoleTokens(ctx context.Context, matches func(vaultRole) bool) {
+	var tokens []string
+	c.roleTokensMutex.Lock()
+	for key, t := range c.roleTokens {
+		if matches(t.scope) {
+			tokens = append(tokens, t.token)
+			delete(c.roleTokens, key)
+		}
+	}
+	c.roleTokensMutex.Unlock()
+	for _, token := range tokens {
+		c.revokeRoleToken(ctx, token)
+	}
+}
@@ -0,0 +1,184 @@
+package backend
+
+import (
+	"context"
+	e "errors"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/tuenti/secrets-manager/errors"
+)
+
+// v1AuthTokenCreateRole creates a new child token on every call, the denied role can't be used
+func v1AuthTokenCreateRole(w http.ResponseWriter, r *http.Request) {
+	role := mux.Vars(r)["role"]
+	if role == "denied" {
+		w.WriteHeader(http.StatusForbidden)
+		fmt.Fprint(w, `{"errors": ["permission denied"]}`)
+		return
+	}
+	testCfg.roleTokensCreated++
+	w.Header().Set("Content-Type", "application/json")
+	fmt.Fprintf(w, `{"auth": {"client_token": "child-%s-%d", "lease_duration": 3600, "renewable": true}}`, role, testCfg.roleTokensCreated)
+}
+
+// v1AuthTokenRevokeSelf records the tokens revoked
+func v1AuthTokenRevokeSelf(w http.ResponseWriter, r *http.Request) {
+	testCfg.revokedTokens = append(testCfg.revokedTokens, r.Header.Get("X-Vault-Token"))
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// v1SecretScopedKv2 returns the token the secret was read with
+func v1SecretScopedKv2(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	fmt.Fprintf(w, `{"data": {"data": {"token": %q}, "metadata": {"version": 1}}}`, r.Header.Get("X-Vault-Token"))
+}
+
+func TestReadSecretWithVaultRole(t *testing.T) {
+	mutex.Lock()
+	defer mutex.Unlock()
+	testCfg.roleTokensCreated = 0
+
+	client, _ := vaultClient(logger, vaultCfg)
+	client.engine, _ = newEngine("kv2")
+
+	token, err := client.ReadSecretWithContext(context.Background(), "secret/data/scoped", "token")
+	assert.Nil(t, err)
+	assert.Equal(t, client.vclient.Token(), token)
+
+	teamA := WithVaultRole(context.Background(), "team-a", "team-a/db")
+	token, err = client.ReadSecretWithContext(teamA, "secret/data/scoped", "token")
+	assert.Nil(t, err)
+	assert.Equal(t, "child-team-a-1", token)
+	token, _ = client.ReadSecretWithContext(teamA, "secret/data/scoped", "token")
+	assert.Equal(t, "child-team-a-1", token)
+
+	// Owners sharing a role get their own token
+	other := WithVaultRole(context.Background(), "team-a", "team-a/cache")
+	token, _ = client.ReadSecretWithContext(other, "secret/data/scoped", "token")
+	assert.Equal(t, "child-team-a-2", token)
+
+	metric, _ := client.metrics.roleTokensCreatedTotal.GetMetricWithLabelValues(vaultCfg.VaultURL, vaultCfg.VaultEngine, vaultFakeVersion, vaultFakeClusterID, vaultFakeClusterName, vaultCfg.VaultNamespace, "team-a")
+	assert.Equal(t, 2.0, testutil.ToFloat64(metric))
+}
+
+func TestReadSecretWithVaultRoleRefresh(t *testing.T) {
+	mutex.Lock()
+	defer mutex.Unlock()
+	testCfg.roleTokensCreated = 0
+
+	client, _ := vaultClient(logger, vaultCfg)
+	client.engine, _ = newEngine("kv2")
+	ctx := WithVaultRole(context.Background(), "team-a", "team-a/db")
+
+	token, _ := client.ReadSecretWithContext(ctx, "secret/data/scoped", "token")
+	assert.Equal(t, "child-team-a-1", token)
+	key := roleTokenKey(vaultRole{role: "team-a", owner: "team-a/db"})
+	assert.True(t, client.roleTokens[key].refreshAt.After(time.Now().Add(39*time.Minute)))
+
+	testCfg.revokedTokens = nil
+	client.roleTokens[key] = roleToken{token: "child-team-a-1", scope: vaultRole{role: "team-a", owner: "team-a/db"}, refreshAt: time.Now().Add(-time.Second)}
+	token, _ = client.ReadSecretWithContext(ctx, "secret/data/scoped", "token")
+	assert.Equal(t, "child-team-a-2", token)
+	// The replaced token is revoked
+	assert.Equal(t, []string{"child-team-a-1"}, testCfg.revokedTokens)
+}
+
+func TestReadSecretWithVaultRoleCreatesTokenOnce(t *testing.T) {
+	mutex.Lock()
+	defer mutex.Unlock()
+	testCfg.roleTokensCreated = 0
+
+	client, _ := vaultClient(logger, vaultCfg)
+	client.engine, _ = newEngine("kv2")
+	ctx := WithVaultRole(context.Background(), "team-a", "team-a/db")
+	key := roleTokenKey(vaultRole{role: "team-a", owner: "team-a/db"})
+
+	// Reads waiting for a token being created share it
+	creation := &roleTokenCreation{done: make(chan struct{})}
+	client.roleTokenCreations = map[string]*roleTokenCreation{key: creation}
+	tokens := make(chan string)
+	for i := 0; i < 2; i++ {
+		go func() {
+			token, _ := client.scopedToken(ctx)
+			tokens <- token
+		}()
+	}
+	creation.token = roleToken{token: "child-team-a-shared"}
+	close(creation.done)
+	assert.Equal(t, "child-team-a-shared", <-tokens)
+	assert.Equal(t, "child-team-a-shared", <-tokens)
+	assert.Equal(t, 0, testCfg.roleTokensCreated)
+
+	// Waiting reads give up along with their context
+	client.roleTokenCreations = map[string]*roleTokenCreation{key: {done: make(chan struct{})}}
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+	_, err := client.scopedToken(cancelled)
+	assert.True(t, errors.IsVaultRoleToken(err))
+	assert.True(t, e.Is(err, context.Canceled))
+
+	// Creating a token gives up when the request times out
+	delete(client.roleTokenCreations, key)
+	timeout, cancel := context.WithTimeout(ctx, 0)
+	defer cancel()
+	_, err = client.scopedToken(timeout)
+	assert.True(t, errors.IsVaultRoleToken(err))
+	assert.Equal(t, 0, testCfg.roleTokensCreated)
+	assert.Empty(t, client.roleTokenCreations)
+}
+
+func TestReleaseVaultRole(t *testing.T) {
+	mutex.Lock()
+	defer mutex.Unlock()
+	testCfg.roleTokensCreated = 0
+	testCfg.revokedTokens = nil
+
+	client, _ := vaultClient(logger, vaultCfg)
+	client.engine, _ = newEngine("kv2")
+	client.ReadSecretWithContext(WithVaultRole(context.Background(), "team-a", "team-a/db"), "secret/data/scoped", "token")
+	client.ReadSecretWithContext(WithVaultRole(context.Background(), "team-a", "team-a/cache"), "secret/data/scoped", "token")
+
+	var releaser VaultRoleReleaser = newCachedClient(client, "vault", time.Minute, 0)
+	releaser.ReleaseVaultRole(context.Background(), "team-a/db")
+	assert.Equal(t, []string{"child-team-a-1"}, testCfg.revokedTokens)
+	assert.Len(t, client.roleTokens, 1)
+
+	// Tokens left are revoked on close
+	client.Close(context.Background())
+	assert.Equal(t, []string{"child-team-a-1", "child-team-a-2"}, testCfg.revokedTokens)
+	assert.Empty(t, client.roleTokens)
+}
+
+func TestReadSecretWithVaultRoleDenied(t *testing.T) {
+	client, _ := vaultClient(logger, vaultCfg)
+	client.engine, _ = newEngine("kv2")
+
+	_, err := client.ReadSecretWithContext(WithVaultRole(context.Background(), "denied", "team-b/db"), "secret/data/scoped", "token")
+	assert.True(t, errors.IsVaultRoleToken(err))
+
+	metric, _ := client.metrics.roleTokenErrorsTotal.GetMetricWithLabelValues(vaultCfg.VaultURL, vaultCfg.VaultEngine, vaultFakeVersion, vaultFakeClusterID, vaultFakeClusterName, vaultCfg.VaultNamespace, "denied", errors.VaultForbiddenErrorType)
+	assert.Equal(t, 1.0, testutil.ToFloat64(metric))
+}
+
+func TestDropScopedToken(t *testing.T) {
+	mutex.Lock()
+	defer mutex.Unlock()
+	testCfg.revokedTokens = nil
+
+	client, _ := vaultClient(logger, vaultCfg)
+	client.roleTokens = map[string]roleToken{}
+	ctx := WithVaultRole(context.Background(), "team-a", "team-a/db")
+	client.roleTokens[roleTokenKey(vaultRole{role: "team-a", owner: "team-a/db"})] = roleToken{token: "child"}
+
+	client.dropScopedToken(context.Background())
+	assert.Len(t, client.roleTokens, 1)
+	client.dropScopedToken(ctx)
+	assert.Len(t, client.roleTokens, 0)
+	assert.Equal(t, []string{"child"}, testCfg.revokedTokens)
+}
@@ -0,0 +1,57 @@
+package backend
+
+import (
+	"context"
+	"sync"
+
+	"github.com/hashicorp/vault/api"
+)
+
+type sharedReadsKey struct{}
+
+// sharedRead is the result of reading a secret path once, ready when done is closed
+type sharedRead struct {
+	done   chan struct{}
+	secret *api.Secret
+	data   map[string]interface{}
+	err    error
+}
+
+// sharedReads are the secret paths read with a context, so reading several keys of the same path sends a
+// single request to Vault
+type sharedReads struct {
+	mutex sync.Mutex
+	reads map[string]*sharedRead
+}
+
+// WithSharedReads returns a copy of ctx making the Vault reads made with it, concurrently or not, share the
+// response of every path read, e.g. the reads of a reconciliation. Values are never kept once ctx is gone, so
+// later reads still get the latest ones
+func WithSharedReads(ctx context.Context) context.Context {
+	return context.WithValue(ctx, sharedReadsKey{}, &sharedReads{reads: make(map[string]*sharedRead)})
+}
+
+func sharedReadsFromContext(ctx context.Context) *sharedReads {
+	reads, _ := ctx.Value(sharedReadsKey{}).(*sharedReads)
+	return reads
+}
+
+// do calls read the first time key is asked for, and returns its result to every caller asking for it, waiting
+// for it when it's still in flight. Failed reads are shared too, so a failing path isn't read again for every key
+func (s *sharedReads) do(key string, read func() (*api.Secret, map[string]interface{}, error)) (*api.Secret, map[string]interface{}, error) {
+	s.mutex.Lock()
+	r, ok := s.reads[key]
+	if !ok {
+		r = &sharedRead{done: make(chan struct{})}
+		s.reads[key] = r
+	}
+	s.mutex.Unlock()
+
+	if ok {
+		<-r.done
+		return r.secret, r.data, r.err
+	}
+	defer close(r.done)
+	r.secret, r.data, r.err = read()
+	return r.secret, r.data, r.err
+}
@@ -0,0 +1,132 @@
+package backend
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/tuenti/secrets-manager/errors"
+)
+
+const sharedPathKeys = 10
+
+// v1SecretSharedKv2 answers a secret with sharedPathKeys keys, key0 to key9, counting the reads in
+// testCfg.sharedPathReads. Reads are slowed down so concurrent reads overlap
+func v1SecretSharedKv2(w http.ResponseWriter, r *http.Request) {
+	atomic.AddInt32(&testCfg.sharedPathReads, 1)
+	time.Sleep(10 * time.Millisecond)
+	data := make(map[string]interface{}, sharedPathKeys)
+	for i := 0; i < sharedPathKeys; i++ {
+		data[fmt.Sprintf("key%d", i)] = fmt.Sprintf("value%d", i)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"data": map[string]interface{}{
+			"data":     data,
+			"metadata": map[string]interface{}{"version": 1},
+		},
+	})
+}
+
+func TestReadSecretSharedReads(t *testing.T) {
+	client, _ := vaultClient(logger, vaultCfg)
+	client.engine, _ = newEngine("kv2")
+	atomic.StoreInt32(&testCfg.sharedPathReads, 0)
+
+	ctx := WithSharedReads(context.Background())
+	for i := 0; i < sharedPathKeys; i++ {
+		value, err := client.ReadSecretWithContext(ctx, "secret/data/shared", fmt.Sprintf("key%d", i))
+		assert.Nil(t, err)
+		assert.Equal(t, fmt.Sprintf("value%d", i), value)
+	}
+	_, err := client.ReadSecretWithContext(ctx, "secret/data/shared", "missing")
+	assert.True(t, errors.IsBackendSecretNotFound(err))
+	assert.EqualError(t, err, "[BackendSecretNotFoundError] secret key missing not found at secret/data/shared")
+	assert.Equal(t, int32(1), atomic.LoadInt32(&testCfg.sharedPathReads))
+
+	// Reads with another context, or without one, get the latest value
+	_, err = client.ReadSecretWithContext(WithSharedReads(context.Background()), "secret/data/shared", "key0")
+	assert.Nil(t, err)
+	_, err = client.ReadSecret("secret/data/shared", "key0")
+	assert.Nil(t, err)
+	assert.Equal(t, int32(3), atomic.LoadInt32(&testCfg.sharedPathReads))
+}
+
+func TestReadSecretAllKeysSharedReads(t *testing.T) {
+	client, _ := vaultClient(logger, vaultCfg)
+	client.engine, _ = newEngine("kv2")
+	atomic.StoreInt32(&testCfg.sharedPathReads, 0)
+
+	// Datasources reading single keys, all the keys or a template of the same path share the read
+	ctx := WithSharedReads(context.Background())
+	data, err := client.ReadSecretAllKeysWithContext(ctx, "secret/data/shared")
+	assert.Nil(t, err)
+	assert.Len(t, data, sharedPathKeys)
+	value, err := client.ReadSecretWithContext(ctx, "secret/data/shared", "key1")
+	assert.Nil(t, err)
+	assert.Equal(t, "value1", value)
+	value, err = RenderTemplateWithContext(ctx, client, "secret/data/shared", "{{.key0}}-{{.key2}}")
+	assert.Nil(t, err)
+	assert.Equal(t, "value0-value2", value)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&testCfg.sharedPathReads))
+}
+
+func TestReadSecretSharedReadsConcurrent(t *testing.T) {
+	client, _ := vaultClient(logger, vaultCfg)
+	client.engine, _ = newEngine("kv2")
+	atomic.StoreInt32(&testCfg.sharedPathReads, 0)
+
+	ctx := WithSharedReads(context.Background())
+	var wg sync.WaitGroup
+	values := make([]string, sharedPathKeys*5)
+	errs := make([]error, len(values))
+	for i := range values {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			values[i], errs[i] = client.ReadSecretWithContext(ctx, "secret/data/shared", fmt.Sprintf("key%d", i%sharedPathKeys))
+		}(i)
+	}
+	wg.Wait()
+
+	for i := range values {
+		assert.Nil(t, errs[i])
+		assert.Equal(t, fmt.Sprintf("value%d", i%sharedPathKeys), values[i])
+	}
+	assert.Equal(t, int32(1), atomic.LoadInt32(&testCfg.sharedPathReads))
+}
+
+// benchmarkReadKeys reads every key of the shared path, as a SecretDefinition mapping all of them does
+func benchmarkReadKeys(b *testing.B, shared bool) {
+	client, _ := vaultClient(logger, vaultCfg)
+	client.engine, _ = newEngine("kv2")
+	atomic.StoreInt32(&testCfg.sharedPathReads, 0)
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		ctx := context.Background()
+		if shared {
+			ctx = WithSharedReads(ctx)
+		}
+		for i := 0; i < sharedPathKeys; i++ {
+			if _, err := client.ReadSecretWithContext(ctx, "secret/data/shared", fmt.Sprintf("key%d", i)); err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+	b.StopTimer()
+	b.Logf("%d vault reads for %d secret definitions", atomic.LoadInt32(&testCfg.sharedPathReads), b.N)
+}
+
+func BenchmarkReadKeys(b *testing.B) {
+	benchmarkReadKeys(b, false)
+}
+
+func BenchmarkReadKeysSharedReads(b *testing.B) {
+	benchmarkReadKeys(b, true)
+}
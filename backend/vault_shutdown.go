@@ -0,0 +1,51 @@
+package backend
+
+import (
+	"context"
+)
+
+// Closer is implemented by backends holding resources that must be released on shutdown
+type Closer interface {
+	Close(ctx context.Context) error
+}
+
+// startBackgroundRoutines starts the token renewer and the health poller, they are stopped when ctx is done or
+// the client is closed
+func (c *client) startBackgroundRoutines(ctx context.Context) {
+	ctx, c.cancel = context.WithCancel(ctx)
+	c.startTokenRenewer(ctx)
+	c.startHealthPoller(ctx)
+}
+
+// Close stops the background go routines, waits for in-flight requests to finish and closes idle connections.
+// It gives up waiting when ctx is done, and it must only be called once no more requests are started
+func (c *client) Close(ctx context.Context) error {
+	if c.cancel != nil {
+		c.cancel()
+	}
+
+	done := make(chan struct{})
+	go func() {
+		c.routines.Wait()
+		c.inflight.Wait()
+		close(done)
+	}()
+
+	var err error
+	select {
+	case <-done:
+		// Child tokens are only revoked once no read can use them anymore
+		c.revokeRoleTokens(ctx, func(vaultRole) bool { return true })
+		c.logger.Info("vault client closed")
+	case <-ctx.Done():
+		err = ctx.Err()
+		c.logger.Error(err, "timed out waiting for in-flight vault requests")
+	}
+	if c.transport != nil {
+		c.transport.CloseIdleConnections()
+	}
+	if c.metricsRegisterer != nil {
+		unregisterCollectors(c.metricsRegisterer, c.metrics.collectors())
+	}
+	return err
+}
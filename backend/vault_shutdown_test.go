@@ -0,0 +1,41 @@
+package backend
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestVaultCloseStopsBackgroundRoutines(t *testing.T) {
+	client, _ := vaultClient(logger, vaultCfg)
+	client.healthPollingPeriod = time.Second
+	client.startBackgroundRoutines(context.Background())
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	assert.Nil(t, client.Close(ctx))
+	assert.NotNil(t, client.ctx.Err())
+}
+
+func TestVaultCloseWaitsInFlightReads(t *testing.T) {
+	client, _ := vaultClient(logger, vaultCfg)
+	client.startBackgroundRoutines(context.Background())
+
+	done := make(chan error)
+	go func() {
+		_, err := client.ReadSecret("secret/data/slow", "foo")
+		done <- err
+	}()
+	time.Sleep(50 * time.Millisecond)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	assert.Equal(t, context.DeadlineExceeded, client.Close(ctx))
+
+	ctx, cancel = context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	assert.Nil(t, client.Close(ctx))
+	assert.Nil(t, <-done)
+}
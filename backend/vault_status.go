@@ -0,0 +1,55 @@
+package backend
+
+import (
+	"time"
+)
+
+// BackendStatus is a snapshot of the backend session, e.g. to show it in a dashboard
+type BackendStatus struct {
+	// TokenTTL is the number of seconds left until the token expires, 0 if it never expires or already expired
+	TokenTTL        int64     `json:"tokenTTL"`
+	TokenRenewable  bool      `json:"tokenRenewable"`
+	TokenType       string    `json:"tokenType,omitempty"`
+	LastRenewal     time.Time `json:"lastRenewal"`
+	LastReadError   string    `json:"lastReadError,omitempty"`
+	LastReadErrorAt time.Time `json:"lastReadErrorAt"`
+	Sealed          bool      `json:"sealed"`
+	ClusterName     string    `json:"clusterName,omitempty"`
+	Version         string    `json:"version,omitempty"`
+	Address         string    `json:"address,omitempty"`
+}
+
+// StatusReporter is implemented by backends able to report the status of their session
+type StatusReporter interface {
+	Status() BackendStatus
+}
+
+func (h *vaultHealth) status(now time.Time) BackendStatus {
+	h.mutex.RLock()
+	defer h.mutex.RUnlock()
+	status := BackendStatus{
+		TokenRenewable:  h.tokenRenewable,
+		TokenType:       h.tokenType,
+		LastRenewal:     h.lastRenewal,
+		LastReadError:   h.lastReadError,
+		LastReadErrorAt: h.lastReadErrorAt,
+		Sealed:          h.sealed,
+		ClusterName:     h.clusterName,
+		Version:         h.version,
+	}
+	if !h.tokenExpiration.IsZero() {
+		status.TokenTTL = int64(h.tokenExpiration.Sub(now).Round(time.Second) / time.Second)
+		if status.TokenTTL < 0 {
+			status.TokenTTL = 0
+		}
+	}
+	return status
+}
+
+// Status returns the current token, renewal and cluster status. It only reads the state recorded by
+// the token renewer, the health poller and secret reads, so it's cheap and never calls Vault
+func (c *client) Status() BackendStatus {
+	status := c.health.status(time.Now())
+	status.Address = c.ActiveAddress()
+	return status
+}
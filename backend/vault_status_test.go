@@ -0,0 +1,54 @@
+package backend
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStatus(t *testing.T) {
+	client, _ := vaultClient(logger, vaultCfg)
+	mutex.Lock()
+	defer mutex.Unlock()
+	testCfg.tokenRenewable = true
+	testCfg.tokenTTL = 600
+	client.maxTokenTTL = 6000
+
+	assert.Nil(t, client.renewalLoop())
+	client.pollHealth()
+	client.ReadSecret("secret/data/forbidden", "foo")
+
+	status := client.Status()
+	assert.True(t, status.TokenRenewable)
+	assert.Equal(t, serviceTokenType, status.TokenType)
+	// The fake Vault renews tokens for 1000 seconds
+	assert.InDelta(t, 1000, status.TokenTTL, 1)
+	assert.WithinDuration(t, time.Now(), status.LastRenewal, time.Second)
+	assert.Contains(t, status.LastReadError, "secret/data/forbidden")
+	assert.Equal(t, vaultFakeClusterName, status.ClusterName)
+	assert.Equal(t, vaultFakeVersion, status.Version)
+	assert.Equal(t, vaultCfg.VaultURL, status.Address)
+}
+
+func TestStatusConcurrentUpdates(t *testing.T) {
+	h := &vaultHealth{}
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(2)
+		go func(i int) {
+			defer wg.Done()
+			h.recordTokenTTL(int64(i))
+			h.recordRenewal(time.Now())
+			h.recordReadError(fmt.Errorf("read %d failed", i), time.Now())
+		}(i)
+		go func() {
+			defer wg.Done()
+			h.status(time.Now())
+		}()
+	}
+	wg.Wait()
+	assert.Contains(t, h.status(time.Now()).LastReadError, "failed")
+}
@@ -0,0 +1,82 @@
+package backend
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+	"time"
+)
+
+const (
+	tokenFileReadAttempts = 3
+	tokenFileReadBackoff  = 100 * time.Millisecond
+)
+
+// readTokenFile reads the token written by Vault Agent. The file may be read while it's being
+// rewritten, so an empty content or a modification during the read are retried a few times
+func (c *client) readTokenFile() (string, time.Time, error) {
+	var lastErr error
+	for attempt := 0; attempt < tokenFileReadAttempts; attempt++ {
+		if attempt > 0 {
+			time.Sleep(tokenFileReadBackoff)
+		}
+		before, err := os.Stat(c.tokenFile)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		content, err := ioutil.ReadFile(c.tokenFile)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		after, err := os.Stat(c.tokenFile)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		token := strings.TrimSpace(string(content))
+		if token == "" {
+			lastErr = fmt.Errorf("token file %s is empty", c.tokenFile)
+			continue
+		}
+		if !before.ModTime().Equal(after.ModTime()) || before.Size() != after.Size() {
+			lastErr = fmt.Errorf("token file %s changed while reading it", c.tokenFile)
+			continue
+		}
+		return token, after.ModTime(), nil
+	}
+	return "", time.Time{}, lastErr
+}
+
+// loadTokenFile sets the token stored in the token file as the Vault client token
+func (c *client) loadTokenFile() error {
+	token, modTime, err := c.readTokenFile()
+	if err != nil {
+		return err
+	}
+	c.vclient.SetToken(token)
+	c.tokenFileModTime = modTime
+	return nil
+}
+
+// reloadTokenFile loads the token file again if it was modified since the last time it was loaded.
+// Vault Agent takes care of renewing the token, so secrets-manager never renews it when using a token file
+func (c *client) reloadTokenFile() error {
+	info, err := os.Stat(c.tokenFile)
+	if err != nil {
+		c.logger.Error(err, "unable to stat vault token file", "vault_token_file", c.tokenFile)
+		return err
+	}
+	if info.ModTime().Equal(c.tokenFileModTime) {
+		return nil
+	}
+	if err := c.loadTokenFile(); err != nil {
+		c.logger.Error(err, "unable to reload vault token file", "vault_token_file", c.tokenFile)
+		return err
+	}
+	c.metrics.updateVaultTokenFileReloadsTotalMetric()
+	c.logger.Info("vault token reloaded from file", "vault_token_file", c.tokenFile)
+	return nil
+}
@@ -0,0 +1,78 @@
+package backend
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestVaultClientTokenFile(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "secrets-manager")
+	defer os.RemoveAll(dir)
+	tokenFile := filepath.Join(dir, "token")
+	ioutil.WriteFile(tokenFile, []byte(fakeToken+"\n"), 0600)
+
+	cfg := vaultCfg
+	cfg.VaultTokenFile = tokenFile
+	cfg.VaultRoleID = ""
+	client, err := vaultClient(logger, cfg)
+	assert.Nil(t, err)
+	assert.Equal(t, fakeToken, client.vclient.Token())
+}
+
+func TestVaultClientTokenFileEmpty(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "secrets-manager")
+	defer os.RemoveAll(dir)
+	tokenFile := filepath.Join(dir, "token")
+	ioutil.WriteFile(tokenFile, []byte(""), 0600)
+
+	cfg := vaultCfg
+	cfg.VaultTokenFile = tokenFile
+	_, err := vaultClient(logger, cfg)
+	assert.NotNil(t, err)
+}
+
+func TestReloadTokenFile(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "secrets-manager")
+	defer os.RemoveAll(dir)
+	tokenFile := filepath.Join(dir, "token")
+	ioutil.WriteFile(tokenFile, []byte(fakeToken), 0600)
+
+	cfg := vaultCfg
+	cfg.VaultTokenFile = tokenFile
+	client, _ := vaultClient(logger, cfg)
+	client.metrics.tokenFileReloadsTotal.Reset()
+	metricReloads, _ := client.metrics.tokenFileReloadsTotal.GetMetricWithLabelValues(vaultCfg.VaultURL, vaultCfg.VaultEngine, vaultFakeVersion, vaultFakeClusterID, vaultFakeClusterName, vaultCfg.VaultNamespace)
+
+	// Unchanged file, nothing to reload
+	assert.Nil(t, client.renewalLoop())
+	assert.Equal(t, 0.0, testutil.ToFloat64(metricReloads))
+
+	ioutil.WriteFile(tokenFile, []byte("rotated-token"), 0600)
+	modTime := time.Now().Add(time.Minute)
+	os.Chtimes(tokenFile, modTime, modTime)
+	assert.Nil(t, client.renewalLoop())
+	assert.Equal(t, "rotated-token", client.vclient.Token())
+	assert.Equal(t, 1.0, testutil.ToFloat64(metricReloads))
+}
+
+func TestReloadTokenFileMissing(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "secrets-manager")
+	defer os.RemoveAll(dir)
+	tokenFile := filepath.Join(dir, "token")
+	ioutil.WriteFile(tokenFile, []byte(fakeToken), 0600)
+
+	cfg := vaultCfg
+	cfg.VaultTokenFile = tokenFile
+	client, _ := vaultClient(logger, cfg)
+
+	os.Remove(tokenFile)
+	assert.NotNil(t, client.renewalLoop())
+	assert.Equal(t, fakeToken, client.vclient.Token())
+	assert.Equal(t, 1, client.failedPolls)
+}
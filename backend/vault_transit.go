@@ -0,0 +1,52 @@
+package backend
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/tuenti/secrets-manager/errors"
+)
+
+// vaultTransitKeyNotFound is the error Vault answers with when decrypting with a key that doesn't exist
+const vaultTransitKeyNotFound = "encryption key not found"
+
+// Decrypt decrypts a ciphertext with the given transit key, returning the plaintext base64-decoded.
+// It can only be used with the transit engine, either as the client engine or mounted at transit
+func (c *client) Decrypt(keyName string, ciphertext string) (string, error) {
+	engine := c.engineFor(context.Background(), defaultTransitPath)
+	transit, ok := engine.(transitEngine)
+	if !ok {
+		c.metrics.updateVaultTransitDecryptErrorsTotalMetric(keyName, errors.VaultEngineNotImplementedErrorType)
+		return "", &errors.VaultEngineNotImplementedError{ErrType: errors.VaultEngineNotImplementedErrorType, Engine: engine.getName()}
+	}
+
+	start := time.Now()
+	secret, err := c.logical.Write(transit.decryptPath(keyName), map[string]interface{}{"ciphertext": ciphertext})
+	c.metrics.updateVaultTransitDecryptDurationMetric(keyName, time.Since(start))
+	if err != nil {
+		if strings.Contains(err.Error(), vaultTransitKeyNotFound) || vaultStatusCode(err) == 404 {
+			c.metrics.updateVaultTransitDecryptErrorsTotalMetric(keyName, errors.BackendSecretNotFoundErrorType)
+			return "", &errors.BackendSecretNotFoundError{ErrType: errors.BackendSecretNotFoundErrorType, Path: transit.decryptPath(keyName)}
+		}
+		c.metrics.updateVaultTransitDecryptErrorsTotalMetric(keyName, errors.VaultTransitErrorType)
+		return "", &errors.VaultTransitError{ErrType: errors.VaultTransitErrorType, KeyName: keyName, Err: err}
+	}
+
+	var plaintext string
+	if secret != nil {
+		plaintext, ok = secret.Data["plaintext"].(string)
+	}
+	if !ok {
+		c.metrics.updateVaultTransitDecryptErrorsTotalMetric(keyName, errors.VaultTransitErrorType)
+		return "", &errors.VaultTransitError{ErrType: errors.VaultTransitErrorType, KeyName: keyName, Err: fmt.Errorf("decrypt response does not contain a plaintext")}
+	}
+	data, err := base64.StdEncoding.DecodeString(plaintext)
+	if err != nil {
+		c.metrics.updateVaultTransitDecryptErrorsTotalMetric(keyName, errors.VaultTransitErrorType)
+		return "", &errors.VaultTransitError{ErrType: errors.VaultTransitErrorType, KeyName: keyName, Err: err}
+	}
+	return string(data), nil
+}
@@ -0,0 +1,82 @@
+package backend
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/tuenti/secrets-manager/errors"
+)
+
+const (
+	transitFakeKey        = "my-key"
+	transitFakeCiphertext = "vault:v1:8SDd3WHDOjf7mq69CyCqYjBXAiQQAVZRkFM13ok481zoCmHnSeDX9vyf7w=="
+	transitFakePlaintext  = "my-plaintext"
+)
+
+func v1TransitDecrypt(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		Ciphertext string `json:"ciphertext"`
+	}
+	json.NewDecoder(r.Body).Decode(&body)
+	w.Header().Set("Content-Type", "application/json")
+	if mux.Vars(r)["key"] != transitFakeKey {
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprint(w, `{"errors":["encryption key not found"]}`)
+		return
+	}
+	if body.Ciphertext != transitFakeCiphertext {
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprint(w, `{"errors":["invalid ciphertext: no prefix"]}`)
+		return
+	}
+	fmt.Fprintf(w, `{"data":{"plaintext":"%s"}}`, base64.StdEncoding.EncodeToString([]byte(transitFakePlaintext)))
+}
+
+func TestTransitDecrypt(t *testing.T) {
+	client, _ := vaultClient(logger, vaultCfg)
+	client.engine, _ = newEngine("transit")
+
+	plaintext, err := client.Decrypt(transitFakeKey, transitFakeCiphertext)
+	assert.Nil(t, err)
+	assert.Equal(t, transitFakePlaintext, plaintext)
+}
+
+func TestTransitDecryptKeyNotFound(t *testing.T) {
+	client, _ := vaultClient(logger, vaultCfg)
+	client.engine, _ = newEngine("transit")
+	client.metrics.transitDecryptErrorsTotal.Reset()
+
+	plaintext, err := client.Decrypt("unknown", transitFakeCiphertext)
+	metric, _ := client.metrics.transitDecryptErrorsTotal.GetMetricWithLabelValues(vaultCfg.VaultURL, vaultCfg.VaultEngine, vaultFakeVersion, vaultFakeClusterID, vaultFakeClusterName, vaultCfg.VaultNamespace, "unknown", errors.BackendSecretNotFoundErrorType)
+
+	assert.Empty(t, plaintext)
+	assert.True(t, errors.IsBackendSecretNotFound(err))
+	assert.Equal(t, 1.0, testutil.ToFloat64(metric))
+}
+
+func TestTransitDecryptInvalidCiphertext(t *testing.T) {
+	client, _ := vaultClient(logger, vaultCfg)
+	client.engine, _ = newEngine("transit")
+	client.metrics.transitDecryptErrorsTotal.Reset()
+
+	plaintext, err := client.Decrypt(transitFakeKey, "invalid")
+	metric, _ := client.metrics.transitDecryptErrorsTotal.GetMetricWithLabelValues(vaultCfg.VaultURL, vaultCfg.VaultEngine, vaultFakeVersion, vaultFakeClusterID, vaultFakeClusterName, vaultCfg.VaultNamespace, transitFakeKey, errors.VaultTransitErrorType)
+
+	assert.Empty(t, plaintext)
+	assert.True(t, errors.IsVaultTransit(err))
+	assert.Equal(t, 1.0, testutil.ToFloat64(metric))
+}
+
+func TestTransitDecryptWrongEngine(t *testing.T) {
+	client, _ := vaultClient(logger, vaultCfg)
+	client.engine, _ = newEngine("kv2")
+
+	_, err := client.Decrypt(transitFakeKey, transitFakeCiphertext)
+	assert.True(t, errors.IsVaultEngineNotImplemented(err))
+}
@@ -0,0 +1,247 @@
+package backend
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/tuenti/secrets-manager/errors"
+	"golang.org/x/net/http/httpproxy"
+)
+
+// newVaultTransport returns an http.Transport with the same defaults as http.DefaultTransport
+// but using the TLS and connection pooling settings provided in the backend config
+func newVaultTransport(cfg Config) (*http.Transport, error) {
+	tlsConfig, err := newVaultTLSConfig(cfg)
+	if err != nil {
+		return nil, err
+	}
+	proxy, err := newVaultProxy(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return &http.Transport{
+		Proxy: proxy,
+		DialContext: (&net.Dialer{
+			Timeout:   30 * time.Second,
+			KeepAlive: 30 * time.Second,
+			DualStack: true,
+		}).DialContext,
+		MaxIdleConns:          cfg.VaultMaxIdleConns,
+		MaxConnsPerHost:       cfg.VaultMaxConnsPerHost,
+		IdleConnTimeout:       cfg.VaultIdleConnTimeout,
+		TLSHandshakeTimeout:   10 * time.Second,
+		ExpectContinueTimeout: 1 * time.Second,
+		TLSClientConfig:       tlsConfig,
+	}, nil
+}
+
+// newVaultProxy returns the function choosing the proxy used to reach Vault. The standard proxy environment
+// variables are honored by default, while VaultProxyURL overrides HTTP_PROXY and HTTPS_PROXY. NO_PROXY
+// exclusions apply in both cases. HTTP, HTTPS and SOCKS5 proxies are supported
+func newVaultProxy(cfg Config) (func(*http.Request) (*url.URL, error), error) {
+	if cfg.VaultProxyURL == "" {
+		return http.ProxyFromEnvironment, nil
+	}
+	u, err := url.Parse(cfg.VaultProxyURL)
+	if err != nil || u.Host == "" {
+		return nil, &errors.BackendConfigError{ErrType: errors.BackendConfigErrorType, Backend: vaultBackendName, Reason: "invalid proxy url " + redactProxyURL(cfg.VaultProxyURL)}
+	}
+	env := httpproxy.FromEnvironment()
+	proxyConfig := &httpproxy.Config{
+		HTTPProxy:  cfg.VaultProxyURL,
+		HTTPSProxy: cfg.VaultProxyURL,
+		NoProxy:    env.NoProxy,
+	}
+	proxyFunc := proxyConfig.ProxyFunc()
+	return func(req *http.Request) (*url.URL, error) {
+		return proxyFunc(req.URL)
+	}, nil
+}
+
+// redactProxyURL returns the scheme and host of a proxy URL, so it can be logged without its credentials
+func redactProxyURL(proxyURL string) string {
+	u, err := url.Parse(proxyURL)
+	if err != nil {
+		return "<invalid>"
+	}
+	return u.Scheme + "://" + u.Host
+}
+
+// newVaultTLSConfig builds the tls.Config used to talk to Vault, validating that the
+// provided combination of CA and client certificates makes sense
+func newVaultTLSConfig(cfg Config) (*tls.Config, error) {
+	if cfg.VaultTLSSkipVerify && (cfg.VaultCACert != "" || cfg.VaultCACertPath != "") {
+		return nil, &errors.VaultTLSConfigError{ErrType: errors.VaultTLSConfigErrorType, Reason: "TLS skip verify can not be used along with a CA certificate"}
+	}
+
+	tlsConfig := &tls.Config{
+		MinVersion:         tls.VersionTLS12,
+		ServerName:         cfg.VaultTLSServerName,
+		InsecureSkipVerify: cfg.VaultTLSSkipVerify,
+	}
+
+	if cfg.VaultCACert != "" || cfg.VaultCACertPath != "" {
+		pool := x509.NewCertPool()
+		if cfg.VaultCACert != "" && !pool.AppendCertsFromPEM([]byte(cfg.VaultCACert)) {
+			return nil, &errors.VaultTLSConfigError{ErrType: errors.VaultTLSConfigErrorType, Reason: "unable to parse CA certificate"}
+		}
+		if cfg.VaultCACertPath != "" {
+			caCert, err := ioutil.ReadFile(cfg.VaultCACertPath)
+			if err != nil {
+				return nil, &errors.VaultTLSConfigError{ErrType: errors.VaultTLSConfigErrorType, Reason: err.Error()}
+			}
+			if !pool.AppendCertsFromPEM(caCert) {
+				return nil, &errors.VaultTLSConfigError{ErrType: errors.VaultTLSConfigErrorType, Reason: "unable to parse CA certificate from " + cfg.VaultCACertPath}
+			}
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	switch {
+	case cfg.VaultClientCert != "" && cfg.VaultClientKey != "":
+		certificate := &clientCertificate{certFile: cfg.VaultClientCert, keyFile: cfg.VaultClientKey}
+		if _, err := certificate.load(); err != nil {
+			return nil, &errors.VaultTLSConfigError{ErrType: errors.VaultTLSConfigErrorType, Reason: err.Error()}
+		}
+		tlsConfig.GetClientCertificate = certificate.getClientCertificate
+	case cfg.VaultClientKey != "":
+		return nil, &errors.VaultTLSConfigError{ErrType: errors.VaultTLSConfigErrorType, Reason: "client key provided without a client certificate"}
+	case cfg.VaultClientCert != "":
+		return nil, &errors.VaultTLSConfigError{ErrType: errors.VaultTLSConfigErrorType, Reason: "client certificate provided without a client key"}
+	}
+
+	return tlsConfig, nil
+}
+
+// clientCertificate holds the client certificate used for mutual TLS. It is loaded again from disk
+// whenever the certificate file changes, so that certificates rotated by tools like cert-manager
+// are used on the next TLS handshake
+type clientCertificate struct {
+	mutex    sync.Mutex
+	certFile string
+	keyFile  string
+	modTime  time.Time
+	cert     *tls.Certificate
+}
+
+// load returns the current certificate, reading it again from disk if the certificate file was modified
+func (c *clientCertificate) load() (*tls.Certificate, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	info, err := os.Stat(c.certFile)
+	if err != nil {
+		return nil, err
+	}
+	if c.cert != nil && info.ModTime().Equal(c.modTime) {
+		return c.cert, nil
+	}
+	cert, err := tls.LoadX509KeyPair(c.certFile, c.keyFile)
+	if err != nil {
+		return nil, err
+	}
+	c.cert = &cert
+	c.modTime = info.ModTime()
+	return c.cert, nil
+}
+
+// getClientCertificate keeps using the last certificate loaded if the files can't be read, e.g. while
+// they are being rotated, so the handshake can still succeed with a certificate that is not expired yet
+func (c *clientCertificate) getClientCertificate(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+	cert, err := c.load()
+	if err != nil {
+		c.mutex.Lock()
+		defer c.mutex.Unlock()
+		if c.cert != nil {
+			return c.cert, nil
+		}
+	}
+	return cert, err
+}
+
+const (
+	vaultResponseOperationLogin  = "login"
+	vaultResponseOperationRenew  = "renew"
+	vaultResponseOperationLookup = "lookup"
+	vaultResponseOperationSys    = "sys"
+	vaultResponseOperationList   = "list"
+	vaultResponseOperationRead   = "read"
+	vaultResponseOperationWrite  = "write"
+	vaultResponseOperationDelete = "delete"
+
+	// vaultResponseStatusError is the status code label of requests that got no response at all
+	vaultResponseStatusError = "error"
+	vaultResponseStatusOther = "other"
+)
+
+// vaultResponseStatusCodes are the status codes Vault answers with, others are counted together to keep
+// the cardinality of the responses metric bounded
+var vaultResponseStatusCodes = map[int]bool{
+	http.StatusOK:                  true,
+	http.StatusNoContent:           true,
+	http.StatusBadRequest:          true,
+	http.StatusForbidden:           true,
+	http.StatusNotFound:            true,
+	http.StatusMethodNotAllowed:    true,
+	http.StatusPreconditionFailed:  true,
+	http.StatusTooManyRequests:     true,
+	473:                            true, // performance standby
+	http.StatusInternalServerError: true,
+	http.StatusBadGateway:          true,
+	http.StatusServiceUnavailable:  true,
+}
+
+// responseMetricsTransport counts the responses of every Vault request by operation and status code, so
+// the ones not mapped to an error by secrets-manager, e.g. a 412 from a performance standby, are observable
+type responseMetricsTransport struct {
+	next           http.RoundTripper
+	responsesTotal *prometheus.CounterVec
+}
+
+func (t *responseMetricsTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.next.RoundTrip(req)
+	status := vaultResponseStatusError
+	if err == nil {
+		status = vaultResponseStatus(resp.StatusCode)
+	}
+	t.responsesTotal.WithLabelValues(req.URL.Scheme+"://"+req.URL.Host, vaultResponseOperation(req), status).Inc()
+	return resp, err
+}
+
+func vaultResponseStatus(code int) string {
+	if vaultResponseStatusCodes[code] {
+		return strconv.Itoa(code)
+	}
+	return vaultResponseStatusOther
+}
+
+// vaultResponseOperation tells the kind of operation of a Vault API request from its method and path
+func vaultResponseOperation(req *http.Request) string {
+	path := strings.TrimPrefix(req.URL.Path, "/v1/")
+	switch {
+	case strings.HasPrefix(path, "auth/token/renew"):
+		return vaultResponseOperationRenew
+	case strings.HasPrefix(path, "auth/token/lookup"):
+		return vaultResponseOperationLookup
+	case strings.HasPrefix(path, "auth/") && strings.Contains(path, "/login"):
+		return vaultResponseOperationLogin
+	case strings.HasPrefix(path, "sys/"):
+		return vaultResponseOperationSys
+	case req.Method == "LIST" || req.URL.Query().Get("list") == "true":
+		return vaultResponseOperationList
+	case req.Method == http.MethodGet:
+		return vaultResponseOperationRead
+	case req.Method == http.MethodDelete:
+		return vaultResponseOperationDelete
+	}
+	return vaultResponseOperationWrite
+}
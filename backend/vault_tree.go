@@ -0,0 +1,68 @@
+package backend
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/tuenti/secrets-manager/errors"
+)
+
+const (
+	defaultTreeMaxDepth  = 10
+	defaultTreeSeparator = "."
+)
+
+// TreeReader is implemented by backends able to read every secret stored under a path
+type TreeReader interface {
+	ReadSecretTree(prefix string) (map[string]string, error)
+}
+
+// ReadSecretTree lists prefix recursively, descending up to treeMaxDepth folders, and reads every key of the
+// secrets found. Keys are flattened joining the secret path relative to prefix and the key with treeSeparator,
+// e.g. the key password of secret/data/app/config/db is returned as db.password when reading secret/data/app/config.
+// A secret that can't be read doesn't abort the whole tree: the keys read are returned along with a SecretTreeError
+func (c *client) ReadSecretTree(prefix string) (map[string]string, error) {
+	prefix = strings.TrimSuffix(prefix, "/")
+	data := make(map[string]string)
+	var errs []error
+	if err := c.readSecretTree(prefix, nil, data, &errs); err != nil {
+		return nil, err
+	}
+	if len(errs) > 0 {
+		return data, &errors.SecretTreeError{ErrType: errors.SecretTreeErrorType, Path: prefix, Errs: errs}
+	}
+	return data, nil
+}
+
+// readSecretTree reads the secrets under path into data, collecting errors of its children in errs. Only
+// listing path itself returns an error
+func (c *client) readSecretTree(path string, folders []string, data map[string]string, errs *[]error) error {
+	keys, err := c.ListSecrets(path)
+	if err != nil {
+		return err
+	}
+	for _, k := range keys {
+		name := strings.TrimSuffix(k, "/")
+		childPath := path + "/" + name
+		relative := append(append([]string{}, folders...), name)
+		if strings.HasSuffix(k, "/") {
+			if len(relative) > c.treeMaxDepth {
+				*errs = append(*errs, fmt.Errorf("%s is deeper than %d folders, not read", childPath, c.treeMaxDepth))
+				continue
+			}
+			if err := c.readSecretTree(childPath, relative, data, errs); err != nil {
+				*errs = append(*errs, err)
+			}
+			continue
+		}
+		secret, err := c.ReadSecretAllKeys(childPath)
+		if err != nil {
+			*errs = append(*errs, err)
+			continue
+		}
+		for key, value := range secret {
+			data[strings.Join(append(relative, key), c.treeSeparator)] = value
+		}
+	}
+	return nil
+}
@@ -0,0 +1,112 @@
+package backend
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/tuenti/secrets-manager/errors"
+)
+
+var (
+	treeFakeFolders = map[string][]string{
+		"tree":               {"db", "nested/", "missing"},
+		"tree/nested":        {"api", "deeper/"},
+		"tree/nested/deeper": {"leaf"},
+	}
+	treeFakeSecrets = map[string]map[string]string{
+		"tree/db":                 {"username": "app", "password": "s3cr3t"},
+		"tree/nested/api":         {"token": "t0k3n"},
+		"tree/nested/deeper/leaf": {"value": "deep"},
+	}
+)
+
+func v1SecretTreeMetadataKv2(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	keys, ok := treeFakeFolders[mux.Vars(r)["path"]]
+	if !ok || r.URL.Query().Get("list") != "true" {
+		w.WriteHeader(http.StatusNotFound)
+		fmt.Fprint(w, `{"errors":[]}`)
+		return
+	}
+	response, _ := json.Marshal(map[string]interface{}{"data": map[string]interface{}{"keys": keys}})
+	w.Write(response)
+}
+
+func v1SecretTreeKv2(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	secret, ok := treeFakeSecrets[mux.Vars(r)["path"]]
+	if !ok {
+		w.WriteHeader(http.StatusNotFound)
+		fmt.Fprint(w, `{"errors":[]}`)
+		return
+	}
+	response, _ := json.Marshal(map[string]interface{}{"data": map[string]interface{}{"data": secret}})
+	w.Write(response)
+}
+
+func newTreeTestClient(t *testing.T) *client {
+	client, err := vaultClient(logger, vaultCfg)
+	assert.Nil(t, err)
+	client.engine, _ = newEngine("kv2")
+	return client
+}
+
+func TestReadSecretTree(t *testing.T) {
+	client := newTreeTestClient(t)
+	data, err := client.ReadSecretTree("secret/data/tree/")
+
+	assert.Equal(t, map[string]string{
+		"db.username":              "app",
+		"db.password":              "s3cr3t",
+		"nested.api.token":         "t0k3n",
+		"nested.deeper.leaf.value": "deep",
+	}, data)
+	// The missing secret doesn't abort the read of the rest of the tree
+	assert.True(t, errors.IsSecretTree(err))
+	assert.Len(t, err.(*errors.SecretTreeError).Errs, 1)
+	assert.True(t, errors.IsBackendSecretNotFound(err.(*errors.SecretTreeError).Errs[0]))
+}
+
+func TestReadSecretTreeSeparator(t *testing.T) {
+	client := newTreeTestClient(t)
+	client.treeSeparator = "_"
+	data, _ := client.ReadSecretTree("secret/data/tree/nested")
+
+	assert.Equal(t, map[string]string{
+		"api_token":         "t0k3n",
+		"deeper_leaf_value": "deep",
+	}, data)
+}
+
+func TestReadSecretTreeMaxDepth(t *testing.T) {
+	client := newTreeTestClient(t)
+	client.treeMaxDepth = 1
+	data, err := client.ReadSecretTree("secret/data/tree")
+
+	assert.Equal(t, "t0k3n", data["nested.api.token"])
+	assert.NotContains(t, data, "nested.deeper.leaf.value")
+	assert.True(t, errors.IsSecretTree(err))
+	assert.Len(t, err.(*errors.SecretTreeError).Errs, 2)
+}
+
+func TestReadSecretTreeNotFound(t *testing.T) {
+	client := newTreeTestClient(t)
+	data, err := client.ReadSecretTree("secret/data/tree/unknown")
+
+	assert.Nil(t, data)
+	assert.True(t, errors.IsBackendSecretNotFound(err))
+}
+
+func TestCachedClientReadSecretTree(t *testing.T) {
+	cached := newCachedClient(newTreeTestClient(t), vaultBackendName, 0, 0)
+	data, _ := cached.ReadSecretTree("secret/data/tree/nested/deeper")
+	assert.Equal(t, map[string]string{"leaf.value": "deep"}, data)
+
+	cached = newCachedClient(NewMemoryClient(nil), memoryBackendName, 0, 0)
+	_, err := cached.ReadSecretTree("secret/data/tree")
+	assert.True(t, errors.IsBackendNotImplemented(err))
+}
@@ -0,0 +1,90 @@
+package backend
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/tuenti/secrets-manager/errors"
+)
+
+const (
+	// defaultWrapTTL is the TTL of the wrapping tokens returned by ReadSecretWrapped when none is given
+	defaultWrapTTL = "5m"
+	// wrappingLookupPath returns the creation path and TTL of a wrapping token without using it
+	wrappingLookupPath = "sys/wrapping/lookup"
+)
+
+// WrappedReader is implemented by backends able to read secrets response-wrapped, returning a single use
+// wrapping token instead of the secret, so it's only ever seen in plaintext by the consumer unwrapping it
+type WrappedReader interface {
+	ReadSecretWrapped(path string, wrapTTL string) (string, error)
+}
+
+type wrapTTLKey struct{}
+
+func withWrapTTL(ctx context.Context, wrapTTL string) context.Context {
+	return context.WithValue(ctx, wrapTTLKey{}, wrapTTL)
+}
+
+func wrapTTLFromContext(ctx context.Context) string {
+	wrapTTL, _ := ctx.Value(wrapTTLKey{}).(string)
+	return wrapTTL
+}
+
+// ReadSecretWrapped reads a secret path asking Vault to wrap the whole response for wrapTTL, e.g. 5m or 300,
+// and returns the wrapping token. A path with nothing to wrap returns a BackendSecretNotFoundError
+func (c *client) ReadSecretWrapped(path string, wrapTTL string) (string, error) {
+	if wrapTTL == "" {
+		wrapTTL = defaultWrapTTL
+	}
+	secret, err := c.readRaw(withWrapTTL(context.Background(), wrapTTL), path, "", "", nil)
+	if err != nil {
+		c.metrics.updateVaultSecretWrappedReadsTotalMetric(path, requestResultError)
+		return "", err
+	}
+	if secret == nil || secret.WrapInfo == nil || secret.WrapInfo.Token == "" {
+		c.metrics.updateVaultSecretWrappedReadsTotalMetric(path, requestResultError)
+		c.metrics.updateVaultSecretReadErrorsTotalMetric(path, "", "", errors.BackendSecretNotFoundErrorType)
+		return "", &errors.BackendSecretNotFoundError{ErrType: errors.BackendSecretNotFoundErrorType, Path: path}
+	}
+	c.metrics.updateVaultSecretWrappedReadsTotalMetric(path, requestResultSuccess)
+	c.metrics.updateVaultSecretLastSyncMetric(path)
+	return secret.WrapInfo.Token, nil
+}
+
+// WrapValidator is implemented by backends able to check a response-wrapping token before it's unwrapped
+type WrapValidator interface {
+	ValidateWrappingToken(wrappingToken string, creationPath string) error
+}
+
+// ValidateWrappingToken looks a wrapping token up without using it and checks it was created at creationPath,
+// e.g. auth/approle/login, and hasn't expired. A token created at another path may have been swapped for one
+// wrapping a different response, and a token Vault doesn't know about was already unwrapped, maybe by someone
+// who intercepted it. Both fail with a VaultWrapValidationError
+func (c *client) ValidateWrappingToken(wrappingToken string, creationPath string) error {
+	expected := strings.Trim(creationPath, "/")
+	secret, err := c.logical.Write(wrappingLookupPath, map[string]interface{}{"token": wrappingToken})
+	if err != nil {
+		return &errors.VaultWrapValidationError{ErrType: errors.VaultWrapValidationErrorType, Path: expected, Reason: "lookup failed, it may have been unwrapped already: " + err.Error()}
+	}
+	if secret == nil || secret.Data == nil {
+		return &errors.VaultWrapValidationError{ErrType: errors.VaultWrapValidationErrorType, Path: expected, Reason: "lookup returned no data"}
+	}
+
+	actual, _ := secret.Data["creation_path"].(string)
+	if strings.Trim(actual, "/") != expected {
+		return &errors.VaultWrapValidationError{ErrType: errors.VaultWrapValidationErrorType, Path: expected, CreationPath: actual, Reason: "created at " + actual}
+	}
+	createdAt, _ := secret.Data["creation_time"].(string)
+	created, err := time.Parse(time.RFC3339Nano, createdAt)
+	if err != nil {
+		return &errors.VaultWrapValidationError{ErrType: errors.VaultWrapValidationErrorType, Path: expected, CreationPath: actual, Reason: "invalid creation time " + createdAt}
+	}
+	ttl := time.Duration(getTokenInt(secret, "creation_ttl")) * time.Second
+	if !time.Now().Before(created.Add(ttl)) {
+		return &errors.VaultWrapValidationError{ErrType: errors.VaultWrapValidationErrorType, Path: expected, CreationPath: actual, Reason: "expired at " + created.Add(ttl).UTC().Format(time.RFC3339)}
+	}
+	c.logger.V(1).Info("vault wrapping token validated", "vault_wrapping_creation_path", actual, "vault_wrapping_ttl", ttl.String())
+	return nil
+}
@@ -0,0 +1,151 @@
+package backend
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/tuenti/secrets-manager/errors"
+)
+
+// v1SecretWrappedKv2 wraps the response in a token named after the wrapping TTL when asked to, the
+// secret/data/nothing path has nothing to wrap
+func v1SecretWrappedKv2(w http.ResponseWriter, r *http.Request) {
+	wrapTTL := r.Header.Get("X-Vault-Wrap-TTL")
+	if mux.Vars(r)["path"] == "nothing" || wrapTTL == "" {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	fmt.Fprintf(w, `{"wrap_info": {"token": "s.wrapped-%s", "ttl": 300, "creation_path": %q}}`, wrapTTL, r.URL.Path)
+}
+
+func TestReadSecretWrapped(t *testing.T) {
+	client, _ := vaultClient(logger, vaultCfg)
+	client.engine, _ = newEngine("kv2")
+	client.metrics.secretWrappedReadsTotal.Reset()
+
+	token, err := client.ReadSecretWrapped("secret/data/wrapped", "10m")
+	assert.Nil(t, err)
+	assert.Equal(t, "s.wrapped-10m", token)
+
+	token, err = client.ReadSecretWrapped("secret/data/wrapped", "")
+	assert.Nil(t, err)
+	assert.Equal(t, "s.wrapped-"+defaultWrapTTL, token)
+
+	metric, _ := client.metrics.secretWrappedReadsTotal.GetMetricWithLabelValues(vaultCfg.VaultURL, vaultCfg.VaultEngine, vaultFakeVersion, vaultFakeClusterID, vaultFakeClusterName, vaultCfg.VaultNamespace, "secret/data/wrapped", requestResultSuccess)
+	assert.Equal(t, 2.0, testutil.ToFloat64(metric))
+}
+
+func TestReadSecretWrappedNothingToWrap(t *testing.T) {
+	client, _ := vaultClient(logger, vaultCfg)
+	client.engine, _ = newEngine("kv2")
+	client.metrics.secretWrappedReadsTotal.Reset()
+
+	_, err := client.ReadSecretWrapped("secret/data/nothing", "5m")
+	assert.True(t, errors.IsBackendSecretNotFound(err))
+
+	metric, _ := client.metrics.secretWrappedReadsTotal.GetMetricWithLabelValues(vaultCfg.VaultURL, vaultCfg.VaultEngine, vaultFakeVersion, vaultFakeClusterID, vaultFakeClusterName, vaultCfg.VaultNamespace, "secret/data/nothing", requestResultError)
+	assert.Equal(t, 1.0, testutil.ToFloat64(metric))
+}
+
+func TestReadSecretNotWrapped(t *testing.T) {
+	client, _ := vaultClient(logger, vaultCfg)
+	client.engine, _ = newEngine("kv2")
+
+	// Plain reads must not ask Vault to wrap the response
+	_, err := client.ReadSecret("secret/data/wrapped", "foo")
+	assert.True(t, errors.IsBackendSecretNotFound(err))
+}
+
+// v1SysWrappingLookup mimics looking up the wrapping tokens of v1SysWrappingUnwrap without using them. The login
+// one was created by auth/approle/login, the data one by sys/wrapping/wrap and s.wrapped-expired expired an hour ago
+func v1SysWrappingLookup(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		Token string `json:"token"`
+	}
+	json.NewDecoder(r.Body).Decode(&body)
+	creationPaths := map[string]string{
+		fakeWrappingToken:     "auth/approle/login",
+		fakeWrappingDataToken: "sys/wrapping/wrap",
+		"s.wrapped-expired":   "auth/approle/login",
+	}
+	mutex.Lock()
+	used := testCfg.unwrappedTokens[body.Token]
+	mutex.Unlock()
+	w.Header().Set("Content-Type", "application/json")
+	if used || creationPaths[body.Token] == "" {
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprint(w, `{"errors":["wrapping token is not valid or does not exist"]}`)
+		return
+	}
+	created := time.Now()
+	if body.Token == "s.wrapped-expired" {
+		created = created.Add(-time.Hour)
+	}
+	fmt.Fprintf(w, `{"data": {"creation_path": %q, "creation_time": %q, "creation_ttl": 300}}`, creationPaths[body.Token], created.Format(time.RFC3339Nano))
+}
+
+func resetUnwrappedTokens() {
+	mutex.Lock()
+	defer mutex.Unlock()
+	testCfg.unwrappedTokens = nil
+}
+
+func TestValidateWrappingToken(t *testing.T) {
+	resetUnwrappedTokens()
+	defer resetUnwrappedTokens()
+	client, _ := vaultClient(logger, vaultCfg)
+
+	assert.Nil(t, client.ValidateWrappingToken(fakeWrappingToken, "/auth/approle/login/"))
+
+	err := client.ValidateWrappingToken(fakeWrappingDataToken, "auth/approle/login")
+	assert.True(t, errors.IsVaultWrapValidation(err))
+	assert.Equal(t, "auth/approle/login", err.(*errors.VaultWrapValidationError).Path)
+	assert.Equal(t, "sys/wrapping/wrap", err.(*errors.VaultWrapValidationError).CreationPath)
+
+	err = client.ValidateWrappingToken("s.wrapped-expired", "auth/approle/login")
+	assert.True(t, errors.IsVaultWrapValidation(err))
+	assert.Contains(t, err.Error(), "expired")
+
+	err = client.ValidateWrappingToken("s.invalid", "auth/approle/login")
+	assert.True(t, errors.IsVaultWrapValidation(err))
+	assert.Contains(t, err.Error(), "wrapping token is not valid")
+
+	// Lookups don't use the token, but once unwrapped it can't be looked up anymore
+	assert.Nil(t, client.vaultUnwrapToken(fakeWrappingToken))
+	assert.True(t, errors.IsVaultWrapValidation(client.ValidateWrappingToken(fakeWrappingToken, "auth/approle/login")))
+
+	cached := newCachedClient(client, vaultBackendName, 0, 0)
+	assert.True(t, errors.IsVaultWrapValidation(cached.ValidateWrappingToken(fakeWrappingDataToken, "auth/approle/login")))
+}
+
+func TestVaultClientWrappedTokenPath(t *testing.T) {
+	resetUnwrappedTokens()
+	defer resetUnwrappedTokens()
+	cfg := vaultCfg
+	cfg.VaultRoleID = ""
+	cfg.VaultWrappedToken = fakeWrappingDataToken
+	cfg.VaultWrappedTokenPath = "auth/approle/login"
+
+	// A token created elsewhere is refused before it's unwrapped
+	_, err := vaultClient(logger, cfg)
+	assert.True(t, errors.IsVaultWrapValidation(err))
+	mutex.Lock()
+	assert.False(t, testCfg.unwrappedTokens[fakeWrappingDataToken])
+	mutex.Unlock()
+
+	cfg.VaultWrappedToken = fakeWrappingToken
+	client, err := vaultClient(logger, cfg)
+	assert.Nil(t, err)
+	assert.Equal(t, fakeToken, client.vclient.Token())
+
+	cfg.VaultWrappedToken = ""
+	_, err = vaultClient(logger, cfg)
+	assert.True(t, errors.IsBackendConfig(err))
+}
@@ -0,0 +1,64 @@
+package backend
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/tuenti/secrets-manager/errors"
+)
+
+// WriteSecret stores data at the given path, using the same path format as reads. Writes replace the whole
+// secret, keys not present in data are removed. Writing the same data twice leaves the secret unchanged in
+// KV version 1, while KV version 2 creates a new version on every write, so writes are not retried
+func (c *client) WriteSecret(path string, data map[string]interface{}) error {
+	return c.writeSecret(path, data, nil)
+}
+
+// WriteSecretCAS stores data at the given path as WriteSecret does, but only if expectedVersion is still the
+// current version of the secret, 0 meaning it must not exist yet, so concurrent writers don't overwrite each
+// other. It's only supported by KV version 2. Writes rejected because the secret changed meanwhile return a
+// VaultCASMismatchError
+func (c *client) WriteSecretCAS(path string, data map[string]interface{}, expectedVersion int) error {
+	return c.writeSecret(path, data, &expectedVersion)
+}
+
+// writeSecret writes data at path, with a check-and-set of the given version when it's not nil
+func (c *client) writeSecret(path string, data map[string]interface{}, cas *int) error {
+	c.inflight.Add(1)
+	defer c.inflight.Done()
+
+	engine := c.engineFor(context.Background(), path)
+	_, kv2 := engine.(kvEngineV2)
+	if _, ok := engine.(transitEngine); ok || (cas != nil && !kv2) {
+		c.metrics.updateVaultSecretWriteErrorsTotalMetric(path, errors.VaultEngineNotImplementedErrorType)
+		return &errors.VaultEngineNotImplementedError{ErrType: errors.VaultEngineNotImplementedErrorType, Engine: engine.getName()}
+	}
+	if c.health.isSealed() {
+		c.metrics.updateVaultSecretWriteErrorsTotalMetric(path, errors.VaultSealedErrorType)
+		return &errors.VaultSealedError{ErrType: errors.VaultSealedErrorType, Path: path}
+	}
+
+	payload := engine.wrapData(data)
+	if cas != nil {
+		payload["options"] = map[string]interface{}{"cas": *cas}
+	}
+	start := time.Now()
+	_, err := c.logical.Write(path, payload)
+	c.metrics.updateVaultSecretWriteDurationMetric(path, time.Since(start))
+	if err != nil && cas != nil && isCASMismatch(err) {
+		c.metrics.updateVaultSecretWriteErrorsTotalMetric(path, errors.VaultCASMismatchErrorType)
+		return &errors.VaultCASMismatchError{ErrType: errors.VaultCASMismatchErrorType, Path: path, Version: *cas}
+	}
+	if err != nil {
+		c.metrics.updateVaultSecretWriteErrorsTotalMetric(path, errors.BackendSecretWriteErrorType)
+		return &errors.BackendSecretWriteError{ErrType: errors.BackendSecretWriteErrorType, Path: path, Err: err}
+	}
+	return nil
+}
+
+// isCASMismatch returns true when Vault rejected a write because its check-and-set version didn't match
+func isCASMismatch(err error) bool {
+	return vaultStatusCode(err) == http.StatusBadRequest && strings.Contains(err.Error(), "check-and-set")
+}
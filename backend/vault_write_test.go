@@ -0,0 +1,118 @@
+package backend
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/tuenti/secrets-manager/errors"
+)
+
+// v1SecretWrite stores the written payload in testCfg.writtenSecrets, paths under denied/ are forbidden. Writes
+// with a check-and-set version are rejected unless it's the number of writes already stored in the path
+func v1SecretWrite(w http.ResponseWriter, r *http.Request) {
+	path := mux.Vars(r)["path"]
+	w.Header().Set("Content-Type", "application/json")
+	if strings.HasPrefix(path, "denied/") {
+		w.WriteHeader(http.StatusForbidden)
+		fmt.Fprint(w, `{"errors":["1 error occurred:\n\t* permission denied\n\n"]}`)
+		return
+	}
+	var body map[string]interface{}
+	json.NewDecoder(r.Body).Decode(&body)
+	mutex.Lock()
+	defer mutex.Unlock()
+	if testCfg.writtenSecrets == nil {
+		testCfg.writtenSecrets = make(map[string]map[string]interface{})
+		testCfg.writtenVersions = make(map[string]int)
+	}
+	if options, ok := body["options"].(map[string]interface{}); ok {
+		if cas, _ := options["cas"].(float64); int(cas) != testCfg.writtenVersions[path] {
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprint(w, `{"errors":["check-and-set parameter did not match the current version"]}`)
+			return
+		}
+	}
+	testCfg.writtenSecrets[path] = body
+	testCfg.writtenVersions[path]++
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func TestVaultWriteSecretKv2(t *testing.T) {
+	client, _ := vaultClient(logger, vaultCfg)
+	client.engine, _ = newEngine("kv2")
+
+	err := client.WriteSecret("secret/data/written/password", map[string]interface{}{"password": "s3cr3t"})
+	assert.Nil(t, err)
+	mutex.Lock()
+	defer mutex.Unlock()
+	assert.Equal(t, map[string]interface{}{"data": map[string]interface{}{"password": "s3cr3t"}}, testCfg.writtenSecrets["data/written/password"])
+}
+
+func TestVaultWriteSecretKv1(t *testing.T) {
+	client, _ := vaultClient(logger, vaultCfg)
+	client.engine, _ = newEngine("kv1")
+
+	err := client.WriteSecret("secret/written/password", map[string]interface{}{"password": "s3cr3t"})
+	assert.Nil(t, err)
+	mutex.Lock()
+	defer mutex.Unlock()
+	assert.Equal(t, map[string]interface{}{"password": "s3cr3t"}, testCfg.writtenSecrets["written/password"])
+}
+
+func TestVaultWriteSecretError(t *testing.T) {
+	client, _ := vaultClient(logger, vaultCfg)
+	client.engine, _ = newEngine("kv2")
+	client.metrics.secretWriteErrorsTotal.Reset()
+	path := "secret/denied/password"
+
+	err := client.WriteSecret(path, map[string]interface{}{"password": "s3cr3t"})
+	metric, _ := client.metrics.secretWriteErrorsTotal.GetMetricWithLabelValues(vaultCfg.VaultURL, vaultCfg.VaultEngine, vaultFakeVersion, vaultFakeClusterID, vaultFakeClusterName, vaultCfg.VaultNamespace, path, errors.BackendSecretWriteErrorType)
+
+	assert.True(t, errors.IsBackendSecretWrite(err))
+	assert.Equal(t, 1.0, testutil.ToFloat64(metric))
+}
+
+func TestVaultWriteSecretWrongEngine(t *testing.T) {
+	client, _ := vaultClient(logger, vaultCfg)
+	client.engine, _ = newEngine("transit")
+
+	err := client.WriteSecret("secret/data/written/password", map[string]interface{}{"password": "s3cr3t"})
+	assert.True(t, errors.IsVaultEngineNotImplemented(err))
+}
+
+func TestVaultWriteSecretCAS(t *testing.T) {
+	client, _ := vaultClient(logger, vaultCfg)
+	client.engine, _ = newEngine("kv2")
+	client.metrics.secretWriteErrorsTotal.Reset()
+	path := "secret/data/written/cas"
+
+	assert.Nil(t, client.WriteSecretCAS(path, map[string]interface{}{"password": "s3cr3t"}, 0))
+	mutex.Lock()
+	assert.Equal(t, map[string]interface{}{"cas": 0.0}, testCfg.writtenSecrets["data/written/cas"]["options"])
+	mutex.Unlock()
+
+	// Another replica already wrote the first version
+	err := client.WriteSecretCAS(path, map[string]interface{}{"password": "0th3r"}, 0)
+	metric, _ := client.metrics.secretWriteErrorsTotal.GetMetricWithLabelValues(vaultCfg.VaultURL, vaultCfg.VaultEngine, vaultFakeVersion, vaultFakeClusterID, vaultFakeClusterName, vaultCfg.VaultNamespace, path, errors.VaultCASMismatchErrorType)
+	assert.True(t, errors.IsVaultCASMismatch(err))
+	assert.Equal(t, 1.0, testutil.ToFloat64(metric))
+	mutex.Lock()
+	assert.Equal(t, map[string]interface{}{"password": "s3cr3t"}, testCfg.writtenSecrets["data/written/cas"]["data"])
+	mutex.Unlock()
+
+	assert.Nil(t, client.WriteSecretCAS(path, map[string]interface{}{"password": "0th3r"}, 1))
+}
+
+func TestVaultWriteSecretCASKv1(t *testing.T) {
+	client, _ := vaultClient(logger, vaultCfg)
+	client.engine, _ = newEngine("kv1")
+
+	err := client.WriteSecretCAS("secret/written/cas", map[string]interface{}{"password": "s3cr3t"}, 0)
+	assert.True(t, errors.IsVaultEngineNotImplemented(err))
+}
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"sort"
+	"text/tabwriter"
+
+	"github.com/go-logr/logr"
+	secretsmanagerv1alpha1 "github.com/tuenti/secrets-manager/api/v1alpha1"
+	"github.com/tuenti/secrets-manager/backend"
+	"github.com/tuenti/secrets-manager/controllers"
+	"k8s.io/apimachinery/pkg/util/yaml"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	checkCommand = "check"
+
+	// Exit codes of the check command
+	checkOK               = 0
+	checkResolutionFailed = 1
+	checkConnectionFailed = 2
+	checkUsageError       = 3
+)
+
+// runCheck resolves the SecretDefinitions stored in a file, or living in a namespace, against the backend
+// and prints a table with the outcome of every key. Wrong arguments and SecretDefinitions that can't be loaded
+// exit with checkUsageError, failing to connect and keys that couldn't be checked because the backend is
+// unreachable exit with checkConnectionFailed, while keys missing or failing to resolve exit with
+// checkResolutionFailed
+func runCheck(ctx context.Context, args []string, selectedBackend string, cfg backend.Config, prefixes *controllers.PathPrefixes, logger logr.Logger, out io.Writer) int {
+	if len(args) != 1 {
+		fmt.Fprintf(out, "usage: %s %s [flags] <file-or-namespace>\n", os.Args[0], checkCommand)
+		return checkUsageError
+	}
+	sDefs, err := loadSecretDefinitions(ctx, args[0])
+	if err != nil {
+		fmt.Fprintf(out, "unable to load SecretDefinitions from %s: %v\n", args[0], err)
+		return checkUsageError
+	}
+	backendClient, err := backend.NewBackendClient(ctx, selectedBackend, logger.WithName("backend"), cfg)
+	if err != nil {
+		fmt.Fprintf(out, "unable to connect to %s backend: %v\n", selectedBackend, err)
+		return checkConnectionFailed
+	}
+	if closer, ok := (*backendClient).(backend.Closer); ok {
+		defer closer.Close(ctx)
+	}
+
+	r := &controllers.SecretDefinitionReconciler{Backend: *backendClient, Log: logger.WithName("check"), Ctx: ctx, PathPrefixes: prefixes}
+	results := make([]controllers.DryRunResult, 0, len(sDefs))
+	for i := range sDefs {
+		results = append(results, r.Check(&sDefs[i]))
+	}
+	return printCheckResults(out, sDefs, results)
+}
+
+// printCheckResults prints a row for every key of the SecretDefinitions and returns the check exit code
+func printCheckResults(out io.Writer, sDefs []secretsmanagerv1alpha1.SecretDefinition, results []controllers.DryRunResult) int {
+	code := checkOK
+	w := tabwriter.NewWriter(out, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(w, "NAMESPACE\tSECRET\tKEY\tPATH\tSTATUS\tERROR")
+	for i, result := range results {
+		keysMap := sDefs[i].Spec.KeysMap
+		keys := make([]string, 0, len(keysMap))
+		for k := range keysMap {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			status, detail := "ok", ""
+			if contains(result.UnreachableKeys, k) {
+				status, detail = "unreachable", result.Errors[k]
+			} else if msg, failed := result.Errors[k]; failed {
+				status, detail = "error", msg
+			} else if contains(result.MissingKeys, k) {
+				status = "missing"
+			} else if contains(result.SkippedKeys, k) {
+				status = "skipped"
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n", result.Namespace, result.Name, k, keysMap[k].Path, status, detail)
+		}
+		switch {
+		case len(result.UnreachableKeys) > 0:
+			code = checkConnectionFailed
+		case !result.OK() && code == checkOK:
+			code = checkResolutionFailed
+		}
+	}
+	w.Flush()
+	return code
+}
+
+// loadSecretDefinitions reads the SecretDefinitions of a YAML or JSON file, which may hold several
+// documents. If no such file exists, the SecretDefinitions of the namespace with that name are listed instead
+func loadSecretDefinitions(ctx context.Context, source string) ([]secretsmanagerv1alpha1.SecretDefinition, error) {
+	if _, err := os.Stat(source); err == nil {
+		data, err := ioutil.ReadFile(source)
+		if err != nil {
+			return nil, err
+		}
+		return decodeSecretDefinitions(data)
+	}
+	restConfig, err := ctrl.GetConfig()
+	if err != nil {
+		return nil, err
+	}
+	k8sClient, err := client.New(restConfig, client.Options{Scheme: scheme})
+	if err != nil {
+		return nil, err
+	}
+	list := &secretsmanagerv1alpha1.SecretDefinitionList{}
+	if err := k8sClient.List(ctx, list, client.InNamespace(source)); err != nil {
+		return nil, err
+	}
+	return list.Items, nil
+}
+
+func decodeSecretDefinitions(data []byte) ([]secretsmanagerv1alpha1.SecretDefinition, error) {
+	sDefs := []secretsmanagerv1alpha1.SecretDefinition{}
+	decoder := yaml.NewYAMLOrJSONDecoder(bytes.NewReader(data), 4096)
+	for {
+		sDef := secretsmanagerv1alpha1.SecretDefinition{}
+		err := decoder.Decode(&sDef)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		// Skip empty documents, e.g. a trailing ---
+		if sDef.Kind == "" && len(sDef.Spec.KeysMap) == 0 {
+			continue
+		}
+		if sDef.Kind != "SecretDefinition" {
+			return nil, fmt.Errorf("unexpected kind %q, only SecretDefinitions can be checked", sDef.Kind)
+		}
+		sDefs = append(sDefs, sDef)
+	}
+	return sDefs, nil
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/tuenti/secrets-manager/backend"
+	"github.com/tuenti/secrets-manager/controllers"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+const checkTestDefinitions = `
+apiVersion: secrets-manager.tuenti.io/v1alpha1
+kind: SecretDefinition
+metadata:
+  name: sd-db
+  namespace: default
+spec:
+  name: db
+  keysMap:
+    password:
+      path: secret/data/db
+      key: password
+    username:
+      path: secret/data/db
+      key: username
+---
+apiVersion: secrets-manager.tuenti.io/v1alpha1
+kind: SecretDefinition
+metadata:
+  name: sd-api
+  namespace: default
+spec:
+  name: api
+  keysMap:
+    token:
+      path: secret/data/api
+      key: token
+---
+`
+
+func TestDecodeSecretDefinitions(t *testing.T) {
+	sDefs, err := decodeSecretDefinitions([]byte(checkTestDefinitions))
+	assert.Nil(t, err)
+	assert.Len(t, sDefs, 2)
+	assert.Equal(t, "db", sDefs[0].Spec.Name)
+	assert.Equal(t, "secret/data/api", sDefs[1].Spec.KeysMap["token"].Path)
+
+	_, err = decodeSecretDefinitions([]byte("apiVersion: v1\nkind: Secret\n"))
+	assert.NotNil(t, err)
+}
+
+func TestPrintCheckResults(t *testing.T) {
+	sDefs, _ := decodeSecretDefinitions([]byte(checkTestDefinitions))
+	results := []controllers.DryRunResult{
+		{Namespace: "default", Name: "db", ResolvedKeys: []string{"password", "username"}, Errors: map[string]string{}},
+		{Namespace: "default", Name: "api", ResolvedKeys: []string{"token"}, Errors: map[string]string{}},
+	}
+	var out bytes.Buffer
+	assert.Equal(t, checkOK, printCheckResults(&out, sDefs, results))
+	assert.Contains(t, out.String(), "db      password  secret/data/db   ok")
+
+	results[0] = controllers.DryRunResult{Namespace: "default", Name: "db", ResolvedKeys: []string{"username"}, SkippedKeys: []string{"password"}, Errors: map[string]string{}}
+	out.Reset()
+	assert.Equal(t, checkOK, printCheckResults(&out, sDefs, results))
+	assert.Contains(t, out.String(), "db      password  secret/data/db   skipped")
+
+	results[0] = controllers.DryRunResult{Namespace: "default", Name: "db", ResolvedKeys: []string{"username"}, MissingKeys: []string{"password"}, Errors: map[string]string{}}
+	out.Reset()
+	assert.Equal(t, checkResolutionFailed, printCheckResults(&out, sDefs, results))
+	assert.Contains(t, out.String(), "missing")
+
+	results[1] = controllers.DryRunResult{Namespace: "default", Name: "api", UnreachableKeys: []string{"token"}, Errors: map[string]string{"token": "connection refused"}}
+	out.Reset()
+	assert.Equal(t, checkConnectionFailed, printCheckResults(&out, sDefs, results))
+	assert.Contains(t, out.String(), "unreachable  connection refused")
+}
+
+func TestRunCheck(t *testing.T) {
+	file, _ := ioutil.TempFile("", "secretdefinitions")
+	defer os.Remove(file.Name())
+	file.WriteString(checkTestDefinitions)
+	file.Close()
+	cfg := backend.Config{MemorySecrets: map[string]map[string]string{
+		"secret/data/db":  {"password": "s3cr3t", "username": "app"},
+		"secret/data/api": {"token": "t0k3n"},
+	}}
+
+	var out bytes.Buffer
+	assert.Equal(t, checkOK, runCheck(context.Background(), []string{file.Name()}, "memory", cfg, nil, logf.NullLogger{}, &out))
+
+	delete(cfg.MemorySecrets, "secret/data/api")
+	out.Reset()
+	assert.Equal(t, checkResolutionFailed, runCheck(context.Background(), []string{file.Name()}, "memory", cfg, nil, logf.NullLogger{}, &out))
+	assert.Contains(t, out.String(), "api     token     secret/data/api  missing")
+
+	out.Reset()
+	assert.Equal(t, checkUsageError, runCheck(context.Background(), []string{}, "memory", cfg, nil, logf.NullLogger{}, &out))
+	assert.Contains(t, out.String(), "usage")
+}
+
+func TestRunCheckUsageErrors(t *testing.T) {
+	file, _ := ioutil.TempFile("", "secretdefinitions")
+	defer os.Remove(file.Name())
+	file.WriteString("kind: SecretDefinition\nspec: [")
+	file.Close()
+
+	var out bytes.Buffer
+	assert.Equal(t, checkUsageError, runCheck(context.Background(), []string{file.Name()}, "memory", backend.Config{}, nil, logf.NullLogger{}, &out))
+	assert.Contains(t, out.String(), "unable to load SecretDefinitions")
+
+	out.Reset()
+	assert.Equal(t, checkUsageError, runCheck(context.Background(), []string{"a", "b"}, "memory", backend.Config{}, nil, logf.NullLogger{}, &out))
+	assert.Contains(t, out.String(), "usage")
+
+	// Failing to connect isn't a usage error
+	valid, _ := ioutil.TempFile("", "secretdefinitions")
+	defer os.Remove(valid.Name())
+	valid.WriteString(checkTestDefinitions)
+	valid.Close()
+	out.Reset()
+	assert.Equal(t, checkConnectionFailed, runCheck(context.Background(), []string{valid.Name()}, "unknown", backend.Config{}, nil, logf.NullLogger{}, &out))
+	assert.Contains(t, out.String(), "unable to connect to unknown backend")
+}
+
+func TestRunCheckPathPrefixes(t *testing.T) {
+	file, _ := ioutil.TempFile("", "secretdefinitions")
+	defer os.Remove(file.Name())
+	file.WriteString(checkTestDefinitions)
+	file.Close()
+	cfg := backend.Config{MemorySecrets: map[string]map[string]string{
+		"tenants/default/secret/data/db":  {"password": "s3cr3t", "username": "app"},
+		"tenants/default/secret/data/api": {"token": "t0k3n"},
+	}}
+	prefixes, _ := controllers.NewPathPrefixes(nil, "tenants/{{.Namespace}}")
+
+	var out bytes.Buffer
+	assert.Equal(t, checkOK, runCheck(context.Background(), []string{file.Name()}, "memory", cfg, prefixes, logf.NullLogger{}, &out))
+	assert.Equal(t, checkResolutionFailed, runCheck(context.Background(), []string{file.Name()}, "memory", cfg, nil, logf.NullLogger{}, &out))
+}
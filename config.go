@@ -0,0 +1,258 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/tuenti/secrets-manager/backend"
+	"k8s.io/apimachinery/pkg/util/yaml"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+const (
+	logLevelFlag                = "log-level"
+	vaultMetricsPathLabelsFlag  = "vault.metrics-path-labels"
+	vaultCacheTTLFlag           = "vault.cache-ttl"
+	vaultTokenPollingJitterFlag = "vault.token-polling-jitter"
+
+	configReloadSuccess = "success"
+	configReloadError   = "error"
+
+	defaultMetricsNamespace = "secrets_manager"
+)
+
+// reloadableFlags are the settings applied on SIGHUP, every other one needs a restart
+var reloadableFlags = map[string]bool{
+	logLevelFlag:                true,
+	vaultMetricsPathLabelsFlag:  true,
+	vaultCacheTTLFlag:           true,
+	vaultTokenPollingJitterFlag: true,
+}
+
+func newConfigReloadsTotal(namespace string) *prometheus.CounterVec {
+	if namespace == "" {
+		namespace = defaultMetricsNamespace
+	}
+	return prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "config",
+		Name:      "reloads_total",
+		Help:      "The total number of config file reloads, by result",
+	}, []string{"result"})
+}
+
+// configFile holds flag values keyed by flag name, e.g. log-level: debug. Flags set on the command line take
+// precedence over the file
+type configFile struct {
+	path  string
+	flags *flag.FlagSet
+	// explicit are the flags set on the command line
+	explicit map[string]bool
+	// defaults are the flag values before the file was applied
+	defaults map[string]string
+	// applied are the flag values in use
+	applied map[string]string
+
+	// reloadsTotal is set by registerMetrics, as the metrics namespace may be set by the file
+	reloadsTotal *prometheus.CounterVec
+	// Set by watch, as they are only built once the file was applied
+	mutex    sync.Mutex
+	cfg      backend.Config
+	backend  backend.Client
+	logLevel *backend.LogLevel
+	logger   logr.Logger
+}
+
+// loadConfigFile reads the config file at path and sets the flags of fs it holds, but the ones set on the
+// command line. It must be called once fs is parsed
+func loadConfigFile(fs *flag.FlagSet, path string) (*configFile, error) {
+	f := &configFile{path: path, flags: fs, explicit: make(map[string]bool), defaults: make(map[string]string)}
+	fs.Visit(func(fl *flag.Flag) {
+		f.explicit[fl.Name] = true
+	})
+	fs.VisitAll(func(fl *flag.Flag) {
+		f.defaults[fl.Name] = fl.Value.String()
+	})
+	values, err := f.read()
+	if err != nil {
+		return nil, err
+	}
+	for name, value := range values {
+		if !f.explicit[name] {
+			if err := fs.Set(name, value); err != nil {
+				return nil, fmt.Errorf("invalid %s in %s: %v", name, path, err)
+			}
+		}
+	}
+	f.applied = make(map[string]string)
+	fs.VisitAll(func(fl *flag.Flag) {
+		f.applied[fl.Name] = fl.Value.String()
+	})
+	return f, nil
+}
+
+// read returns the flag values of the file, normalized as the flags would print them. Unknown flags and
+// values of the wrong type are an error
+func (f *configFile) read() (map[string]string, error) {
+	content, err := ioutil.ReadFile(f.path)
+	if err != nil {
+		return nil, err
+	}
+	raw := make(map[string]interface{})
+	if err := yaml.NewYAMLOrJSONDecoder(strings.NewReader(string(content)), 4096).Decode(&raw); err != nil {
+		return nil, fmt.Errorf("unable to parse %s: %v", f.path, err)
+	}
+	values := make(map[string]string, len(raw))
+	for name, value := range raw {
+		fl := f.flags.Lookup(name)
+		if fl == nil {
+			return nil, fmt.Errorf("unknown flag %s in %s", name, f.path)
+		}
+		// A new value of the flag type parses and prints it, e.g. so 60s and 1m are the same duration
+		v := reflect.New(reflect.TypeOf(fl.Value).Elem()).Interface().(flag.Value)
+		s := fmt.Sprint(value)
+		if number, ok := value.(float64); ok {
+			// Numbers are decoded as float64, printed without exponent so integer flags parse them
+			s = strconv.FormatFloat(number, 'f', -1, 64)
+		}
+		if err := v.Set(s); err != nil {
+			return nil, fmt.Errorf("invalid %s in %s: %v", name, f.path, err)
+		}
+		values[name] = v.String()
+	}
+	return values, nil
+}
+
+// registerMetrics registers the config file metrics in the controller-runtime registry, with names starting
+// with namespace
+func (f *configFile) registerMetrics(namespace string) error {
+	f.reloadsTotal = newConfigReloadsTotal(namespace)
+	return metrics.Registry.Register(f.reloadsTotal)
+}
+
+// watch reloads the config file every time a signal is received on signals, until it's closed
+func (f *configFile) watch(signals <-chan os.Signal, cfg backend.Config, backendClient backend.Client, logLevel *backend.LogLevel, logger logr.Logger) {
+	f.mutex.Lock()
+	f.cfg, f.backend, f.logLevel, f.logger = cfg, backendClient, logLevel, logger
+	f.mutex.Unlock()
+	for range signals {
+		f.reload()
+	}
+}
+
+// reload reads the config file again and applies the reloadable settings changed since the last time, either
+// all of them or none when any is invalid. Changes of any other setting are logged and ignored
+func (f *configFile) reload() error {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	err := f.apply()
+	if err != nil {
+		f.reloadsTotal.WithLabelValues(configReloadError).Inc()
+		f.logger.Error(err, "unable to reload config file", "config_file", f.path)
+		return err
+	}
+	f.reloadsTotal.WithLabelValues(configReloadSuccess).Inc()
+	return nil
+}
+
+// apply must be called with the mutex held
+func (f *configFile) apply() error {
+	values, err := f.read()
+	if err != nil {
+		return err
+	}
+
+	changed := make(map[string]string)
+	ignored := make([]string, 0)
+	for name := range f.applied {
+		value, ok := values[name]
+		if f.explicit[name] {
+			if ok && value != f.applied[name] {
+				ignored = append(ignored, name)
+			}
+			continue
+		}
+		if !ok {
+			value = f.defaults[name]
+		}
+		if value == f.applied[name] {
+			continue
+		}
+		if !reloadableFlags[name] {
+			ignored = append(ignored, name)
+			continue
+		}
+		if _, ok := f.backend.(backend.Cache); name == vaultCacheTTLFlag && !ok {
+			// The cache can't be enabled without a restart
+			ignored = append(ignored, name)
+			continue
+		}
+		changed[name] = value
+	}
+	sort.Strings(ignored)
+	if len(ignored) > 0 {
+		// Only names are logged, as some settings are credentials
+		f.logger.Info("WARNING: settings set on the command line or needing a restart were not reloaded", "config_file", f.path, "settings", strings.Join(ignored, ", "))
+	}
+
+	cfg := f.cfg
+	for name, value := range changed {
+		switch name {
+		case logLevelFlag:
+			if f.logLevel == nil {
+				return fmt.Errorf("%s can't be reloaded with this logger", name)
+			}
+			if err := f.logLevel.Validate(value); err != nil {
+				return err
+			}
+			cfg.LogLevel = value
+		case vaultMetricsPathLabelsFlag:
+			cfg.VaultMetricsPathLabels, err = strconv.ParseBool(value)
+		case vaultCacheTTLFlag:
+			cfg.VaultCacheTTL, err = time.ParseDuration(value)
+		case vaultTokenPollingJitterFlag:
+			cfg.VaultTokenPollingJitter, err = strconv.Atoi(value)
+		}
+		if err != nil {
+			return fmt.Errorf("invalid %s: %v", name, err)
+		}
+	}
+
+	backendChanged := false
+	for name := range changed {
+		backendChanged = backendChanged || name != logLevelFlag
+	}
+	if backendChanged {
+		reloader, ok := f.backend.(backend.Reloader)
+		if !ok {
+			return fmt.Errorf("the backend settings can't be reloaded with this backend")
+		}
+		if err := reloader.Reload(cfg); err != nil {
+			return err
+		}
+	}
+	if _, ok := changed[logLevelFlag]; ok {
+		f.logLevel.Set(cfg.LogLevel)
+	}
+
+	f.cfg = cfg
+	diff := make([]string, 0, len(changed))
+	for name, value := range changed {
+		diff = append(diff, fmt.Sprintf("%s: %s -> %s", name, f.applied[name], value))
+		f.applied[name] = value
+	}
+	sort.Strings(diff)
+	f.logger.Info("config file reloaded", "config_file", f.path, "changes", strings.Join(diff, ", "))
+	return nil
+}
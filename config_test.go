@@ -0,0 +1,97 @@
+package main
+
+import (
+	"flag"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/tuenti/secrets-manager/backend"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+func newConfigTestFlags() (*flag.FlagSet, *backend.Config) {
+	cfg := &backend.Config{}
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	fs.StringVar(&cfg.LogLevel, logLevelFlag, "", "")
+	fs.StringVar(&cfg.VaultURL, "vault.url", "https://127.0.0.1:8200", "")
+	fs.DurationVar(&cfg.VaultCacheTTL, vaultCacheTTLFlag, 0, "")
+	fs.IntVar(&cfg.VaultMaxRetries, "vault.max-retries", 2, "")
+	fs.IntVar(&cfg.VaultTokenPollingJitter, vaultTokenPollingJitterFlag, 0, "")
+	return fs, cfg
+}
+
+func writeConfigFile(t *testing.T, path string, content string) {
+	assert.Nil(t, ioutil.WriteFile(path, []byte(content), 0600))
+}
+
+func TestLoadConfigFile(t *testing.T) {
+	file, err := ioutil.TempFile("", "config")
+	assert.Nil(t, err)
+	defer os.Remove(file.Name())
+	writeConfigFile(t, file.Name(), "log-level: warn\nvault.url: https://vault:8200\nvault.max-retries: 5\n")
+
+	fs, cfg := newConfigTestFlags()
+	assert.Nil(t, fs.Parse([]string{"-vault.url=https://other:8200"}))
+	_, err = loadConfigFile(fs, file.Name())
+	assert.Nil(t, err)
+	assert.Equal(t, "warn", cfg.LogLevel)
+	assert.Equal(t, 5, cfg.VaultMaxRetries)
+	// The command line takes precedence
+	assert.Equal(t, "https://other:8200", cfg.VaultURL)
+
+	writeConfigFile(t, file.Name(), "vault.unknown: true\n")
+	_, err = loadConfigFile(fs, file.Name())
+	assert.Contains(t, err.Error(), "unknown flag vault.unknown")
+	writeConfigFile(t, file.Name(), "vault.max-retries: many\n")
+	_, err = loadConfigFile(fs, file.Name())
+	assert.Contains(t, err.Error(), "invalid vault.max-retries")
+}
+
+func TestConfigFileReloadLogLevel(t *testing.T) {
+	file, err := ioutil.TempFile("", "config")
+	assert.Nil(t, err)
+	defer os.Remove(file.Name())
+	writeConfigFile(t, file.Name(), "log-level: info\nvault.url: https://vault:8200\n")
+
+	fs, cfg := newConfigTestFlags()
+	assert.Nil(t, fs.Parse(nil))
+	f, err := loadConfigFile(fs, file.Name())
+	assert.Nil(t, err)
+	_, level, err := backend.NewReloadableLogger(*cfg, false)
+	assert.Nil(t, err)
+	f.reloadsTotal = newConfigReloadsTotal("")
+	f.cfg, f.backend, f.logLevel, f.logger = *cfg, nil, level, logf.NullLogger{}
+
+	// Connection settings are ignored until a restart
+	writeConfigFile(t, file.Name(), "log-level: debug\nvault.url: https://other:8200\n")
+	assert.Nil(t, f.reload())
+	assert.Equal(t, "debug", level.String())
+	assert.Equal(t, "debug", f.cfg.LogLevel)
+	assert.Equal(t, "https://vault:8200", f.applied["vault.url"])
+	assert.Equal(t, 1.0, testutil.ToFloat64(f.reloadsTotal.WithLabelValues(configReloadSuccess)))
+
+	// Removed settings go back to their defaults
+	writeConfigFile(t, file.Name(), "vault.url: https://vault:8200\n")
+	assert.Nil(t, f.reload())
+	assert.Equal(t, "info", level.String())
+
+	// The cache TTL needs a restart when the cache is disabled
+	writeConfigFile(t, file.Name(), "vault.cache-ttl: 1m\n")
+	assert.Nil(t, f.reload())
+	assert.Equal(t, "0s", f.applied[vaultCacheTTLFlag])
+	assert.Equal(t, time.Duration(0), f.cfg.VaultCacheTTL)
+
+	// Nothing is applied when any setting can't be, e.g. backend settings with a backend unable to reload them
+	writeConfigFile(t, file.Name(), "log-level: debug\nvault.token-polling-jitter: 10\n")
+	assert.NotNil(t, f.reload())
+	assert.Equal(t, "info", level.String())
+	assert.Equal(t, 1.0, testutil.ToFloat64(f.reloadsTotal.WithLabelValues(configReloadError)))
+
+	writeConfigFile(t, file.Name(), "log-level: verbose\n")
+	assert.NotNil(t, f.reload())
+	assert.Equal(t, "info", level.String())
+}
@@ -0,0 +1,132 @@
+package controllers
+
+import (
+	"context"
+	"net"
+	"sort"
+
+	smv1alpha1 "github.com/tuenti/secrets-manager/api/v1alpha1"
+	"github.com/tuenti/secrets-manager/backend"
+	smerrors "github.com/tuenti/secrets-manager/errors"
+)
+
+const (
+	dryRunOutcomeResolved    = "resolved"
+	dryRunOutcomeMissing     = "missing"
+	dryRunOutcomeSkipped     = "skipped"
+	dryRunOutcomeError       = "error"
+	dryRunOutcomeUnreachable = "unreachable"
+)
+
+// DryRunResult summarizes how the keys of a SecretDefinition resolve against the backend
+type DryRunResult struct {
+	Namespace    string   `json:"namespace"`
+	Name         string   `json:"name"`
+	ResolvedKeys []string `json:"resolvedKeys"`
+	MissingKeys  []string `json:"missingKeys"`
+	// SkippedKeys are optional keys missing in the backend, they don't make the SecretDefinition fail
+	SkippedKeys []string `json:"skippedKeys"`
+	// UnreachableKeys couldn't be resolved because the backend couldn't be reached, rather than because of the key itself
+	UnreachableKeys []string          `json:"unreachableKeys"`
+	Errors          map[string]string `json:"errors"`
+}
+
+// OK returns true if all the keys of the SecretDefinition were resolved
+func (d DryRunResult) OK() bool {
+	return len(d.MissingKeys) == 0 && len(d.UnreachableKeys) == 0 && len(d.Errors) == 0
+}
+
+// isBackendUnreachable returns true for errors reaching the backend, as opposed to errors resolving a given key
+func isBackendUnreachable(err error) bool {
+	if smerrors.IsVaultTimeout(err) || smerrors.IsVaultSealed(err) {
+		return true
+	}
+	_, ok := err.(net.Error)
+	return ok
+}
+
+// Check resolves every key of a SecretDefinition against the backend without writing anything, e.g. to
+// validate SecretDefinitions out of the controller loop
+func (r *SecretDefinitionReconciler) Check(sDef *smv1alpha1.SecretDefinition) DryRunResult {
+	return r.dryRun(sDef)
+}
+
+// dryRun reads and decodes every key of a SecretDefinition, carrying on after failures so that
+// the summary reports all the keys that can't be resolved at once
+func (r *SecretDefinitionReconciler) dryRun(sDef *smv1alpha1.SecretDefinition) DryRunResult {
+	result := DryRunResult{
+		Namespace:       sDef.Namespace,
+		Name:            sDef.Spec.Name,
+		ResolvedKeys:    []string{},
+		MissingKeys:     []string{},
+		SkippedKeys:     []string{},
+		UnreachableKeys: []string{},
+		Errors:          map[string]string{},
+	}
+	ctx := backend.WithSharedReads(context.Background())
+	if sDef.Spec.VaultRole != "" {
+		ctx = backend.WithVaultRole(ctx, sDef.Spec.VaultRole, sDef.Namespace+"/"+sDef.Name)
+	}
+	if sDef.Spec.VaultNamespace != "" {
+		ctx = backend.WithVaultNamespace(ctx, sDef.Spec.VaultNamespace)
+	}
+	secretKeys := map[string]bool{}
+	for k, v := range sDef.Spec.KeysMap {
+		var secrets map[string]string
+		var err error
+		v.Path, err = r.PathPrefixes.Resolve(sDef.Namespace, v.Path)
+		if err == nil {
+			secrets, err = r.readDataSourceKeys(ctx, k, v)
+		}
+		if err == nil {
+			var decoder backend.Decoder
+			decoder, err = backend.NewDecoder(v.Encoding)
+			for secretKey, bSecret := range secrets {
+				if err != nil {
+					break
+				}
+				if secretKeys[secretKey] {
+					err = &smerrors.SecretKeyCollisionError{ErrType: smerrors.SecretKeyCollisionErrorType, Key: secretKey, Path: v.Path}
+					break
+				}
+				secretKeys[secretKey] = true
+				var data []byte
+				data, err = decoder.DecodeString(bSecret)
+				if err == nil {
+					_, err = backend.Transform(v.Transform, data)
+				}
+			}
+		}
+		switch {
+		case err == nil:
+			result.ResolvedKeys = append(result.ResolvedKeys, k)
+		case smerrors.IsBackendSecretNotFound(err) && v.Optional:
+			result.SkippedKeys = append(result.SkippedKeys, k)
+		case smerrors.IsBackendSecretNotFound(err):
+			result.MissingKeys = append(result.MissingKeys, k)
+		case isBackendUnreachable(err):
+			result.UnreachableKeys = append(result.UnreachableKeys, k)
+			result.Errors[k] = err.Error()
+		default:
+			result.Errors[k] = err.Error()
+		}
+	}
+	sort.Strings(result.ResolvedKeys)
+	sort.Strings(result.MissingKeys)
+	sort.Strings(result.SkippedKeys)
+	sort.Strings(result.UnreachableKeys)
+
+	dryRunKeys.WithLabelValues(sDef.Namespace, sDef.Name, dryRunOutcomeResolved).Set(float64(len(result.ResolvedKeys)))
+	dryRunKeys.WithLabelValues(sDef.Namespace, sDef.Name, dryRunOutcomeMissing).Set(float64(len(result.MissingKeys)))
+	dryRunKeys.WithLabelValues(sDef.Namespace, sDef.Name, dryRunOutcomeSkipped).Set(float64(len(result.SkippedKeys)))
+	dryRunKeys.WithLabelValues(sDef.Namespace, sDef.Name, dryRunOutcomeError).Set(float64(len(result.Errors) - len(result.UnreachableKeys)))
+	dryRunKeys.WithLabelValues(sDef.Namespace, sDef.Name, dryRunOutcomeUnreachable).Set(float64(len(result.UnreachableKeys)))
+	return result
+}
+
+// deleteDryRunKeys removes the dry-run key counts of a SecretDefinition no longer validated
+func deleteDryRunKeys(namespace string, name string) {
+	for _, outcome := range []string{dryRunOutcomeResolved, dryRunOutcomeMissing, dryRunOutcomeSkipped, dryRunOutcomeError, dryRunOutcomeUnreachable} {
+		dryRunKeys.DeleteLabelValues(namespace, name, outcome)
+	}
+}
@@ -0,0 +1,50 @@
+package controllers
+
+import (
+	"context"
+	"strings"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+
+	smv1alpha1 "github.com/tuenti/secrets-manager/api/v1alpha1"
+	"github.com/tuenti/secrets-manager/backend"
+	smerrors "github.com/tuenti/secrets-manager/errors"
+)
+
+// watchSecretEvents sends an event for every SecretDefinition syncing a path as soon as the backend notifies
+// it changed, until stop is closed. Backends that can't notify changes are left to polling
+func (r *SecretDefinitionReconciler) watchSecretEvents(watcher backend.EventWatcher, events chan<- event.GenericEvent, stop <-chan struct{}) error {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		<-stop
+		cancel()
+	}()
+
+	err := watcher.WatchSecretEvents(ctx, func(path string) {
+		for _, definition := range syncedPaths.definitionsOf(path) {
+			secretEventsTotal.Inc()
+			select {
+			case events <- secretDefinitionEvent(definition):
+			case <-stop:
+				return
+			}
+		}
+	})
+	if smerrors.IsBackendNotImplemented(err) {
+		r.Log.Info("backend can't notify secret changes, they are picked up by polling")
+		return nil
+	}
+	return err
+}
+
+// secretDefinitionEvent returns the event enqueuing the SecretDefinition namespace/name
+func secretDefinitionEvent(definition string) event.GenericEvent {
+	namespace, name := "", definition
+	if i := strings.Index(definition, "/"); i >= 0 {
+		namespace, name = definition[:i], definition[i+1:]
+	}
+	sDef := &smv1alpha1.SecretDefinition{ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: name}}
+	return event.GenericEvent{Meta: sDef, Object: sDef}
+}
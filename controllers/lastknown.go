@@ -0,0 +1,98 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"k8s.io/apimachinery/pkg/types"
+
+	smv1alpha1 "github.com/tuenti/secrets-manager/api/v1alpha1"
+	smerrors "github.com/tuenti/secrets-manager/errors"
+)
+
+// lastKnownValues keeps the values last read for the datasources of every SecretDefinition, so they can be
+// synced again while the backend is failing
+type lastKnownValues struct {
+	mutex sync.Mutex
+	// values holds the values of every datasource, by SecretDefinition namespace/name and datasource
+	values map[string]map[string]map[string]string
+}
+
+// lastKnown holds the values last read for every SecretDefinition, when LastKnownGood is set
+var lastKnown = newLastKnownValues()
+
+func newLastKnownValues() *lastKnownValues {
+	return &lastKnownValues{values: make(map[string]map[string]map[string]string)}
+}
+
+// dataSourceKey identifies a datasource and the way it's read, so values read before it was changed are never used
+func dataSourceKey(name string, v smv1alpha1.DataSource) string {
+	return fmt.Sprintf("%s\x00%s\x00%s\x00%s\x00%t\x00%v", name, v.Path, v.Key, v.Template, v.AllKeys, v.Remap)
+}
+
+func (l *lastKnownValues) get(definition string, dataSource string) (map[string]string, bool) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	values, ok := l.values[definition][dataSource]
+	return values, ok
+}
+
+func (l *lastKnownValues) set(definition string, dataSource string, values map[string]string) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	if l.values[definition] == nil {
+		l.values[definition] = make(map[string]map[string]string)
+	}
+	l.values[definition][dataSource] = values
+}
+
+// remove forgets the values of a SecretDefinition, e.g. once deleted
+func (l *lastKnownValues) remove(definition string) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	delete(l.values, definition)
+}
+
+// isTransientError returns true for backend errors expected to go away by themselves, e.g. Vault being
+// sealed or unreachable. Secrets not found, denied reads or invalid datasources are not transient
+func isTransientError(err error) bool {
+	switch smerrors.GetErrorType(err) {
+	case smerrors.VaultSealedErrorType, smerrors.VaultTimeoutErrorType, smerrors.VaultCircuitOpenErrorType, smerrors.UnknownErrorType:
+		return true
+	}
+	return false
+}
+
+type definitionKey struct{}
+
+// withDefinition returns a copy of ctx with the SecretDefinition its reads are made for, so the values last read
+// for it can be used when the backend fails
+func withDefinition(ctx context.Context, definition types.NamespacedName) context.Context {
+	return context.WithValue(ctx, definitionKey{}, definition)
+}
+
+func definitionFromContext(ctx context.Context) (types.NamespacedName, bool) {
+	definition, ok := ctx.Value(definitionKey{}).(types.NamespacedName)
+	return definition, ok
+}
+
+// lastKnownDataSourceKeys returns the values last read for a datasource of the SecretDefinition of ctx, when
+// LastKnownGood is set and reading it failed with a transient error
+func (r *SecretDefinitionReconciler) lastKnownDataSourceKeys(ctx context.Context, name string, v smv1alpha1.DataSource, err error) (map[string]string, bool) {
+	definition, ok := definitionFromContext(ctx)
+	if !r.LastKnownGood || !ok || !isTransientError(err) {
+		return nil, false
+	}
+	return lastKnown.get(definition.String(), dataSourceKey(name, v))
+}
+
+// rememberDataSourceKeys keeps the values read for a datasource of the SecretDefinition of ctx, when
+// LastKnownGood is set. Response-wrapped values are single use tokens and are never kept
+func (r *SecretDefinitionReconciler) rememberDataSourceKeys(ctx context.Context, name string, v smv1alpha1.DataSource, values map[string]string) {
+	definition, ok := definitionFromContext(ctx)
+	if !r.LastKnownGood || !ok || v.WrapTTL != "" {
+		return
+	}
+	lastKnown.set(definition.String(), dataSourceKey(name, v), values)
+}
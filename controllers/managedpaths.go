@@ -0,0 +1,78 @@
+package controllers
+
+import (
+	"sort"
+	"strings"
+	"sync"
+
+	smv1alpha1 "github.com/tuenti/secrets-manager/api/v1alpha1"
+)
+
+// managedPaths tracks the backend paths synced by every SecretDefinition, so the number of distinct paths can be
+// reported no matter how many SecretDefinitions share them
+type managedPaths struct {
+	mutex sync.Mutex
+	// definitions holds the paths of every SecretDefinition, by namespace/name
+	definitions map[string]map[string]bool
+	// paths holds the number of SecretDefinitions syncing every path
+	paths map[string]int
+}
+
+func newManagedPaths() *managedPaths {
+	return &managedPaths{
+		definitions: make(map[string]map[string]bool),
+		paths:       make(map[string]int),
+	}
+}
+
+// set replaces the paths synced by a SecretDefinition with the paths of keysMap and returns the number of
+// distinct paths managed
+func (m *managedPaths) set(definition string, keysMap map[string]smv1alpha1.DataSource) int {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.removeLocked(definition)
+	paths := make(map[string]bool, len(keysMap))
+	for _, v := range keysMap {
+		p := strings.Trim(v.Path, "/")
+		if !paths[p] {
+			paths[p] = true
+			m.paths[p]++
+		}
+	}
+	m.definitions[definition] = paths
+	return len(m.paths)
+}
+
+// remove forgets the paths synced by a SecretDefinition, e.g. once it's deleted, and returns the number of
+// distinct paths managed
+func (m *managedPaths) remove(definition string) int {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.removeLocked(definition)
+	return len(m.paths)
+}
+
+// definitionsOf returns the SecretDefinitions syncing path, by namespace/name
+func (m *managedPaths) definitionsOf(path string) []string {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	path = strings.Trim(path, "/")
+	definitions := []string{}
+	for definition, paths := range m.definitions {
+		if paths[path] {
+			definitions = append(definitions, definition)
+		}
+	}
+	sort.Strings(definitions)
+	return definitions
+}
+
+func (m *managedPaths) removeLocked(definition string) {
+	for p := range m.definitions[definition] {
+		m.paths[p]--
+		if m.paths[p] <= 0 {
+			delete(m.paths, p)
+		}
+	}
+	delete(m.definitions, definition)
+}
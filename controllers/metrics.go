@@ -29,6 +29,63 @@ var (
 		Name:      "last_sync_status",
 		Help:      "The result of the last sync of a secret. 1 = OK, 0 = Error",
 	}, []string{"namespace", "name"})
+
+	secretReconcilesTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "secrets_manager",
+		Subsystem: "controller",
+		Name:      "reconciles_total",
+		Help:      "Successful secrets reconciliations. outcome is applied when the secret was written and skipped when it was up to date",
+	}, []string{"namespace", "name", "outcome"})
+
+	dryRunKeys = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "secrets_manager",
+		Subsystem: "controller",
+		Name:      "dry_run_keys",
+		Help:      "Number of keys of a SecretDefinition by dry-run resolution outcome: resolved, missing, skipped, error or unreachable",
+	}, []string{"namespace", "name", "outcome"})
+
+	optionalKeysSkippedTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "secrets_manager",
+		Subsystem: "controller",
+		Name:      "optional_keys_skipped_total",
+		Help:      "Optional keys left out of a secret because they were missing in the backend",
+	}, []string{"namespace", "name"})
+
+	managedPathsCount = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: "secrets_manager",
+		Subsystem: "controller",
+		Name:      "managed_paths",
+		Help:      "Distinct backend paths synced by SecretDefinitions",
+	})
+
+	backendReadsTotal = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: "secrets_manager",
+		Subsystem: "controller",
+		Name:      "backend_reads_total",
+		Help:      "Datasources read from the backend by reconciliations",
+	})
+
+	secretEventsTotal = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: "secrets_manager",
+		Subsystem: "controller",
+		Name:      "secret_events_total",
+		Help:      "SecretDefinitions re-synced because the backend notified one of their paths changed",
+	})
+
+	degraded = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "secrets_manager",
+		Subsystem: "controller",
+		Name:      "degraded",
+		Help:      "Datasources of a secret synced with the values last read because the backend failed with a transient error, when last-known-good is enabled",
+	}, []string{"namespace", "name"})
+
+	// syncedPaths holds the backend paths synced by every SecretDefinition
+	syncedPaths = newManagedPaths()
+)
+
+const (
+	reconcileOutcomeApplied = "applied"
+	reconcileOutcomeSkipped = "skipped"
 )
 
 func init() {
@@ -36,4 +93,11 @@ func init() {
 	r.MustRegister(secretReadErrorsTotal)
 	r.MustRegister(secretSyncErrorsTotal)
 	r.MustRegister(secretLastSyncStatus)
+	r.MustRegister(secretReconcilesTotal)
+	r.MustRegister(dryRunKeys)
+	r.MustRegister(optionalKeysSkippedTotal)
+	r.MustRegister(managedPathsCount)
+	r.MustRegister(backendReadsTotal)
+	r.MustRegister(secretEventsTotal)
+	r.MustRegister(degraded)
 }
@@ -0,0 +1,108 @@
+package controllers
+
+import (
+	"bytes"
+	"fmt"
+	"path"
+	"strings"
+	"text/template"
+
+	smv1alpha1 "github.com/tuenti/secrets-manager/api/v1alpha1"
+	smerrors "github.com/tuenti/secrets-manager/errors"
+)
+
+// PathPrefixes makes the paths of SecretDefinitions relative to a backend path prefix of their namespace,
+// so tenants can only read secrets under their own prefix
+type PathPrefixes struct {
+	prefixes map[string]string
+	template *template.Template
+}
+
+// pathPrefixData is the data the path prefix template is executed with
+type pathPrefixData struct {
+	Namespace string
+}
+
+// NewPathPrefixes returns the PathPrefixes of the namespaces in prefixes. The prefix of other namespaces is
+// prefixTemplate executed with the namespace, e.g. secret/data/{{.Namespace}}. Without a template, the paths
+// of the namespaces not in prefixes are used as they are
+func NewPathPrefixes(prefixes map[string]string, prefixTemplate string) (*PathPrefixes, error) {
+	p := &PathPrefixes{prefixes: prefixes}
+	if prefixTemplate != "" {
+		t, err := template.New("path-prefix").Option("missingkey=error").Parse(prefixTemplate)
+		if err != nil {
+			return nil, err
+		}
+		p.template = t
+	}
+	return p, nil
+}
+
+// prefix returns the path prefix of a namespace, or an empty string if its paths are used as they are
+func (p *PathPrefixes) prefix(namespace string) (string, error) {
+	if prefix, ok := p.prefixes[namespace]; ok {
+		return prefix, nil
+	}
+	if p.template == nil {
+		return "", nil
+	}
+	var prefix bytes.Buffer
+	if err := p.template.Execute(&prefix, pathPrefixData{Namespace: namespace}); err != nil {
+		return "", err
+	}
+	return prefix.String(), nil
+}
+
+// Resolve returns the backend path of a path relative to the prefix of namespace. Paths with .. segments
+// are rejected, as they could escape the prefix
+func (p *PathPrefixes) Resolve(namespace string, relativePath string) (string, error) {
+	if p == nil {
+		return relativePath, nil
+	}
+	prefix, err := p.prefix(namespace)
+	if err != nil {
+		return "", &smerrors.SecretPathError{ErrType: smerrors.SecretPathErrorType, Namespace: namespace, Path: relativePath, Reason: err.Error()}
+	}
+	if prefix == "" {
+		return relativePath, nil
+	}
+	for _, segment := range strings.Split(relativePath, "/") {
+		if segment == ".." {
+			return "", &smerrors.SecretPathError{ErrType: smerrors.SecretPathErrorType, Namespace: namespace, Path: relativePath, Reason: "it escapes the namespace path prefix " + prefix}
+		}
+	}
+	return strings.TrimRight(prefix, "/") + path.Clean("/"+relativePath), nil
+}
+
+// resolveKeysMap returns a copy of keysMap with the paths resolved against the path prefix of namespace
+func (r *SecretDefinitionReconciler) resolveKeysMap(namespace string, keysMap map[string]smv1alpha1.DataSource) (map[string]smv1alpha1.DataSource, error) {
+	if r.PathPrefixes == nil {
+		return keysMap, nil
+	}
+	resolved := make(map[string]smv1alpha1.DataSource, len(keysMap))
+	for k, v := range keysMap {
+		p, err := r.PathPrefixes.Resolve(namespace, v.Path)
+		if err != nil {
+			return nil, err
+		}
+		v.Path = p
+		resolved[k] = v
+	}
+	return resolved, nil
+}
+
+// ParsePathPrefixes parses a comma-separated list of namespace=prefix pairs, e.g. team-a=secret/data/team-a
+func ParsePathPrefixes(value string) (map[string]string, error) {
+	prefixes := map[string]string{}
+	if value == "" {
+		return prefixes, nil
+	}
+	for _, pair := range strings.Split(value, ",") {
+		parts := strings.SplitN(strings.TrimSpace(pair), "=", 2)
+		if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+			return nil, fmt.Errorf("invalid path prefix %q, expected namespace=prefix", pair)
+		}
+		prefixes[parts[0]] = parts[1]
+	}
+	return prefixes, nil
+}
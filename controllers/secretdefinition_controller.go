@@ -19,17 +19,25 @@ import (
 	"context"
 	"fmt"
 	"reflect"
+	"sort"
 	"time"
 
 	"github.com/go-logr/logr"
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/uuid"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+	"sigs.k8s.io/controller-runtime/pkg/source"
 
 	smv1alpha1 "github.com/tuenti/secrets-manager/api/v1alpha1"
 	"github.com/tuenti/secrets-manager/backend"
+	smerrors "github.com/tuenti/secrets-manager/errors"
+	"github.com/tuenti/secrets-manager/leader"
 )
 
 const (
@@ -38,6 +46,8 @@ const (
 	finalizerName   = "secret.finalizer." + smv1alpha1.Group
 	managedByLabel  = "app.kubernetes.io/managed-by"
 	lastUpdateLabel = smv1alpha1.Group + "/lastUpdateTime"
+	// secretHashAnnotation holds the hash of the secret data, so consumers can tell when the content changes
+	secretHashAnnotation = smv1alpha1.Group + "/secret-hash"
 )
 
 // SecretDefinitionReconciler reconciles a SecretDefinition object
@@ -49,6 +59,22 @@ type SecretDefinitionReconciler struct {
 	APIReader            client.Reader
 	ReconciliationPeriod time.Duration
 	ExcludeNamespaces    map[string]bool
+	// DryRun makes the reconciler resolve the keys of SecretDefinitions without writing anything to Kubernetes
+	DryRun bool
+	// Leader, when set, makes standby replicas requeue SecretDefinitions without reconciling them
+	Leader leader.Checker
+	// PathPrefixes, when set, makes the paths of SecretDefinitions relative to a path prefix of their namespace
+	PathPrefixes *PathPrefixes
+	// HashAlgorithm, when set, annotates secrets with the hash of their data, and they are written whenever it changes
+	HashAlgorithm string
+	// RequestIDs makes every reconciliation send a request ID along with its backend reads, and log it
+	RequestIDs bool
+	// Events makes SecretDefinitions sync as soon as the backend notifies one of their paths changed, on top
+	// of the periodic reconciliations
+	Events bool
+	// LastKnownGood makes reconciliations keep the values last read for datasources failing with transient
+	// backend errors, instead of failing the sync
+	LastKnownGood bool
 }
 
 // Annotations to skip when copying from a SecretDef to a Secret
@@ -136,35 +162,162 @@ func isNotMarkedForRemoval(sDef smv1alpha1.SecretDefinition) bool {
 	return sDef.ObjectMeta.DeletionTimestamp.IsZero()
 }
 
-// getDesiredState reads the content from the Datasource for later comparison
-func (r *SecretDefinitionReconciler) getDesiredState(keysMap map[string]smv1alpha1.DataSource) (map[string][]byte, error) {
+// readDataSource reads the value of a datasource from the backend, rendering its template if it has one.
+// ctx is used when the backend can read with a context
+func (r *SecretDefinitionReconciler) readDataSource(ctx context.Context, v smv1alpha1.DataSource) (string, error) {
+	if backend.VaultRoleFromContext(ctx) != "" || backend.VaultNamespaceFromContext(ctx) != "" {
+		// Reads must never fall back to the controller token or namespace when a role or namespace is set
+		reader, ok := r.Backend.(backend.ContextReader)
+		if !ok || v.WrapTTL != "" || v.Template != "" {
+			return "", fmt.Errorf("vault roles and namespaces are only supported reading keys from the vault backend")
+		}
+		return reader.ReadSecretWithContext(ctx, v.Path, v.Key)
+	}
+	if v.WrapTTL != "" {
+		reader, ok := r.Backend.(backend.WrappedReader)
+		if !ok {
+			return "", fmt.Errorf("backend can't read secrets response-wrapped")
+		}
+		return reader.ReadSecretWrapped(v.Path, v.WrapTTL)
+	}
+	if v.Template != "" {
+		return backend.RenderTemplateWithContext(ctx, r.Backend, v.Path, v.Template)
+	}
+	if reader, ok := r.Backend.(backend.ContextReader); ok {
+		return reader.ReadSecretWithContext(ctx, v.Path, v.Key)
+	}
+	return r.Backend.ReadSecret(v.Path, v.Key)
+}
+
+// readDataSourceKeys reads the values a datasource stores in the secret by their secret key: the value of the
+// datasource named as its keysMap entry, or every key of its path, renamed with remap, when allKeys is set
+func (r *SecretDefinitionReconciler) readDataSourceKeys(ctx context.Context, name string, v smv1alpha1.DataSource) (map[string]string, error) {
+	backendReadsTotal.Inc()
+	if !v.AllKeys {
+		value, err := r.readDataSource(ctx, v)
+		if err != nil {
+			return nil, err
+		}
+		return map[string]string{name: value}, nil
+	}
+	reader, ok := r.Backend.(backend.AllKeysReader)
+	if !ok {
+		return nil, fmt.Errorf("backend can't read all the keys of a secret")
+	}
+	var data map[string]string
+	var err error
+	if ctxReader, ok := r.Backend.(backend.AllKeysContextReader); ok {
+		data, err = ctxReader.ReadSecretAllKeysWithContext(ctx, v.Path)
+	} else if backend.VaultRoleFromContext(ctx) != "" {
+		return nil, fmt.Errorf("backend can't read all the keys of a secret with a vault role")
+	} else if backend.VaultNamespaceFromContext(ctx) != "" {
+		return nil, fmt.Errorf("backend can't read all the keys of a secret in a vault namespace")
+	} else {
+		data, err = reader.ReadSecretAllKeys(v.Path)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return remapKeys(v.Path, data, v.Remap)
+}
+
+// remapKeys renames the keys read from path found in remap, leaving the others as they are. Two keys renamed
+// to the same secret key are a SecretKeyCollisionError
+func remapKeys(path string, data map[string]string, remap map[string]string) (map[string]string, error) {
+	keys := make(map[string]string, len(data))
+	for k, value := range data {
+		if to, ok := remap[k]; ok {
+			k = to
+		}
+		if _, ok := keys[k]; ok {
+			return nil, &smerrors.SecretKeyCollisionError{ErrType: smerrors.SecretKeyCollisionErrorType, Key: k, Path: path}
+		}
+		keys[k] = value
+	}
+	return keys, nil
+}
+
+// readContext returns the context of the backend reads of a reconciliation, carrying a new request ID
+// when request IDs are enabled. Datasources reading keys of the same path share a single read
+func (r *SecretDefinitionReconciler) readContext() (context.Context, string) {
+	ctx := backend.WithSharedReads(context.Background())
+	if !r.RequestIDs {
+		return ctx, ""
+	}
+	requestID := string(uuid.NewUUID())
+	return backend.WithRequestID(ctx, requestID), requestID
+}
+
+// getDesiredState reads the content from the Datasource for later comparison. Optional keys missing in the
+// backend are left out and returned as skipped, any other error fails unless LastKnownGood is set and the
+// error is transient, then the values last read are used. Datasources storing the same secret key are a
+// SecretKeyCollisionError
+func (r *SecretDefinitionReconciler) getDesiredState(ctx context.Context, keysMap map[string]smv1alpha1.DataSource) (map[string][]byte, []string, error) {
 	desiredState := make(map[string][]byte)
+	skipped := []string{}
+	stale := []string{}
 	var err error
 	for k, v := range keysMap {
-		bSecret, err := r.Backend.ReadSecret(v.Path, v.Key)
+		secrets, err := r.readDataSourceKeys(ctx, k, v)
+		if err != nil && v.Optional && smerrors.IsBackendSecretNotFound(err) {
+			skipped = append(skipped, k)
+			continue
+		}
 		if err != nil {
-			r.Log.Error(err, "unable to read secret from backend", "path", v.Path, "key", v.Key)
-			return nil, err
+			lastKnownSecrets, ok := r.lastKnownDataSourceKeys(ctx, k, v, err)
+			if !ok {
+				r.Log.Error(err, "unable to read secret from backend", "path", v.Path, "key", v.Key, "error_type", smerrors.GetErrorType(err))
+				return nil, nil, err
+			}
+			r.Log.Info("WARNING: unable to read secret from backend, using the values last read", "path", v.Path, "key", v.Key, "error", err.Error())
+			secrets = lastKnownSecrets
+			stale = append(stale, k)
+		} else {
+			r.rememberDataSourceKeys(ctx, k, v, secrets)
 		}
 		decoder, err := backend.NewDecoder(v.Encoding)
 		if err != nil {
 			r.Log.Error(err, "refusing to use encoding", "encoding", v.Encoding)
-			return nil, err
+			return nil, nil, err
 		}
-		desiredState[k], err = decoder.DecodeString(bSecret)
-		if err != nil {
-			r.Log.Error(err, "unable to decode data for secret", "encoding", v.Encoding, "path", v.Path, "key", v.Key)
-			return nil, err
+		for secretKey, bSecret := range secrets {
+			if _, ok := desiredState[secretKey]; ok {
+				err = &smerrors.SecretKeyCollisionError{ErrType: smerrors.SecretKeyCollisionErrorType, Key: secretKey, Path: v.Path}
+				r.Log.Error(err, "secret key set by several datasources", "secret_key", secretKey, "path", v.Path)
+				return nil, nil, err
+			}
+			desiredState[secretKey], err = decoder.DecodeString(bSecret)
+			if err != nil {
+				r.Log.Error(err, "unable to decode data for secret", "encoding", v.Encoding, "path", v.Path, "key", v.Key)
+				return nil, nil, err
+			}
+			desiredState[secretKey], err = backend.Transform(v.Transform, desiredState[secretKey])
+			if err != nil {
+				r.Log.Error(err, "unable to transform data for secret", "transform", v.Transform, "path", v.Path, "key", v.Key)
+				return nil, nil, err
+			}
 		}
 	}
-	return desiredState, err
+	if definition, ok := definitionFromContext(ctx); ok && r.LastKnownGood {
+		degraded.WithLabelValues(definition.Namespace, definition.Name).Set(float64(len(stale)))
+	}
+	sort.Strings(skipped)
+	return desiredState, skipped, err
 }
 
 // getCurrentState reads the content from the Kubernetes Secret API object for later comparison
 func (r *SecretDefinitionReconciler) getCurrentState(namespace string, name string) (map[string][]byte, error) {
+	secret, err := r.getCurrentSecret(namespace, name)
+	if err != nil {
+		return make(map[string][]byte), err
+	}
+	return secret.Data, nil
+}
+
+// getCurrentSecret reads the Kubernetes Secret API object
+func (r *SecretDefinitionReconciler) getCurrentSecret(namespace string, name string) (*corev1.Secret, error) {
 	// We don't read secrets from cache, as it's not the object we reconcile
 	reader := r.APIReader
-	data := make(map[string][]byte)
 	secret := &corev1.Secret{}
 	err := reader.Get(r.Ctx, client.ObjectKey{
 		Namespace: namespace,
@@ -172,15 +325,34 @@ func (r *SecretDefinitionReconciler) getCurrentState(namespace string, name stri
 	}, secret)
 	if err != nil {
 		secretReadErrorsTotal.WithLabelValues(name, namespace).Inc()
-		return data, err
+		return secret, err
+	}
+	return secret, nil
+}
+
+// upToDate tells whether the current secret holds the desired data and, when secrets are hashed, the
+// hash annotation of the desired data
+func upToDate(current *corev1.Secret, desiredState map[string][]byte, desiredHash string) bool {
+	if desiredHash != "" && current.Annotations[secretHashAnnotation] != desiredHash {
+		return false
+	}
+	currentState := current.Data
+	if currentState == nil {
+		currentState = make(map[string][]byte)
 	}
-	data = secret.Data
-	return data, err
+	return reflect.DeepEqual(desiredState, currentState)
 }
 
 // upsertSecret will create or update a secret
 func (r *SecretDefinitionReconciler) upsertSecret(sDef *smv1alpha1.SecretDefinition, data map[string][]byte) error {
 	secret := getSecretFromSecretDefinition(sDef, data)
+	if r.HashAlgorithm != "" {
+		hash, err := backend.HashSecret(r.HashAlgorithm, data)
+		if err != nil {
+			return err
+		}
+		secret.Annotations[secretHashAnnotation] = hash
+	}
 	err := r.Create(r.Ctx, secret)
 	if errors.IsAlreadyExists(err) {
 		err = r.Update(r.Ctx, secret)
@@ -222,11 +394,26 @@ func (r *SecretDefinitionReconciler) AddFinalizerIfNotPresent(sDef *smv1alpha1.S
 func (r *SecretDefinitionReconciler) Reconcile(req ctrl.Request) (ctrl.Result, error) {
 	log := r.Log.WithValues("secretdefinition", req.NamespacedName)
 
+	// Standby replicas keep requeueing, so they reconcile everything soon after being elected
+	if r.Leader != nil && !r.Leader.IsLeader() {
+		return ctrl.Result{RequeueAfter: r.ReconciliationPeriod}, nil
+	}
+
 	sDef := &smv1alpha1.SecretDefinition{}
 
 	err := r.Get(r.Ctx, req.NamespacedName, sDef)
 	if err != nil {
-		log.Error(err, fmt.Sprintf("could not get SecretDefinition '%s'", req.NamespacedName))
+		if errors.IsNotFound(err) {
+			managedPathsCount.Set(float64(syncedPaths.remove(req.NamespacedName.String())))
+			lastKnown.remove(req.NamespacedName.String())
+			degraded.DeleteLabelValues(req.Namespace, req.Name)
+			deleteDryRunKeys(req.Namespace, req.Name)
+			// The child tokens of a deleted SecretDefinition won't be used anymore
+			if releaser, ok := r.Backend.(backend.VaultRoleReleaser); ok {
+				releaser.ReleaseVaultRole(r.Ctx, req.NamespacedName.String())
+			}
+		}
+		log.Error(err, "could not get SecretDefinition")
 		return ctrl.Result{}, ignoreNotFoundError(err)
 	}
 
@@ -235,6 +422,16 @@ func (r *SecretDefinitionReconciler) Reconcile(req ctrl.Request) (ctrl.Result, e
 
 	log = log.WithValues("secret", fmt.Sprintf("%s/%s", secretNamespace, secretName))
 
+	if r.DryRun {
+		if !isNotMarkedForRemoval(*sDef) || r.shouldExclude(sDef.Namespace) {
+			deleteDryRunKeys(req.Namespace, req.Name)
+			return ctrl.Result{}, nil
+		}
+		result := r.dryRun(sDef)
+		log.Info("dry-run summary", "ok", result.OK(), "resolved_keys", result.ResolvedKeys, "missing_keys", result.MissingKeys, "skipped_keys", result.SkippedKeys, "unreachable_keys", result.UnreachableKeys, "errors", result.Errors)
+		return ctrl.Result{RequeueAfter: r.ReconciliationPeriod}, nil
+	}
+
 	if isNotMarkedForRemoval(*sDef) {
 
 		err = r.AddFinalizerIfNotPresent(sDef, finalizerName)
@@ -245,10 +442,34 @@ func (r *SecretDefinitionReconciler) Reconcile(req ctrl.Request) (ctrl.Result, e
 
 		if r.shouldExclude(sDef.Namespace) {
 			log.Info("Secret definition in excluded namespace, ignoring", "excluded_namespaces", r.ExcludeNamespaces)
+			managedPathsCount.Set(float64(syncedPaths.remove(req.NamespacedName.String())))
+			lastKnown.remove(req.NamespacedName.String())
+			degraded.DeleteLabelValues(req.Namespace, req.Name)
 			return ctrl.Result{}, nil
 		}
 		// Get data from the secret source of truth
-		desiredState, err := r.getDesiredState(sDef.Spec.KeysMap)
+		keysMap, err := r.resolveKeysMap(sDef.Namespace, sDef.Spec.KeysMap)
+		if err != nil {
+			log.Error(err, "unable to resolve secret paths")
+			secretSyncErrorsTotal.WithLabelValues(secretNamespace, secretName).Inc()
+			secretLastSyncStatus.WithLabelValues(secretNamespace, secretName).Set(0.0)
+			return ctrl.Result{}, err
+		}
+		managedPathsCount.Set(float64(syncedPaths.set(req.NamespacedName.String(), keysMap)))
+		readCtx, requestID := r.readContext()
+		readCtx = withDefinition(readCtx, req.NamespacedName)
+		if requestID != "" {
+			log = log.WithValues("request_id", requestID)
+		}
+		if sDef.Spec.VaultRole != "" {
+			readCtx = backend.WithVaultRole(readCtx, sDef.Spec.VaultRole, req.NamespacedName.String())
+			log = log.WithValues("vault_role", sDef.Spec.VaultRole)
+		}
+		if sDef.Spec.VaultNamespace != "" {
+			readCtx = backend.WithVaultNamespace(readCtx, sDef.Spec.VaultNamespace)
+			log = log.WithValues("vault_namespace", sDef.Spec.VaultNamespace)
+		}
+		desiredState, skipped, err := r.getDesiredState(readCtx, keysMap)
 
 		if err != nil {
 			log.Error(err, "unable to get desired state for secret")
@@ -256,9 +477,24 @@ func (r *SecretDefinitionReconciler) Reconcile(req ctrl.Request) (ctrl.Result, e
 			secretLastSyncStatus.WithLabelValues(secretNamespace, secretName).Set(0.0)
 			return ctrl.Result{}, err
 		}
+		if len(skipped) > 0 {
+			log.Info("optional keys missing in the backend, leaving them out of the secret", "skipped_keys", skipped)
+			optionalKeysSkippedTotal.WithLabelValues(secretNamespace, secretName).Add(float64(len(skipped)))
+		}
+
+		var desiredHash string
+		if r.HashAlgorithm != "" {
+			desiredHash, err = backend.HashSecret(r.HashAlgorithm, desiredState)
+			if err != nil {
+				log.Error(err, "unable to hash secret", "hash_algorithm", r.HashAlgorithm)
+				secretSyncErrorsTotal.WithLabelValues(secretNamespace, secretName).Inc()
+				secretLastSyncStatus.WithLabelValues(secretNamespace, secretName).Set(0.0)
+				return ctrl.Result{}, err
+			}
+		}
 
 		// Get the actual secret from Kubernetes
-		currentState, err := r.getCurrentState(secretNamespace, secretName)
+		currentSecret, err := r.getCurrentSecret(secretNamespace, secretName)
 
 		if err != nil && !errors.IsNotFound(err) {
 			log.Error(err, "unable to get current state of secret")
@@ -267,7 +503,7 @@ func (r *SecretDefinitionReconciler) Reconcile(req ctrl.Request) (ctrl.Result, e
 			return ctrl.Result{}, ignoreNotFoundError(err)
 		}
 
-		eq := reflect.DeepEqual(desiredState, currentState)
+		eq := upToDate(currentSecret, desiredState, desiredHash)
 		if !eq {
 			log.Info("secret must be updated")
 			if err := r.upsertSecret(sDef, desiredState); err != nil {
@@ -277,11 +513,17 @@ func (r *SecretDefinitionReconciler) Reconcile(req ctrl.Request) (ctrl.Result, e
 				return ctrl.Result{}, err
 			}
 			log.Info("secret updated")
+			secretReconcilesTotal.WithLabelValues(secretNamespace, secretName, reconcileOutcomeApplied).Inc()
+		} else {
+			secretReconcilesTotal.WithLabelValues(secretNamespace, secretName, reconcileOutcomeSkipped).Inc()
 		}
 		secretLastSyncStatus.WithLabelValues(secretNamespace, secretName).Set(1.0)
 		return ctrl.Result{RequeueAfter: r.ReconciliationPeriod}, nil
 
 	} else {
+		managedPathsCount.Set(float64(syncedPaths.remove(req.NamespacedName.String())))
+		lastKnown.remove(req.NamespacedName.String())
+		degraded.DeleteLabelValues(req.Namespace, req.Name)
 		// SecretDefinition has been marked for deletion and contains finalizer
 		if containsString(sDef.ObjectMeta.Finalizers, finalizerName) {
 			if err = r.deleteSecret(secretNamespace, secretName); err != nil && !errors.IsNotFound(err) {
@@ -302,10 +544,20 @@ func (r *SecretDefinitionReconciler) Reconcile(req ctrl.Request) (ctrl.Result, e
 
 // SetupWithManager will register the controller
 func (r *SecretDefinitionReconciler) SetupWithManager(mgr ctrl.Manager, name string) error {
-	return ctrl.NewControllerManagedBy(mgr).
+	builder := ctrl.NewControllerManagedBy(mgr).
 		For(&smv1alpha1.SecretDefinition{}).
-		Named(name).
-		Complete(r)
+		Named(name)
+	if watcher, ok := r.Backend.(backend.EventWatcher); ok && r.Events && !r.DryRun {
+		events := make(chan event.GenericEvent)
+		builder = builder.Watches(&source.Channel{Source: events}, &handler.EnqueueRequestForObject{})
+		err := mgr.Add(manager.RunnableFunc(func(stop <-chan struct{}) error {
+			return r.watchSecretEvents(watcher, events, stop)
+		}))
+		if err != nil {
+			return err
+		}
+	}
+	return builder.Complete(r)
 }
 
 func init() {
@@ -3,11 +3,14 @@ package controllers
 import (
 	"context"
 	"encoding/base64"
+	"fmt"
+	"net"
 	"time"
 
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
 	smv1alpha1 "github.com/tuenti/secrets-manager/api/v1alpha1"
+	"github.com/tuenti/secrets-manager/backend"
 	"github.com/tuenti/secrets-manager/errors"
 
 	"reflect"
@@ -27,6 +30,13 @@ const (
 	decodedValue = "lorem ipsum dorma"
 )
 
+// unreachableBackend fails every read as if the backend was down
+type unreachableBackend struct{}
+
+func (b unreachableBackend) ReadSecret(path string, key string) (string, error) {
+	return "", &net.OpError{Op: "dial", Net: "tcp", Err: fmt.Errorf("connection refused")}
+}
+
 var _ = Describe("SecretsManager", func() {
 	var (
 		cfg *rest.Config
@@ -205,6 +215,33 @@ var _ = Describe("SecretsManager", func() {
 				},
 			},
 		}
+		sdDryRun = &smv1alpha1.SecretDefinition{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace: "default",
+				Name:      "secret-dry-run",
+			},
+			Spec: smv1alpha1.SecretDefinitionSpec{
+				Name: "secret-dry-run",
+				Type: "Opaque",
+				KeysMap: map[string]smv1alpha1.DataSource{
+					"resolved": smv1alpha1.DataSource{
+						Path:     "secret/data/pathtosecret1",
+						Key:      "value",
+						Encoding: "base64",
+					},
+					"missing": smv1alpha1.DataSource{
+						Path:     "secret/data/notfound",
+						Key:      "value",
+						Encoding: "base64",
+					},
+					"wrong-encoding": smv1alpha1.DataSource{
+						Path:     "secret/data/pathtosecret1",
+						Key:      "value",
+						Encoding: "base65",
+					},
+				},
+			},
+		}
 		sdExcludedNs = &smv1alpha1.SecretDefinition{
 			ObjectMeta: metav1.ObjectMeta{
 				Namespace: "default",
@@ -373,6 +410,23 @@ var _ = Describe("SecretsManager", func() {
 			// then:
 			Expect(err).To(BeNil())
 		})
+		It("Upsert a secret annotated with the hash of its data", func() {
+			// setup:
+			r2 := *getReconciler()
+			r2.HashAlgorithm = backend.HashSHA256
+			expectedHash, _ := backend.HashSecret(backend.HashSHA256, anyData)
+
+			// when:
+			err := r2.upsertSecret(sd, anyData)
+			secret, err2 := r2.getCurrentSecret(sd.Namespace, sd.Spec.Name)
+
+			// then:
+			Expect(err).To(BeNil())
+			Expect(err2).To(BeNil())
+			Expect(secret.Annotations[secretHashAnnotation]).To(Equal(expectedHash))
+			Expect(upToDate(secret, anyData, expectedHash)).To(BeTrue())
+			Expect(upToDate(secret, anyData, "sha512:other")).To(BeFalse())
+		})
 	})
 	Context("SecretDefinitionReconciler.getObjectMetaFromSecretDefinition", func() {
 
@@ -407,6 +461,411 @@ var _ = Describe("SecretsManager", func() {
 			Expect(objectMeta.Labels).Should(Not(HaveKey(corev1.LastAppliedConfigAnnotation)))
 		})
 	})
+	Context("SecretDefinitionReconciler.readContext", func() {
+		It("readContext should only generate request IDs when enabled", func() {
+			// when:
+			_, requestID := r.readContext()
+			r2 := getReconciler()
+			r2.RequestIDs = true
+			_, requestID1 := r2.readContext()
+			_, requestID2 := r2.readContext()
+
+			// then:
+			Expect(requestID).To(BeEmpty())
+			Expect(requestID1).NotTo(BeEmpty())
+			Expect(requestID1).NotTo(Equal(requestID2))
+		})
+	})
+	Context("SecretDefinitionReconciler.getDesiredState", func() {
+
+		It("getDesiredState should render templates with all the keys of the path", func() {
+			// when:
+			data, _, err := r.getDesiredState(context.Background(), map[string]smv1alpha1.DataSource{
+				"url": smv1alpha1.DataSource{
+					Path:     "secret/data/pathtosecret1",
+					Template: "value={{.value}}",
+				},
+			})
+
+			// then:
+			Expect(err).To(BeNil())
+			Expect(data).To(Equal(map[string][]byte{"url": []byte("value=" + encodedValue)}))
+		})
+		It("getDesiredState should apply transforms to decoded values", func() {
+			// when:
+			data, _, err := r.getDesiredState(context.Background(), map[string]smv1alpha1.DataSource{
+				"double": smv1alpha1.DataSource{
+					Path:      "secret/data/pathtosecret1",
+					Key:       "value",
+					Transform: "base64-encode",
+				},
+				"decoded": smv1alpha1.DataSource{
+					Path:      "secret/data/pathtosecret1",
+					Key:       "value",
+					Transform: "base64-decode",
+				},
+			})
+
+			// then:
+			Expect(err).To(BeNil())
+			Expect(data["double"]).To(Equal([]byte(base64.StdEncoding.EncodeToString([]byte(encodedValue)))))
+			Expect(data["decoded"]).To(Equal(decodedBytes))
+		})
+		It("getDesiredState should assemble keys read from several paths", func() {
+			// when:
+			data, skipped, err := r.getDesiredState(backend.WithSharedReads(context.Background()), map[string]smv1alpha1.DataSource{
+				"password": smv1alpha1.DataSource{
+					Path:     "secret/data/pathtosecret1",
+					Key:      "value",
+					Encoding: "base64",
+				},
+				"apikey": smv1alpha1.DataSource{
+					Path:     "secret/data/pathtosecret2",
+					Key:      "apikey",
+					Encoding: "base64",
+				},
+				"url": smv1alpha1.DataSource{
+					Path:     "secret/data/pathtosecret1",
+					Template: "value={{.value}}",
+				},
+				"token": smv1alpha1.DataSource{
+					Path:     "secret/data/pathtosecret3",
+					Key:      "token",
+					Optional: true,
+				},
+			})
+
+			// then:
+			Expect(err).To(BeNil())
+			Expect(data).To(Equal(map[string][]byte{
+				"password": decodedBytes,
+				"apikey":   []byte("secret-api-key\n"),
+				"url":      []byte("value=" + encodedValue),
+			}))
+			Expect(skipped).To(Equal([]string{"token"}))
+		})
+		It("getDesiredState should fail when a required key of any path is missing", func() {
+			// when:
+			_, _, err := r.getDesiredState(backend.WithSharedReads(context.Background()), map[string]smv1alpha1.DataSource{
+				"password": smv1alpha1.DataSource{
+					Path: "secret/data/pathtosecret1",
+					Key:  "value",
+				},
+				"token": smv1alpha1.DataSource{
+					Path: "secret/data/pathtosecret3",
+					Key:  "token",
+				},
+			})
+
+			// then:
+			Expect(errors.IsBackendSecretNotFound(err)).To(BeTrue())
+		})
+		It("getDesiredState should fail when a template references a missing key", func() {
+			// when:
+			_, _, err := r.getDesiredState(context.Background(), map[string]smv1alpha1.DataSource{
+				"url": smv1alpha1.DataSource{
+					Path:     "secret/data/pathtosecret1",
+					Template: "{{.missing}}",
+				},
+			})
+
+			// then:
+			Expect(errors.IsSecretTemplate(err)).To(BeTrue())
+		})
+		It("getDesiredState should not read with the controller token when a vault role is set", func() {
+			// given:
+			ctx := backend.WithVaultRole(context.Background(), "team-a", "default/secretdef-test")
+
+			// when:
+			_, _, err := r.getDesiredState(ctx, map[string]smv1alpha1.DataSource{
+				"value": smv1alpha1.DataSource{
+					Path: "secret/data/pathtosecret1",
+					Key:  "value",
+				},
+			})
+
+			// then:
+			Expect(err).NotTo(BeNil())
+		})
+		It("getDesiredState should read all the keys of a path with the token of the vault role", func() {
+			// given:
+			ctx := backend.WithVaultRole(context.Background(), "team-a", "default/secretdef-test")
+
+			// when:
+			_, _, err := r.getDesiredState(ctx, map[string]smv1alpha1.DataSource{
+				"db": smv1alpha1.DataSource{
+					Path:    "secret/data/pathtosecret1",
+					AllKeys: true,
+				},
+			})
+
+			// then:
+			Expect(err).NotTo(BeNil())
+			Expect(err.Error()).NotTo(ContainSubstring("can't read all the keys"))
+		})
+		It("getDesiredState should not read from the controller namespace when a vault namespace is set", func() {
+			// given:
+			ctx := backend.WithVaultNamespace(context.Background(), "org/team-a")
+
+			// when:
+			_, _, err := r.getDesiredState(ctx, map[string]smv1alpha1.DataSource{
+				"value": smv1alpha1.DataSource{
+					Path:     "secret/data/pathtosecret1",
+					Template: "{{.value}}",
+				},
+			})
+
+			// then:
+			Expect(err).NotTo(BeNil())
+		})
+		It("getDesiredState should store all the keys of a path renamed with remap", func() {
+			// when:
+			data, _, err := r.getDesiredState(context.Background(), map[string]smv1alpha1.DataSource{
+				"db": smv1alpha1.DataSource{
+					Path:    "secret/data/pathtosecret1",
+					AllKeys: true,
+					Remap:   map[string]string{"value": "POSTGRES_PASSWORD"},
+				},
+			})
+
+			// then:
+			Expect(err).To(BeNil())
+			Expect(data).To(Equal(map[string][]byte{"POSTGRES_PASSWORD": []byte(encodedValue)}))
+		})
+		It("getDesiredState should fail when a remapped key collides with another key", func() {
+			// when:
+			_, _, err := r.getDesiredState(context.Background(), map[string]smv1alpha1.DataSource{
+				"db": smv1alpha1.DataSource{
+					Path:    "secret/data/pathtosecret1",
+					AllKeys: true,
+					Remap:   map[string]string{"value": "password"},
+				},
+				"password": smv1alpha1.DataSource{
+					Path: "secret/data/pathtosecret1",
+					Key:  "value",
+				},
+			})
+
+			// then:
+			Expect(errors.IsSecretKeyCollision(err)).To(BeTrue())
+		})
+		It("remapKeys should fail when two keys are renamed to the same secret key", func() {
+			// when:
+			_, err := remapKeys("secret/data/db", map[string]string{"db_password": "foo", "password": "bar"}, map[string]string{"db_password": "password"})
+
+			// then:
+			Expect(errors.IsSecretKeyCollision(err)).To(BeTrue())
+		})
+		It("getDesiredState should fail wrapped reads when the backend can't wrap responses", func() {
+			// when:
+			_, _, err := r.getDesiredState(context.Background(), map[string]smv1alpha1.DataSource{
+				"token": smv1alpha1.DataSource{
+					Path:    "secret/data/pathtosecret1",
+					WrapTTL: "5m",
+				},
+			})
+
+			// then:
+			Expect(err).NotTo(BeNil())
+		})
+	})
+	Context("managedPaths", func() {
+
+		It("managedPaths should count the distinct paths of all the SecretDefinitions", func() {
+			// given:
+			m := newManagedPaths()
+			keysMap := map[string]smv1alpha1.DataSource{
+				"user":     smv1alpha1.DataSource{Path: "secret/data/db", Key: "user"},
+				"password": smv1alpha1.DataSource{Path: "secret/data/db", Key: "password"},
+				"token":    smv1alpha1.DataSource{Path: "secret/data/api", Key: "token"},
+			}
+
+			// when:
+			Expect(m.set("default/a", keysMap)).To(Equal(2))
+			Expect(m.set("default/b", map[string]smv1alpha1.DataSource{"token": keysMap["token"]})).To(Equal(2))
+
+			// then:
+			Expect(m.set("default/a", map[string]smv1alpha1.DataSource{"user": keysMap["user"]})).To(Equal(2))
+			Expect(m.remove("default/b")).To(Equal(1))
+			Expect(m.remove("default/b")).To(Equal(1))
+			Expect(m.remove("default/a")).To(Equal(0))
+		})
+		It("definitionsOf should return the SecretDefinitions syncing a path", func() {
+			// given:
+			m := newManagedPaths()
+			m.set("team-a/db", map[string]smv1alpha1.DataSource{"password": smv1alpha1.DataSource{Path: "/secret/data/db", Key: "password"}})
+			m.set("team-b/db", map[string]smv1alpha1.DataSource{"password": smv1alpha1.DataSource{Path: "secret/data/db", Key: "password"}})
+			m.set("team-b/api", map[string]smv1alpha1.DataSource{"token": smv1alpha1.DataSource{Path: "secret/data/api", Key: "token"}})
+
+			// then:
+			Expect(m.definitionsOf("secret/data/db")).To(Equal([]string{"team-a/db", "team-b/db"}))
+			Expect(m.definitionsOf("secret/data/missing")).To(BeEmpty())
+		})
+		It("secretDefinitionEvent should enqueue the SecretDefinition by namespace and name", func() {
+			// when:
+			e := secretDefinitionEvent("team-a/db")
+
+			// then:
+			Expect(e.Meta.GetNamespace()).To(Equal("team-a"))
+			Expect(e.Meta.GetName()).To(Equal("db"))
+		})
+	})
+	Context("PathPrefixes.Resolve", func() {
+
+		It("Resolve should make paths relative to the prefix of the namespace", func() {
+			// given:
+			prefixes, err := NewPathPrefixes(map[string]string{"team-a": "secret/data/team-a/"}, "secret/data/{{.Namespace}}")
+			Expect(err).To(BeNil())
+
+			// then:
+			Expect(prefixes.Resolve("team-a", "db")).To(Equal("secret/data/team-a/db"))
+			Expect(prefixes.Resolve("team-a", "/db/./password")).To(Equal("secret/data/team-a/db/password"))
+			Expect(prefixes.Resolve("team-b", "db")).To(Equal("secret/data/team-b/db"))
+		})
+		It("Resolve should reject paths escaping the prefix", func() {
+			// given:
+			prefixes, _ := NewPathPrefixes(map[string]string{"team-a": "secret/data/team-a"}, "")
+
+			// when:
+			_, err := prefixes.Resolve("team-a", "../team-b/db")
+
+			// then:
+			Expect(errors.IsSecretPath(err)).To(BeTrue())
+		})
+		It("Resolve should keep paths of namespaces without prefix", func() {
+			// given:
+			prefixes, _ := NewPathPrefixes(map[string]string{"team-a": "secret/data/team-a"}, "")
+
+			// then:
+			Expect(prefixes.Resolve("team-b", "secret/data/db")).To(Equal("secret/data/db"))
+		})
+		It("getDesiredState should skip optional keys missing in the backend", func() {
+			// when:
+			data, skipped, err := r.getDesiredState(context.Background(), map[string]smv1alpha1.DataSource{
+				"value": smv1alpha1.DataSource{
+					Path: "secret/data/pathtosecret1",
+					Key:  "value",
+				},
+				"optional": smv1alpha1.DataSource{
+					Path:     "secret/data/missing",
+					Key:      "value",
+					Optional: true,
+				},
+			})
+
+			// then:
+			Expect(err).To(BeNil())
+			Expect(data).Should(HaveKey("value"))
+			Expect(data).ShouldNot(HaveKey("optional"))
+			Expect(skipped).To(Equal([]string{"optional"}))
+		})
+		It("getDesiredState should fail on optional keys the backend can't reach", func() {
+			// setup:
+			r2 := *getReconciler()
+			r2.Backend = unreachableBackend{}
+
+			// when:
+			_, _, err := r2.getDesiredState(context.Background(), map[string]smv1alpha1.DataSource{
+				"optional": smv1alpha1.DataSource{
+					Path:     "secret/data/pathtosecret1",
+					Key:      "value",
+					Optional: true,
+				},
+			})
+
+			// then:
+			Expect(err).ToNot(BeNil())
+			Expect(errors.IsBackendSecretNotFound(err)).To(BeFalse())
+		})
+		It("getDesiredState should use the values last read when the backend is unreachable with LastKnownGood", func() {
+			// setup:
+			r2 := *getReconciler()
+			r2.LastKnownGood = true
+			ctx := withDefinition(context.Background(), types.NamespacedName{Namespace: "default", Name: "last-known-good"})
+			keysMap := map[string]smv1alpha1.DataSource{
+				"value": smv1alpha1.DataSource{
+					Path: "secret/data/pathtosecret1",
+					Key:  "value",
+				},
+			}
+			_, _, err := r2.getDesiredState(ctx, keysMap)
+			Expect(err).To(BeNil())
+			r2.Backend = unreachableBackend{}
+
+			// when:
+			data, _, err := r2.getDesiredState(ctx, keysMap)
+
+			// then:
+			Expect(err).To(BeNil())
+			Expect(data).To(Equal(map[string][]byte{"value": []byte(encodedValue)}))
+			lastKnown.remove("default/last-known-good")
+		})
+		It("getDesiredState should fail on keys missing in the backend with LastKnownGood", func() {
+			// setup:
+			r2 := *getReconciler()
+			r2.LastKnownGood = true
+			ctx := withDefinition(context.Background(), types.NamespacedName{Namespace: "default", Name: "last-known-good"})
+
+			// when:
+			_, _, err := r2.getDesiredState(ctx, map[string]smv1alpha1.DataSource{
+				"value": smv1alpha1.DataSource{
+					Path: "secret/data/pathtosecret1",
+					Key:  "missing",
+				},
+			})
+
+			// then:
+			Expect(errors.IsBackendSecretNotFound(err)).To(BeTrue())
+		})
+	})
+	Context("SecretDefinitionReconciler.dryRun", func() {
+
+		It("dryRun should report resolved, missing and failing keys", func() {
+			// when:
+			result := r.dryRun(sdDryRun)
+
+			// then:
+			Expect(result.OK()).To(BeFalse())
+			Expect(result.ResolvedKeys).To(Equal([]string{"resolved"}))
+			Expect(result.MissingKeys).To(Equal([]string{"missing"}))
+			Expect(result.Errors).Should(HaveKey("wrong-encoding"))
+		})
+		It("Check should report keys the backend can't reach as unreachable", func() {
+			// setup:
+			r2 := *getReconciler()
+			r2.Backend = unreachableBackend{}
+
+			// when:
+			result := r2.Check(sdDryRun)
+
+			// then:
+			Expect(result.OK()).To(BeFalse())
+			Expect(result.ResolvedKeys).To(BeEmpty())
+			Expect(result.UnreachableKeys).To(ContainElement("resolved"))
+			Expect(result.Errors).Should(HaveKey("resolved"))
+		})
+		It("Reconcile in dry-run mode should not write the secret", func() {
+			// setup:
+			r2 := *getReconciler()
+			r2.DryRun = true
+
+			// when:
+			err := r2.Create(context.Background(), sdDryRun)
+			res, err2 := r2.Reconcile(reconcile.Request{
+				NamespacedName: types.NamespacedName{
+					Namespace: sdDryRun.Namespace,
+					Name:      sdDryRun.Name,
+				},
+			})
+			_, err3 := r2.getCurrentState("default", sdDryRun.Spec.Name)
+
+			// then:
+			Expect(err).To(BeNil())
+			Expect(err2).To(BeNil())
+			Expect(res.RequeueAfter).To(Equal(r2.ReconciliationPeriod))
+			Expect(err3).ToNot(BeNil())
+		})
+	})
 	Context("Manager.MultiNamespacedCache", func() {
 
 		It("Creates secret in watched namespace", func(done Done) {
@@ -17,7 +17,6 @@ package controllers
 
 import (
 	"context"
-	"errors"
 	"path/filepath"
 	"testing"
 	"time"
@@ -26,6 +25,7 @@ import (
 	. "github.com/onsi/gomega"
 
 	secretsmanagerv1alpha1 "github.com/tuenti/secrets-manager/api/v1alpha1"
+	"github.com/tuenti/secrets-manager/backend"
 	"k8s.io/client-go/rest"
 
 	corev1 "k8s.io/api/core/v1"
@@ -49,32 +49,6 @@ var testEnv *envtest.Environment
 var mgr ctrl.Manager
 var scheme *runtime.Scheme
 
-type fakeBackendSecret struct {
-	Path    string
-	Key     string
-	Content string
-}
-
-type fakeBackend struct {
-	fakeSecrets []fakeBackendSecret
-}
-
-func newFakeBackend(fakeSecrets []fakeBackendSecret) fakeBackend {
-	return fakeBackend{
-		fakeSecrets: fakeSecrets,
-	}
-}
-
-func (f fakeBackend) ReadSecret(path string, key string) (string, error) {
-	for _, fakeSecret := range f.fakeSecrets {
-		if fakeSecret.Path == path && fakeSecret.Key == key {
-			return fakeSecret.Content, nil
-		}
-	}
-	return "", errors.New("Not found")
-
-}
-
 func getReconciler() *SecretDefinitionReconciler {
 	return r
 }
@@ -136,8 +110,9 @@ var _ = BeforeSuite(func(done Done) {
 	}
 
 	r = &SecretDefinitionReconciler{
-		Backend: newFakeBackend([]fakeBackendSecret{
-			{"secret/data/pathtosecret1", "value", "bG9yZW0gaXBzdW0gZG9ybWEK"},
+		Backend: backend.NewMemoryClient(map[string]map[string]string{
+			"secret/data/pathtosecret1": {"value": "bG9yZW0gaXBzdW0gZG9ybWEK"},
+			"secret/data/pathtosecret2": {"apikey": "c2VjcmV0LWFwaS1rZXkK"},
 		}),
 		Client:               k8sClient,
 		APIReader:            k8sClient,
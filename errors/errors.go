@@ -1,17 +1,111 @@
 package errors
 
-import "fmt"
+import (
+	stderrors "errors"
+	"fmt"
+	"strings"
+)
 
 // Error Types constants
 const (
-	UnknownErrorType                   = "UnknownError"
-	BackendNotImplementedErrorType     = "BackendNotImplementedError"
-	BackendSecretNotFoundErrorType     = "BackendSecretNotFoundError"
-	K8sSecretNotFoundErrorType         = "K8sSecretNotFoundError"
-	InvalidConfigmapNameErrorType      = "InvalidConfigmapNameError"
-	EncodingNotImplementedErrorType    = "EncodingNotImplementedError"
-	VaultEngineNotImplementedErrorType = "VaultEngineNotImplementedError"
-	VaultTokenNotRenewableErrorType    = "VaultTokenNotRenewableError"
+	UnknownErrorType                     = "UnknownError"
+	BackendNotImplementedErrorType       = "BackendNotImplementedError"
+	BackendSecretNotFoundErrorType       = "BackendSecretNotFoundError"
+	K8sSecretNotFoundErrorType           = "K8sSecretNotFoundError"
+	InvalidConfigmapNameErrorType        = "InvalidConfigmapNameError"
+	EncodingNotImplementedErrorType      = "EncodingNotImplementedError"
+	VaultEngineNotImplementedErrorType   = "VaultEngineNotImplementedError"
+	VaultTokenNotRenewableErrorType      = "VaultTokenNotRenewableError"
+	VaultKubernetesAuthErrorType         = "VaultKubernetesAuthError"
+	VaultAppRoleAuthErrorType            = "VaultAppRoleAuthError"
+	VaultTLSConfigErrorType              = "VaultTLSConfigError"
+	VaultVersioningNotSupportedErrorType = "VaultVersioningNotSupportedError"
+	BackendSecretTypeErrorType           = "BackendSecretTypeError"
+	BackendConfigErrorType               = "BackendConfigError"
+	BackendForbiddenErrorType            = "BackendForbiddenError"
+	VaultTransitErrorType                = "VaultTransitError"
+	VaultPKIErrorType                    = "VaultPKIError"
+	VaultTimeoutErrorType                = "VaultTimeoutError"
+	VaultSealedErrorType                 = "VaultSealedError"
+	VaultForbiddenErrorType              = "VaultForbiddenError"
+	VaultJWTAuthErrorType                = "VaultJWTAuthError"
+	VaultUnwrapErrorType                 = "VaultUnwrapError"
+	BackendSecretWriteErrorType          = "BackendSecretWriteError"
+	VaultUserpassAuthErrorType           = "VaultUserpassAuthError"
+	VaultCertAuthErrorType               = "VaultCertAuthError"
+	SecretTemplateErrorType              = "SecretTemplateError"
+	BackendSecretEncodingErrorType       = "BackendSecretEncodingError"
+	SecretTreeErrorType                  = "SecretTreeError"
+	VaultAWSAuthErrorType                = "VaultAWSAuthError"
+	VaultGCPAuthErrorType                = "VaultGCPAuthError"
+	VaultAzureAuthErrorType              = "VaultAzureAuthError"
+	VaultTokenMalformedErrorType         = "VaultTokenMalformedError"
+	SecretPathErrorType                  = "SecretPathError"
+	HashNotImplementedErrorType          = "HashNotImplementedError"
+	VaultSecretDeletedErrorType          = "VaultSecretDeletedError"
+	VaultSecretDestroyedErrorType        = "VaultSecretDestroyedError"
+	VaultTokenRenewNoProgressErrorType   = "VaultTokenRenewNoProgressError"
+	VaultCircuitOpenErrorType            = "VaultCircuitOpenError"
+	VaultRoleTokenErrorType              = "VaultRoleTokenError"
+	VaultTokenTTLTooShortErrorType       = "VaultTokenTTLTooShortError"
+	SecretKeyCollisionErrorType          = "SecretKeyCollisionError"
+	VaultCASMismatchErrorType            = "VaultCASMismatchError"
+	BackendSecretTooLargeErrorType       = "BackendSecretTooLargeError"
+	PathNotAllowedErrorType              = "PathNotAllowedError"
+	VaultTokenCreateErrorType            = "VaultTokenCreateError"
+	BackendSecretKeysErrorType           = "BackendSecretKeysError"
+	VaultWrapValidationErrorType         = "VaultWrapValidationError"
+)
+
+// Sentinel errors matching the error of the same type with errors.Is, e.g. errors.Is(err, ErrBackendSecretNotFound)
+// holds when err or any error it wraps is a BackendSecretNotFoundError. Use errors.As to read its fields
+var (
+	ErrBackendNotImplemented       = stderrors.New(BackendNotImplementedErrorType)
+	ErrBackendSecretNotFound       = stderrors.New(BackendSecretNotFoundErrorType)
+	ErrK8sSecretNotFound           = stderrors.New(K8sSecretNotFoundErrorType)
+	ErrInvalidConfigmapName        = stderrors.New(InvalidConfigmapNameErrorType)
+	ErrEncodingNotImplemented      = stderrors.New(EncodingNotImplementedErrorType)
+	ErrVaultEngineNotImplemented   = stderrors.New(VaultEngineNotImplementedErrorType)
+	ErrVaultTokenNotRenewable      = stderrors.New(VaultTokenNotRenewableErrorType)
+	ErrVaultKubernetesAuth         = stderrors.New(VaultKubernetesAuthErrorType)
+	ErrVaultAppRoleAuth            = stderrors.New(VaultAppRoleAuthErrorType)
+	ErrVaultTLSConfig              = stderrors.New(VaultTLSConfigErrorType)
+	ErrVaultVersioningNotSupported = stderrors.New(VaultVersioningNotSupportedErrorType)
+	ErrBackendSecretType           = stderrors.New(BackendSecretTypeErrorType)
+	ErrBackendConfig               = stderrors.New(BackendConfigErrorType)
+	ErrBackendForbidden            = stderrors.New(BackendForbiddenErrorType)
+	ErrVaultTransit                = stderrors.New(VaultTransitErrorType)
+	ErrVaultPKI                    = stderrors.New(VaultPKIErrorType)
+	ErrVaultTimeout                = stderrors.New(VaultTimeoutErrorType)
+	ErrVaultSealed                 = stderrors.New(VaultSealedErrorType)
+	ErrVaultForbidden              = stderrors.New(VaultForbiddenErrorType)
+	ErrVaultJWTAuth                = stderrors.New(VaultJWTAuthErrorType)
+	ErrVaultUnwrap                 = stderrors.New(VaultUnwrapErrorType)
+	ErrBackendSecretWrite          = stderrors.New(BackendSecretWriteErrorType)
+	ErrVaultUserpassAuth           = stderrors.New(VaultUserpassAuthErrorType)
+	ErrVaultCertAuth               = stderrors.New(VaultCertAuthErrorType)
+	ErrSecretTemplate              = stderrors.New(SecretTemplateErrorType)
+	ErrBackendSecretEncoding       = stderrors.New(BackendSecretEncodingErrorType)
+	ErrSecretTree                  = stderrors.New(SecretTreeErrorType)
+	ErrVaultAWSAuth                = stderrors.New(VaultAWSAuthErrorType)
+	ErrVaultGCPAuth                = stderrors.New(VaultGCPAuthErrorType)
+	ErrVaultAzureAuth              = stderrors.New(VaultAzureAuthErrorType)
+	ErrVaultTokenMalformed         = stderrors.New(VaultTokenMalformedErrorType)
+	ErrSecretPath                  = stderrors.New(SecretPathErrorType)
+	ErrHashNotImplemented          = stderrors.New(HashNotImplementedErrorType)
+	ErrVaultSecretDeleted          = stderrors.New(VaultSecretDeletedErrorType)
+	ErrVaultSecretDestroyed        = stderrors.New(VaultSecretDestroyedErrorType)
+	ErrVaultTokenRenewNoProgress   = stderrors.New(VaultTokenRenewNoProgressErrorType)
+	ErrVaultCircuitOpen            = stderrors.New(VaultCircuitOpenErrorType)
+	ErrVaultRoleToken              = stderrors.New(VaultRoleTokenErrorType)
+	ErrVaultTokenTTLTooShort       = stderrors.New(VaultTokenTTLTooShortErrorType)
+	ErrSecretKeyCollision          = stderrors.New(SecretKeyCollisionErrorType)
+	ErrVaultCASMismatch            = stderrors.New(VaultCASMismatchErrorType)
+	ErrBackendSecretTooLarge       = stderrors.New(BackendSecretTooLargeErrorType)
+	ErrPathNotAllowed              = stderrors.New(PathNotAllowedErrorType)
+	ErrVaultTokenCreate            = stderrors.New(VaultTokenCreateErrorType)
+	ErrBackendSecretKeys           = stderrors.New(BackendSecretKeysErrorType)
+	ErrVaultWrapValidation         = stderrors.New(VaultWrapValidationErrorType)
 )
 
 // BackendNotImplementedError will be raised if the selected backend is not implemented
@@ -57,7 +151,289 @@ type VaultTokenNotRenewableError struct {
 	ErrType string
 }
 
+// VaultKubernetesAuthError will be raised if secrets-manager can't login to Vault using the kubernetes auth method
+type VaultKubernetesAuthError struct {
+	ErrType string
+	Role    string
+	Err     error
+}
+
+// VaultAppRoleAuthError will be raised if secrets-manager can't login to Vault using the approle auth method
+type VaultAppRoleAuthError struct {
+	ErrType string
+	RoleID  string
+	Err     error
+}
+
+// VaultTLSConfigError will be raised if the TLS configuration provided to talk to Vault is not valid
+type VaultTLSConfigError struct {
+	ErrType string
+	Reason  string
+}
+
+// VaultVersioningNotSupportedError will be raised if a secret version is requested to an engine without versioning
+type VaultVersioningNotSupportedError struct {
+	ErrType string
+	Engine  string
+}
+
+// BackendSecretTypeError will be raised if a secret value can not be converted to the requested type
+type BackendSecretTypeError struct {
+	ErrType string
+	Path    string
+	Key     string
+	Type    string
+}
+
+// BackendConfigError will be raised if the configuration provided for a backend is not valid
+type BackendConfigError struct {
+	ErrType string
+	Backend string
+	Reason  string
+}
+
+// BackendForbiddenError will be raised if secrets-manager is not allowed to read a secret from the selected backend
+type BackendForbiddenError struct {
+	ErrType string
+	Backend string
+	Path    string
+}
+
+// VaultTransitError will be raised if Vault transit engine fails to decrypt a ciphertext
+type VaultTransitError struct {
+	ErrType string
+	KeyName string
+	Err     error
+}
+
+// VaultPKIError will be raised if Vault PKI engine fails to issue a certificate
+type VaultPKIError struct {
+	ErrType string
+	Role    string
+	Err     error
+}
+
+// VaultTimeoutError will be raised if a Vault request does not complete before its deadline
+type VaultTimeoutError struct {
+	ErrType string
+	Path    string
+	Err     error
+}
+
+// VaultSealedError will be raised when reading a secret while the Vault cluster is sealed
+type VaultSealedError struct {
+	ErrType string
+	Path    string
+}
+
+// VaultForbiddenError will be raised if the Vault token is not allowed to read a path
+type VaultForbiddenError struct {
+	ErrType string
+	Path    string
+}
+
+// VaultJWTAuthError will be raised if secrets-manager can't login to Vault using the jwt auth method
+type VaultJWTAuthError struct {
+	ErrType string
+	Role    string
+	Err     error
+}
+
+// VaultUnwrapError will be raised if the response-wrapping token can't be unwrapped, e.g. because it was already used
+type VaultUnwrapError struct {
+	ErrType string
+	Err     error
+}
+
+// BackendSecretWriteError will be raised if a secret can not be written to the selected backend
+type BackendSecretWriteError struct {
+	ErrType string
+	Path    string
+	Err     error
+}
+
+// VaultUserpassAuthError will be raised if secrets-manager can't login to Vault using the userpass auth method
+type VaultUserpassAuthError struct {
+	ErrType  string
+	Username string
+	Err      error
+}
+
+// VaultCertAuthError will be raised if secrets-manager can't login to Vault using the cert auth method
+type VaultCertAuthError struct {
+	ErrType string
+	Role    string
+	Err     error
+}
+
+// SecretTemplateError will be raised if a secret template can't be parsed or executed, e.g. when it references a key missing in the secret
+type SecretTemplateError struct {
+	ErrType string
+	Path    string
+	Err     error
+}
+
+// BackendSecretEncodingError will be raised if a secret value is not valid for the encoding it is decoded from
+type BackendSecretEncodingError struct {
+	ErrType  string
+	Encoding string
+	Err      error
+}
+
+// SecretTreeError will be raised if some of the secrets under a path can not be read, it collects the error of every one of them
+type SecretTreeError struct {
+	ErrType string
+	Path    string
+	Errs    []error
+}
+
+// VaultAWSAuthError will be raised if secrets-manager can't login to Vault using the aws auth method
+type VaultAWSAuthError struct {
+	ErrType string
+	Role    string
+	Err     error
+}
+
+// VaultGCPAuthError will be raised if secrets-manager can't login to Vault using the gcp auth method
+type VaultGCPAuthError struct {
+	ErrType string
+	Role    string
+	Err     error
+}
+
+// VaultAzureAuthError will be raised if secrets-manager can't login to Vault using the azure auth method
+type VaultAzureAuthError struct {
+	ErrType string
+	Role    string
+	Err     error
+}
+
+// VaultTokenMalformedError will be raised if the Vault token lookup response doesn't have the expected shape, e.g. a missing ttl
+type VaultTokenMalformedError struct {
+	ErrType string
+	Reason  string
+}
+
+// SecretPathError will be raised if the path of a SecretDefinition key can not be resolved under the path prefix of its namespace, e.g. when it tries to escape it
+type SecretPathError struct {
+	ErrType   string
+	Namespace string
+	Path      string
+	Reason    string
+}
+
+// HashNotImplementedError will be raised if the selected secret hash algorithm is not implemented
+type HashNotImplementedError struct {
+	ErrType   string
+	Algorithm string
+}
+
+// VaultSecretDeletedError will be raised if the requested KV version 2 secret version has been soft-deleted and can still be undeleted
+type VaultSecretDeletedError struct {
+	ErrType      string
+	Path         string
+	Version      string
+	DeletionTime string
+}
+
+// VaultSecretDestroyedError will be raised if the requested KV version 2 secret version has been permanently destroyed
+type VaultSecretDestroyedError struct {
+	ErrType string
+	Path    string
+	Version string
+}
+
+// VaultTokenRenewNoProgressError will be raised if renewing the Vault token didn't extend its TTL, e.g. because it reached its max TTL
+type VaultTokenRenewNoProgressError struct {
+	ErrType string
+	TTL     int64
+}
+
+// VaultCircuitOpenError will be raised if a read is not sent to Vault because the circuit breaker is open after failing reads in a row
+type VaultCircuitOpenError struct {
+	ErrType string
+	Path    string
+	RetryIn string
+}
+
+// VaultRoleTokenError will be raised if a child token can not be created with a Vault token role
+type VaultRoleTokenError struct {
+	ErrType string
+	Role    string
+	Err     error
+}
+
+// VaultTokenTTLTooShortError will be raised if the vault token is not renewable and expires before it can be checked again
+type VaultTokenTTLTooShortError struct {
+	ErrType string
+	TTL     int64
+	MinTTL  int64
+}
+
+// SecretKeyCollisionError is returned when two datasources of a SecretDefinition store the same Kubernetes secret key
+type SecretKeyCollisionError struct {
+	ErrType string
+	Key     string
+	Path    string
+}
+
+// VaultCASMismatchError is returned when Vault rejects a check-and-set write because the secret is not at the expected version
+type VaultCASMismatchError struct {
+	ErrType string
+	Path    string
+	Version int
+}
+
+// BackendSecretTooLargeError is returned when a secret value is larger than the configured size limit
+type BackendSecretTooLargeError struct {
+	ErrType string
+	Path    string
+	Key     string
+	Size    int
+	MaxSize int
+}
+
+// PathNotAllowedError is returned when reading a path denied by the allowed and denied path prefixes of the backend
+type PathNotAllowedError struct {
+	ErrType string
+	Path    string
+	Reason  string
+}
+
+// VaultTokenCreateError is returned when the child token the client reads with can not be created with the token logged in
+type VaultTokenCreateError struct {
+	ErrType string
+	Role    string
+	Err     error
+}
+
+// BackendSecretKeysError is returned when several keys of a secret can not be read, holding the error of every key
+type BackendSecretKeysError struct {
+	ErrType string
+	Path    string
+	Errs    []error
+}
+
+// VaultWrapValidationError is returned when a response-wrapping token fails the checks made with sys/wrapping/lookup before unwrapping it, e.g. because it was not created at the expected path
+type VaultWrapValidationError struct {
+	ErrType      string
+	Path         string
+	CreationPath string
+	Reason       string
+}
+
+// getErrorType returns the type of the first error of this package in the chain of err, so errors wrapped with
+// fmt.Errorf and %w keep their type
 func getErrorType(err error) string {
+	for ; err != nil; err = stderrors.Unwrap(err) {
+		if errType := errorType(err); errType != UnknownErrorType {
+			return errType
+		}
+	}
+	return UnknownErrorType
+}
+
+func errorType(err error) string {
 	switch err.(type) {
 	case *BackendNotImplementedError:
 		return BackendNotImplementedErrorType
@@ -73,6 +449,84 @@ func getErrorType(err error) string {
 		return VaultEngineNotImplementedErrorType
 	case *VaultTokenNotRenewableError:
 		return VaultTokenNotRenewableErrorType
+	case *VaultKubernetesAuthError:
+		return VaultKubernetesAuthErrorType
+	case *VaultAppRoleAuthError:
+		return VaultAppRoleAuthErrorType
+	case *VaultTLSConfigError:
+		return VaultTLSConfigErrorType
+	case *VaultVersioningNotSupportedError:
+		return VaultVersioningNotSupportedErrorType
+	case *BackendSecretTypeError:
+		return BackendSecretTypeErrorType
+	case *BackendConfigError:
+		return BackendConfigErrorType
+	case *BackendForbiddenError:
+		return BackendForbiddenErrorType
+	case *VaultTransitError:
+		return VaultTransitErrorType
+	case *VaultPKIError:
+		return VaultPKIErrorType
+	case *VaultTimeoutError:
+		return VaultTimeoutErrorType
+	case *VaultSealedError:
+		return VaultSealedErrorType
+	case *VaultForbiddenError:
+		return VaultForbiddenErrorType
+	case *VaultJWTAuthError:
+		return VaultJWTAuthErrorType
+	case *VaultUnwrapError:
+		return VaultUnwrapErrorType
+	case *BackendSecretWriteError:
+		return BackendSecretWriteErrorType
+	case *VaultUserpassAuthError:
+		return VaultUserpassAuthErrorType
+	case *VaultCertAuthError:
+		return VaultCertAuthErrorType
+	case *SecretTemplateError:
+		return SecretTemplateErrorType
+	case *BackendSecretEncodingError:
+		return BackendSecretEncodingErrorType
+	case *SecretTreeError:
+		return SecretTreeErrorType
+	case *VaultAWSAuthError:
+		return VaultAWSAuthErrorType
+	case *VaultGCPAuthError:
+		return VaultGCPAuthErrorType
+	case *VaultAzureAuthError:
+		return VaultAzureAuthErrorType
+	case *VaultTokenMalformedError:
+		return VaultTokenMalformedErrorType
+	case *SecretPathError:
+		return SecretPathErrorType
+	case *HashNotImplementedError:
+		return HashNotImplementedErrorType
+	case *VaultSecretDeletedError:
+		return VaultSecretDeletedErrorType
+	case *VaultSecretDestroyedError:
+		return VaultSecretDestroyedErrorType
+	case *VaultTokenRenewNoProgressError:
+		return VaultTokenRenewNoProgressErrorType
+	case *VaultCircuitOpenError:
+		return VaultCircuitOpenErrorType
+	case *VaultRoleTokenError:
+		return VaultRoleTokenErrorType
+	case *VaultTokenTTLTooShortError:
+		return VaultTokenTTLTooShortErrorType
+	case *SecretKeyCollisionError:
+		return SecretKeyCollisionErrorType
+	case *VaultCASMismatchError:
+		return VaultCASMismatchErrorType
+	case *BackendSecretTooLargeError:
+		return BackendSecretTooLargeErrorType
+	case *PathNotAllowedError:
+		return PathNotAllowedErrorType
+	case *VaultTokenCreateError:
+		return VaultTokenCreateErrorType
+	case *BackendSecretKeysError:
+		return BackendSecretKeysErrorType
+	case *VaultWrapValidationError:
+		return VaultWrapValidationErrorType
 	default:
 		return UnknownErrorType
 	}
@@ -82,61 +536,715 @@ func (e BackendNotImplementedError) Error() string {
 	return fmt.Sprintf("[%s] backend %s not supported", e.ErrType, e.Backend)
 }
 
+func (e BackendNotImplementedError) Is(target error) bool {
+	return target == ErrBackendNotImplemented
+}
+
 func (e BackendSecretNotFoundError) Error() string {
 	return fmt.Sprintf("[%s] secret key %s not found at %s", e.ErrType, e.Key, e.Path)
 }
 
+func (e BackendSecretNotFoundError) Is(target error) bool {
+	return target == ErrBackendSecretNotFound
+}
+
 func (e K8sSecretNotFoundError) Error() string {
 	return fmt.Sprintf("[%s] secret '%s/%s' not found", e.ErrType, e.Namespace, e.Name)
 }
 
+func (e K8sSecretNotFoundError) Is(target error) bool {
+	return target == ErrK8sSecretNotFound
+}
+
 func (e InvalidConfigmapNameError) Error() string {
 	return fmt.Sprintf("[%s] invalid configmap name '%s'", e.ErrType, e.Value)
 }
 
+func (e InvalidConfigmapNameError) Is(target error) bool {
+	return target == ErrInvalidConfigmapName
+}
+
 func (e EncodingNotImplementedError) Error() string {
 	return fmt.Sprintf("[%s] encoding %s not supported", e.ErrType, e.Encoding)
 }
 
+func (e EncodingNotImplementedError) Is(target error) bool {
+	return target == ErrEncodingNotImplemented
+}
+
 func (e VaultEngineNotImplementedError) Error() string {
 	return fmt.Sprintf("[%s] vault engine %s not supported", e.ErrType, e.Engine)
 }
 
+func (e VaultEngineNotImplementedError) Is(target error) bool {
+	return target == ErrVaultEngineNotImplemented
+}
+
 func (e VaultTokenNotRenewableError) Error() string {
 	return fmt.Sprintf("[%s] vault token not renewable", e.ErrType)
 }
 
-// IsBackendNotImplemented returns true if the error is type of BackendNotImplementedError and false otherwise
+func (e VaultTokenNotRenewableError) Is(target error) bool {
+	return target == ErrVaultTokenNotRenewable
+}
+
+func (e VaultKubernetesAuthError) Error() string {
+	return fmt.Sprintf("[%s] unable to login to vault with kubernetes role %s: %v", e.ErrType, e.Role, e.Err)
+}
+
+func (e VaultKubernetesAuthError) Is(target error) bool {
+	return target == ErrVaultKubernetesAuth
+}
+
+func (e VaultKubernetesAuthError) Unwrap() error {
+	return e.Err
+}
+
+func (e VaultAppRoleAuthError) Error() string {
+	return fmt.Sprintf("[%s] unable to login to vault with approle role_id %s: %v", e.ErrType, e.RoleID, e.Err)
+}
+
+func (e VaultAppRoleAuthError) Is(target error) bool {
+	return target == ErrVaultAppRoleAuth
+}
+
+func (e VaultAppRoleAuthError) Unwrap() error {
+	return e.Err
+}
+
+func (e VaultTLSConfigError) Error() string {
+	return fmt.Sprintf("[%s] invalid vault tls configuration: %s", e.ErrType, e.Reason)
+}
+
+func (e VaultTLSConfigError) Is(target error) bool {
+	return target == ErrVaultTLSConfig
+}
+
+func (e VaultVersioningNotSupportedError) Error() string {
+	return fmt.Sprintf("[%s] vault engine %s does not support versioning", e.ErrType, e.Engine)
+}
+
+func (e VaultVersioningNotSupportedError) Is(target error) bool {
+	return target == ErrVaultVersioningNotSupported
+}
+
+func (e BackendSecretTypeError) Error() string {
+	return fmt.Sprintf("[%s] secret key %s at %s has unsupported type %s", e.ErrType, e.Key, e.Path, e.Type)
+}
+
+func (e BackendSecretTypeError) Is(target error) bool {
+	return target == ErrBackendSecretType
+}
+
+func (e BackendConfigError) Error() string {
+	return fmt.Sprintf("[%s] invalid %s backend configuration: %s", e.ErrType, e.Backend, e.Reason)
+}
+
+func (e BackendConfigError) Is(target error) bool {
+	return target == ErrBackendConfig
+}
+
+func (e BackendForbiddenError) Error() string {
+	return fmt.Sprintf("[%s] access to %s denied by %s backend", e.ErrType, e.Path, e.Backend)
+}
+
+func (e BackendForbiddenError) Is(target error) bool {
+	return target == ErrBackendForbidden
+}
+
+func (e VaultTransitError) Error() string {
+	return fmt.Sprintf("[%s] unable to decrypt with vault transit key %s: %v", e.ErrType, e.KeyName, e.Err)
+}
+
+func (e VaultTransitError) Is(target error) bool {
+	return target == ErrVaultTransit
+}
+
+func (e VaultTransitError) Unwrap() error {
+	return e.Err
+}
+
+func (e VaultPKIError) Error() string {
+	return fmt.Sprintf("[%s] unable to issue certificate with vault pki role %s: %v", e.ErrType, e.Role, e.Err)
+}
+
+func (e VaultPKIError) Is(target error) bool {
+	return target == ErrVaultPKI
+}
+
+func (e VaultPKIError) Unwrap() error {
+	return e.Err
+}
+
+func (e VaultTimeoutError) Error() string {
+	return fmt.Sprintf("[%s] vault request to %s timed out: %v", e.ErrType, e.Path, e.Err)
+}
+
+func (e VaultTimeoutError) Is(target error) bool {
+	return target == ErrVaultTimeout
+}
+
+func (e VaultTimeoutError) Unwrap() error {
+	return e.Err
+}
+
+func (e VaultSealedError) Error() string {
+	return fmt.Sprintf("[%s] vault is sealed, unable to read %s", e.ErrType, e.Path)
+}
+
+func (e VaultSealedError) Is(target error) bool {
+	return target == ErrVaultSealed
+}
+
+func (e VaultForbiddenError) Error() string {
+	return fmt.Sprintf("[%s] permission denied reading %s from vault", e.ErrType, e.Path)
+}
+
+func (e VaultForbiddenError) Is(target error) bool {
+	return target == ErrVaultForbidden
+}
+
+func (e VaultJWTAuthError) Error() string {
+	return fmt.Sprintf("[%s] unable to login to vault with jwt role %s: %v", e.ErrType, e.Role, e.Err)
+}
+
+func (e VaultJWTAuthError) Is(target error) bool {
+	return target == ErrVaultJWTAuth
+}
+
+func (e VaultJWTAuthError) Unwrap() error {
+	return e.Err
+}
+
+func (e VaultUnwrapError) Error() string {
+	return fmt.Sprintf("[%s] unable to unwrap vault token: %v", e.ErrType, e.Err)
+}
+
+func (e VaultUnwrapError) Is(target error) bool {
+	return target == ErrVaultUnwrap
+}
+
+func (e VaultUnwrapError) Unwrap() error {
+	return e.Err
+}
+
+func (e BackendSecretWriteError) Error() string {
+	return fmt.Sprintf("[%s] unable to write secret at %s: %v", e.ErrType, e.Path, e.Err)
+}
+
+func (e BackendSecretWriteError) Is(target error) bool {
+	return target == ErrBackendSecretWrite
+}
+
+func (e BackendSecretWriteError) Unwrap() error {
+	return e.Err
+}
+
+func (e VaultUserpassAuthError) Error() string {
+	return fmt.Sprintf("[%s] unable to login to vault with userpass user %s: %v", e.ErrType, e.Username, e.Err)
+}
+
+func (e VaultUserpassAuthError) Is(target error) bool {
+	return target == ErrVaultUserpassAuth
+}
+
+func (e VaultUserpassAuthError) Unwrap() error {
+	return e.Err
+}
+
+func (e VaultCertAuthError) Error() string {
+	return fmt.Sprintf("[%s] unable to login to vault with the tls client certificate and role %s: %v", e.ErrType, e.Role, e.Err)
+}
+
+func (e VaultCertAuthError) Is(target error) bool {
+	return target == ErrVaultCertAuth
+}
+
+func (e VaultCertAuthError) Unwrap() error {
+	return e.Err
+}
+
+func (e SecretTemplateError) Error() string {
+	return fmt.Sprintf("[%s] unable to render template for secret %s: %v", e.ErrType, e.Path, e.Err)
+}
+
+func (e SecretTemplateError) Is(target error) bool {
+	return target == ErrSecretTemplate
+}
+
+func (e SecretTemplateError) Unwrap() error {
+	return e.Err
+}
+
+func (e BackendSecretEncodingError) Error() string {
+	return fmt.Sprintf("[%s] unable to decode secret value as %s: %v", e.ErrType, e.Encoding, e.Err)
+}
+
+func (e BackendSecretEncodingError) Is(target error) bool {
+	return target == ErrBackendSecretEncoding
+}
+
+func (e BackendSecretEncodingError) Unwrap() error {
+	return e.Err
+}
+
+func (e SecretTreeError) Error() string {
+	msgs := make([]string, len(e.Errs))
+	for i, err := range e.Errs {
+		msgs[i] = err.Error()
+	}
+	return fmt.Sprintf("[%s] unable to read %d secrets under %s: %s", e.ErrType, len(e.Errs), e.Path, strings.Join(msgs, "; "))
+}
+
+// Is matches ErrSecretTree and the errors of the secrets that failed
+func (e SecretTreeError) Is(target error) bool {
+	if target == ErrSecretTree {
+		return true
+	}
+	for _, err := range e.Errs {
+		if stderrors.Is(err, target) {
+			return true
+		}
+	}
+	return false
+}
+
+// As finds the first error of Errs matching target
+func (e SecretTreeError) As(target interface{}) bool {
+	for _, err := range e.Errs {
+		if stderrors.As(err, target) {
+			return true
+		}
+	}
+	return false
+}
+
+func (e VaultAWSAuthError) Error() string {
+	return fmt.Sprintf("[%s] unable to login to vault with the AWS IAM credentials and role %s: %v", e.ErrType, e.Role, e.Err)
+}
+
+func (e VaultAWSAuthError) Is(target error) bool {
+	return target == ErrVaultAWSAuth
+}
+
+func (e VaultAWSAuthError) Unwrap() error {
+	return e.Err
+}
+
+func (e VaultGCPAuthError) Error() string {
+	return fmt.Sprintf("[%s] unable to login to vault with the GCP instance identity token and role %s: %v", e.ErrType, e.Role, e.Err)
+}
+
+func (e VaultGCPAuthError) Is(target error) bool {
+	return target == ErrVaultGCPAuth
+}
+
+func (e VaultGCPAuthError) Unwrap() error {
+	return e.Err
+}
+
+func (e VaultAzureAuthError) Error() string {
+	return fmt.Sprintf("[%s] unable to login to vault with the Azure managed identity and role %s: %v", e.ErrType, e.Role, e.Err)
+}
+
+func (e VaultAzureAuthError) Is(target error) bool {
+	return target == ErrVaultAzureAuth
+}
+
+func (e VaultAzureAuthError) Unwrap() error {
+	return e.Err
+}
+
+func (e VaultTokenMalformedError) Error() string {
+	return fmt.Sprintf("[%s] vault token lookup response is malformed: %s", e.ErrType, e.Reason)
+}
+
+func (e VaultTokenMalformedError) Is(target error) bool {
+	return target == ErrVaultTokenMalformed
+}
+
+func (e SecretPathError) Error() string {
+	return fmt.Sprintf("[%s] path %s of namespace %s is not allowed: %s", e.ErrType, e.Path, e.Namespace, e.Reason)
+}
+
+func (e SecretPathError) Is(target error) bool {
+	return target == ErrSecretPath
+}
+
+func (e HashNotImplementedError) Error() string {
+	return fmt.Sprintf("[%s] hash algorithm %s not implemented", e.ErrType, e.Algorithm)
+}
+
+func (e HashNotImplementedError) Is(target error) bool {
+	return target == ErrHashNotImplemented
+}
+
+func (e VaultSecretDeletedError) Error() string {
+	return fmt.Sprintf("[%s] secret %s version %s was deleted at %s", e.ErrType, e.Path, e.Version, e.DeletionTime)
+}
+
+func (e VaultSecretDeletedError) Is(target error) bool {
+	return target == ErrVaultSecretDeleted
+}
+
+func (e VaultSecretDestroyedError) Error() string {
+	return fmt.Sprintf("[%s] secret %s version %s was destroyed", e.ErrType, e.Path, e.Version)
+}
+
+func (e VaultSecretDestroyedError) Is(target error) bool {
+	return target == ErrVaultSecretDestroyed
+}
+
+func (e VaultTokenRenewNoProgressError) Error() string {
+	return fmt.Sprintf("[%s] vault token renewal didn't extend its TTL of %ds", e.ErrType, e.TTL)
+}
+
+func (e VaultTokenRenewNoProgressError) Is(target error) bool {
+	return target == ErrVaultTokenRenewNoProgress
+}
+
+func (e VaultCircuitOpenError) Error() string {
+	return fmt.Sprintf("[%s] circuit breaker open, not reading %s from vault, retrying in %s", e.ErrType, e.Path, e.RetryIn)
+}
+
+func (e VaultCircuitOpenError) Is(target error) bool {
+	return target == ErrVaultCircuitOpen
+}
+
+func (e VaultRoleTokenError) Error() string {
+	return fmt.Sprintf("[%s] unable to create a vault token with role %s: %v", e.ErrType, e.Role, e.Err)
+}
+
+func (e VaultRoleTokenError) Is(target error) bool {
+	return target == ErrVaultRoleToken
+}
+
+func (e VaultRoleTokenError) Unwrap() error {
+	return e.Err
+}
+
+func (e VaultTokenTTLTooShortError) Error() string {
+	return fmt.Sprintf("[%s] vault token ttl %ds is not renewable and shorter than the minimum %ds, it would expire before it is checked again", e.ErrType, e.TTL, e.MinTTL)
+}
+
+func (e VaultTokenTTLTooShortError) Is(target error) bool {
+	return target == ErrVaultTokenTTLTooShort
+}
+
+func (e SecretKeyCollisionError) Error() string {
+	return fmt.Sprintf("[%s] secret key %s read from %s is already set by another datasource", e.ErrType, e.Key, e.Path)
+}
+
+func (e SecretKeyCollisionError) Is(target error) bool {
+	return target == ErrSecretKeyCollision
+}
+
+func (e VaultCASMismatchError) Error() string {
+	return fmt.Sprintf("[%s] unable to write secret %s, it is no longer at version %d", e.ErrType, e.Path, e.Version)
+}
+
+func (e VaultCASMismatchError) Is(target error) bool {
+	return target == ErrVaultCASMismatch
+}
+
+func (e BackendSecretTooLargeError) Error() string {
+	return fmt.Sprintf("[%s] secret %s key %s is %d bytes, larger than the %d bytes limit", e.ErrType, e.Path, e.Key, e.Size, e.MaxSize)
+}
+
+func (e BackendSecretTooLargeError) Is(target error) bool {
+	return target == ErrBackendSecretTooLarge
+}
+
+func (e PathNotAllowedError) Error() string {
+	return fmt.Sprintf("[%s] reading %s is not allowed: %s", e.ErrType, e.Path, e.Reason)
+}
+
+func (e PathNotAllowedError) Is(target error) bool {
+	return target == ErrPathNotAllowed
+}
+
+func (e VaultTokenCreateError) Error() string {
+	return fmt.Sprintf("[%s] unable to create a vault child token with role %s: %v", e.ErrType, e.Role, e.Err)
+}
+
+func (e VaultTokenCreateError) Is(target error) bool {
+	return target == ErrVaultTokenCreate
+}
+
+func (e VaultTokenCreateError) Unwrap() error {
+	return e.Err
+}
+
+func (e BackendSecretKeysError) Error() string {
+	msgs := make([]string, len(e.Errs))
+	for i, err := range e.Errs {
+		msgs[i] = err.Error()
+	}
+	return fmt.Sprintf("[%s] unable to read %d keys of %s: %s", e.ErrType, len(e.Errs), e.Path, strings.Join(msgs, "; "))
+}
+
+func (e VaultWrapValidationError) Error() string {
+	return fmt.Sprintf("[%s] vault wrapping token expected from %s is not valid: %s", e.ErrType, e.Path, e.Reason)
+}
+
+func (e VaultWrapValidationError) Is(target error) bool {
+	return target == ErrVaultWrapValidation
+}
+
+// Is matches ErrBackendSecretKeys and the errors of the keys that failed
+func (e BackendSecretKeysError) Is(target error) bool {
+	if target == ErrBackendSecretKeys {
+		return true
+	}
+	for _, err := range e.Errs {
+		if stderrors.Is(err, target) {
+			return true
+		}
+	}
+	return false
+}
+
+// As finds the first error of Errs matching target
+func (e BackendSecretKeysError) As(target interface{}) bool {
+	for _, err := range e.Errs {
+		if stderrors.As(err, target) {
+			return true
+		}
+	}
+	return false
+}
+
+// GetErrorType returns the error type of any of the errors defined in this package or UnknownErrorType otherwise
+func GetErrorType(err error) string {
+	return getErrorType(err)
+}
+
+// IsBackendNotImplemented returns true if the error, or any error it wraps, is type of BackendNotImplementedError and false otherwise
 func IsBackendNotImplemented(err error) bool {
-	return getErrorType(err) == BackendNotImplementedErrorType
+	return stderrors.Is(err, ErrBackendNotImplemented)
 }
 
-// IsBackendSecretNotFound returns true if the error is type of BackendSecretNotFound and false otherwise
+// IsBackendSecretNotFound returns true if the error, or any error it wraps, is type of BackendSecretNotFound and false otherwise
 func IsBackendSecretNotFound(err error) bool {
-	return getErrorType(err) == BackendSecretNotFoundErrorType
+	return stderrors.Is(err, ErrBackendSecretNotFound)
 }
 
-// IsK8sSecretNotFound returns true if the error is type of K8sSecretNotFound and false otherwise
+// IsK8sSecretNotFound returns true if the error, or any error it wraps, is type of K8sSecretNotFound and false otherwise
 func IsK8sSecretNotFound(err error) bool {
-	return getErrorType(err) == K8sSecretNotFoundErrorType
+	return stderrors.Is(err, ErrK8sSecretNotFound)
 }
 
-// IsInvalidConfigmapName returns true if the error is type of InvalidConfigmapName and false otherwise
+// IsInvalidConfigmapName returns true if the error, or any error it wraps, is type of InvalidConfigmapName and false otherwise
 func IsInvalidConfigmapName(err error) bool {
-	return getErrorType(err) == InvalidConfigmapNameErrorType
+	return stderrors.Is(err, ErrInvalidConfigmapName)
 }
 
-// IsEncodingNotImplemented returns true if the error is type of EncodingNotImplementedError and false otherwise
+// IsEncodingNotImplemented returns true if the error, or any error it wraps, is type of EncodingNotImplementedError and false otherwise
 func IsEncodingNotImplemented(err error) bool {
-	return getErrorType(err) == EncodingNotImplementedErrorType
+	return stderrors.Is(err, ErrEncodingNotImplemented)
 }
 
-// IsVaultEngineNotImplemented returns true if the error is type of VaultEngineNotImplementedError and false otherwise
+// IsVaultEngineNotImplemented returns true if the error, or any error it wraps, is type of VaultEngineNotImplementedError and false otherwise
 func IsVaultEngineNotImplemented(err error) bool {
-	return getErrorType(err) == VaultEngineNotImplementedErrorType
+	return stderrors.Is(err, ErrVaultEngineNotImplemented)
 }
 
-// IsVaultTokenNotRenewable returns true if the error is type of VaultTokenNotRenewableError and false otherwise
+// IsVaultTokenNotRenewable returns true if the error, or any error it wraps, is type of VaultTokenNotRenewableError and false otherwise
 func IsVaultTokenNotRenewable(err error) bool {
-	return getErrorType(err) == VaultTokenNotRenewableErrorType
+	return stderrors.Is(err, ErrVaultTokenNotRenewable)
+}
+
+// IsVaultKubernetesAuth returns true if the error, or any error it wraps, is type of VaultKubernetesAuthError and false otherwise
+func IsVaultKubernetesAuth(err error) bool {
+	return stderrors.Is(err, ErrVaultKubernetesAuth)
+}
+
+// IsVaultAppRoleAuth returns true if the error, or any error it wraps, is type of VaultAppRoleAuthError and false otherwise
+func IsVaultAppRoleAuth(err error) bool {
+	return stderrors.Is(err, ErrVaultAppRoleAuth)
+}
+
+// IsVaultTLSConfig returns true if the error, or any error it wraps, is type of VaultTLSConfigError and false otherwise
+func IsVaultTLSConfig(err error) bool {
+	return stderrors.Is(err, ErrVaultTLSConfig)
+}
+
+// IsVaultVersioningNotSupported returns true if the error, or any error it wraps, is type of VaultVersioningNotSupportedError and false otherwise
+func IsVaultVersioningNotSupported(err error) bool {
+	return stderrors.Is(err, ErrVaultVersioningNotSupported)
+}
+
+// IsBackendSecretType returns true if the error, or any error it wraps, is type of BackendSecretTypeError and false otherwise
+func IsBackendSecretType(err error) bool {
+	return stderrors.Is(err, ErrBackendSecretType)
+}
+
+// IsBackendConfig returns true if the error, or any error it wraps, is type of BackendConfigError and false otherwise
+func IsBackendConfig(err error) bool {
+	return stderrors.Is(err, ErrBackendConfig)
+}
+
+// IsBackendForbidden returns true if the error, or any error it wraps, is type of BackendForbiddenError and false otherwise
+func IsBackendForbidden(err error) bool {
+	return stderrors.Is(err, ErrBackendForbidden)
+}
+
+// IsVaultTransit returns true if the error, or any error it wraps, is type of VaultTransitError and false otherwise
+func IsVaultTransit(err error) bool {
+	return stderrors.Is(err, ErrVaultTransit)
+}
+
+// IsVaultPKI returns true if the error, or any error it wraps, is type of VaultPKIError and false otherwise
+func IsVaultPKI(err error) bool {
+	return stderrors.Is(err, ErrVaultPKI)
+}
+
+// IsVaultTimeout returns true if the error, or any error it wraps, is type of VaultTimeoutError and false otherwise
+func IsVaultTimeout(err error) bool {
+	return stderrors.Is(err, ErrVaultTimeout)
+}
+
+// IsVaultSealed returns true if the error, or any error it wraps, is type of VaultSealedError and false otherwise
+func IsVaultSealed(err error) bool {
+	return stderrors.Is(err, ErrVaultSealed)
+}
+
+// IsVaultForbidden returns true if the error, or any error it wraps, is type of VaultForbiddenError and false otherwise
+func IsVaultForbidden(err error) bool {
+	return stderrors.Is(err, ErrVaultForbidden)
+}
+
+// IsVaultJWTAuth returns true if the error, or any error it wraps, is type of VaultJWTAuthError and false otherwise
+func IsVaultJWTAuth(err error) bool {
+	return stderrors.Is(err, ErrVaultJWTAuth)
+}
+
+// IsVaultUnwrap returns true if the error, or any error it wraps, is type of VaultUnwrapError and false otherwise
+func IsVaultUnwrap(err error) bool {
+	return stderrors.Is(err, ErrVaultUnwrap)
+}
+
+// IsBackendSecretWrite returns true if the error, or any error it wraps, is type of BackendSecretWriteError and false otherwise
+func IsBackendSecretWrite(err error) bool {
+	return stderrors.Is(err, ErrBackendSecretWrite)
+}
+
+// IsVaultUserpassAuth returns true if the error, or any error it wraps, is type of VaultUserpassAuthError and false otherwise
+func IsVaultUserpassAuth(err error) bool {
+	return stderrors.Is(err, ErrVaultUserpassAuth)
+}
+
+// IsVaultCertAuth returns true if the error, or any error it wraps, is type of VaultCertAuthError and false otherwise
+func IsVaultCertAuth(err error) bool {
+	return stderrors.Is(err, ErrVaultCertAuth)
+}
+
+// IsSecretTemplate returns true if the error, or any error it wraps, is type of SecretTemplateError and false otherwise
+func IsSecretTemplate(err error) bool {
+	return stderrors.Is(err, ErrSecretTemplate)
+}
+
+// IsBackendSecretEncoding returns true if the error, or any error it wraps, is type of BackendSecretEncodingError and false otherwise
+func IsBackendSecretEncoding(err error) bool {
+	return stderrors.Is(err, ErrBackendSecretEncoding)
+}
+
+// IsSecretTree returns true if the error, or any error it wraps, is type of SecretTreeError and false otherwise
+func IsSecretTree(err error) bool {
+	return stderrors.Is(err, ErrSecretTree)
+}
+
+// IsVaultAWSAuth returns true if the error, or any error it wraps, is type of VaultAWSAuthError and false otherwise
+func IsVaultAWSAuth(err error) bool {
+	return stderrors.Is(err, ErrVaultAWSAuth)
+}
+
+// IsVaultGCPAuth returns true if the error, or any error it wraps, is type of VaultGCPAuthError and false otherwise
+func IsVaultGCPAuth(err error) bool {
+	return stderrors.Is(err, ErrVaultGCPAuth)
+}
+
+// IsVaultAzureAuth returns true if the error, or any error it wraps, is type of VaultAzureAuthError and false otherwise
+func IsVaultAzureAuth(err error) bool {
+	return stderrors.Is(err, ErrVaultAzureAuth)
+}
+
+// IsVaultTokenMalformed returns true if the error, or any error it wraps, is type of VaultTokenMalformedError and false otherwise
+func IsVaultTokenMalformed(err error) bool {
+	return stderrors.Is(err, ErrVaultTokenMalformed)
+}
+
+// IsSecretPath returns true if the error, or any error it wraps, is type of SecretPathError and false otherwise
+func IsSecretPath(err error) bool {
+	return stderrors.Is(err, ErrSecretPath)
+}
+
+// IsHashNotImplemented returns true if the error, or any error it wraps, is type of HashNotImplementedError and false otherwise
+func IsHashNotImplemented(err error) bool {
+	return stderrors.Is(err, ErrHashNotImplemented)
+}
+
+// IsVaultSecretDeleted returns true if the error, or any error it wraps, is type of VaultSecretDeletedError and false otherwise
+func IsVaultSecretDeleted(err error) bool {
+	return stderrors.Is(err, ErrVaultSecretDeleted)
+}
+
+// IsVaultSecretDestroyed returns true if the error, or any error it wraps, is type of VaultSecretDestroyedError and false otherwise
+func IsVaultSecretDestroyed(err error) bool {
+	return stderrors.Is(err, ErrVaultSecretDestroyed)
+}
+
+// IsVaultTokenRenewNoProgress returns true if the error, or any error it wraps, is type of VaultTokenRenewNoProgressError and false otherwise
+func IsVaultTokenRenewNoProgress(err error) bool {
+	return stderrors.Is(err, ErrVaultTokenRenewNoProgress)
+}
+
+// IsVaultCircuitOpen returns true if the error, or any error it wraps, is type of VaultCircuitOpenError and false otherwise
+func IsVaultCircuitOpen(err error) bool {
+	return stderrors.Is(err, ErrVaultCircuitOpen)
+}
+
+// IsVaultRoleToken returns true if the error, or any error it wraps, is type of VaultRoleTokenError and false otherwise
+func IsVaultRoleToken(err error) bool {
+	return stderrors.Is(err, ErrVaultRoleToken)
+}
+
+// IsVaultTokenTTLTooShort returns true if the error, or any error it wraps, is type of VaultTokenTTLTooShortError and false otherwise
+func IsVaultTokenTTLTooShort(err error) bool {
+	return stderrors.Is(err, ErrVaultTokenTTLTooShort)
+}
+
+// IsSecretKeyCollision returns true if the error, or any error it wraps, is type of SecretKeyCollisionError and false otherwise
+func IsSecretKeyCollision(err error) bool {
+	return stderrors.Is(err, ErrSecretKeyCollision)
+}
+
+// IsVaultCASMismatch returns true if the error, or any error it wraps, is type of VaultCASMismatchError and false otherwise
+func IsVaultCASMismatch(err error) bool {
+	return stderrors.Is(err, ErrVaultCASMismatch)
+}
+
+// IsBackendSecretTooLarge returns true if the error, or any error it wraps, is type of BackendSecretTooLargeError and false otherwise
+func IsBackendSecretTooLarge(err error) bool {
+	return stderrors.Is(err, ErrBackendSecretTooLarge)
+}
+
+// IsPathNotAllowed returns true if the error, or any error it wraps, is type of PathNotAllowedError and false otherwise
+func IsPathNotAllowed(err error) bool {
+	return stderrors.Is(err, ErrPathNotAllowed)
+}
+
+// IsVaultTokenCreate returns true if the error, or any error it wraps, is type of VaultTokenCreateError and false otherwise
+func IsVaultTokenCreate(err error) bool {
+	return stderrors.Is(err, ErrVaultTokenCreate)
+}
+
+// IsBackendSecretKeys returns true if the error, or any error it wraps, is type of BackendSecretKeysError and false otherwise
+func IsBackendSecretKeys(err error) bool {
+	return stderrors.Is(err, ErrBackendSecretKeys)
+}
+
+// IsVaultWrapValidation returns true if the error, or any error it wraps, is type of VaultWrapValidationError and false otherwise
+func IsVaultWrapValidation(err error) bool {
+	return stderrors.Is(err, ErrVaultWrapValidation)
 }
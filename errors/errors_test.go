@@ -1,8 +1,10 @@
 package errors
 
 import (
+	"context"
 	e "errors"
 	"fmt"
+	"io"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -23,6 +25,84 @@ func TestErrorString(t *testing.T) {
 	assert.EqualError(t, err6, fmt.Sprintf("[%s] vault engine %s not supported", err6.ErrType, err6.Engine))
 	err7 := &VaultTokenNotRenewableError{ErrType: VaultTokenNotRenewableErrorType}
 	assert.EqualError(t, err7, fmt.Sprintf("[%s] vault token not renewable", err7.ErrType))
+	err8 := &VaultKubernetesAuthError{ErrType: VaultKubernetesAuthErrorType, Role: "foo", Err: e.New("bar")}
+	assert.EqualError(t, err8, fmt.Sprintf("[%s] unable to login to vault with kubernetes role %s: %v", err8.ErrType, err8.Role, err8.Err))
+	err9 := &VaultAppRoleAuthError{ErrType: VaultAppRoleAuthErrorType, RoleID: "foo", Err: e.New("bar")}
+	assert.EqualError(t, err9, fmt.Sprintf("[%s] unable to login to vault with approle role_id %s: %v", err9.ErrType, err9.RoleID, err9.Err))
+	err10 := &VaultTLSConfigError{ErrType: VaultTLSConfigErrorType, Reason: "foo"}
+	assert.EqualError(t, err10, fmt.Sprintf("[%s] invalid vault tls configuration: %s", err10.ErrType, err10.Reason))
+	err11 := &VaultVersioningNotSupportedError{ErrType: VaultVersioningNotSupportedErrorType, Engine: "kv1"}
+	assert.EqualError(t, err11, fmt.Sprintf("[%s] vault engine %s does not support versioning", err11.ErrType, err11.Engine))
+	err12 := &BackendSecretTypeError{ErrType: BackendSecretTypeErrorType, Path: "foo", Key: "bar", Type: "float64"}
+	assert.EqualError(t, err12, fmt.Sprintf("[%s] secret key %s at %s has unsupported type %s", err12.ErrType, err12.Key, err12.Path, err12.Type))
+	err13 := &BackendConfigError{ErrType: BackendConfigErrorType, Backend: "foo", Reason: "bar"}
+	assert.EqualError(t, err13, fmt.Sprintf("[%s] invalid %s backend configuration: %s", err13.ErrType, err13.Backend, err13.Reason))
+	err14 := &BackendForbiddenError{ErrType: BackendForbiddenErrorType, Backend: "foo", Path: "bar"}
+	assert.EqualError(t, err14, fmt.Sprintf("[%s] access to %s denied by %s backend", err14.ErrType, err14.Path, err14.Backend))
+	err15 := &VaultTransitError{ErrType: VaultTransitErrorType, KeyName: "foo", Err: e.New("bar")}
+	assert.EqualError(t, err15, fmt.Sprintf("[%s] unable to decrypt with vault transit key %s: %v", err15.ErrType, err15.KeyName, err15.Err))
+	err16 := &VaultPKIError{ErrType: VaultPKIErrorType, Role: "foo", Err: e.New("bar")}
+	assert.EqualError(t, err16, fmt.Sprintf("[%s] unable to issue certificate with vault pki role %s: %v", err16.ErrType, err16.Role, err16.Err))
+	err17 := &VaultTimeoutError{ErrType: VaultTimeoutErrorType, Path: "foo", Err: e.New("bar")}
+	assert.EqualError(t, err17, fmt.Sprintf("[%s] vault request to %s timed out: %v", err17.ErrType, err17.Path, err17.Err))
+	err18 := &VaultSealedError{ErrType: VaultSealedErrorType, Path: "foo"}
+	assert.EqualError(t, err18, fmt.Sprintf("[%s] vault is sealed, unable to read %s", err18.ErrType, err18.Path))
+	err19 := &VaultForbiddenError{ErrType: VaultForbiddenErrorType, Path: "foo"}
+	assert.EqualError(t, err19, fmt.Sprintf("[%s] permission denied reading %s from vault", err19.ErrType, err19.Path))
+	err20 := &VaultJWTAuthError{ErrType: VaultJWTAuthErrorType, Role: "foo", Err: e.New("bar")}
+	assert.EqualError(t, err20, fmt.Sprintf("[%s] unable to login to vault with jwt role %s: %v", err20.ErrType, err20.Role, err20.Err))
+	err21 := &VaultUnwrapError{ErrType: VaultUnwrapErrorType, Err: e.New("bar")}
+	assert.EqualError(t, err21, fmt.Sprintf("[%s] unable to unwrap vault token: %v", err21.ErrType, err21.Err))
+	err22 := &BackendSecretWriteError{ErrType: BackendSecretWriteErrorType, Path: "foo", Err: e.New("bar")}
+	assert.EqualError(t, err22, fmt.Sprintf("[%s] unable to write secret at %s: %v", err22.ErrType, err22.Path, err22.Err))
+	err23 := &VaultUserpassAuthError{ErrType: VaultUserpassAuthErrorType, Username: "foo", Err: e.New("bar")}
+	assert.EqualError(t, err23, fmt.Sprintf("[%s] unable to login to vault with userpass user %s: %v", err23.ErrType, err23.Username, err23.Err))
+	err24 := &VaultCertAuthError{ErrType: VaultCertAuthErrorType, Role: "foo", Err: e.New("bar")}
+	assert.EqualError(t, err24, fmt.Sprintf("[%s] unable to login to vault with the tls client certificate and role %s: %v", err24.ErrType, err24.Role, err24.Err))
+	err25 := &SecretTemplateError{ErrType: SecretTemplateErrorType, Path: "foo", Err: e.New("bar")}
+	assert.EqualError(t, err25, fmt.Sprintf("[%s] unable to render template for secret %s: %v", err25.ErrType, err25.Path, err25.Err))
+	err26 := &BackendSecretEncodingError{ErrType: BackendSecretEncodingErrorType, Encoding: "base64", Err: e.New("bar")}
+	assert.EqualError(t, err26, fmt.Sprintf("[%s] unable to decode secret value as %s: %v", err26.ErrType, err26.Encoding, err26.Err))
+	err27 := &SecretTreeError{ErrType: SecretTreeErrorType, Path: "foo", Errs: []error{e.New("bar"), e.New("baz")}}
+	assert.EqualError(t, err27, fmt.Sprintf("[%s] unable to read 2 secrets under %s: bar; baz", err27.ErrType, err27.Path))
+	err28 := &VaultAWSAuthError{ErrType: VaultAWSAuthErrorType, Role: "foo", Err: e.New("bar")}
+	assert.EqualError(t, err28, fmt.Sprintf("[%s] unable to login to vault with the AWS IAM credentials and role %s: %v", err28.ErrType, err28.Role, err28.Err))
+	err29 := &VaultGCPAuthError{ErrType: VaultGCPAuthErrorType, Role: "foo", Err: e.New("bar")}
+	assert.EqualError(t, err29, fmt.Sprintf("[%s] unable to login to vault with the GCP instance identity token and role %s: %v", err29.ErrType, err29.Role, err29.Err))
+	err30 := &VaultAzureAuthError{ErrType: VaultAzureAuthErrorType, Role: "foo", Err: e.New("bar")}
+	assert.EqualError(t, err30, fmt.Sprintf("[%s] unable to login to vault with the Azure managed identity and role %s: %v", err30.ErrType, err30.Role, err30.Err))
+	err31 := &VaultTokenMalformedError{ErrType: VaultTokenMalformedErrorType, Reason: "foo"}
+	assert.EqualError(t, err31, fmt.Sprintf("[%s] vault token lookup response is malformed: %s", err31.ErrType, err31.Reason))
+	err32 := &SecretPathError{ErrType: SecretPathErrorType, Namespace: "team-a", Path: "../team-b/db", Reason: "it escapes the namespace prefix"}
+	assert.EqualError(t, err32, fmt.Sprintf("[%s] path %s of namespace %s is not allowed: %s", err32.ErrType, err32.Path, err32.Namespace, err32.Reason))
+	err33 := &HashNotImplementedError{ErrType: HashNotImplementedErrorType, Algorithm: "md5"}
+	assert.EqualError(t, err33, fmt.Sprintf("[%s] hash algorithm %s not implemented", err33.ErrType, err33.Algorithm))
+	err34 := &VaultSecretDeletedError{ErrType: VaultSecretDeletedErrorType, Path: "secret/data/foo", Version: "3", DeletionTime: "2019-06-01T10:00:00Z"}
+	assert.EqualError(t, err34, fmt.Sprintf("[%s] secret %s version %s was deleted at %s", err34.ErrType, err34.Path, err34.Version, err34.DeletionTime))
+	err35 := &VaultSecretDestroyedError{ErrType: VaultSecretDestroyedErrorType, Path: "secret/data/foo", Version: "3"}
+	assert.EqualError(t, err35, fmt.Sprintf("[%s] secret %s version %s was destroyed", err35.ErrType, err35.Path, err35.Version))
+	err36 := &VaultTokenRenewNoProgressError{ErrType: VaultTokenRenewNoProgressErrorType, TTL: 300}
+	assert.EqualError(t, err36, fmt.Sprintf("[%s] vault token renewal didn't extend its TTL of %ds", err36.ErrType, err36.TTL))
+	err37 := &VaultCircuitOpenError{ErrType: VaultCircuitOpenErrorType, Path: "secret/data/foo", RetryIn: "30s"}
+	assert.EqualError(t, err37, fmt.Sprintf("[%s] circuit breaker open, not reading %s from vault, retrying in %s", err37.ErrType, err37.Path, err37.RetryIn))
+	err38 := &VaultRoleTokenError{ErrType: VaultRoleTokenErrorType, Role: "team-a", Err: e.New("denied")}
+	assert.EqualError(t, err38, fmt.Sprintf("[%s] unable to create a vault token with role %s: %v", err38.ErrType, err38.Role, err38.Err))
+	err39 := &VaultTokenTTLTooShortError{ErrType: VaultTokenTTLTooShortErrorType, TTL: 5, MinTTL: 90}
+	assert.EqualError(t, err39, fmt.Sprintf("[%s] vault token ttl %ds is not renewable and shorter than the minimum %ds, it would expire before it is checked again", err39.ErrType, err39.TTL, err39.MinTTL))
+	err40 := &SecretKeyCollisionError{ErrType: SecretKeyCollisionErrorType, Key: "POSTGRES_PASSWORD", Path: "secret/data/db"}
+	assert.EqualError(t, err40, fmt.Sprintf("[%s] secret key %s read from %s is already set by another datasource", err40.ErrType, err40.Key, err40.Path))
+	err41 := &VaultCASMismatchError{ErrType: VaultCASMismatchErrorType, Path: "secret/data/foo", Version: 3}
+	assert.EqualError(t, err41, fmt.Sprintf("[%s] unable to write secret %s, it is no longer at version %d", err41.ErrType, err41.Path, err41.Version))
+	err42 := &BackendSecretTooLargeError{ErrType: BackendSecretTooLargeErrorType, Path: "secret/data/foo", Key: "kubeconfig", Size: 2048, MaxSize: 1024}
+	assert.EqualError(t, err42, fmt.Sprintf("[%s] secret %s key %s is %d bytes, larger than the %d bytes limit", err42.ErrType, err42.Path, err42.Key, err42.Size, err42.MaxSize))
+	err43 := &PathNotAllowedError{ErrType: PathNotAllowedErrorType, Path: "secret/data/admin", Reason: "it matches the denied prefix secret/data/admin"}
+	assert.EqualError(t, err43, fmt.Sprintf("[%s] reading %s is not allowed: %s", err43.ErrType, err43.Path, err43.Reason))
+	err44 := &VaultTokenCreateError{ErrType: VaultTokenCreateErrorType, Role: "secrets-manager", Err: e.New("denied")}
+	assert.EqualError(t, err44, fmt.Sprintf("[%s] unable to create a vault child token with role %s: %v", err44.ErrType, err44.Role, err44.Err))
+	err45 := &BackendSecretKeysError{ErrType: BackendSecretKeysErrorType, Path: "secret/data/app", Errs: []error{e.New("foo"), e.New("bar")}}
+	assert.EqualError(t, err45, fmt.Sprintf("[%s] unable to read 2 keys of %s: foo; bar", err45.ErrType, err45.Path))
+	err46 := &VaultWrapValidationError{ErrType: VaultWrapValidationErrorType, Path: "foo", CreationPath: "bar", Reason: "baz"}
+	assert.EqualError(t, err46, fmt.Sprintf("[%s] vault wrapping token expected from %s is not valid: %s", err46.ErrType, err46.Path, err46.Reason))
 }
 
 func TestGetErrorType(t *testing.T) {
@@ -42,6 +122,90 @@ func TestGetErrorType(t *testing.T) {
 	assert.Equal(t, getErrorType(err7), VaultEngineNotImplementedErrorType)
 	err8 := &VaultTokenNotRenewableError{ErrType: VaultTokenNotRenewableErrorType}
 	assert.Equal(t, getErrorType(err8), VaultTokenNotRenewableErrorType)
+	err9 := &VaultKubernetesAuthError{ErrType: VaultKubernetesAuthErrorType}
+	assert.Equal(t, getErrorType(err9), VaultKubernetesAuthErrorType)
+	err10 := &VaultAppRoleAuthError{ErrType: VaultAppRoleAuthErrorType}
+	assert.Equal(t, getErrorType(err10), VaultAppRoleAuthErrorType)
+	err11 := &VaultTLSConfigError{ErrType: VaultTLSConfigErrorType}
+	assert.Equal(t, getErrorType(err11), VaultTLSConfigErrorType)
+	err12 := &VaultVersioningNotSupportedError{ErrType: VaultVersioningNotSupportedErrorType}
+	assert.Equal(t, getErrorType(err12), VaultVersioningNotSupportedErrorType)
+	err13 := &BackendSecretTypeError{ErrType: BackendSecretTypeErrorType}
+	assert.Equal(t, getErrorType(err13), BackendSecretTypeErrorType)
+	err14 := &BackendConfigError{ErrType: BackendConfigErrorType}
+	assert.Equal(t, getErrorType(err14), BackendConfigErrorType)
+	err15 := &BackendForbiddenError{ErrType: BackendForbiddenErrorType}
+	assert.Equal(t, getErrorType(err15), BackendForbiddenErrorType)
+	err16 := &VaultTransitError{ErrType: VaultTransitErrorType}
+	assert.Equal(t, getErrorType(err16), VaultTransitErrorType)
+	err17 := &VaultPKIError{ErrType: VaultPKIErrorType}
+	assert.Equal(t, getErrorType(err17), VaultPKIErrorType)
+	err18 := &VaultTimeoutError{ErrType: VaultTimeoutErrorType}
+	assert.Equal(t, getErrorType(err18), VaultTimeoutErrorType)
+	err19 := &VaultSealedError{ErrType: VaultSealedErrorType}
+	assert.Equal(t, getErrorType(err19), VaultSealedErrorType)
+	err20 := &VaultForbiddenError{ErrType: VaultForbiddenErrorType}
+	assert.Equal(t, getErrorType(err20), VaultForbiddenErrorType)
+	err21 := &VaultJWTAuthError{ErrType: VaultJWTAuthErrorType}
+	assert.Equal(t, getErrorType(err21), VaultJWTAuthErrorType)
+	err22 := &VaultUnwrapError{ErrType: VaultUnwrapErrorType}
+	assert.Equal(t, getErrorType(err22), VaultUnwrapErrorType)
+	err23 := &BackendSecretWriteError{ErrType: BackendSecretWriteErrorType}
+	assert.Equal(t, getErrorType(err23), BackendSecretWriteErrorType)
+	err24 := &VaultUserpassAuthError{ErrType: VaultUserpassAuthErrorType}
+	assert.Equal(t, getErrorType(err24), VaultUserpassAuthErrorType)
+	err25 := &VaultCertAuthError{ErrType: VaultCertAuthErrorType}
+	assert.Equal(t, getErrorType(err25), VaultCertAuthErrorType)
+	err26 := &SecretTemplateError{ErrType: SecretTemplateErrorType}
+	assert.Equal(t, getErrorType(err26), SecretTemplateErrorType)
+	err27 := &BackendSecretEncodingError{ErrType: BackendSecretEncodingErrorType}
+	assert.Equal(t, getErrorType(err27), BackendSecretEncodingErrorType)
+	err28 := &SecretTreeError{ErrType: SecretTreeErrorType}
+	assert.Equal(t, getErrorType(err28), SecretTreeErrorType)
+	err29 := &VaultAWSAuthError{ErrType: VaultAWSAuthErrorType}
+	assert.Equal(t, getErrorType(err29), VaultAWSAuthErrorType)
+	err30 := &VaultGCPAuthError{ErrType: VaultGCPAuthErrorType}
+	assert.Equal(t, getErrorType(err30), VaultGCPAuthErrorType)
+	err31 := &VaultAzureAuthError{ErrType: VaultAzureAuthErrorType}
+	assert.Equal(t, getErrorType(err31), VaultAzureAuthErrorType)
+	err32 := &VaultTokenMalformedError{ErrType: VaultTokenMalformedErrorType}
+	assert.Equal(t, getErrorType(err32), VaultTokenMalformedErrorType)
+	err33 := &SecretPathError{ErrType: SecretPathErrorType}
+	assert.Equal(t, getErrorType(err33), SecretPathErrorType)
+	err34 := &HashNotImplementedError{ErrType: HashNotImplementedErrorType}
+	assert.Equal(t, getErrorType(err34), HashNotImplementedErrorType)
+	err35 := &VaultSecretDeletedError{ErrType: VaultSecretDeletedErrorType}
+	assert.Equal(t, getErrorType(err35), VaultSecretDeletedErrorType)
+	err36 := &VaultSecretDestroyedError{ErrType: VaultSecretDestroyedErrorType}
+	assert.Equal(t, getErrorType(err36), VaultSecretDestroyedErrorType)
+	err37 := &VaultTokenRenewNoProgressError{ErrType: VaultTokenRenewNoProgressErrorType}
+	assert.Equal(t, getErrorType(err37), VaultTokenRenewNoProgressErrorType)
+	err38 := &VaultCircuitOpenError{ErrType: VaultCircuitOpenErrorType}
+	assert.Equal(t, getErrorType(err38), VaultCircuitOpenErrorType)
+	err39 := &VaultRoleTokenError{ErrType: VaultRoleTokenErrorType}
+	assert.Equal(t, getErrorType(err39), VaultRoleTokenErrorType)
+	err40 := &VaultTokenTTLTooShortError{ErrType: VaultTokenTTLTooShortErrorType}
+	assert.Equal(t, getErrorType(err40), VaultTokenTTLTooShortErrorType)
+	err41 := &SecretKeyCollisionError{ErrType: SecretKeyCollisionErrorType}
+	assert.Equal(t, getErrorType(err41), SecretKeyCollisionErrorType)
+	err42 := &VaultCASMismatchError{ErrType: VaultCASMismatchErrorType}
+	assert.Equal(t, getErrorType(err42), VaultCASMismatchErrorType)
+	err43 := &BackendSecretTooLargeError{ErrType: BackendSecretTooLargeErrorType}
+	assert.Equal(t, getErrorType(err43), BackendSecretTooLargeErrorType)
+	err44 := &PathNotAllowedError{ErrType: PathNotAllowedErrorType}
+	assert.Equal(t, getErrorType(err44), PathNotAllowedErrorType)
+	err45 := &VaultTokenCreateError{ErrType: VaultTokenCreateErrorType}
+	assert.Equal(t, getErrorType(err45), VaultTokenCreateErrorType)
+	err46 := &BackendSecretKeysError{ErrType: BackendSecretKeysErrorType}
+	assert.Equal(t, getErrorType(err46), BackendSecretKeysErrorType)
+	err47 := &VaultWrapValidationError{ErrType: VaultWrapValidationErrorType}
+	assert.Equal(t, getErrorType(err47), VaultWrapValidationErrorType)
+}
+
+func TestGetErrorTypeExported(t *testing.T) {
+	err := &VaultAppRoleAuthError{ErrType: VaultAppRoleAuthErrorType}
+	assert.Equal(t, VaultAppRoleAuthErrorType, GetErrorType(err))
+	assert.Equal(t, UnknownErrorType, GetErrorType(e.New("foo")))
 }
 
 func TestIsBackendNotImplemented(t *testing.T) {
@@ -88,3 +252,410 @@ func TestIsVaultTokenNotRenewable(t *testing.T) {
 	err := &VaultTokenNotRenewableError{ErrType: VaultTokenNotRenewableErrorType}
 	assert.True(t, IsVaultTokenNotRenewable(err))
 }
+
+func TestIsVaultKubernetesAuth(t *testing.T) {
+	err := &VaultKubernetesAuthError{ErrType: VaultKubernetesAuthErrorType}
+	assert.True(t, IsVaultKubernetesAuth(err))
+	err2 := e.New("foo")
+	assert.False(t, IsVaultKubernetesAuth(err2))
+}
+
+func TestIsVaultAppRoleAuth(t *testing.T) {
+	err := &VaultAppRoleAuthError{ErrType: VaultAppRoleAuthErrorType}
+	assert.True(t, IsVaultAppRoleAuth(err))
+	err2 := e.New("foo")
+	assert.False(t, IsVaultAppRoleAuth(err2))
+}
+
+func TestIsVaultTLSConfig(t *testing.T) {
+	err := &VaultTLSConfigError{ErrType: VaultTLSConfigErrorType}
+	assert.True(t, IsVaultTLSConfig(err))
+	err2 := e.New("foo")
+	assert.False(t, IsVaultTLSConfig(err2))
+}
+
+func TestIsVaultVersioningNotSupported(t *testing.T) {
+	err := &VaultVersioningNotSupportedError{ErrType: VaultVersioningNotSupportedErrorType}
+	assert.True(t, IsVaultVersioningNotSupported(err))
+	err2 := e.New("foo")
+	assert.False(t, IsVaultVersioningNotSupported(err2))
+}
+
+func TestIsBackendSecretType(t *testing.T) {
+	err := &BackendSecretTypeError{ErrType: BackendSecretTypeErrorType}
+	assert.True(t, IsBackendSecretType(err))
+	err2 := e.New("foo")
+	assert.False(t, IsBackendSecretType(err2))
+}
+
+func TestIsBackendConfig(t *testing.T) {
+	err := &BackendConfigError{ErrType: BackendConfigErrorType}
+	assert.True(t, IsBackendConfig(err))
+	err2 := e.New("foo")
+	assert.False(t, IsBackendConfig(err2))
+}
+
+func TestIsBackendForbidden(t *testing.T) {
+	err := &BackendForbiddenError{ErrType: BackendForbiddenErrorType}
+	assert.True(t, IsBackendForbidden(err))
+	err2 := e.New("foo")
+	assert.False(t, IsBackendForbidden(err2))
+}
+
+func TestIsVaultTransit(t *testing.T) {
+	err := &VaultTransitError{ErrType: VaultTransitErrorType}
+	assert.True(t, IsVaultTransit(err))
+	err2 := e.New("foo")
+	assert.False(t, IsVaultTransit(err2))
+}
+
+func TestIsVaultPKI(t *testing.T) {
+	err := &VaultPKIError{ErrType: VaultPKIErrorType}
+	assert.True(t, IsVaultPKI(err))
+	err2 := e.New("foo")
+	assert.False(t, IsVaultPKI(err2))
+}
+
+func TestIsVaultTimeout(t *testing.T) {
+	err := &VaultTimeoutError{ErrType: VaultTimeoutErrorType}
+	assert.True(t, IsVaultTimeout(err))
+	err2 := e.New("foo")
+	assert.False(t, IsVaultTimeout(err2))
+}
+
+func TestIsVaultSealed(t *testing.T) {
+	err := &VaultSealedError{ErrType: VaultSealedErrorType}
+	assert.True(t, IsVaultSealed(err))
+	err2 := e.New("foo")
+	assert.False(t, IsVaultSealed(err2))
+}
+
+func TestIsVaultForbidden(t *testing.T) {
+	err := &VaultForbiddenError{ErrType: VaultForbiddenErrorType}
+	assert.True(t, IsVaultForbidden(err))
+	err2 := e.New("foo")
+	assert.False(t, IsVaultForbidden(err2))
+}
+
+func TestIsVaultJWTAuth(t *testing.T) {
+	err := &VaultJWTAuthError{ErrType: VaultJWTAuthErrorType}
+	assert.True(t, IsVaultJWTAuth(err))
+	err2 := e.New("foo")
+	assert.False(t, IsVaultJWTAuth(err2))
+}
+
+func TestIsVaultUnwrap(t *testing.T) {
+	err := &VaultUnwrapError{ErrType: VaultUnwrapErrorType}
+	assert.True(t, IsVaultUnwrap(err))
+	err2 := e.New("foo")
+	assert.False(t, IsVaultUnwrap(err2))
+}
+
+func TestIsBackendSecretWrite(t *testing.T) {
+	err := &BackendSecretWriteError{ErrType: BackendSecretWriteErrorType}
+	assert.True(t, IsBackendSecretWrite(err))
+	err2 := e.New("foo")
+	assert.False(t, IsBackendSecretWrite(err2))
+}
+
+func TestIsVaultUserpassAuth(t *testing.T) {
+	err := &VaultUserpassAuthError{ErrType: VaultUserpassAuthErrorType}
+	assert.True(t, IsVaultUserpassAuth(err))
+	err2 := e.New("foo")
+	assert.False(t, IsVaultUserpassAuth(err2))
+}
+
+func TestIsVaultCertAuth(t *testing.T) {
+	err := &VaultCertAuthError{ErrType: VaultCertAuthErrorType}
+	assert.True(t, IsVaultCertAuth(err))
+	err2 := e.New("foo")
+	assert.False(t, IsVaultCertAuth(err2))
+}
+
+func TestIsSecretTemplate(t *testing.T) {
+	err := &SecretTemplateError{ErrType: SecretTemplateErrorType}
+	assert.True(t, IsSecretTemplate(err))
+	err2 := e.New("foo")
+	assert.False(t, IsSecretTemplate(err2))
+}
+
+func TestIsBackendSecretEncoding(t *testing.T) {
+	err := &BackendSecretEncodingError{ErrType: BackendSecretEncodingErrorType}
+	assert.True(t, IsBackendSecretEncoding(err))
+	err2 := e.New("foo")
+	assert.False(t, IsBackendSecretEncoding(err2))
+}
+
+func TestIsSecretTree(t *testing.T) {
+	err := &SecretTreeError{ErrType: SecretTreeErrorType}
+	assert.True(t, IsSecretTree(err))
+	err2 := e.New("foo")
+	assert.False(t, IsSecretTree(err2))
+}
+
+func TestIsVaultAWSAuth(t *testing.T) {
+	err := &VaultAWSAuthError{ErrType: VaultAWSAuthErrorType}
+	assert.True(t, IsVaultAWSAuth(err))
+	err2 := e.New("foo")
+	assert.False(t, IsVaultAWSAuth(err2))
+}
+
+func TestIsVaultGCPAuth(t *testing.T) {
+	err := &VaultGCPAuthError{ErrType: VaultGCPAuthErrorType}
+	assert.True(t, IsVaultGCPAuth(err))
+	err2 := e.New("foo")
+	assert.False(t, IsVaultGCPAuth(err2))
+}
+
+func TestIsVaultAzureAuth(t *testing.T) {
+	err := &VaultAzureAuthError{ErrType: VaultAzureAuthErrorType}
+	assert.True(t, IsVaultAzureAuth(err))
+	err2 := e.New("foo")
+	assert.False(t, IsVaultAzureAuth(err2))
+}
+
+func TestIsVaultTokenMalformed(t *testing.T) {
+	err := &VaultTokenMalformedError{ErrType: VaultTokenMalformedErrorType}
+	assert.True(t, IsVaultTokenMalformed(err))
+	err2 := e.New("foo")
+	assert.False(t, IsVaultTokenMalformed(err2))
+}
+
+func TestIsSecretPath(t *testing.T) {
+	err := &SecretPathError{ErrType: SecretPathErrorType}
+	assert.True(t, IsSecretPath(err))
+	err2 := e.New("foo")
+	assert.False(t, IsSecretPath(err2))
+}
+
+func TestIsHashNotImplemented(t *testing.T) {
+	err := &HashNotImplementedError{ErrType: HashNotImplementedErrorType}
+	assert.True(t, IsHashNotImplemented(err))
+	err2 := e.New("foo")
+	assert.False(t, IsHashNotImplemented(err2))
+}
+
+func TestIsVaultSecretDeleted(t *testing.T) {
+	err := &VaultSecretDeletedError{ErrType: VaultSecretDeletedErrorType}
+	assert.True(t, IsVaultSecretDeleted(err))
+	err2 := e.New("foo")
+	assert.False(t, IsVaultSecretDeleted(err2))
+}
+
+func TestIsVaultSecretDestroyed(t *testing.T) {
+	err := &VaultSecretDestroyedError{ErrType: VaultSecretDestroyedErrorType}
+	assert.True(t, IsVaultSecretDestroyed(err))
+	err2 := e.New("foo")
+	assert.False(t, IsVaultSecretDestroyed(err2))
+}
+
+func TestIsVaultTokenRenewNoProgress(t *testing.T) {
+	err := &VaultTokenRenewNoProgressError{ErrType: VaultTokenRenewNoProgressErrorType}
+	assert.True(t, IsVaultTokenRenewNoProgress(err))
+	err2 := e.New("foo")
+	assert.False(t, IsVaultTokenRenewNoProgress(err2))
+}
+
+func TestIsVaultCircuitOpen(t *testing.T) {
+	err := &VaultCircuitOpenError{ErrType: VaultCircuitOpenErrorType}
+	assert.True(t, IsVaultCircuitOpen(err))
+	err2 := e.New("foo")
+	assert.False(t, IsVaultCircuitOpen(err2))
+}
+
+func TestIsVaultRoleToken(t *testing.T) {
+	err := &VaultRoleTokenError{ErrType: VaultRoleTokenErrorType}
+	assert.True(t, IsVaultRoleToken(err))
+	err2 := e.New("foo")
+	assert.False(t, IsVaultRoleToken(err2))
+}
+
+func TestIsVaultTokenTTLTooShort(t *testing.T) {
+	err := &VaultTokenTTLTooShortError{ErrType: VaultTokenTTLTooShortErrorType}
+	assert.True(t, IsVaultTokenTTLTooShort(err))
+	err2 := e.New("foo")
+	assert.False(t, IsVaultTokenTTLTooShort(err2))
+}
+
+func TestIsSecretKeyCollision(t *testing.T) {
+	err := &SecretKeyCollisionError{ErrType: SecretKeyCollisionErrorType}
+	assert.True(t, IsSecretKeyCollision(err))
+	err2 := e.New("foo")
+	assert.False(t, IsSecretKeyCollision(err2))
+}
+
+func TestIsVaultCASMismatch(t *testing.T) {
+	err := &VaultCASMismatchError{ErrType: VaultCASMismatchErrorType}
+	assert.True(t, IsVaultCASMismatch(err))
+	err2 := e.New("foo")
+	assert.False(t, IsVaultCASMismatch(err2))
+}
+
+func TestIsBackendSecretTooLarge(t *testing.T) {
+	err := &BackendSecretTooLargeError{ErrType: BackendSecretTooLargeErrorType}
+	assert.True(t, IsBackendSecretTooLarge(err))
+	err2 := e.New("foo")
+	assert.False(t, IsBackendSecretTooLarge(err2))
+}
+
+func TestIsPathNotAllowed(t *testing.T) {
+	err := &PathNotAllowedError{ErrType: PathNotAllowedErrorType}
+	assert.True(t, IsPathNotAllowed(err))
+	err2 := e.New("foo")
+	assert.False(t, IsPathNotAllowed(err2))
+}
+
+func TestIsVaultTokenCreate(t *testing.T) {
+	err := &VaultTokenCreateError{ErrType: VaultTokenCreateErrorType}
+	assert.True(t, IsVaultTokenCreate(err))
+	err2 := e.New("foo")
+	assert.False(t, IsVaultTokenCreate(err2))
+}
+
+func TestIsBackendSecretKeys(t *testing.T) {
+	err := &BackendSecretKeysError{ErrType: BackendSecretKeysErrorType}
+	assert.True(t, IsBackendSecretKeys(err))
+	err2 := e.New("foo")
+	assert.False(t, IsBackendSecretKeys(err2))
+}
+
+func TestErrorsIs(t *testing.T) {
+	cases := []struct {
+		err      error
+		sentinel error
+	}{
+		{&BackendNotImplementedError{ErrType: BackendNotImplementedErrorType}, ErrBackendNotImplemented},
+		{&BackendSecretNotFoundError{ErrType: BackendSecretNotFoundErrorType}, ErrBackendSecretNotFound},
+		{&K8sSecretNotFoundError{ErrType: K8sSecretNotFoundErrorType}, ErrK8sSecretNotFound},
+		{&InvalidConfigmapNameError{ErrType: InvalidConfigmapNameErrorType}, ErrInvalidConfigmapName},
+		{&EncodingNotImplementedError{ErrType: EncodingNotImplementedErrorType}, ErrEncodingNotImplemented},
+		{&VaultEngineNotImplementedError{ErrType: VaultEngineNotImplementedErrorType}, ErrVaultEngineNotImplemented},
+		{&VaultTokenNotRenewableError{ErrType: VaultTokenNotRenewableErrorType}, ErrVaultTokenNotRenewable},
+		{&VaultKubernetesAuthError{ErrType: VaultKubernetesAuthErrorType}, ErrVaultKubernetesAuth},
+		{&VaultAppRoleAuthError{ErrType: VaultAppRoleAuthErrorType}, ErrVaultAppRoleAuth},
+		{&VaultTLSConfigError{ErrType: VaultTLSConfigErrorType}, ErrVaultTLSConfig},
+		{&VaultVersioningNotSupportedError{ErrType: VaultVersioningNotSupportedErrorType}, ErrVaultVersioningNotSupported},
+		{&BackendSecretTypeError{ErrType: BackendSecretTypeErrorType}, ErrBackendSecretType},
+		{&BackendConfigError{ErrType: BackendConfigErrorType}, ErrBackendConfig},
+		{&BackendForbiddenError{ErrType: BackendForbiddenErrorType}, ErrBackendForbidden},
+		{&VaultTransitError{ErrType: VaultTransitErrorType}, ErrVaultTransit},
+		{&VaultPKIError{ErrType: VaultPKIErrorType}, ErrVaultPKI},
+		{&VaultTimeoutError{ErrType: VaultTimeoutErrorType}, ErrVaultTimeout},
+		{&VaultSealedError{ErrType: VaultSealedErrorType}, ErrVaultSealed},
+		{&VaultForbiddenError{ErrType: VaultForbiddenErrorType}, ErrVaultForbidden},
+		{&VaultJWTAuthError{ErrType: VaultJWTAuthErrorType}, ErrVaultJWTAuth},
+		{&VaultUnwrapError{ErrType: VaultUnwrapErrorType}, ErrVaultUnwrap},
+		{&BackendSecretWriteError{ErrType: BackendSecretWriteErrorType}, ErrBackendSecretWrite},
+		{&VaultUserpassAuthError{ErrType: VaultUserpassAuthErrorType}, ErrVaultUserpassAuth},
+		{&VaultCertAuthError{ErrType: VaultCertAuthErrorType}, ErrVaultCertAuth},
+		{&SecretTemplateError{ErrType: SecretTemplateErrorType}, ErrSecretTemplate},
+		{&BackendSecretEncodingError{ErrType: BackendSecretEncodingErrorType}, ErrBackendSecretEncoding},
+		{&SecretTreeError{ErrType: SecretTreeErrorType}, ErrSecretTree},
+		{&VaultAWSAuthError{ErrType: VaultAWSAuthErrorType}, ErrVaultAWSAuth},
+		{&VaultGCPAuthError{ErrType: VaultGCPAuthErrorType}, ErrVaultGCPAuth},
+		{&VaultAzureAuthError{ErrType: VaultAzureAuthErrorType}, ErrVaultAzureAuth},
+		{&VaultTokenMalformedError{ErrType: VaultTokenMalformedErrorType}, ErrVaultTokenMalformed},
+		{&SecretPathError{ErrType: SecretPathErrorType}, ErrSecretPath},
+		{&HashNotImplementedError{ErrType: HashNotImplementedErrorType}, ErrHashNotImplemented},
+		{&VaultSecretDeletedError{ErrType: VaultSecretDeletedErrorType}, ErrVaultSecretDeleted},
+		{&VaultSecretDestroyedError{ErrType: VaultSecretDestroyedErrorType}, ErrVaultSecretDestroyed},
+		{&VaultTokenRenewNoProgressError{ErrType: VaultTokenRenewNoProgressErrorType}, ErrVaultTokenRenewNoProgress},
+		{&VaultCircuitOpenError{ErrType: VaultCircuitOpenErrorType}, ErrVaultCircuitOpen},
+		{&VaultRoleTokenError{ErrType: VaultRoleTokenErrorType}, ErrVaultRoleToken},
+		{&VaultTokenTTLTooShortError{ErrType: VaultTokenTTLTooShortErrorType}, ErrVaultTokenTTLTooShort},
+		{&SecretKeyCollisionError{ErrType: SecretKeyCollisionErrorType}, ErrSecretKeyCollision},
+		{&VaultCASMismatchError{ErrType: VaultCASMismatchErrorType}, ErrVaultCASMismatch},
+		{&BackendSecretTooLargeError{ErrType: BackendSecretTooLargeErrorType}, ErrBackendSecretTooLarge},
+		{&PathNotAllowedError{ErrType: PathNotAllowedErrorType}, ErrPathNotAllowed},
+		{&VaultTokenCreateError{ErrType: VaultTokenCreateErrorType}, ErrVaultTokenCreate},
+		{&BackendSecretKeysError{ErrType: BackendSecretKeysErrorType}, ErrBackendSecretKeys},
+		{&VaultWrapValidationError{ErrType: VaultWrapValidationErrorType}, ErrVaultWrapValidation},
+	}
+	for i, c := range cases {
+		wrapped := fmt.Errorf("wrapped: %w", c.err)
+		for j, other := range cases {
+			assert.Equal(t, i == j, e.Is(c.err, other.sentinel), c.err.Error())
+			assert.Equal(t, i == j, e.Is(wrapped, other.sentinel), wrapped.Error())
+		}
+	}
+}
+
+func TestErrorsIsWrapped(t *testing.T) {
+	err := fmt.Errorf("unable to read datasource: %w", &BackendSecretNotFoundError{ErrType: BackendSecretNotFoundErrorType, Path: "foo", Key: "bar"})
+	assert.True(t, e.Is(err, ErrBackendSecretNotFound))
+	assert.True(t, IsBackendSecretNotFound(err))
+	assert.True(t, IsBackendSecretNotFound(fmt.Errorf("twice: %w", err)))
+	assert.False(t, IsVaultForbidden(err))
+	assert.Equal(t, BackendSecretNotFoundErrorType, GetErrorType(err))
+
+	// The errors held by the errors of this package are matched too, while the type is the outer one
+	roleErr := &VaultRoleTokenError{ErrType: VaultRoleTokenErrorType, Role: "foo", Err: &BackendSecretNotFoundError{ErrType: BackendSecretNotFoundErrorType}}
+	assert.True(t, IsVaultRoleToken(roleErr))
+	assert.True(t, IsBackendSecretNotFound(fmt.Errorf("wrapped: %w", roleErr)))
+	assert.Equal(t, VaultRoleTokenErrorType, GetErrorType(fmt.Errorf("wrapped: %w", roleErr)))
+
+	timeoutErr := fmt.Errorf("unable to read datasource: %w", &VaultTimeoutError{ErrType: VaultTimeoutErrorType, Path: "foo", Err: context.DeadlineExceeded})
+	assert.True(t, IsVaultTimeout(timeoutErr))
+	assert.True(t, e.Is(timeoutErr, context.DeadlineExceeded))
+	assert.False(t, e.Is(timeoutErr, context.Canceled))
+
+	writeErr := fmt.Errorf("unable to sync: %w", &BackendSecretWriteError{ErrType: BackendSecretWriteErrorType, Path: "foo", Err: io.ErrUnexpectedEOF})
+	assert.True(t, e.Is(writeErr, io.ErrUnexpectedEOF))
+
+	assert.False(t, IsBackendSecretNotFound(nil))
+	assert.False(t, e.Is(e.New(BackendSecretNotFoundErrorType), ErrBackendSecretNotFound))
+}
+
+func TestErrorsAs(t *testing.T) {
+	err := fmt.Errorf("unable to read datasource: %w", &BackendSecretNotFoundError{ErrType: BackendSecretNotFoundErrorType, Path: "foo", Key: "bar"})
+
+	var notFound *BackendSecretNotFoundError
+	assert.True(t, e.As(err, &notFound))
+	assert.Equal(t, "foo", notFound.Path)
+	assert.Equal(t, "bar", notFound.Key)
+	assert.Equal(t, BackendSecretNotFoundErrorType, notFound.ErrType)
+
+	var forbidden *VaultForbiddenError
+	assert.False(t, e.As(err, &forbidden))
+
+	transitErr := fmt.Errorf("unable to decrypt: %w", &VaultTransitError{ErrType: VaultTransitErrorType, KeyName: "foo", Err: &VaultForbiddenError{ErrType: VaultForbiddenErrorType, Path: "transit/decrypt/foo"}})
+	assert.True(t, e.As(transitErr, &forbidden))
+	assert.Equal(t, "transit/decrypt/foo", forbidden.Path)
+}
+
+func TestErrorsIsMultiple(t *testing.T) {
+	tooLarge := &BackendSecretTooLargeError{ErrType: BackendSecretTooLargeErrorType, Path: "foo", Key: "tls", Size: 3, MaxSize: 2}
+	keysErr := fmt.Errorf("unable to read datasource: %w", &BackendSecretKeysError{ErrType: BackendSecretKeysErrorType, Path: "foo", Errs: []error{
+		&BackendSecretTypeError{ErrType: BackendSecretTypeErrorType, Path: "foo", Key: "count", Type: "json.Number"},
+		fmt.Errorf("key tls: %w", tooLarge),
+	}})
+	assert.True(t, IsBackendSecretKeys(keysErr))
+	assert.True(t, IsBackendSecretType(keysErr))
+	assert.True(t, IsBackendSecretTooLarge(keysErr))
+	assert.False(t, IsBackendSecretNotFound(keysErr))
+	assert.Equal(t, BackendSecretKeysErrorType, GetErrorType(keysErr))
+
+	var found *BackendSecretTooLargeError
+	assert.True(t, e.As(keysErr, &found))
+	assert.Equal(t, tooLarge, found)
+
+	treeErr := fmt.Errorf("unable to read tree: %w", &SecretTreeError{ErrType: SecretTreeErrorType, Path: "foo", Errs: []error{
+		e.New("foo/bar is deeper than 1 folders, not read"),
+		&BackendSecretNotFoundError{ErrType: BackendSecretNotFoundErrorType, Path: "foo/baz"},
+	}})
+	assert.True(t, IsSecretTree(treeErr))
+	assert.True(t, e.Is(treeErr, ErrBackendSecretNotFound))
+	assert.False(t, IsBackendSecretKeys(treeErr))
+
+	var notFound *BackendSecretNotFoundError
+	assert.True(t, e.As(treeErr, &notFound))
+	assert.Equal(t, "foo/baz", notFound.Path)
+	assert.False(t, e.As(&SecretTreeError{ErrType: SecretTreeErrorType}, &notFound))
+}
+
+func TestIsVaultWrapValidation(t *testing.T) {
+	err := &VaultWrapValidationError{ErrType: VaultWrapValidationErrorType}
+	assert.True(t, IsVaultWrapValidation(err))
+	err2 := e.New("foo")
+	assert.False(t, IsVaultWrapValidation(err2))
+}
@@ -0,0 +1,75 @@
+package health
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/tuenti/secrets-manager/backend"
+	"github.com/tuenti/secrets-manager/leader"
+)
+
+const (
+	statusOK          = "ok"
+	statusUnavailable = "unavailable"
+)
+
+type response struct {
+	Status  string `json:"status"`
+	Reason  string `json:"reason,omitempty"`
+	Address string `json:"address,omitempty"`
+	Role    string `json:"role,omitempty"`
+}
+
+// NewHandler returns the handler serving the liveness (/healthz) and readiness (/readyz) probes, and the backend
+// session status (/status) when the backend implements backend.StatusReporter.
+// Readiness is delegated on the backend when it implements backend.HealthChecker, otherwise it is always ready.
+// The backend address in use is reported when it implements backend.ActiveAddressReporter, and the leader election
+// role when a leader.Checker is given. Standby replicas are ready, so they can take over as soon as they are elected
+func NewHandler(client backend.Client, elector leader.Checker) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+		writeResponse(w, http.StatusOK, response{Status: statusOK})
+	})
+	mux.HandleFunc("/status", func(w http.ResponseWriter, r *http.Request) {
+		reporter, ok := client.(backend.StatusReporter)
+		if !ok {
+			writeResponse(w, http.StatusNotFound, response{Status: statusUnavailable, Reason: "backend doesn't report its status"})
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(reporter.Status())
+	})
+	mux.HandleFunc("/readyz", func(w http.ResponseWriter, r *http.Request) {
+		address := ""
+		if reporter, ok := client.(backend.ActiveAddressReporter); ok {
+			address = reporter.ActiveAddress()
+		}
+		role := ""
+		if elector != nil {
+			role = leader.RoleStandby
+			if elector.IsLeader() {
+				role = leader.RoleLeader
+			}
+		}
+		if checker, ok := client.(backend.HealthChecker); ok {
+			if err := checker.Ready(); err != nil {
+				writeResponse(w, http.StatusServiceUnavailable, response{Status: statusUnavailable, Reason: err.Error(), Address: address, Role: role})
+				return
+			}
+		}
+		writeResponse(w, http.StatusOK, response{Status: statusOK, Address: address, Role: role})
+	})
+	return mux
+}
+
+// NewServer returns the HTTP server exposing the probes on the given address. elector is nil when
+// leader election is disabled
+func NewServer(addr string, client backend.Client, elector leader.Checker) *http.Server {
+	return &http.Server{Addr: addr, Handler: NewHandler(client, elector)}
+}
+
+func writeResponse(w http.ResponseWriter, code int, r response) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(r)
+}
@@ -0,0 +1,105 @@
+package health
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/tuenti/secrets-manager/backend"
+)
+
+type fakeChecker struct {
+	backend.Client
+	err error
+}
+
+func (f *fakeChecker) Ready() error {
+	return f.err
+}
+
+func probe(client backend.Client, path string) *httptest.ResponseRecorder {
+	rec := httptest.NewRecorder()
+	NewHandler(client, nil).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+	return rec
+}
+
+func TestHealthz(t *testing.T) {
+	rec := probe(&fakeChecker{err: fmt.Errorf("vault token expired")}, "/healthz")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.JSONEq(t, `{"status":"ok"}`, rec.Body.String())
+}
+
+func TestReadyz(t *testing.T) {
+	rec := probe(&fakeChecker{}, "/readyz")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.JSONEq(t, `{"status":"ok"}`, rec.Body.String())
+}
+
+func TestReadyzNotReady(t *testing.T) {
+	rec := probe(&fakeChecker{err: fmt.Errorf("vault token expired")}, "/readyz")
+	assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+	assert.Equal(t, "application/json", rec.Header().Get("Content-Type"))
+	assert.JSONEq(t, `{"status":"unavailable","reason":"vault token expired"}`, rec.Body.String())
+}
+
+func TestReadyzWithoutChecker(t *testing.T) {
+	rec := probe(backend.NewMemoryClient(nil), "/readyz")
+	assert.Equal(t, http.StatusOK, rec.Code)
+}
+
+type fakeFailover struct {
+	fakeChecker
+	address string
+}
+
+func (f *fakeFailover) ActiveAddress() string {
+	return f.address
+}
+
+func TestReadyzActiveAddress(t *testing.T) {
+	rec := probe(&fakeFailover{address: "https://vault-dr:8200"}, "/readyz")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.JSONEq(t, `{"status":"ok","address":"https://vault-dr:8200"}`, rec.Body.String())
+}
+
+type fakeElector bool
+
+func (f fakeElector) IsLeader() bool {
+	return bool(f)
+}
+
+func TestReadyzLeaderElection(t *testing.T) {
+	rec := httptest.NewRecorder()
+	NewHandler(&fakeChecker{}, fakeElector(true)).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.JSONEq(t, `{"status":"ok","role":"leader"}`, rec.Body.String())
+
+	rec = httptest.NewRecorder()
+	NewHandler(&fakeChecker{}, fakeElector(false)).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.JSONEq(t, `{"status":"ok","role":"standby"}`, rec.Body.String())
+}
+
+type fakeStatusReporter struct {
+	fakeChecker
+	status backend.BackendStatus
+}
+
+func (f *fakeStatusReporter) Status() backend.BackendStatus {
+	return f.status
+}
+
+func TestStatus(t *testing.T) {
+	rec := probe(&fakeStatusReporter{status: backend.BackendStatus{TokenTTL: 300, TokenRenewable: true, ClusterName: "vault-cluster", Version: "1.2.0"}}, "/status")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "application/json", rec.Header().Get("Content-Type"))
+	assert.Contains(t, rec.Body.String(), `"tokenTTL":300,"tokenRenewable":true`)
+	assert.Contains(t, rec.Body.String(), `"clusterName":"vault-cluster","version":"1.2.0"`)
+}
+
+func TestStatusNotReported(t *testing.T) {
+	rec := probe(backend.NewMemoryClient(nil), "/status")
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+}
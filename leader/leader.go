@@ -0,0 +1,249 @@
+package leader
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/prometheus/client_golang/prometheus"
+	"k8s.io/apimachinery/pkg/util/uuid"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/leaderelection"
+	"k8s.io/client-go/tools/leaderelection/resourcelock"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+const (
+	// RoleLeader is the role of the replica holding the lease
+	RoleLeader = "leader"
+	// RoleStandby is the role of the replicas waiting to acquire the lease
+	RoleStandby = "standby"
+
+	inClusterNamespacePath = "/var/run/secrets/kubernetes.io/serviceaccount/namespace"
+
+	defaultMetricsNamespace = "secrets_manager"
+)
+
+func newLeadingGauge(namespace string) prometheus.Gauge {
+	if namespace == "" {
+		namespace = defaultMetricsNamespace
+	}
+	return prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: "leader_election",
+		Name:      "leading",
+		Help:      "Whether this replica is the leader writing secrets. 1 = leader, 0 = standby",
+	})
+}
+
+// Checker tells whether this replica is the leader
+type Checker interface {
+	IsLeader() bool
+}
+
+// Config holds the leader election settings
+type Config struct {
+	// Namespace of the Lease. Defaults to the namespace secrets-manager runs in
+	Namespace     string
+	Name          string
+	LeaseDuration time.Duration
+	RenewDeadline time.Duration
+	RetryPeriod   time.Duration
+	// MetricsNamespace is the namespace the names of the leader election metrics start with, e.g. secretsmanager
+	// for secretsmanager_leader_election_leading
+	MetricsNamespace string
+}
+
+// Elector elects a leader among secrets-manager replicas holding a Kubernetes Lease. Standby replicas
+// keep campaigning, so one of them takes over as soon as the leader releases or stops renewing the lease
+type Elector struct {
+	cfg      Config
+	lock     *leaseLock
+	leader   int32
+	leading  prometheus.Gauge
+	onChange func(leader bool)
+	logger   logr.Logger
+}
+
+// leaseLock serializes the calls to the Lease. When a renewal times out, e.g. because the elector is stopped, client-go
+// leaves it running in the background, so it would otherwise race with the next renewal or the release of the lease.
+// Once stopped, the lease is not acquired or renewed anymore, only released
+type leaseLock struct {
+	resourcelock.Interface
+	mutex   sync.Mutex
+	stopped bool
+}
+
+var errLeaseLockStopped = fmt.Errorf("leader election stopped")
+
+func (l *leaseLock) Get() (*resourcelock.LeaderElectionRecord, error) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	if l.stopped {
+		return nil, errLeaseLockStopped
+	}
+	return l.Interface.Get()
+}
+
+func (l *leaseLock) Create(ler resourcelock.LeaderElectionRecord) error {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	if l.stopped {
+		return errLeaseLockStopped
+	}
+	return l.Interface.Create(ler)
+}
+
+func (l *leaseLock) Update(ler resourcelock.LeaderElectionRecord) error {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	if l.stopped {
+		return errLeaseLockStopped
+	}
+	return l.Interface.Update(ler)
+}
+
+func (l *leaseLock) RecordEvent(s string) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	l.Interface.RecordEvent(s)
+}
+
+// stop stops acquiring and renewing the lease, and then calls cancel. As no call to the Lease is in flight
+// by then, client-go never gives up on one that would keep updating the elector afterwards
+func (l *leaseLock) stop(cancel context.CancelFunc) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	l.stopped = true
+	cancel()
+}
+
+// release gives the lease up if this replica holds it, so a standby takes over without waiting for it to expire
+func (l *leaseLock) release() error {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	record, err := l.Interface.Get()
+	if err != nil {
+		return err
+	}
+	if record.HolderIdentity != l.Identity() {
+		return nil
+	}
+	return l.Interface.Update(resourcelock.LeaderElectionRecord{LeaderTransitions: record.LeaderTransitions})
+}
+
+// NewElector returns an Elector for the Lease described by cfg. onChange, if not nil, is called
+// every time this replica becomes the leader or a standby
+func NewElector(restConfig *rest.Config, cfg Config, onChange func(leader bool), logger logr.Logger) (*Elector, error) {
+	if cfg.Namespace == "" {
+		namespace, err := ioutil.ReadFile(inClusterNamespacePath)
+		if err != nil {
+			return nil, fmt.Errorf("unable to find leader election namespace, not running in-cluster: %v", err)
+		}
+		cfg.Namespace = strings.TrimSpace(string(namespace))
+	}
+	hostname, err := os.Hostname()
+	if err != nil {
+		return nil, err
+	}
+	client, err := kubernetes.NewForConfig(restConfig)
+	if err != nil {
+		return nil, err
+	}
+	lock, err := resourcelock.New(resourcelock.LeasesResourceLock, cfg.Namespace, cfg.Name, client.CoreV1(), client.CoordinationV1(),
+		resourcelock.ResourceLockConfig{Identity: hostname + "_" + string(uuid.NewUUID())})
+	if err != nil {
+		return nil, err
+	}
+	e := newElector(lock, cfg, onChange, logger)
+	if err := metrics.Registry.Register(e.leading); err != nil {
+		return nil, err
+	}
+	return e, nil
+}
+
+func newElector(lock resourcelock.Interface, cfg Config, onChange func(leader bool), logger logr.Logger) *Elector {
+	return &Elector{
+		cfg:      cfg,
+		lock:     &leaseLock{Interface: lock},
+		leading:  newLeadingGauge(cfg.MetricsNamespace),
+		onChange: onChange,
+		logger:   logger.WithValues("lease", cfg.Namespace+"/"+cfg.Name, "identity", lock.Identity()),
+	}
+}
+
+// IsLeader returns true if this replica holds the lease
+func (e *Elector) IsLeader() bool {
+	return atomic.LoadInt32(&e.leader) == 1
+}
+
+// Role returns RoleLeader or RoleStandby
+func (e *Elector) Role() string {
+	if e.IsLeader() {
+		return RoleLeader
+	}
+	return RoleStandby
+}
+
+func (e *Elector) setLeader(leader bool) {
+	value := int32(0)
+	if leader {
+		value = 1
+	}
+	if atomic.SwapInt32(&e.leader, value) == value {
+		return
+	}
+	e.leading.Set(float64(value))
+	e.logger.Info("leader election role changed", "role", e.Role())
+	if e.onChange != nil {
+		e.onChange(leader)
+	}
+}
+
+// Run campaigns for the lease until ctx is done, campaigning again whenever leadership is lost. The lease
+// is released when ctx is done, so make sure nothing is written anymore before cancelling it
+func (e *Elector) Run(ctx context.Context) error {
+	runCtx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		select {
+		case <-ctx.Done():
+			e.lock.stop(cancel)
+		case <-runCtx.Done():
+		}
+	}()
+	for {
+		elector, err := leaderelection.NewLeaderElector(leaderelection.LeaderElectionConfig{
+			Lock:          e.lock,
+			LeaseDuration: e.cfg.LeaseDuration,
+			RenewDeadline: e.cfg.RenewDeadline,
+			RetryPeriod:   e.cfg.RetryPeriod,
+			Callbacks: leaderelection.LeaderCallbacks{
+				OnStartedLeading: func(context.Context) { e.setLeader(true) },
+				OnStoppedLeading: func() { e.setLeader(false) },
+			},
+		})
+		if err != nil {
+			return err
+		}
+		elector.Run(runCtx)
+		select {
+		case <-runCtx.Done():
+			// Leadership is given up only once the leader stopped, client-go releasing it could race with renewals
+			if err := e.lock.release(); err != nil {
+				e.logger.Error(err, "unable to release the leader election lease")
+			}
+			e.logger.Info("leader election stopped")
+			return nil
+		default:
+			e.logger.Info("leadership lost, campaigning again")
+		}
+	}
+}
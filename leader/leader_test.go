@@ -0,0 +1,133 @@
+package leader
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/tools/leaderelection/resourcelock"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+var testConfig = Config{
+	Namespace:     "default",
+	Name:          "secrets-manager",
+	LeaseDuration: 2 * time.Second,
+	RenewDeadline: time.Second,
+	RetryPeriod:   100 * time.Millisecond,
+}
+
+// attemptsLock signals every time the lease is read, i.e. on every attempt to acquire or renew it
+type attemptsLock struct {
+	resourcelock.Interface
+	attempts chan struct{}
+}
+
+func (l *attemptsLock) Get() (*resourcelock.LeaderElectionRecord, error) {
+	record, err := l.Interface.Get()
+	select {
+	case l.attempts <- struct{}{}:
+	default:
+	}
+	return record, err
+}
+
+func newTestElector(client *fake.Clientset, identity string, changes chan bool, attempts chan struct{}) *Elector {
+	lock := &attemptsLock{
+		Interface: &resourcelock.LeaseLock{
+			LeaseMeta:  metav1.ObjectMeta{Namespace: testConfig.Namespace, Name: testConfig.Name},
+			Client:     client.CoordinationV1(),
+			LockConfig: resourcelock.ResourceLockConfig{Identity: identity},
+		},
+		attempts: attempts,
+	}
+	return newElector(lock, testConfig, func(leader bool) { changes <- leader }, logf.NullLogger{})
+}
+
+func waitChange(t *testing.T, changes chan bool) bool {
+	select {
+	case leader := <-changes:
+		return leader
+	case <-time.After(5 * time.Second):
+		t.Fatal("leader election role didn't change")
+		return false
+	}
+}
+
+func TestElectorRole(t *testing.T) {
+	changes := make(chan bool, 2)
+	e := newTestElector(fake.NewSimpleClientset(), "replica-1", changes, nil)
+	assert.False(t, e.IsLeader())
+	assert.Equal(t, RoleStandby, e.Role())
+
+	e.setLeader(true)
+	assert.True(t, e.IsLeader())
+	assert.Equal(t, RoleLeader, e.Role())
+	assert.Equal(t, 1.0, testutil.ToFloat64(e.leading))
+
+	e.setLeader(true)
+	e.setLeader(false)
+	assert.Equal(t, 0.0, testutil.ToFloat64(e.leading))
+	assert.Equal(t, []bool{true, false}, []bool{<-changes, <-changes})
+}
+
+func TestElectorHandoff(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	changes1 := make(chan bool, 2)
+	changes2 := make(chan bool, 2)
+	attempts2 := make(chan struct{})
+	e1 := newTestElector(client, "replica-1", changes1, nil)
+	e2 := newTestElector(client, "replica-2", changes2, attempts2)
+
+	ctx1, cancel1 := context.WithCancel(context.Background())
+	done1 := make(chan error)
+	go func() { done1 <- e1.Run(ctx1) }()
+	assert.True(t, waitChange(t, changes1))
+
+	ctx2, cancel2 := context.WithCancel(context.Background())
+	done2 := make(chan error)
+	go func() { done2 <- e2.Run(ctx2) }()
+	// The standby found the lease held twice, so it's campaigning without taking it over
+	for i := 0; i < 2; i++ {
+		select {
+		case <-attempts2:
+		case <-time.After(5 * time.Second):
+			t.Fatal("the standby replica didn't campaign for the lease")
+		}
+	}
+	assert.False(t, e2.IsLeader())
+	assert.Len(t, changes2, 0)
+
+	// The lease is released on cancel, so the standby takes over without waiting for it to expire
+	start := time.Now()
+	cancel1()
+	assert.Nil(t, <-done1)
+	assert.False(t, waitChange(t, changes1))
+	assert.True(t, waitChange(t, changes2))
+	assert.True(t, time.Since(start) < testConfig.LeaseDuration)
+
+	cancel2()
+	assert.Nil(t, <-done2)
+	assert.False(t, waitChange(t, changes2))
+	assert.Equal(t, 0.0, testutil.ToFloat64(e2.leading))
+}
+
+func TestElectorMetricsNamespace(t *testing.T) {
+	cfg := testConfig
+	cfg.MetricsNamespace = "secretsmanager"
+	lock := &resourcelock.LeaseLock{LockConfig: resourcelock.ResourceLockConfig{Identity: "replica-1"}}
+	e := newElector(lock, cfg, nil, logf.NullLogger{})
+	registry := prometheus.NewRegistry()
+	assert.Nil(t, registry.Register(e.leading))
+
+	e.setLeader(true)
+	families, err := registry.Gather()
+	assert.Nil(t, err)
+	assert.Len(t, families, 1)
+	assert.Equal(t, "secretsmanager_leader_election_leading", families[0].GetName())
+}
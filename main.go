@@ -4,19 +4,26 @@ import (
 	"context"
 	"flag"
 	"fmt"
+	"math/rand"
+	"net/http"
 	"os"
+	"os/signal"
+	"strconv"
 	"strings"
+	"syscall"
 	"time"
 
+	"github.com/go-logr/logr"
 	secretsmanagerv1alpha1 "github.com/tuenti/secrets-manager/api/v1alpha1"
 	"github.com/tuenti/secrets-manager/backend"
 	"github.com/tuenti/secrets-manager/controllers"
+	"github.com/tuenti/secrets-manager/health"
+	"github.com/tuenti/secrets-manager/leader"
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	_ "k8s.io/client-go/plugin/pkg/client/auth/gcp"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/cache"
-	"sigs.k8s.io/controller-runtime/pkg/log/zap"
 	// +kubebuilder:scaffold:imports
 )
 
@@ -35,50 +42,204 @@ func init() {
 var version string
 
 func main() {
+	var configFilePath string
 	var metricsAddr string
+	var healthAddr string
 	var controllerName string
 	var enableLeaderElection bool
+	var standbyTokenRenewal bool
 	var enableDebugLog bool
+	var dryRun bool
 	var versionFlag bool
 	var reconcilePeriod time.Duration
+	var shutdownTimeout time.Duration
 	var selectedBackend string
 	var watchNamespaces string
 	var excludeNamespaces string
+	var vaultFallbackURLs string
+	var vaultMountEngines string
+	var vaultTokenPolicies string
+	var vaultAllowedPathPrefixes string
+	var vaultDeniedPathPrefixes string
+	var pathPrefixes string
+	var pathPrefixTemplate string
+	var secretHashAnnotation bool
+	var secretHashAlgorithm string
+	var lastKnownGood bool
+	var requestIDs bool
+	var vaultEvents bool
+	var vaultDurationBuckets string
+	var vaultRenewTTLIncrement string
+	var vaultMaxTokenTTL string
 	var mgr ctrl.Manager
 	var namespaceList []string
 
 	backendCfg := backend.Config{}
+	leaderCfg := leader.Config{}
 
+	// Used to add jitter to Vault requests
+	rand.Seed(time.Now().UnixNano())
+
+	flag.StringVar(&configFilePath, "config-file", "", "YAML or JSON file of flag values keyed by flag name, e.g. log-level: debug. Flags set on the command line take precedence. log-level, vault.metrics-path-labels, vault.cache-ttl and vault.token-polling-jitter are reloaded on SIGHUP.")
 	flag.StringVar(&metricsAddr, "metrics-addr", ":8080", "The address the metric endpoint binds to.")
+	flag.StringVar(&healthAddr, "health-addr", ":8081", "The address the liveness (/healthz) and readiness (/readyz) probes bind to.")
 	flag.StringVar(&controllerName, "controller-name", "SecretDefinition", "If running secrets manager in multiple namespaces, set the controller name to something unique avoid 'duplicate metrics collector registration attempted' errors.")
 	flag.BoolVar(&enableLeaderElection, "enable-leader-election", false,
 		"Enable leader election for controller manager. Enabling this will ensure there is only one active controller manager.")
-	flag.StringVar(&selectedBackend, "backend", "vault", "Selected backend. Only vault supported")
+	flag.StringVar(&leaderCfg.Namespace, "leader-election-namespace", "", "Namespace of the leader election Lease. Defaults to the namespace secrets-manager runs in.")
+	flag.StringVar(&leaderCfg.Name, "leader-election-id", "secrets-manager-leader-election", "Name of the leader election Lease.")
+	flag.DurationVar(&leaderCfg.LeaseDuration, "leader-election-lease-duration", 15*time.Second, "Time standby replicas wait before taking over a lease that is not renewed.")
+	flag.DurationVar(&leaderCfg.RenewDeadline, "leader-election-renew-deadline", 10*time.Second, "Time the leader retries renewing the lease before giving up leadership.")
+	flag.DurationVar(&leaderCfg.RetryPeriod, "leader-election-retry-period", 2*time.Second, "Time between leader election attempts.")
+	flag.BoolVar(&standbyTokenRenewal, "leader-election-standby-token-renewal", true, "Keep renewing the Vault token on standby replicas. When disabled only the leader renews it, and a standby logs in again once elected.")
+	flag.StringVar(&selectedBackend, "backend", "vault", "Selected backend. Supported: vault, aws-secrets-manager, gcp-secret-manager, azure-key-vault, consul, memory (empty, for local development)")
 	flag.BoolVar(&enableDebugLog, "enable-debug-log", false, "Enable this to get more logs verbosity and debug messages.")
+	flag.StringVar(&backendCfg.LogLevel, "log-level", "", "Log level: debug, info, warn or error. Defaults to debug when enable-debug-log is set and info otherwise.")
+	flag.StringVar(&backendCfg.LogFormat, "log-format", "", "Log format: json or console. Defaults to console when enable-debug-log is set and json otherwise.")
 	flag.BoolVar(&versionFlag, "version", false, "Display Secret Manager version")
+	flag.BoolVar(&dryRun, "dry-run", false, "Resolve the keys of every SecretDefinition and log a summary without creating, updating or deleting any Kubernetes secret.")
+	flag.DurationVar(&shutdownTimeout, "shutdown-timeout", 20*time.Second, "How long to wait for in-flight backend requests to finish when shutting down. Should be lower than the pod termination grace period.")
 	flag.DurationVar(&reconcilePeriod, "reconcile-period", 5*time.Second, "How often the controller will re-queue secretdefinition events")
 	flag.DurationVar(&backendCfg.BackendTimeout, "config.backend-timeout", 5*time.Second, "Backend connection timeout")
-	flag.StringVar(&backendCfg.VaultURL, "vault.url", "https://127.0.0.1:8200", "Vault address. VAULT_ADDR environment would take precedence.")
-	flag.StringVar(&backendCfg.VaultAuthMethod, "vault.auth-method", "approle", "Vault authentication method. Supported: approle, kubernetes.")
+	flag.StringVar(&backendCfg.VaultURL, "vault.url", "https://127.0.0.1:8200", "Vault address. VAULT_ADDR environment would take precedence. Several comma-separated addresses can be given, the first one is the primary.")
+	flag.StringVar(&vaultFallbackURLs, "vault.fallback-urls", "", "Comma-separated Vault addresses to fail over to when the primary one is unreachable.")
+	flag.DurationVar(&backendCfg.VaultFailoverInterval, "vault.failover-interval", 30*time.Second, "Minimum time between two Vault address failovers.")
+	flag.StringVar(&backendCfg.VaultNamespace, "vault.namespace", "", "Vault Enterprise namespace. VAULT_NAMESPACE environment would take precedence. Empty means the root namespace.")
+	flag.StringVar(&backendCfg.VaultCACert, "vault.ca-cert", "", "PEM-encoded CA certificate used to verify the Vault server certificate.")
+	flag.StringVar(&backendCfg.VaultCACertPath, "vault.ca-cert-path", "", "Path to a PEM-encoded CA certificate file used to verify the Vault server certificate.")
+	flag.StringVar(&backendCfg.VaultClientCert, "vault.client-cert", "", "Path to a PEM-encoded client certificate for Vault mutual TLS. Requires vault.client-key.")
+	flag.StringVar(&backendCfg.VaultClientKey, "vault.client-key", "", "Path to a PEM-encoded client key for Vault mutual TLS. Requires vault.client-cert.")
+	flag.StringVar(&backendCfg.VaultTLSServerName, "vault.tls-server-name", "", "Name used as SNI host and to verify the Vault server certificate.")
+	flag.BoolVar(&backendCfg.VaultTLSSkipVerify, "vault.tls-skip-verify", false, "Disable Vault server certificate verification. Insecure, only meant for development. Can't be used along with vault.ca-cert or vault.ca-cert-path.")
+	flag.StringVar(&backendCfg.VaultProxyURL, "vault.proxy-url", "", "HTTP, HTTPS or SOCKS5 proxy used to reach Vault, overriding HTTP_PROXY and HTTPS_PROXY environment. NO_PROXY environment is still honored.")
+	flag.IntVar(&backendCfg.VaultMaxRetries, "vault.max-retries", 2, "Max number of retries for Vault requests failing with a 5xx or connection error. 0 disables retries.")
+	flag.DurationVar(&backendCfg.VaultRetryBackoff, "vault.retry-backoff", 500*time.Millisecond, "Initial backoff between Vault request retries. It is doubled on every retry.")
+	flag.DurationVar(&backendCfg.VaultRetryMaxBackoff, "vault.retry-max-backoff", 5*time.Second, "Max backoff between Vault request retries.")
+	flag.DurationVar(&backendCfg.VaultRequestTimeout, "vault.request-timeout", 10*time.Second, "Max time a Vault secret read can take, including retries. 0 disables it.")
+	flag.IntVar(&backendCfg.VaultStartupRetries, "vault.startup-retries", 0, "Number of times logging in and checking Vault health is retried at startup, e.g. when starting before Vault is reachable. 0 fails right away.")
+	flag.DurationVar(&backendCfg.VaultStartupRetryInterval, "vault.startup-retry-interval", 5*time.Second, "Time between Vault connection attempts at startup.")
+	flag.IntVar(&backendCfg.VaultBreakerThreshold, "vault.breaker-threshold", 0, "Vault reads failing in a row with connection or server errors that open the circuit breaker, failing reads without sending them. 0 disables it.")
+	flag.DurationVar(&backendCfg.VaultBreakerWindow, "vault.breaker-window", time.Minute, "Time the failed reads opening the circuit breaker must happen within. 0 means no limit.")
+	flag.DurationVar(&backendCfg.VaultBreakerCoolDown, "vault.breaker-cool-down", 30*time.Second, "Time the circuit breaker stays open before a probe read is sent to Vault.")
+	flag.IntVar(&backendCfg.VaultMaxIdleConns, "vault.max-idle-conns", 100, "Max number of idle connections to Vault kept in the pool. 0 means unlimited.")
+	flag.IntVar(&backendCfg.VaultMaxConnsPerHost, "vault.max-conns-per-host", 0, "Max number of connections to a Vault host, including the ones in use. 0 means unlimited.")
+	flag.DurationVar(&backendCfg.VaultIdleConnTimeout, "vault.idle-conn-timeout", 90*time.Second, "Time an idle connection to Vault is kept in the pool. 0 means no limit.")
+	flag.DurationVar(&backendCfg.VaultCacheTTL, "vault.cache-ttl", 0, "Time Vault secret values are cached for. 0 disables the cache.")
+	flag.IntVar(&backendCfg.VaultCacheMaxSize, "vault.cache-max-size", 1000, "Max number of Vault secret keys kept in the cache. 0 means unlimited.")
+	flag.BoolVar(&backendCfg.VaultCacheEncrypt, "vault.cache-encrypt", false, "Keep the values in the Vault cache encrypted with AES-GCM and a key generated at startup, decrypting them on every cache hit.")
+	flag.IntVar(&backendCfg.VaultMaxSecretValueSize, "vault.max-secret-value-size", 768*1024, "Max size in bytes of a secret value, larger values fail to be read. Keeps secrets below the 1MiB size limit of Kubernetes secrets.")
+	flag.IntVar(&backendCfg.VaultTreeMaxDepth, "vault.tree-max-depth", 10, "Max number of folders descended when reading every secret under a path.")
+	flag.StringVar(&backendCfg.VaultTreeSeparator, "vault.tree-separator", ".", "Separator joining folders, secret and key names when reading every secret under a path.")
+	flag.StringVar(&backendCfg.VaultUserAgent, "vault.user-agent", "", "User-Agent of the requests to Vault. Defaults to secrets-manager/<version>.")
+	flag.DurationVar(&backendCfg.VaultHealthPollingPeriod, "vault.health-polling-period", 15*time.Second, "Polling interval to check Vault health, seal and standby status.")
+	flag.IntVar(&backendCfg.VaultHealthFailureThreshold, "vault.health-failure-threshold", 0, "Failed Vault health polls in a row making secrets-manager not ready, sealed included. 0 makes every poll count.")
+	flag.IntVar(&backendCfg.VaultHealthSuccessThreshold, "vault.health-success-threshold", 1, "Successful Vault health polls in a row making secrets-manager ready again after vault.health-failure-threshold failed ones.")
+	flag.DurationVar(&backendCfg.VaultReadinessThreshold, "vault.readiness-threshold", time.Minute, "Max time since the last successful Vault health check to consider secrets-manager ready.")
+	flag.BoolVar(&backendCfg.VaultMetricsPathLabels, "vault.metrics-path-labels", true, "Label Vault secret metrics by path and key. Disable it when reading thousands of secrets to keep metrics cardinality low.")
+	flag.BoolVar(&backendCfg.VaultMetricsIdentity, "vault.metrics-identity", false, "Report the entity_id and display_name of the Vault token in secrets_manager_vault_token_identity_info. Email addresses in display names are redacted.")
+	flag.IntVar(&backendCfg.VaultMetricsPathDepth, "vault.metrics-path-depth", 0, "Number of leading path segments kept in the path label of Vault metrics, e.g. 2 buckets secret/data/foo/bar as secret/data. 0 keeps the whole path.")
+	flag.StringVar(&backendCfg.MetricsNamespace, "vault.metrics-namespace", "secrets_manager", "Namespace the names of the Vault, backend, leader election and config file metrics start with, e.g. secretsmanager for secretsmanager_vault_login_successes_total.")
+	flag.StringVar(&backendCfg.MetricsSubsystem, "vault.metrics-subsystem", "vault", "Subsystem the names of the Vault metrics have after their namespace.")
+	flag.StringVar(&vaultDurationBuckets, "vault.metrics-duration-buckets", "", "Comma-separated buckets, in seconds, of the Vault read and token requests latency histograms. Prometheus default buckets are used when empty.")
+	flag.StringVar(&backendCfg.VaultAuthMethod, "vault.auth-method", "approle", "Vault authentication method. Supported: approle, kubernetes, jwt, userpass, cert, aws, gcp, azure.")
 	flag.StringVar(&backendCfg.VaultRoleID, "vault.role-id", "", "Vault approle role id. VAULT_ROLE_ID environment would take precedence.")
 	flag.StringVar(&backendCfg.VaultSecretID, "vault.secret-id", "", "Vault approle secret id. VAULT_SECRET_ID environment would take precedence.")
+	flag.StringVar(&backendCfg.VaultTokenFile, "vault.token-file", "", "Path to a file containing a Vault token, e.g. written by a Vault Agent sidecar. It is reloaded when it changes and takes precedence over vault.auth-method, the token is never renewed by secrets-manager.")
+	flag.StringVar(&backendCfg.VaultTokenRole, "vault.token-role", "", "Token role a child token is created with (auth/token/create/<role>) right after logging in. Secrets are read with the child token instead of the token logged in.")
+	flag.StringVar(&vaultTokenPolicies, "vault.token-policies", "", "Comma-separated policies of the child token created right after logging in. Secrets are read with the child token instead of the token logged in.")
+	flag.StringVar(&backendCfg.VaultWrappedToken, "vault.wrapped-token", "", "Single-use response-wrapping token unwrapped at startup to get the Vault token. VAULT_WRAPPED_TOKEN environment would take precedence.")
+	flag.StringVar(&backendCfg.VaultWrappedTokenPath, "vault.wrapped-token-path", "", "Path the wrapping token in vault.wrapped-token must have been created at, e.g. auth/approle/login. When set, the token is checked with sys/wrapping/lookup before it's unwrapped.")
+	flag.StringVar(&backendCfg.VaultSecretIDFile, "vault.secret-id-file", "", "Path to a file containing the Vault approle secret id. It is read on every login and takes precedence over vault.secret-id.")
 	flag.StringVar(&backendCfg.VaultKubernetesRole, "vault.kubernetes-role", "", "Vault kubernetes role name.")
-	flag.Int64Var(&backendCfg.VaultMaxTokenTTL, "vault.max-token-ttl", 300, "Max seconds to consider a token expired.")
+	flag.StringVar(&vaultMaxTokenTTL, "vault.max-token-ttl", "300", "Max TTL to consider a token expired, in seconds or as a duration, e.g. 5m.")
+	flag.Float64Var(&backendCfg.VaultRenewThresholdRatio, "vault.renew-threshold-ratio", 0, "Renew tokens once less than this fraction of their creation TTL remains, e.g. 0.25. vault.max-token-ttl is still the floor. 0 disables it.")
+	flag.DurationVar(&backendCfg.VaultRenewGracePeriod, "vault.renew-grace-period", 0, "Renew tokens once their TTL drops below vault.max-token-ttl plus this period, e.g. 1m, leaving headroom for slow renewals and delayed polls.")
 	flag.DurationVar(&backendCfg.VaultTokenPollingPeriod, "vault.token-polling-period", 15*time.Second, "Polling interval to check token expiration time.")
-	flag.IntVar(&backendCfg.VaultRenewTTLIncrement, "vault.renew-ttl-increment", 600, "TTL time for renewed token.")
-	flag.StringVar(&backendCfg.VaultEngine, "vault.engine", "kv2", "Vault secret engine. Only KV version 1 and 2 supported")
+	flag.DurationVar(&backendCfg.VaultRenewMaxBackoff, "vault.renew-max-backoff", 5*time.Minute, "Max time between token polls while renewal keeps failing, the polling period is doubled on every failure. 0 disables it.")
+	flag.IntVar(&backendCfg.VaultTokenPollingJitter, "vault.token-polling-jitter", 10, "Percentage used to randomize the token polling period, so replicas don't poll Vault at the same time. 0 disables it.")
+	flag.StringVar(&vaultRenewTTLIncrement, "vault.renew-ttl-increment", "600", "TTL time for renewed token, in seconds or as a duration, e.g. 1h.")
+	flag.StringVar(&backendCfg.VaultEngine, "vault.engine", "kv2", "Vault secret engine. KV version 1 and 2 and transit supported, auto detects the engine of every mount")
+	flag.StringVar(&vaultAllowedPathPrefixes, "vault.allowed-path-prefixes", "", "Comma-separated path prefixes, or globs such as secret/data/*/app, the only Vault paths allowed to be read. Every path is allowed when empty.")
+	flag.StringVar(&vaultDeniedPathPrefixes, "vault.denied-path-prefixes", "", "Comma-separated path prefixes, or globs such as secret/data/*/admin, of Vault paths never read. They take precedence over vault.allowed-path-prefixes.")
+	flag.StringVar(&backendCfg.VaultKVMount, "vault.kv-mount", "", "Mount path of the KV version 2 engine, e.g. teams/kv, so paths are rewritten after it instead of after their first segment.")
+	flag.StringVar(&vaultMountEngines, "vault.mount-engines", "", "Comma-separated mount=engine pairs overriding vault.engine for the paths under those mounts, e.g. legacy=kv1,transit=transit.")
+	flag.StringVar(&backendCfg.VaultDefaultKey, "vault.default-key", "data", "Key read from a Vault secret when a SecretDefinition doesn't set one.")
 	flag.StringVar(&backendCfg.VaultApprolePath, "vault.approle-path", "approle", "Vault approle login path")
 	flag.StringVar(&backendCfg.VaultKubernetesPath, "vault.kubernetes-path", "kubernetes", "Vault kubernetes login path")
+	flag.StringVar(&backendCfg.VaultKubernetesJWTPath, "vault.kubernetes-jwt-path", "/var/run/secrets/kubernetes.io/serviceaccount/token", "Path to the service account JWT used to login with the kubernetes auth method")
+	flag.StringVar(&backendCfg.VaultJWTRole, "vault.jwt-role", "", "Vault jwt role name.")
+	flag.StringVar(&backendCfg.VaultJWTPath, "vault.jwt-path", "", "Path to the JWT issued by an external identity provider used to login with the jwt auth method. It is read on every login.")
+	flag.StringVar(&backendCfg.VaultJWTMountPath, "vault.jwt-mount-path", "jwt", "Vault jwt login path")
+	flag.StringVar(&backendCfg.VaultUsername, "vault.username", "", "Vault userpass username.")
+	flag.StringVar(&backendCfg.VaultPassword, "vault.password", "", "Vault userpass password. VAULT_PASSWORD environment would take precedence.")
+	flag.StringVar(&backendCfg.VaultPasswordFile, "vault.password-file", "", "Path to a file containing the Vault userpass password. It is read on every login and takes precedence over vault.password.")
+	flag.StringVar(&backendCfg.VaultUserpassPath, "vault.userpass-path", "userpass", "Vault userpass login path")
+	flag.StringVar(&backendCfg.VaultCertRole, "vault.cert-role", "", "Vault cert role name. If empty Vault tries all the roles matching the client certificate.")
+	flag.StringVar(&backendCfg.VaultCertMountPath, "vault.cert-mount-path", "cert", "Vault cert login path")
+	flag.StringVar(&backendCfg.VaultAWSRole, "vault.aws-role", "", "Vault aws role name. If empty Vault uses the friendly name of the IAM principal.")
+	flag.StringVar(&backendCfg.VaultAWSMountPath, "vault.aws-mount-path", "aws", "Vault aws login path")
+	flag.StringVar(&backendCfg.VaultGCPRole, "vault.gcp-role", "", "Vault gcp role name")
+	flag.StringVar(&backendCfg.VaultGCPMountPath, "vault.gcp-mount-path", "gcp", "Vault gcp login path")
+	flag.StringVar(&backendCfg.VaultAzureRole, "vault.azure-role", "", "Vault azure role name")
+	flag.StringVar(&backendCfg.VaultAzureMountPath, "vault.azure-mount-path", "azure", "Vault azure login path")
+	flag.StringVar(&backendCfg.VaultAzureResource, "vault.azure-resource", "https://management.azure.com", "Azure resource the access token sent to Vault is requested for. It must match the resource configured in the Vault azure auth method.")
+	flag.StringVar(&backendCfg.AWSRegion, "aws.region", "", "AWS region of the aws-secrets-manager backend. AWS_REGION and AWS_DEFAULT_REGION environment are used when not set.")
+	flag.StringVar(&backendCfg.AWSSecretsManagerEndpoint, "aws.secrets-manager-endpoint", "", "Custom AWS Secrets Manager endpoint, e.g. a VPC endpoint. Defaults to the regional endpoint.")
+	flag.StringVar(&backendCfg.GCPProject, "gcp.project", "", "GCP project used by the gcp-secret-manager backend when a secret path is not a full resource name.")
+	flag.StringVar(&backendCfg.GCPSecretManagerEndpoint, "gcp.secret-manager-endpoint", "", "Custom GCP Secret Manager endpoint. Defaults to https://secretmanager.googleapis.com.")
+	flag.StringVar(&backendCfg.ConsulAddress, "consul.address", "", "Consul address of the consul backend. CONSUL_HTTP_ADDR environment is used when not set, and http://127.0.0.1:8500 otherwise.")
+	flag.StringVar(&backendCfg.ConsulToken, "consul.token", "", "Consul ACL token of the consul backend. CONSUL_HTTP_TOKEN environment is used when not set.")
+	flag.StringVar(&backendCfg.ConsulDatacenter, "consul.datacenter", "", "Consul datacenter the consul backend reads from. Defaults to the datacenter of the agent.")
 	flag.StringVar(&watchNamespaces, "watch-namespaces", "", "Comma separated list of namespaces that secrets-manager will watch for SecretDefinitions. By default all namespaces are watched.")
 	flag.StringVar(&excludeNamespaces, "exclude-namespaces", "", "Comma separated list of namespaces that secrets-manager will not watch for SecretDefinitions. By default all namespaces are watched.")
-	flag.Parse()
+	flag.StringVar(&pathPrefixes, "path-prefixes", "", "Comma separated list of namespace=prefix pairs. The paths of the SecretDefinitions of those namespaces are relative to their prefix, e.g. team-a=secret/data/team-a.")
+	flag.StringVar(&pathPrefixTemplate, "path-prefix-template", "", "Template of the path prefix of the namespaces not in path-prefixes, e.g. secret/data/{{.Namespace}}. Without it, their paths are used as they are.")
+	flag.BoolVar(&secretHashAnnotation, "secret-hash-annotation", false, "Annotate secrets with the hash of their data in secrets-manager.tuenti.io/secret-hash, e.g. to roll out pods when their content changes.")
+	flag.StringVar(&secretHashAlgorithm, "secret-hash-algorithm", backend.HashSHA256, "Hash algorithm of the secret-hash annotation. Supported: sha256, sha384, sha512.")
+	flag.BoolVar(&vaultEvents, "vault.events", false, "Subscribe to Vault KV events (sys/events/subscribe) to sync secrets as soon as they change, on top of the periodic reconciliations. Falls back to polling when Vault has no events.")
+	flag.BoolVar(&lastKnownGood, "last-known-good", false, "Keep syncing the values last read for keys the backend fails to read with transient errors, e.g. while Vault is sealed or unreachable, instead of failing the sync. Keys missing in the backend still fail.")
+	flag.BoolVar(&requestIDs, "request-ids", false, "Send a request ID per reconciliation to Vault in the X-Request-Id header, and log it along with the reconciliation.")
+	// check is a one-shot subcommand taking the same flags as the controller
+	checkMode := len(os.Args) > 1 && os.Args[1] == checkCommand
+	if checkMode {
+		flag.CommandLine.Parse(os.Args[2:])
+	} else {
+		flag.Parse()
+	}
+
+	var cfgFile *configFile
+	if configFilePath != "" {
+		var err error
+		cfgFile, err = loadConfigFile(flag.CommandLine, configFilePath)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "unable to load config file: %v\n", err)
+			os.Exit(1)
+		}
+	}
 
 	if versionFlag {
 		fmt.Printf("Secrets Manager %s\n", version)
 		os.Exit(0)
 	}
 
-	logger := zap.Logger(enableDebugLog).WithName("backend")
+	if backendCfg.VaultUserAgent == "" {
+		backendCfg.VaultUserAgent = fmt.Sprintf("secrets-manager/%s", version)
+	}
+
+	var baseLogger logr.Logger
+	var logLevel *backend.LogLevel
+	var err error
+	if cfgFile != nil {
+		// The level is kept to be changed on SIGHUP
+		baseLogger, logLevel, err = backend.NewReloadableLogger(backendCfg, enableDebugLog)
+	} else {
+		baseLogger, err = backend.NewLogger(backendCfg, enableDebugLog)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "unable to setup logger: %v\n", err)
+		os.Exit(1)
+	}
+	logger := baseLogger.WithName("backend")
 
 	if os.Getenv("VAULT_ADDR") != "" {
 		backendCfg.VaultURL = os.Getenv("VAULT_ADDR")
@@ -92,16 +253,136 @@ func main() {
 		backendCfg.VaultSecretID = os.Getenv("VAULT_SECRET_ID")
 	}
 
+	if os.Getenv("VAULT_PASSWORD") != "" {
+		backendCfg.VaultPassword = os.Getenv("VAULT_PASSWORD")
+	}
+
+	if os.Getenv("VAULT_WRAPPED_TOKEN") != "" {
+		backendCfg.VaultWrappedToken = os.Getenv("VAULT_WRAPPED_TOKEN")
+	}
+
+	if os.Getenv("VAULT_NAMESPACE") != "" {
+		backendCfg.VaultNamespace = os.Getenv("VAULT_NAMESPACE")
+	}
+
+	if vaultDurationBuckets != "" {
+		for _, b := range strings.Split(vaultDurationBuckets, ",") {
+			bucket, err := strconv.ParseFloat(strings.TrimSpace(b), 64)
+			if err != nil {
+				logger.Error(err, "invalid vault metrics duration bucket", "bucket", b)
+				os.Exit(1)
+			}
+			backendCfg.VaultMetricsDurationBuckets = append(backendCfg.VaultMetricsDurationBuckets, bucket)
+		}
+	}
+
+	backendCfg.VaultRenewTTLIncrement, err = backend.ParseRenewTTLIncrement(vaultRenewTTLIncrement)
+	if err != nil {
+		logger.Error(err, "invalid vault renew ttl increment")
+		os.Exit(1)
+	}
+
+	backendCfg.VaultMaxTokenTTL, err = backend.ParseMaxTokenTTL(vaultMaxTokenTTL)
+	if err != nil {
+		logger.Error(err, "invalid vault max token ttl")
+		os.Exit(1)
+	}
+
+	backendCfg.VaultMountEngines, err = backend.ParseMountEngines(vaultMountEngines)
+	if err != nil {
+		logger.Error(err, "invalid vault mount engines")
+		os.Exit(1)
+	}
+
+	if vaultFallbackURLs != "" {
+		backendCfg.VaultFallbackURLs = strings.Split(vaultFallbackURLs, ",")
+	}
+	if vaultTokenPolicies != "" {
+		backendCfg.VaultTokenPolicies = strings.Split(vaultTokenPolicies, ",")
+	}
+	if vaultAllowedPathPrefixes != "" {
+		backendCfg.VaultAllowedPathPrefixes = strings.Split(vaultAllowedPathPrefixes, ",")
+	}
+	if vaultDeniedPathPrefixes != "" {
+		backendCfg.VaultDeniedPathPrefixes = strings.Split(vaultDeniedPathPrefixes, ",")
+	}
+
+	var prefixes *controllers.PathPrefixes
+	if pathPrefixes != "" || pathPrefixTemplate != "" {
+		namespacePrefixes, err := controllers.ParsePathPrefixes(pathPrefixes)
+		if err == nil {
+			prefixes, err = controllers.NewPathPrefixes(namespacePrefixes, pathPrefixTemplate)
+		}
+		if err != nil {
+			logger.Error(err, "invalid path prefixes")
+			os.Exit(1)
+		}
+	}
+
+	hashAlgorithm := ""
+	if secretHashAnnotation {
+		if err := backend.ValidateHashAlgorithm(secretHashAlgorithm); err != nil {
+			logger.Error(err, "invalid secret hash algorithm")
+			os.Exit(1)
+		}
+		hashAlgorithm = secretHashAlgorithm
+	}
+
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
+	if checkMode {
+		code := runCheck(ctx, flag.Args(), selectedBackend, backendCfg, prefixes, baseLogger, os.Stdout)
+		cancel()
+		os.Exit(code)
+	}
+
 	backendClient, err := backend.NewBackendClient(ctx, selectedBackend, logger, backendCfg)
 	if err != nil {
 		logger.Error(err, "could not build backend client")
 		os.Exit(1)
 	}
 
-	ctrl.SetLogger(zap.Logger(enableDebugLog))
+	if cfgFile != nil {
+		if err := cfgFile.registerMetrics(backendCfg.MetricsNamespace); err != nil {
+			logger.Error(err, "unable to register config file metrics")
+			os.Exit(1)
+		}
+		hangups := make(chan os.Signal, 1)
+		signal.Notify(hangups, syscall.SIGHUP)
+		defer signal.Stop(hangups)
+		go cfgFile.watch(hangups, backendCfg, *backendClient, logLevel, baseLogger.WithName("config"))
+	}
+
+	// A nil interface, not a nil *leader.Elector, disables leader election in the probes and the controller
+	var elector *leader.Elector
+	var leaderChecker leader.Checker
+	if enableLeaderElection {
+		leaderCfg.MetricsNamespace = backendCfg.MetricsNamespace
+		pauser, _ := (*backendClient).(backend.TokenRenewalPauser)
+		elector, err = leader.NewElector(ctrl.GetConfigOrDie(), leaderCfg, func(leading bool) {
+			if !standbyTokenRenewal && pauser != nil {
+				pauser.PauseTokenRenewal(!leading)
+			}
+		}, baseLogger.WithName("leader-election"))
+		if err != nil {
+			logger.Error(err, "unable to setup leader election")
+			os.Exit(1)
+		}
+		if !standbyTokenRenewal && pauser != nil {
+			pauser.PauseTokenRenewal(true)
+		}
+		leaderChecker = elector
+	}
+
+	healthServer := health.NewServer(healthAddr, *backendClient, leaderChecker)
+	go func() {
+		if err := healthServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			logger.Error(err, "health probes server stopped")
+		}
+	}()
+
+	ctrl.SetLogger(baseLogger)
 
 	nsSlice := func(ns string) []string {
 		trimmed := strings.Trim(strings.TrimSpace(ns), "\"")
@@ -118,11 +399,10 @@ func main() {
 	if len(strings.TrimSpace(watchNamespaces)) > 0 {
 		logger.Info("setting restricted namespace list for controller")
 		namespaceList = nsSlice(watchNamespaces)
-		logger.Info("watching namespaces: " + watchNamespaces)
+		logger.Info("watching namespaces", "namespaces", watchNamespaces)
 		mgr, err = ctrl.NewManager(ctrl.GetConfigOrDie(), ctrl.Options{
 			Scheme:             scheme,
 			MetricsBindAddress: metricsAddr,
-			LeaderElection:     enableLeaderElection,
 			NewCache:           cache.MultiNamespacedCacheBuilder(namespaceList),
 		})
 		if err != nil {
@@ -133,7 +413,6 @@ func main() {
 		mgr, err = ctrl.NewManager(ctrl.GetConfigOrDie(), ctrl.Options{
 			Scheme:             scheme,
 			MetricsBindAddress: metricsAddr,
-			LeaderElection:     enableLeaderElection,
 		})
 		if err != nil {
 			setupLog.Error(err, "unable to start manager")
@@ -149,6 +428,13 @@ func main() {
 		Ctx:                  ctx,
 		ReconciliationPeriod: reconcilePeriod,
 		ExcludeNamespaces:    excludeNs,
+		PathPrefixes:         prefixes,
+		HashAlgorithm:        hashAlgorithm,
+		RequestIDs:           requestIDs,
+		Events:               vaultEvents,
+		LastKnownGood:        lastKnownGood,
+		DryRun:               dryRun,
+		Leader:               leaderChecker,
 	}).SetupWithManager(mgr, controllerName)
 	if err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", controllerName)
@@ -156,9 +442,42 @@ func main() {
 	}
 	// +kubebuilder:scaffold:builder
 
+	if dryRun {
+		setupLog.Info("dry-run mode enabled, kubernetes secrets won't be written")
+	}
+
+	// Only the leader reconciles, while the manager runs on every replica to keep caches warm
+	electionCtx, electionCancel := context.WithCancel(context.Background())
+	electionDone := make(chan struct{})
+	if elector != nil {
+		go func() {
+			defer close(electionDone)
+			if err := elector.Run(electionCtx); err != nil {
+				setupLog.Error(err, "leader election failed")
+			}
+		}()
+	} else {
+		close(electionDone)
+	}
+
 	setupLog.Info("starting manager")
 	if err := mgr.Start(ctrl.SetupSignalHandler()); err != nil {
 		setupLog.Error(err, "problem running manager")
 		os.Exit(1)
 	}
+
+	setupLog.Info("shutting down")
+	// The manager is stopped, so the lease can be released for a standby to take over right away
+	electionCancel()
+	<-electionDone
+	shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer shutdownCancel()
+	if err := healthServer.Shutdown(shutdownCtx); err != nil {
+		setupLog.Error(err, "unable to shutdown health probes server")
+	}
+	if closer, ok := (*backendClient).(backend.Closer); ok {
+		if err := closer.Close(shutdownCtx); err != nil {
+			setupLog.Error(err, "unable to close backend client")
+		}
+	}
 }